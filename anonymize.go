@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// anonymizedDomain is the reserved top-level domain of anonymized hostnames
+const anonymizedDomain = ".invalid"
+
+var (
+	// messageURLPattern matches the URLs quoted in test messages
+	messageURLPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>;,()]+`)
+	// identifierSegmentPattern matches path segments that identify a resource:
+	// numbers, UUIDs and long hexadecimal strings
+	identifierSegmentPattern = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+)
+
+// anonymizer rewrites results so that they can be shared outside the
+// organization without revealing its hosts, credentials or data
+type anonymizer struct {
+	key []byte
+}
+
+// newAnonymizer returns an anonymizer with a random key, so that the hashed
+// hostnames cannot be matched against guessed ones, nor across exports
+func newAnonymizer() (*anonymizer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate anonymization key: %v", err)
+	}
+	return &anonymizer{key: key}, nil
+}
+
+// host replaces a hostname or IP address with a keyed hash of it, the same
+// for every occurrence of the host in an export
+func (a *anonymizer) host(host string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(strings.ToLower(host)))
+	return "host-" + hex.EncodeToString(mac.Sum(nil))[:12] + anonymizedDomain
+}
+
+// url generalizes a URL: its host is hashed, the user info, query and
+// fragment are dropped and path segments that identify a resource become {id}
+func (a *anonymizer) url(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "{url}"
+	}
+	host := a.host(u.Hostname())
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if identifierSegmentPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return u.Scheme + "://" + host + strings.Join(segments, "/")
+}
+
+// message generalizes the URLs in a test message and hashes the hosts it mentions by name
+func (a *anonymizer) message(message string, hosts []string) string {
+	message = messageURLPattern.ReplaceAllStringFunc(message, a.url)
+	for _, host := range hosts {
+		message = strings.Replace(message, host, a.host(host), -1)
+	}
+	return message
+}
+
+// results returns anonymized copies of the results. Endpoint names, the
+// requests attached to findings with their reproduction commands, the
+// certificates and the GraphQL schema files are left out.
+func (a *anonymizer) results(results []EndpointResult) []EndpointResult {
+	hosts := resultHosts(results)
+	anonymized := make([]EndpointResult, len(results))
+	for i, result := range results {
+		result.Name = fmt.Sprintf("Endpoint %d", i+1)
+		result.URL = a.url(result.URL)
+		result.Certificate = nil
+		if result.GraphQL != nil {
+			schema := *result.GraphQL
+			schema.SchemaFile = ""
+			result.GraphQL = &schema
+		}
+		if result.Limiter != nil {
+			limiter := *result.Limiter
+			limiter.Host = strings.TrimPrefix(a.url("http://"+limiter.Host), "http://")
+			result.Limiter = &limiter
+		}
+
+		tests := make([]TestResult, len(result.Results))
+		for j, test := range result.Results {
+			test.Message = a.message(test.Message, hosts)
+			test.Request = nil
+			test.Curl = ""
+			test.HTTPie = ""
+			tests[j] = test
+		}
+		result.Results = tests
+		anonymized[i] = result
+	}
+	return anonymized
+}
+
+// resultHosts returns the hosts of the endpoints, longest first so that a
+// host is replaced before the shorter hosts it contains
+func resultHosts(results []EndpointResult) []string {
+	seen := map[string]bool{}
+	var hosts []string
+	for _, result := range results {
+		u, err := url.Parse(result.URL)
+		if err != nil || u.Hostname() == "" || seen[u.Hostname()] {
+			continue
+		}
+		seen[u.Hostname()] = true
+		hosts = append(hosts, u.Hostname())
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if len(hosts[i]) != len(hosts[j]) {
+			return len(hosts[i]) > len(hosts[j])
+		}
+		return hosts[i] < hosts[j]
+	})
+	return hosts
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authentication types
+const (
+	authBasic  = "basic"
+	authOAuth2 = "oauth2"
+	authSigV4  = "aws_sigv4"
+	authHMAC   = "hmac"
+)
+
+// authTypes lists the values accepted by auth.type
+var authTypes = []string{authBasic, authOAuth2, authSigV4, authHMAC}
+
+// prepare validates the authentication settings and sets up state shared by all endpoints
+func (a *Auth) prepare() error {
+	switch a.Type {
+	case "", authBasic:
+		return nil
+	case authOAuth2:
+		if a.OAuth2 == nil {
+			return fmt.Errorf("auth type %q requires an oauth2 section", a.Type)
+		}
+		tokens, err := newOAuth2TokenSource(*a.OAuth2)
+		if err != nil {
+			return err
+		}
+		a.tokens = tokens
+		return nil
+	case authSigV4:
+		if a.SigV4 == nil {
+			return fmt.Errorf("auth type %q requires a sigv4 section", a.Type)
+		}
+		signer, err := newSigV4Signer(*a.SigV4)
+		if err != nil {
+			return err
+		}
+		a.signer = signer
+		return nil
+	case authHMAC:
+		if a.HMAC == nil {
+			return fmt.Errorf("auth type %q requires an hmac section", a.Type)
+		}
+		signer, err := newHMACSigner(*a.HMAC)
+		if err != nil {
+			return err
+		}
+		a.hmac = signer
+		return nil
+	default:
+		return fmt.Errorf("unknown auth type %q (supported: %s)", a.Type, strings.Join(authTypes, ", "))
+	}
+}
+
+// apply adds the configured credentials to req
+func (a Auth) apply(req *http.Request) error {
+	// Auth settings that did not go through prepare get state of their own
+	if a.tokens == nil && a.signer == nil && a.hmac == nil {
+		if err := a.prepare(); err != nil {
+			return err
+		}
+	}
+
+	switch a.Type {
+	case authOAuth2:
+		token, err := a.tokens.token()
+		if err != nil {
+			return LoginError{fmt.Sprintf("failed to obtain OAuth2 token: %v", err)}
+		}
+		req.Header.Set("Authorization", token.authorization())
+		return nil
+	case authSigV4:
+		return a.signer.sign(req)
+	case authHMAC:
+		return a.hmac.sign(req)
+	default:
+		// Without credentials the request keeps the session token, if any
+		if a.Username != "" || a.Password != "" {
+			req.SetBasicAuth(a.Username, a.Password)
+		}
+		return nil
+	}
+}
+
+// authorize obtains the OAuth2 token before the scan starts. Grants such as
+// the device code grant wait for the user, which takes longer than the scan
+// requests' timeouts allow; the scan then reuses and refreshes the token.
+func (a Auth) authorize() error {
+	if a.tokens == nil {
+		return nil
+	}
+	if _, err := a.tokens.token(); err != nil {
+		return LoginError{fmt.Sprintf("failed to obtain OAuth2 token: %v", err)}
+	}
+	return nil
+}
+
+// signsRequests reports whether the auth type adds credentials to every
+// request, rather than only the Auth Test's
+func (a Auth) signsRequests() bool {
+	switch a.Type {
+	case authOAuth2, authSigV4, authHMAC:
+		return true
+	default:
+		return false
+	}
+}
+
+// ownCredentialsKey marks requests that carry credentials of their own, or
+// deliberately none, which authTransport leaves alone
+type ownCredentialsKey struct{}
+
+// withOwnCredentials marks req as carrying credentials of its own
+func withOwnCredentials(req *http.Request) *http.Request {
+	if exchange := recordedExchange(req); exchange != nil {
+		exchange.OwnCredentials = true
+	}
+	return req.WithContext(context.WithValue(req.Context(), ownCredentialsKey{}, true))
+}
+
+// authTransport adds the configured bearer token, SigV4 or HMAC signature to
+// every request of a scan client. It sits below the retries of
+// throttleTransport and the adaptive limiter, so each attempt is signed
+// afresh when it is actually sent.
+type authTransport struct {
+	base http.RoundTripper
+	auth Auth
+}
+
+// newAuthTransport returns base unchanged for auth types that only apply to the Auth Test
+func newAuthTransport(base http.RoundTripper, auth Auth) http.RoundTripper {
+	if !auth.signsRequests() {
+		return base
+	}
+	// Share one token source or signer between the requests; a failure is reported by apply
+	if auth.tokens == nil && auth.signer == nil && auth.hmac == nil {
+		auth.prepare()
+	}
+	return &authTransport{base: base, auth: auth}
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(ownCredentialsKey{}) != nil {
+		return t.base.RoundTrip(req)
+	}
+	signed := req.Clone(req.Context())
+	if err := t.auth.apply(signed); err != nil {
+		return nil, err
+	}
+	if exchange := recordedExchange(req); exchange != nil {
+		exchange.Headers = flattenHeaders(signed.Header)
+	}
+	return t.base.RoundTrip(signed)
+}
+
+// ownCredentialsTransport marks every request as carrying credentials of its own
+type ownCredentialsTransport struct {
+	base http.RoundTripper
+}
+
+func (t ownCredentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(withOwnCredentials(req))
+}
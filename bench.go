@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"api-security-scanner/testserver"
+
+	"gopkg.in/yaml.v2"
+)
+
+// defaultBenchPayloads are used when no payload pack is given
+var defaultBenchPayloads = []string{"' OR '1'='1", "'; DROP TABLE users;--"}
+
+// flawTests maps the flaws seeded into the test server to the tests that
+// should detect them. Flaws missing here are not covered by any test yet.
+var flawTests = map[string]string{
+	testserver.FlawSQLInjection:   "Injection Test",
+	testserver.FlawBrokenAuth:     "Auth Test",
+	testserver.FlawMethodRejected: "HTTP Method Test",
+	testserver.FlawMissingHeaders: "Security Headers Test",
+}
+
+// countingHandler counts the requests served by next
+func countingHandler(next http.Handler, requests *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// benchReport summarises a benchmark run
+type benchReport struct {
+	Endpoints      int
+	Requests       int64
+	Elapsed        time.Duration
+	Expected       int
+	Detected       int
+	Flaws          map[string]*flawDetection
+	Missed         []string
+	FalsePositives []string
+}
+
+// flawDetection counts how often a seeded flaw was detected
+type flawDetection struct {
+	Seeded   int
+	Detected int
+	Covered  bool
+}
+
+// runBench scans the test server copies times with the given payloads
+func runBench(payloads []string, copies int) benchReport {
+	var requests int64
+	server := httptest.NewServer(countingHandler(testserver.Handler(), &requests))
+	defer server.Close()
+
+	config := &Config{
+		Auth:              Auth{Username: testserver.Username, Password: testserver.Password},
+		InjectionPayloads: payloads,
+		SecurityHeaders:   SecurityHeadersConfig{Enabled: true},
+	}
+	report := benchReport{Flaws: map[string]*flawDetection{}}
+	flaws := map[string][]string{}
+	for i := 0; i < copies; i++ {
+		for _, endpoint := range testserver.Endpoints() {
+			// The copy parameter keeps the URLs of each copy distinct
+			separator := "?"
+			if strings.Contains(endpoint.Path, "?") {
+				separator = "&"
+			}
+			endpointURL := fmt.Sprintf("%s%s%scopy=%d", server.URL, endpoint.Path, separator, i)
+			config.APIEndpoints = append(config.APIEndpoints, APIEndpoint{URL: endpointURL, Method: endpoint.Method, Body: endpoint.Body})
+			flaws[endpointURL] = endpoint.Flaws
+		}
+	}
+
+	start := time.Now()
+	results := runTests(config)
+	report.Endpoints = len(results)
+	report.Elapsed = time.Since(start)
+	report.Requests = atomic.LoadInt64(&requests)
+
+	for _, result := range results {
+		failed := map[string]bool{}
+		for _, testResult := range result.Results {
+			if testResult.Failed() {
+				failed[testResult.TestName] = true
+			}
+		}
+
+		expected := map[string]bool{}
+		for _, flaw := range flaws[result.URL] {
+			detection, ok := report.Flaws[flaw]
+			if !ok {
+				detection = &flawDetection{}
+				report.Flaws[flaw] = detection
+			}
+			detection.Seeded++
+
+			testName, covered := flawTests[flaw]
+			if !covered {
+				continue
+			}
+			detection.Covered = true
+			expected[testName] = true
+			report.Expected++
+			if failed[testName] {
+				detection.Detected++
+				report.Detected++
+			} else {
+				report.Missed = append(report.Missed, fmt.Sprintf("%s: %s", result.URL, testName))
+			}
+		}
+		for testName := range failed {
+			if !expected[testName] {
+				report.FalsePositives = append(report.FalsePositives, fmt.Sprintf("%s: %s", result.URL, testName))
+			}
+		}
+	}
+	sort.Strings(report.Missed)
+	sort.Strings(report.FalsePositives)
+	return report
+}
+
+// DetectionRate returns the percentage of seeded findings that were detected
+func (r benchReport) DetectionRate() float64 {
+	if r.Expected == 0 {
+		return 100
+	}
+	return float64(r.Detected) / float64(r.Expected) * 100
+}
+
+func (r benchReport) write(w io.Writer) {
+	seconds := r.Elapsed.Seconds()
+	if seconds == 0 {
+		seconds = 1e-9
+	}
+	fmt.Fprintln(w, "Benchmark Results")
+	fmt.Fprintln(w, "=================")
+	fmt.Fprintf(w, "Endpoints Scanned: %d\n", r.Endpoints)
+	fmt.Fprintf(w, "Requests Sent: %d\n", r.Requests)
+	fmt.Fprintf(w, "Duration: %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput: %.1f requests/s, %.1f endpoints/s\n", float64(r.Requests)/seconds, float64(r.Endpoints)/seconds)
+	fmt.Fprintf(w, "Detection Rate: %d/%d (%.1f%%)\n", r.Detected, r.Expected, r.DetectionRate())
+	fmt.Fprintf(w, "False Positives: %d\n", len(r.FalsePositives))
+
+	fmt.Fprintln(w, "Detection Confidence:")
+	var flaws []string
+	for flaw := range r.Flaws {
+		flaws = append(flaws, flaw)
+	}
+	sort.Strings(flaws)
+	for _, flaw := range flaws {
+		detection := r.Flaws[flaw]
+		if !detection.Covered {
+			fmt.Fprintf(w, "- %s: not covered by any test\n", flaw)
+			continue
+		}
+		fmt.Fprintf(w, "- %s: %d/%d (%.1f%%)\n", flaw, detection.Detected, detection.Seeded,
+			float64(detection.Detected)/float64(detection.Seeded)*100)
+	}
+	for _, missed := range r.Missed {
+		fmt.Fprintf(w, "- missed %s\n", missed)
+	}
+	for _, falsePositive := range r.FalsePositives {
+		fmt.Fprintf(w, "- false positive %s\n", falsePositive)
+	}
+}
+
+// benchCommand implements the bench subcommand
+func benchCommand(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	payloadsFile := flags.String("payloads", "", "YAML file with a list of injection payloads to benchmark (defaults to the built-in payloads)")
+	copies := flags.Int("copies", 1, "number of copies of the test server endpoints to scan")
+	flags.Parse(args)
+
+	payloads := defaultBenchPayloads
+	if *payloadsFile != "" {
+		data, err := ioutil.ReadFile(*payloadsFile)
+		if err != nil {
+			return invalidConfig(fmt.Errorf("failed to read payloads: %v", err))
+		}
+		if err := yaml.Unmarshal(data, &payloads); err != nil {
+			return invalidConfig(fmt.Errorf("failed to parse payloads: %v", err))
+		}
+	}
+	if *copies < 1 {
+		return invalidConfig(fmt.Errorf("copies must be at least 1"))
+	}
+
+	runBench(payloads, *copies).write(os.Stdout)
+	return nil
+}
+
+// testServerCommand implements the testserver subcommand, which serves the
+// intentionally vulnerable test server so a deployed scanner can be pointed at it
+func testServerCommand(args []string) error {
+	flags := flag.NewFlagSet("testserver", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	flags.Parse(args)
+
+	fmt.Printf("Serving the vulnerable test server on http://%s (credentials %s/%s)\n", *addr, testserver.Username, testserver.Password)
+	for _, endpoint := range testserver.Endpoints() {
+		fmt.Printf("- %s %s %v\n", endpoint.Method, endpoint.Path, endpoint.Flaws)
+	}
+	return http.ListenAndServe(*addr, testserver.Handler())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"api-security-scanner/testserver"
+)
+
+func TestRunBench(t *testing.T) {
+	report := runBench(defaultBenchPayloads, 2)
+
+	if report.Endpoints != 2*len(testserver.Endpoints()) {
+		t.Errorf("Expected %d endpoints, got %d", 2*len(testserver.Endpoints()), report.Endpoints)
+	}
+	if report.Expected != 8 || report.Detected != 8 {
+		t.Errorf("Expected all 8 seeded findings to be detected, got %d/%d (missed %v)", report.Detected, report.Expected, report.Missed)
+	}
+	if len(report.FalsePositives) != 0 {
+		t.Errorf("Expected no false positives, got %v", report.FalsePositives)
+	}
+	if report.Requests == 0 {
+		t.Errorf("Expected requests to be counted")
+	}
+}
+
+func TestRunBenchWithoutPayloads(t *testing.T) {
+	report := runBench(nil, 1)
+	if report.Detected != 3 || len(report.Missed) != 1 || !strings.Contains(report.Missed[0], "Injection Test") {
+		t.Errorf("Expected only the injection finding to be missed, got %+v", report)
+	}
+
+	var out bytes.Buffer
+	report.write(&out)
+	for _, line := range []string{"Detection Rate: 3/4 (75.0%)", "- missing-headers: 1/1 (100.0%)", "- sqli: 0/1 (0.0%)", "- xss: not covered by any test"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in output, got %q", line, out.String())
+		}
+	}
+}
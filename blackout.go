@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBlackoutDuration bounds how far back a window's start is searched for
+const maxBlackoutDuration = 7 * 24 * time.Hour
+
+// BlackoutWindow represents a period during which the target must not be
+// scanned, such as a change freeze or peak traffic. Each window starts at the
+// times matched by a five-field cron expression and lasts for Duration.
+type BlackoutWindow struct {
+	Cron     string        `yaml:"cron"`
+	Duration time.Duration `yaml:"duration"`
+	Timezone string        `yaml:"timezone"`
+	Reason   string        `yaml:"reason"`
+}
+
+// BlackoutError is returned for requests that would reach the target during a blackout window
+type BlackoutError struct {
+	Reason string
+	Until  time.Time
+}
+
+func (e BlackoutError) Error() string {
+	reason := ""
+	if e.Reason != "" {
+		reason = fmt.Sprintf(" (%s)", e.Reason)
+	}
+	return fmt.Sprintf("blackout window%s active until %s; no requests sent", reason, e.Until.Format(time.RFC3339))
+}
+
+// cronSchedule is a parsed cron expression, with the allowed values of each field
+type cronSchedule struct {
+	minute, hour, dom, month, dow []bool
+	// Like cron, a day matches if either the day of month or the day of week does
+	// when both are restricted
+	domRestricted, dowRestricted bool
+}
+
+// parseCronField parses a comma-separated list of *, values, ranges and steps
+func parseCronField(field string, min, max int) ([]bool, error) {
+	allowed := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		low, high := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			low, high = value, value
+			if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			allowed[v] = true
+		}
+	}
+	return allowed, nil
+}
+
+// parseCron parses a five-field cron expression: minute, hour, day of month, month and day of week
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron expression %q needs 5 fields", expr)
+	}
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid minute: %v", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid hour: %v", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid day of month: %v", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid month: %v", err)
+	}
+	// 7 is accepted as Sunday, like in most cron implementations
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid day of week: %v", err)
+	}
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+	return s, nil
+}
+
+func (s cronSchedule) matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// blackout is a validated blackout window
+type blackout struct {
+	window   BlackoutWindow
+	schedule cronSchedule
+	location *time.Location
+}
+
+// parseBlackouts validates blackout windows
+func parseBlackouts(windows []BlackoutWindow) ([]blackout, error) {
+	var blackouts []blackout
+	for _, window := range windows {
+		schedule, err := parseCron(window.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window: %v", err)
+		}
+		if window.Duration <= 0 || window.Duration > maxBlackoutDuration {
+			return nil, fmt.Errorf("blackout window %q needs a duration between 1m and %s", window.Cron, maxBlackoutDuration)
+		}
+		location := time.Local
+		if window.Timezone != "" {
+			if location, err = time.LoadLocation(window.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid blackout timezone %q: %v", window.Timezone, err)
+			}
+		}
+		blackouts = append(blackouts, blackout{window: window, schedule: schedule, location: location})
+	}
+	return blackouts, nil
+}
+
+// activeBlackout returns the window that is active at now, with the time it ends
+func activeBlackout(blackouts []blackout, now time.Time) (BlackoutError, bool) {
+	for _, b := range blackouts {
+		start := now.In(b.location).Truncate(time.Minute)
+		for elapsed := time.Duration(0); elapsed < b.window.Duration; elapsed += time.Minute {
+			if candidate := start.Add(-elapsed); b.schedule.matches(candidate) {
+				if end := candidate.Add(b.window.Duration); now.Before(end) {
+					return BlackoutError{Reason: b.window.Reason, Until: end}, true
+				}
+			}
+		}
+	}
+	return BlackoutError{}, false
+}
+
+// endpointBlackouts returns the global and endpoint-specific blackout windows of an endpoint
+func endpointBlackouts(config *Config, endpoint APIEndpoint) ([]blackout, error) {
+	windows := append(append([]BlackoutWindow{}, config.Blackouts...), endpoint.Blackouts...)
+	return parseBlackouts(windows)
+}
+
+// validateBlackouts checks every blackout window in the configuration
+func validateBlackouts(config *Config) error {
+	if _, err := parseBlackouts(config.Blackouts); err != nil {
+		return err
+	}
+	for _, endpoint := range config.APIEndpoints {
+		if _, err := parseBlackouts(endpoint.Blackouts); err != nil {
+			return fmt.Errorf("%s: %v", endpoint.URL, err)
+		}
+	}
+	return nil
+}
+
+// outsideBlackouts returns the endpoints that are not in a blackout window at
+// now, along with a message for each endpoint that is
+func outsideBlackouts(config *Config, now time.Time) ([]APIEndpoint, []string) {
+	var endpoints []APIEndpoint
+	var skipped []string
+	for _, endpoint := range config.APIEndpoints {
+		blackouts, _ := endpointBlackouts(config, endpoint)
+		if blackoutErr, active := activeBlackout(blackouts, now); active {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", endpoint.URL, blackoutErr))
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, skipped
+}
+
+// blackoutGuard stops the tests that reach the target without the scan
+// client, such as port scans, DNS queries and plugins, once a blackout window
+// opens during a scan. Its check does nothing on a nil *blackoutGuard.
+type blackoutGuard struct {
+	blackouts []blackout
+	now       func() time.Time
+}
+
+func newBlackoutGuard(blackouts []blackout) *blackoutGuard {
+	if len(blackouts) == 0 {
+		return nil
+	}
+	return &blackoutGuard{blackouts: blackouts, now: time.Now}
+}
+
+// check returns a BlackoutError if a blackout window is active
+func (g *blackoutGuard) check() error {
+	if g == nil {
+		return nil
+	}
+	if blackoutErr, active := activeBlackout(g.blackouts, g.now()); active {
+		return blackoutErr
+	}
+	return nil
+}
+
+// untilNextWindow returns how long until the next blackout window opens, if
+// one opens within limit, so that long-running work such as plugins can stop
+// in time
+func (g *blackoutGuard) untilNextWindow(limit time.Duration) (time.Duration, bool) {
+	if g == nil {
+		return 0, false
+	}
+	now := g.now()
+	for start := now.Truncate(time.Minute).Add(time.Minute); start.Sub(now) <= limit; start = start.Add(time.Minute) {
+		for _, b := range g.blackouts {
+			if b.schedule.matches(start.In(b.location)) {
+				return start.Sub(now), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// blackoutTransport stops sending requests once a blackout window opens during
+// a scan, so that the tests that are still running end as skipped
+type blackoutTransport struct {
+	base      http.RoundTripper
+	blackouts []blackout
+	now       func() time.Time
+}
+
+func newBlackoutTransport(base http.RoundTripper, blackouts []blackout) http.RoundTripper {
+	if len(blackouts) == 0 {
+		return base
+	}
+	return &blackoutTransport{base: base, blackouts: blackouts, now: time.Now}
+}
+
+func (t *blackoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if blackoutErr, active := activeBlackout(t.blackouts, t.now()); active {
+		return nil, blackoutErr
+	}
+	return t.base.RoundTrip(req)
+}
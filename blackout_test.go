@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	schedule, err := parseCron("*/15 9-17 * * 1-5")
+	if err != nil {
+		t.Fatalf("Expected a valid expression, got %v", err)
+	}
+	monday := time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		time     time.Time
+		expected bool
+	}{
+		{monday, true},
+		{monday.Add(5 * time.Minute), false},
+		{monday.Add(-time.Hour), false},
+		{monday.AddDate(0, 0, 5), false}, // Saturday
+	} {
+		if got := schedule.matches(test.time); got != test.expected {
+			t.Errorf("Expected %v for %s, got %v", test.expected, test.time, got)
+		}
+	}
+
+	// Day of month and day of week match either way when both are restricted
+	schedule, _ = parseCron("0 0 1 * 7")
+	if !schedule.matches(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !schedule.matches(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the 1st and Sundays to match")
+	}
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestActiveBlackout(t *testing.T) {
+	blackouts, err := parseBlackouts([]BlackoutWindow{{Cron: "0 22 * * 5", Duration: 60 * time.Hour, Timezone: "UTC", Reason: "weekend freeze"}})
+	if err != nil {
+		t.Fatalf("Expected valid windows, got %v", err)
+	}
+	saturday := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	blackoutErr, active := activeBlackout(blackouts, saturday)
+	if !active || !blackoutErr.Until.Equal(time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC)) || blackoutErr.Reason != "weekend freeze" {
+		t.Errorf("Expected the weekend freeze until Monday 10:00, got %+v (%v)", blackoutErr, active)
+	}
+	if _, active := activeBlackout(blackouts, time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC)); active {
+		t.Errorf("Expected the window to end after its duration")
+	}
+
+	for _, invalid := range []BlackoutWindow{{Cron: "0 0 * * *"}, {Cron: "0 0 * * *", Duration: 8 * 24 * time.Hour}, {Cron: "0 0 * * *", Duration: time.Hour, Timezone: "Mars/Olympus"}} {
+		if _, err := parseBlackouts([]BlackoutWindow{invalid}); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestOutsideBlackouts(t *testing.T) {
+	config := &Config{APIEndpoints: []APIEndpoint{
+		{URL: "https://payments.example.com", Blackouts: []BlackoutWindow{{Cron: "* * * * *", Duration: time.Hour, Reason: "peak"}}},
+		{URL: "https://api.example.com"},
+	}}
+	if err := validateBlackouts(config); err != nil {
+		t.Fatalf("Expected valid windows, got %v", err)
+	}
+	endpoints, skipped := outsideBlackouts(config, time.Now())
+	if len(endpoints) != 1 || endpoints[0].URL != "https://api.example.com" {
+		t.Errorf("Expected only the endpoint outside its window, got %+v", endpoints)
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "blackout window (peak)") {
+		t.Errorf("Expected the skipped endpoint to be reported, got %v", skipped)
+	}
+}
+
+func TestBlackoutTransportSkipsTests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	blackouts, _ := parseBlackouts([]BlackoutWindow{{Cron: "0 2 * * *", Duration: time.Hour, Timezone: "UTC"}})
+	transport := newBlackoutTransport(http.DefaultTransport, blackouts).(*blackoutTransport)
+	client := &http.Client{Transport: transport}
+
+	transport.now = func() time.Time { return time.Date(2024, 3, 4, 1, 59, 0, 0, time.UTC) }
+	if err := performAuthTest(client, APIEndpoint{URL: server.URL, Method: "GET"}, Auth{}); err != nil || requests != 1 {
+		t.Fatalf("Expected the request to be sent before the window, got %v", err)
+	}
+
+	transport.now = func() time.Time { return time.Date(2024, 3, 4, 2, 30, 0, 0, time.UTC) }
+	err := performAuthTest(client, APIEndpoint{URL: server.URL, Method: "GET"}, Auth{})
+	var blackoutErr BlackoutError
+	if !errors.As(err, &blackoutErr) || requests != 1 {
+		t.Fatalf("Expected no request during the window, got %v", err)
+	}
+	if result := newTestResult("Auth Test", err, 0); !result.Skipped {
+		t.Errorf("Expected the test to be skipped, got %+v", result)
+	}
+}
+
+func TestBlackoutGuardStopsPortScans(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer listener.Close()
+	closedListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := closedListener.Addr().(*net.TCPAddr).Port
+	closedListener.Close()
+	open := listener.Addr().(*net.TCPAddr).Port
+
+	// The window opens after the first port was tried
+	blackouts, _ := parseBlackouts([]BlackoutWindow{{Cron: "0 2 * * *", Duration: time.Hour, Timezone: "UTC"}})
+	guard := newBlackoutGuard(blackouts)
+	checks := 0
+	guard.now = func() time.Time {
+		checks++
+		if checks > 1 {
+			return time.Date(2024, 3, 4, 2, 30, 0, 0, time.UTC)
+		}
+		return time.Date(2024, 3, 4, 1, 59, 0, 0, time.UTC)
+	}
+
+	config := PortScanConfig{Ports: []int{closed, open}, Rate: 1000, Timeout: time.Second}
+	err = performPortScan(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, guard)
+	var blackoutErr BlackoutError
+	if !errors.As(err, &blackoutErr) || checks != 2 {
+		t.Fatalf("Expected the scan to stop when the window opened, got %v after %d checks", err, checks)
+	}
+	if err := newBlackoutGuard(nil).check(); err != nil {
+		t.Errorf("Expected no blackout without windows, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+)
+
+// bodyPrefixSize is how much of each body is kept for signature matching, such as WAF block pages
+const bodyPrefixSize = 64 * 1024
+
+// sqlErrorMessages are common database error messages leaked by injectable endpoints
+var sqlErrorMessages = []string{
+	"SQL syntax",
+	"mysql_fetch_array",
+	"ORA-01756",
+	"SQLite3::SQLException",
+	"PostgreSQL ERROR",
+	"Incorrect syntax near",
+	"SQLSTATE[",
+	"JDBC Driver",
+	"Microsoft SQL Server",
+	"You have an error in your SQL syntax",
+}
+
+// bodyFingerprint summarises a response body so that responses can be
+// compared without keeping them in memory
+type bodyFingerprint struct {
+	Length   int64
+	SHA256   [sha256.Size]byte
+	SQLError string // first SQL error message found in the body, if any
+	Prefix   []byte // the first bodyPrefixSize bytes of the body
+}
+
+// fingerprintBody reads r in chunks and fingerprints it. Error messages are
+// matched across chunk boundaries by carrying over the end of each chunk.
+func fingerprintBody(r io.Reader) (bodyFingerprint, error) {
+	var fp bodyFingerprint
+	hash := sha256.New()
+
+	overlap := 0
+	for _, message := range sqlErrorMessages {
+		if len(message)-1 > overlap {
+			overlap = len(message) - 1
+		}
+	}
+
+	chunk := make([]byte, 32*1024)
+	var window []byte
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			data := chunk[:n]
+			fp.Length += int64(n)
+			hash.Write(data)
+			if room := bodyPrefixSize - len(fp.Prefix); room > 0 {
+				if room > n {
+					room = n
+				}
+				fp.Prefix = append(fp.Prefix, data[:room]...)
+			}
+
+			if fp.SQLError == "" {
+				window = append(window, data...)
+				for _, message := range sqlErrorMessages {
+					if bytes.Contains(window, []byte(message)) {
+						fp.SQLError = message
+						break
+					}
+				}
+				if len(window) > overlap {
+					window = append(window[:0], window[len(window)-overlap:]...)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fp, err
+		}
+	}
+
+	copy(fp.SHA256[:], hash.Sum(nil))
+	return fp, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFingerprintBody(t *testing.T) {
+	body := `{"error": "You have an error in your SQL syntax"}` + strings.Repeat("x", bodyPrefixSize)
+
+	// Read one byte at a time so that the error message spans many chunks
+	fp, err := fingerprintBody(iotest.OneByteReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if fp.Length != int64(len(body)) {
+		t.Errorf("Expected length %d, got %d", len(body), fp.Length)
+	}
+	if fp.SQLError != "SQL syntax" {
+		t.Errorf("Expected SQL error to be detected, got %q", fp.SQLError)
+	}
+	if fp.SHA256 != sha256.Sum256([]byte(body)) {
+		t.Errorf("Expected hash of the whole body")
+	}
+	if len(fp.Prefix) != bodyPrefixSize || !bytes.HasPrefix([]byte(body), fp.Prefix) {
+		t.Errorf("Expected a %d byte prefix, got %d bytes", bodyPrefixSize, len(fp.Prefix))
+	}
+}
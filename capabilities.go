@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// scannerVersion is set at build time with -ldflags "-X main.scannerVersion=..."
+var scannerVersion = "dev"
+
+// builtinTest describes a test the scanner runs on every endpoint
+type builtinTest struct {
+	Name        string   `json:"name"`
+	Severity    string   `json:"severity"`
+	CWE         int      `json:"cwe"`
+	OWASP       []string `json:"owasp"`
+	Description string   `json:"description"`
+	Remediation string   `json:"remediation"`
+	Payloads    int      `json:"payloads"`
+	SafeMode    string   `json:"safe_mode"`
+	Requires    []string `json:"requires"`
+}
+
+// capabilityManifest lists what this version of the scanner supports, for
+// platforms that orchestrate scans
+type capabilityManifest struct {
+	Scanner       string        `json:"scanner"`
+	Version       string        `json:"version"`
+	SchemaVersion int           `json:"result_schema_version"`
+	Tests         []builtinTest `json:"tests"`
+	Plugins       []string      `json:"plugins"`
+	Rules         []string      `json:"rules"`
+	ExportFormats []string      `json:"export_formats"`
+	Compliance    []string      `json:"compliance_frameworks"`
+	ConfigOptions []string      `json:"config_options"`
+	Subcommands   []string      `json:"subcommands"`
+}
+
+// capabilities builds the manifest; config may be nil when no configuration is available
+func capabilities(config *Config) capabilityManifest {
+	if config == nil {
+		config = &Config{}
+	}
+
+	tests := []builtinTest{
+		{
+			Name:        "Auth Test",
+			OWASP:       []string{"API2:2023 Broken Authentication", "A07:2021 Identification and Authentication Failures"},
+			Description: "Sends the configured credentials and fails if the endpoint rejects them.",
+			SafeMode:    "runs, with state-changing methods sent as HEAD",
+			Requires:    []string{"api_endpoints", "auth.username", "auth.password"},
+		},
+		{
+			Name:        "HTTP Method Test",
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Fails if the endpoint rejects its configured method.",
+			SafeMode:    "replaced by an OPTIONS request that fails on dangerous methods such as TRACE",
+			Requires:    []string{"api_endpoints"},
+		},
+		{
+			Name:        "Injection Test",
+			OWASP:       []string{"API8:2019 Injection", "A03:2021 Injection"},
+			Description: "Sends SQL injection payloads and looks for database errors and boolean-based differences.",
+			Payloads:    len(config.InjectionPayloads),
+			SafeMode:    "skipped",
+			Requires:    []string{"api_endpoints", "injection_payloads"},
+		},
+		{
+			Name:        "Security Headers Test",
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Checks the response for the headers of the configured policy, scoring each missing or weak header by its severity.",
+			SafeMode:    "runs, with state-changing methods sent as HEAD",
+			Requires:    []string{"api_endpoints", "security_headers.enabled"},
+		},
+		{
+			Name:        cookieTestName,
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Reports each cookie the response sets that is missing Secure, HttpOnly or SameSite, skipping ignored cookies.",
+			SafeMode:    "runs, with state-changing methods sent as HEAD",
+			Requires:    []string{"api_endpoints", "cookies.enabled"},
+		},
+		{
+			Name:        "Default Credentials Test",
+			OWASP:       []string{"API2:2023 Broken Authentication", "A07:2021 Identification and Authentication Failures"},
+			Description: "Opt-in. Tries a short list of default credentials against Basic-auth endpoints, spaced out and stopping at signs of account lockout.",
+			Payloads:    len(defaultCredentials(config.DefaultCreds)),
+			SafeMode:    "skipped",
+			Requires:    []string{"api_endpoints", "default_credentials.enabled"},
+		},
+		{
+			Name:        fuzzTestName,
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Sends mutations of the request body (type flips, boundary values, random bytes, big numbers) and fails on server errors and stack traces, saving each crash case with its seed.",
+			Payloads:    fuzzIterations(config.Fuzz),
+			SafeMode:    "skipped",
+			Requires:    []string{"api_endpoints", "api_endpoints.body", "fuzz.enabled"},
+		},
+		{
+			Name:        urlTokensTestName,
+			OWASP:       []string{"API2:2023 Broken Authentication", "A07:2021 Identification and Authentication Failures"},
+			Description: "Always runs, without requests of its own. Fails if the endpoint's URL or a redirect served during the other tests carries apparent secrets: credential-like query parameters, a password in the user info or base64-encoded user:password values.",
+			SafeMode:    "runs; it sends no requests",
+			Requires:    []string{"api_endpoints"},
+		},
+		{
+			Name:        exposedServicesTestName,
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Connects to MQTT, AMQP, Redis and Elasticsearch on each endpoint's host, once per host, and fails if any accepts an anonymous client.",
+			SafeMode:    "runs; the probes only read the services' handshake",
+			Requires:    []string{"api_endpoints", "services.enabled"},
+		},
+		{
+			Name:        openPortsTestName,
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Runs a rate-limited TCP connect scan of a shortlist of management and database ports on each endpoint's host, once per host, and fails on any open port that is not allowed.",
+			Payloads:    len(scanPorts(config.PortScan, 0)),
+			SafeMode:    "runs; connections are closed as soon as they are established",
+			Requires:    []string{"api_endpoints", "port_scan.enabled"},
+		},
+		{
+			Name:        dnsTestName,
+			OWASP:       []string{"API8:2023 Security Misconfiguration", "A05:2021 Security Misconfiguration"},
+			Description: "Opt-in. Checks the DNS records of each endpoint's host, once per host, for a dangling CNAME, a missing CAA record, a zone without DNSSEC and wildcard records.",
+			SafeMode:    "runs; only DNS queries are sent",
+			Requires:    []string{"api_endpoints", "dns.enabled"},
+		},
+		{
+			Name:        cloudIAMTestName,
+			OWASP:       []string{"API2:2023 Broken Authentication", "A07:2021 Identification and Authentication Failures"},
+			Description: "Opt-in. Sends each endpoint's request to a GCP or AWS IAM-protected API without credentials, with identity tokens for another audience or fake SigV4 signatures, and with configured metadata-server or low-privilege credentials, and fails if any is accepted.",
+			SafeMode:    "runs, with state-changing methods sent as HEAD",
+			Requires:    []string{"api_endpoints", "cloud_iam.enabled", "cloud_iam.provider"},
+		},
+		{
+			Name:        oidcTestName,
+			OWASP:       []string{"API2:2023 Broken Authentication", "A07:2021 Identification and Authentication Failures"},
+			Description: "Opt-in. Reads an OpenID Connect provider's discovery document and reports unauthenticated token endpoint clients, missing or unenforced PKCE, unsigned ID tokens, weak JWKS keys and open dynamic client registration, as an endpoint of its own.",
+			SafeMode:    "runs without the dynamic client registration check, which creates a client",
+			Requires:    []string{"oidc.enabled", "oidc.discovery"},
+		},
+	}
+	for i := range tests {
+		tests[i].Severity = testSeverity(tests[i].Name)
+		tests[i].CWE = testCWE(tests[i].Name)
+		tests[i].Remediation = testRemediation(tests[i].Name)
+	}
+
+	manifest := capabilityManifest{
+		Scanner:       "api-security-scanner",
+		Version:       scannerVersion,
+		SchemaVersion: resultSchemaVersion,
+		Tests:         tests,
+		Plugins:       []string{},
+		Rules:         []string{},
+		ExportFormats: exportFormats,
+		Compliance:    builtinFrameworks(),
+		ConfigOptions: yamlOptions(reflect.TypeOf(Config{})),
+		Subcommands:   []string{"bench", "capabilities", "feedback", "grafana", "migrate", "pack", "passive", "replay", "testserver", "verify"},
+	}
+	for _, plugin := range config.Plugins {
+		manifest.Plugins = append(manifest.Plugins, plugin.Name)
+	}
+	for _, rule := range config.Rules {
+		manifest.Rules = append(manifest.Rules, rule.Name)
+	}
+	return manifest
+}
+
+// yamlOptions returns the YAML keys of a configuration struct
+func yamlOptions(t reflect.Type) []string {
+	var options []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			options = append(options, tag)
+		}
+	}
+	return options
+}
+
+// capabilitiesCommand implements the capabilities subcommand
+func capabilitiesCommand(args []string) error {
+	flags := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	configFile := flags.String("config", "config.yaml", "configuration to report payloads, plugins and rules from, if it exists")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configFile)
+	if os.IsNotExist(err) {
+		config = nil
+	} else if err != nil {
+		return invalidConfig(fmt.Errorf("failed to load configuration: %v", err))
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(capabilities(config))
+}
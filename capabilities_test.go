@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	config := &Config{
+		InjectionPayloads: []string{"' OR '1'='1", "'; DROP TABLE users;--"},
+		Plugins:           []PluginConfig{{Name: "jwt-check"}},
+	}
+	manifest := capabilities(config)
+
+	if len(manifest.Tests) != 13 {
+		t.Fatalf("Expected 13 built-in tests, got %d", len(manifest.Tests))
+	}
+	injection := manifest.Tests[2]
+	if injection.Name != "Injection Test" || injection.Severity != "critical" || injection.CWE != 89 || injection.Payloads != 2 {
+		t.Errorf("Unexpected injection test capabilities: %+v", injection)
+	}
+	if defaults := manifest.Tests[5]; defaults.Name != "Default Credentials Test" || defaults.Payloads != len(defaultCredentialList) {
+		t.Errorf("Unexpected default credentials test capabilities: %+v", defaults)
+	}
+	if len(manifest.Plugins) != 1 || manifest.Plugins[0] != "jwt-check" {
+		t.Errorf("Expected configured plugin, got %v", manifest.Plugins)
+	}
+
+	found := false
+	for _, option := range manifest.ConfigOptions {
+		if option == "injection_payloads" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected injection_payloads in config options, got %v", manifest.ConfigOptions)
+	}
+
+	if _, err := json.Marshal(capabilities(nil)); err != nil {
+		t.Errorf("Expected manifest without configuration to encode, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	certificateExpiringEvent = "certificate.expiring"
+
+	defaultCertificateWarnDays = 30
+)
+
+// CertificatesConfig represents when webhooks are alerted about TLS certificates nearing expiry
+type CertificatesConfig struct {
+	WarnDays int `yaml:"warn_days"`
+}
+
+// CertificateInfo is the TLS certificate an endpoint served during the scan
+type CertificateInfo struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// DaysLeft returns the number of whole days until the certificate expires, negative once it has
+func (c CertificateInfo) DaysLeft(now time.Time) int {
+	return int(math.Floor(c.NotAfter.Sub(now).Hours() / 24))
+}
+
+// certificateRecorder records the leaf certificate of the responses to a scan
+// client, keeping the one that expires first if the endpoint serves several
+type certificateRecorder struct {
+	base http.RoundTripper
+
+	mu   sync.Mutex
+	cert *CertificateInfo
+}
+
+func (r *certificateRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil || resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return resp, err
+	}
+	leaf := resp.TLS.PeerCertificates[0]
+	r.mu.Lock()
+	if r.cert == nil || leaf.NotAfter.Before(r.cert.NotAfter) {
+		r.cert = &CertificateInfo{Subject: leaf.Subject.CommonName, Issuer: leaf.Issuer.CommonName, NotAfter: leaf.NotAfter}
+	}
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Certificate returns the recorded certificate, or nil if the endpoint did not use TLS
+func (r *certificateRecorder) Certificate() *CertificateInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+func certificateWarnDays(config CertificatesConfig) int {
+	if config.WarnDays <= 0 {
+		return defaultCertificateWarnDays
+	}
+	return config.WarnDays
+}
+
+// expiringCertificate is a certificate within the warning period, as sent to webhooks
+type expiringCertificate struct {
+	Endpoint string    `json:"endpoint"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+}
+
+// expiringCertificates returns the certificates that expire within the
+// warning period, soonest first, once per endpoint URL
+func expiringCertificates(results []EndpointResult, config CertificatesConfig, now time.Time) []expiringCertificate {
+	warnDays := certificateWarnDays(config)
+	seen := map[string]bool{}
+	var expiring []expiringCertificate
+	for _, result := range results {
+		cert := result.Certificate
+		if cert == nil || seen[result.URL] || cert.DaysLeft(now) > warnDays {
+			continue
+		}
+		seen[result.URL] = true
+		expiring = append(expiring, expiringCertificate{
+			Endpoint: result.URL,
+			Subject:  cert.Subject,
+			Issuer:   cert.Issuer,
+			NotAfter: cert.NotAfter,
+			DaysLeft: cert.DaysLeft(now),
+		})
+	}
+	sort.SliceStable(expiring, func(i, j int) bool { return expiring[i].NotAfter.Before(expiring[j].NotAfter) })
+	return expiring
+}
+
+// sendCertificateAlerts posts the certificates nearing expiry to every
+// configured webhook as a certificate.expiring event
+func sendCertificateAlerts(client *http.Client, webhooks []WebhookConfig, results []EndpointResult, config CertificatesConfig, now time.Time) []error {
+	expiring := expiringCertificates(results, config, now)
+	if len(webhooks) == 0 || len(expiring) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event":        certificateExpiringEvent,
+		"warn_days":    certificateWarnDays(config),
+		"certificates": expiring,
+	})
+	if err != nil {
+		return []error{fmt.Errorf("failed to encode certificate alert: %v", err)}
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	var errs []error
+	for _, webhook := range webhooks {
+		if err := sendWebhook(client, webhook, certificateExpiringEvent, body, timestamp); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %v", webhook.URL, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCertificateRecorder(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	recorder := &certificateRecorder{base: server.Client().Transport}
+	resp, err := (&http.Client{Transport: recorder}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	cert := recorder.Certificate()
+	if cert == nil || !cert.NotAfter.Equal(server.Certificate().NotAfter) {
+		t.Errorf("Expected the server's certificate to be recorded, got %+v", cert)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	recorder = &certificateRecorder{base: http.DefaultTransport}
+	resp, err = (&http.Client{Transport: recorder}).Get(plain.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if cert := recorder.Certificate(); cert != nil {
+		t.Errorf("Expected no certificate over plain HTTP, got %+v", cert)
+	}
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	results := []EndpointResult{
+		{URL: "https://a.example.com", Certificate: &CertificateInfo{Subject: "a.example.com", NotAfter: now.Add(60 * 24 * time.Hour)}},
+		{URL: "https://b.example.com", Certificate: &CertificateInfo{Subject: "b.example.com", NotAfter: now.Add(10 * 24 * time.Hour)}},
+		{URL: "https://c.example.com", Certificate: &CertificateInfo{Subject: "c.example.com", NotAfter: now.Add(-24 * time.Hour)}},
+		{URL: "https://b.example.com", Certificate: &CertificateInfo{Subject: "b.example.com", NotAfter: now.Add(10 * 24 * time.Hour)}},
+		{URL: "http://d.example.com"},
+	}
+
+	expiring := expiringCertificates(results, CertificatesConfig{}, now)
+	if len(expiring) != 2 || expiring[0].Endpoint != "https://c.example.com" || expiring[1].Endpoint != "https://b.example.com" {
+		t.Fatalf("Expected the expired and the 10-day certificates, soonest first, got %+v", expiring)
+	}
+	if expiring[0].DaysLeft != -1 || expiring[1].DaysLeft != 10 {
+		t.Errorf("Expected -1 and 10 days left, got %d and %d", expiring[0].DaysLeft, expiring[1].DaysLeft)
+	}
+	if expiring := expiringCertificates(results, CertificatesConfig{WarnDays: 90}, now); len(expiring) != 3 {
+		t.Errorf("Expected 3 certificates within 90 days, got %d", len(expiring))
+	}
+}
+
+func TestSendCertificateAlerts(t *testing.T) {
+	var event string
+	var payload struct {
+		Event        string                `json:"event"`
+		Certificates []expiringCertificate `json:"certificates"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event = r.Header.Get("X-Scanner-Event")
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	webhooks := []WebhookConfig{{URL: server.URL}}
+	healthy := []EndpointResult{{URL: "https://a.example.com", Certificate: &CertificateInfo{NotAfter: now.Add(365 * 24 * time.Hour)}}}
+	if errs := sendCertificateAlerts(server.Client(), webhooks, healthy, CertificatesConfig{}, now); len(errs) != 0 || event != "" {
+		t.Fatalf("Expected no alert for a certificate far from expiry, got %v (event %q)", errs, event)
+	}
+
+	expiring := []EndpointResult{{URL: "https://a.example.com", Certificate: &CertificateInfo{Subject: "a.example.com", NotAfter: now.Add(5 * 24 * time.Hour)}}}
+	if errs := sendCertificateAlerts(server.Client(), webhooks, expiring, CertificatesConfig{}, now); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+	if event != certificateExpiringEvent || payload.Event != certificateExpiringEvent {
+		t.Errorf("Expected a %s event, got header %q and payload %q", certificateExpiringEvent, event, payload.Event)
+	}
+	if len(payload.Certificates) != 1 || payload.Certificates[0].Subject != "a.example.com" {
+		t.Errorf("Expected the expiring certificate in the payload, got %+v", payload.Certificates)
+	}
+}
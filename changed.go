@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+)
+
+// changedEndpoints returns the endpoints that are new or defined differently
+// than in the baseline, so that a pull request scan can skip the endpoints it
+// does not touch. Endpoints are matched by name (or URL) and method, and
+// compared once the environment is applied, so that the URLs of the other
+// environments do not count.
+func changedEndpoints(endpoints, baseline []APIEndpoint) []APIEndpoint {
+	previous := map[string]APIEndpoint{}
+	for _, endpoint := range baseline {
+		previous[changeKey(endpoint)] = endpoint
+	}
+	var changed []APIEndpoint
+	for _, endpoint := range endpoints {
+		if old, ok := previous[changeKey(endpoint)]; !ok || !sameEndpoint(old, endpoint) {
+			changed = append(changed, endpoint)
+		}
+	}
+	return changed
+}
+
+// sameEndpoint reports whether two endpoints with their environment applied
+// are defined the same way
+func sameEndpoint(a, b APIEndpoint) bool {
+	a.Environments, b.Environments = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func changeKey(endpoint APIEndpoint) string {
+	return endpoint.Method + " " + endpointKey(endpoint.Name, endpoint.URL)
+}
+
+// loadBaselineEndpoints loads the endpoints of a baseline configuration, such
+// as config.yaml on the target branch, for the same environment as the scan
+func loadBaselineEndpoints(path string, overlays []string, environment string) ([]APIEndpoint, error) {
+	baseline, err := loadConfig(path, overlays...)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := applyEnvironment(baseline.APIEndpoints, environment)
+	if errors.Is(err, errNoEnvironmentEndpoints) {
+		// The baseline has no endpoints in this environment yet, so every endpoint is new
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
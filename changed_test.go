@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestChangedEndpoints(t *testing.T) {
+	baseline := []APIEndpoint{
+		{URL: "http://example.com/users", Method: "GET"},
+		{URL: "http://example.com/users", Method: "POST", Body: `{"name": "%s"}`},
+		{Name: "orders", URL: "http://old.example.com/orders", Method: "GET"},
+		{URL: "http://example.com/removed", Method: "GET"},
+	}
+	endpoints := []APIEndpoint{
+		{URL: "http://example.com/users", Method: "GET"},
+		{URL: "http://example.com/users", Method: "POST", Body: `{"email": "%s"}`},
+		{Name: "orders", URL: "http://new.example.com/orders", Method: "GET"},
+		{URL: "http://example.com/new", Method: "GET"},
+	}
+
+	changed := changedEndpoints(endpoints, baseline)
+	if len(changed) != 3 {
+		t.Fatalf("Expected 3 changed endpoints, got %+v", changed)
+	}
+	if changed[0].Method != "POST" || changed[1].Name != "orders" || changed[2].URL != "http://example.com/new" {
+		t.Errorf("Expected the changed body, the moved endpoint and the new endpoint, got %+v", changed)
+	}
+}
+
+func TestLoadBaselineEndpoints(t *testing.T) {
+	path := writeTempConfig(t, `api_endpoints:
+  - name: users
+    method: GET
+    environments:
+      staging: https://staging.example.com/users
+`)
+	endpoints, err := loadBaselineEndpoints(path, nil, "staging")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].URL != "https://staging.example.com/users" {
+		t.Errorf("Expected the staging endpoint, got %+v", endpoints)
+	}
+
+	endpoints, err = loadBaselineEndpoints(path, nil, "prod")
+	if err != nil || len(endpoints) != 0 {
+		t.Errorf("Expected no baseline endpoints in prod, got %+v, %v", endpoints, err)
+	}
+}
+
+func TestChangedEndpointsIgnoresOtherEnvironments(t *testing.T) {
+	baseline := []APIEndpoint{{Name: "users", URL: "https://staging.example.com/users", Method: "GET",
+		Environments: map[string]string{"staging": "https://staging.example.com/users", "prod": "https://example.com/users"}}}
+	endpoints := []APIEndpoint{{Name: "users", URL: "https://staging.example.com/users", Method: "GET",
+		Environments: map[string]string{"staging": "https://staging.example.com/users", "prod": "https://api.example.com/users"}}}
+
+	if changed := changedEndpoints(endpoints, baseline); len(changed) != 0 {
+		t.Errorf("Expected a URL change in another environment not to count, got %+v", changed)
+	}
+}
+
+func TestLoadBaselineEndpointsReturnsEnvironmentErrors(t *testing.T) {
+	path := writeTempConfig(t, `api_endpoints:
+  - name: users
+    method: GET
+    environments:
+      staging: https://staging.example.com/users
+`)
+	if _, err := loadBaselineEndpoints(path, nil, ""); err == nil {
+		t.Errorf("Expected an error for a baseline defined per environment without one selected")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ciSummaryFile     = "scan-summary.md"
+	gitlabQualityFile = "gl-code-quality-report.json"
+)
+
+// gitlabIssue represents a single entry of a GitLab Code Quality report
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+// detectCIPlatform returns "gitlab" when running under GitLab CI and "github" otherwise
+func detectCIPlatform() string {
+	if os.Getenv("GITLAB_CI") == "true" {
+		return "gitlab"
+	}
+	return "github"
+}
+
+// writeCIOutput emits annotations for the detected CI platform and writes the
+// markdown summary. GitLab issues are located in configPath, the configuration
+// the scanned endpoints come from.
+func writeCIOutput(results []EndpointResult, metadata map[string]string, configPath string) error {
+	summary := ciSummaryMarkdown(results, metadata)
+	if err := ioutil.WriteFile(ciSummaryFile, []byte(summary), 0644); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
+	}
+
+	switch detectCIPlatform() {
+	case "gitlab":
+		data, err := gitlabCodeQuality(results, configPath)
+		if err != nil {
+			return fmt.Errorf("failed to encode code quality report: %v", err)
+		}
+		if err := ioutil.WriteFile(gitlabQualityFile, data, 0644); err != nil {
+			return fmt.Errorf("failed to write code quality report: %v", err)
+		}
+	default:
+		for _, annotation := range githubAnnotations(results) {
+			fmt.Println(annotation)
+		}
+		if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("failed to open step summary: %v", err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString(summary); err != nil {
+				return fmt.Errorf("failed to write step summary: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasFailures reports whether any test failed on any endpoint
+func hasFailures(results []EndpointResult) bool {
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if testResult.Failed() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// githubAnnotations returns one GitHub workflow command per failed test
+func githubAnnotations(results []EndpointResult) []string {
+	var annotations []string
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+			level := "warning"
+			if severity := resultSeverity(testResult); severity == "critical" || severity == "high" {
+				level = "error"
+			}
+			annotations = append(annotations, fmt.Sprintf("::%s title=%s::%s",
+				level,
+				escapeWorkflowProperty(testResult.TestName),
+				escapeWorkflowData(result.URL+": "+testResult.Message)))
+		}
+	}
+	return annotations
+}
+
+func gitlabCodeQuality(results []EndpointResult, configPath string) ([]byte, error) {
+	issues := []gitlabIssue{}
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+			issues = append(issues, gitlabIssue{
+				Description: fmt.Sprintf("%s: %s", result.URL, testResult.Message),
+				CheckName:   testResult.TestName,
+				Fingerprint: findingFingerprint(result.key(), testResult.TestName),
+				Severity:    gitlabSeverity(resultSeverity(testResult)),
+				Location:    gitlabLocation{Path: filepath.ToSlash(configPath), Lines: gitlabLines{Begin: 1}},
+			})
+		}
+	}
+	return json.MarshalIndent(issues, "", "  ")
+}
+
+// gitlabSeverity maps scanner severities onto the GitLab Code Quality scale
+func gitlabSeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "critical"
+	case "high":
+		return "major"
+	case "medium":
+		return "minor"
+	default:
+		return "info"
+	}
+}
+
+func ciSummaryMarkdown(results []EndpointResult, metadata map[string]string) string {
+	var b strings.Builder
+	b.WriteString("## API Security Scan Summary\n\n")
+	for _, key := range metadataKeys(metadata) {
+		fmt.Fprintf(&b, "- **%s**: %s\n", key, metadata[key])
+	}
+	if len(metadata) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("| Endpoint | Score | Failed Tests |\n")
+	b.WriteString("|----------|-------|--------------|\n")
+	for _, result := range results {
+		var failed []string
+		for _, testResult := range result.Results {
+			if testResult.Failed() {
+				failed = append(failed, testResult.TestName)
+			}
+		}
+		failedTests := "None"
+		if len(failed) > 0 {
+			failedTests = strings.Join(failed, ", ")
+		}
+		fmt.Fprintf(&b, "| %s | %d/100 | %s |\n", result.URL, result.Score, failedTests)
+	}
+	return b.String()
+}
+
+func escapeWorkflowData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func escapeWorkflowProperty(s string) string {
+	s = escapeWorkflowData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
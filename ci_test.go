@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGithubAnnotations(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Results: []TestResult{
+			{TestName: "Injection Test", Passed: false, Message: "payload: 100%\nmatched"},
+			{TestName: "Auth Test", Passed: true},
+		}},
+		{URL: "http://example.com/b", Results: []TestResult{
+			{TestName: "HTTP Method Test", Passed: false, Message: "unexpected status code: 405"},
+		}},
+	}
+
+	annotations := githubAnnotations(results)
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, got %d", len(annotations))
+	}
+
+	expected := "::error title=Injection Test::http://example.com/a: payload: 100%25%0Amatched"
+	if annotations[0] != expected {
+		t.Errorf("Expected %q, got %q", expected, annotations[0])
+	}
+	if !strings.HasPrefix(annotations[1], "::warning ") {
+		t.Errorf("Expected warning annotation, got %q", annotations[1])
+	}
+}
+
+func TestGitlabCodeQuality(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Results: []TestResult{
+			{TestName: "Auth Test", Passed: false, Message: "authentication failed"},
+			{TestName: "HTTP Method Test", Passed: true},
+		}},
+	}
+
+	data, err := gitlabCodeQuality(results, "ci/scanner.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var issues []gitlabIssue
+	if err := json.Unmarshal(data, &issues); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("Expected 1 issue, got %d", len(issues))
+	}
+	if issues[0].Severity != "major" || issues[0].CheckName != "Auth Test" {
+		t.Errorf("Unexpected issue: %+v", issues[0])
+	}
+	if issues[0].Location.Path != "ci/scanner.yaml" {
+		t.Errorf("Expected the issue to be located in the -config file, got %q", issues[0].Location.Path)
+	}
+}
+
+func TestCISummaryMarkdown(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Score: 100, Results: []TestResult{{TestName: "Auth Test", Passed: true}}},
+	}
+
+	summary := ciSummaryMarkdown(results, nil)
+	if !strings.Contains(summary, "| http://example.com/a | 100/100 | None |") {
+		t.Errorf("Expected endpoint row in summary, got %q", summary)
+	}
+	if hasFailures(results) {
+		t.Errorf("Expected no failures")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// builtinComplianceMappings holds the mapping files shipped with the scanner,
+// one per framework, named after the framework key used in configuration
+//
+//go:embed compliance/*.yaml
+var builtinComplianceMappings embed.FS
+
+// Control statuses in compliance reports
+const (
+	controlPassed    = "pass"
+	controlFailed    = "fail"
+	controlNotTested = "not_tested"
+)
+
+// ComplianceConfig represents the compliance frameworks to report on
+type ComplianceConfig struct {
+	Frameworks []string `yaml:"frameworks"`
+	Mappings   []string `yaml:"mappings"`
+	Output     string   `yaml:"output"`
+}
+
+// complianceMapping associates the controls of a framework with the tests that provide evidence for them
+type complianceMapping struct {
+	Framework string              `yaml:"framework"`
+	Version   string              `yaml:"version"`
+	Controls  []complianceControl `yaml:"controls"`
+}
+
+type complianceControl struct {
+	ID    string   `yaml:"id"`
+	Title string   `yaml:"title"`
+	Tests []string `yaml:"tests"`
+}
+
+// complianceReport is the outcome of a scan against the controls of one framework
+type complianceReport struct {
+	Framework string          `json:"framework"`
+	Version   string          `json:"version"`
+	Passed    int             `json:"passed"`
+	Failed    int             `json:"failed"`
+	NotTested int             `json:"not_tested"`
+	Controls  []controlResult `json:"controls"`
+}
+
+type controlResult struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Status   string   `json:"status"`
+	Tests    []string `json:"tests"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// builtinFrameworks returns the keys of the shipped mapping files
+func builtinFrameworks() []string {
+	entries, _ := builtinComplianceMappings.ReadDir("compliance")
+	var frameworks []string
+	for _, entry := range entries {
+		frameworks = append(frameworks, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(frameworks)
+	return frameworks
+}
+
+func parseComplianceMapping(data []byte) (complianceMapping, error) {
+	var mapping complianceMapping
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return mapping, err
+	}
+	if mapping.Framework == "" {
+		return mapping, fmt.Errorf("missing framework name")
+	}
+	for _, control := range mapping.Controls {
+		if control.ID == "" || len(control.Tests) == 0 {
+			return mapping, fmt.Errorf("control %q needs an id and at least one test", control.ID)
+		}
+	}
+	return mapping, nil
+}
+
+// loadComplianceMappings returns the selected built-in frameworks followed by the custom mapping files
+func loadComplianceMappings(config ComplianceConfig) ([]complianceMapping, error) {
+	var mappings []complianceMapping
+	for _, framework := range config.Frameworks {
+		data, err := builtinComplianceMappings.ReadFile("compliance/" + strings.ToLower(framework) + ".yaml")
+		if err != nil {
+			return nil, fmt.Errorf("unknown compliance framework %q (supported: %s)", framework, strings.Join(builtinFrameworks(), ", "))
+		}
+		mapping, err := parseComplianceMapping(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid built-in mapping %s: %v", framework, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	for _, path := range config.Mappings {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compliance mapping: %v", err)
+		}
+		mapping, err := parseComplianceMapping(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compliance mapping %s: %v", path, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// evaluateCompliance marks a control as failed if any mapped test failed on any
+// endpoint, as passed if at least one mapped test passed and none failed, and
+// as not tested when the scan produced no evidence for it
+func evaluateCompliance(mapping complianceMapping, results []EndpointResult) complianceReport {
+	report := complianceReport{Framework: mapping.Framework, Version: mapping.Version}
+	for _, control := range mapping.Controls {
+		tests := map[string]bool{}
+		for _, test := range control.Tests {
+			tests[test] = true
+		}
+
+		outcome := controlResult{ID: control.ID, Title: control.Title, Status: controlNotTested, Tests: control.Tests}
+		for _, result := range results {
+			for _, testResult := range result.Results {
+				if !tests[baseTestName(testResult.TestName)] || testResult.Skipped {
+					continue
+				}
+				if testResult.Failed() {
+					outcome.Status = controlFailed
+					outcome.Failures = append(outcome.Failures, fmt.Sprintf("%s on %s", testResult.TestName, result.URL))
+				} else if outcome.Status == controlNotTested {
+					outcome.Status = controlPassed
+				}
+			}
+		}
+
+		switch outcome.Status {
+		case controlPassed:
+			report.Passed++
+		case controlFailed:
+			report.Failed++
+		default:
+			report.NotTested++
+		}
+		report.Controls = append(report.Controls, outcome)
+	}
+	return report
+}
+
+// formatComplianceReport renders a compliance report for the text output
+func formatComplianceReport(report complianceReport, l localizer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %s\n", report.Framework, report.Version,
+		l.T("%d passed, %d failed, %d not tested", report.Passed, report.Failed, report.NotTested))
+	for _, control := range report.Controls {
+		status := "PASSED"
+		switch control.Status {
+		case controlFailed:
+			status = "FAILED"
+		case controlNotTested:
+			status = "NOT TESTED"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", strings.TrimSpace(control.ID+" "+control.Title), l.T(status))
+		for _, failure := range control.Failures {
+			fmt.Fprintf(&b, "  %s\n", failure)
+		}
+	}
+	return b.String()
+}
+
+// writeComplianceReports prints the compliance section of the report and
+// writes the reports as JSON when an output file is configured
+func writeComplianceReports(config ComplianceConfig, mappings []complianceMapping, results []EndpointResult, l localizer) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	var reports []complianceReport
+	fmt.Println("\n" + l.T("Compliance Assessment:"))
+	for _, mapping := range mappings {
+		report := evaluateCompliance(mapping, results)
+		reports = append(reports, report)
+		fmt.Print(formatComplianceReport(report, l))
+	}
+
+	if config.Output == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode compliance reports: %v", err)
+	}
+	return writeOutput(config.Output, data)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinComplianceMappings(t *testing.T) {
+	frameworks := builtinFrameworks()
+	if !reflect.DeepEqual(frameworks, []string{"hipaa", "pci-dss", "soc2"}) {
+		t.Fatalf("Expected the hipaa, pci-dss and soc2 mappings, got %v", frameworks)
+	}
+
+	mappings, err := loadComplianceMappings(ComplianceConfig{Frameworks: []string{"PCI-DSS", "soc2", "hipaa"}})
+	if err != nil {
+		t.Fatalf("Expected built-in mappings to load, got %v", err)
+	}
+	builtin := map[string]bool{"Auth Test": true, "HTTP Method Test": true, "Injection Test": true, "Default Credentials Test": true}
+	for _, mapping := range mappings {
+		for _, control := range mapping.Controls {
+			for _, test := range control.Tests {
+				if !builtin[test] {
+					t.Errorf("Expected %s control %s to map to built-in tests, got %q", mapping.Framework, control.ID, test)
+				}
+			}
+		}
+	}
+
+	if _, err := loadComplianceMappings(ComplianceConfig{Frameworks: []string{"iso27001"}}); err == nil {
+		t.Errorf("Expected an error for an unknown framework")
+	}
+}
+
+func TestCustomComplianceMapping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compliance")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "internal.yaml")
+	mapping := "framework: Internal\nversion: \"1\"\ncontrols:\n  - id: SEC-1\n    title: No verbose errors\n    tests: [Verbose Errors]\n"
+	if err := ioutil.WriteFile(path, []byte(mapping), 0644); err != nil {
+		t.Fatalf("Failed to write mapping: %v", err)
+	}
+	mappings, err := loadComplianceMappings(ComplianceConfig{Mappings: []string{path}})
+	if err != nil || len(mappings) != 1 || mappings[0].Controls[0].Tests[0] != "Verbose Errors" {
+		t.Errorf("Expected the custom mapping to load, got %+v, %v", mappings, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("framework: Internal\ncontrols:\n  - id: SEC-1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write mapping: %v", err)
+	}
+	if _, err := loadComplianceMappings(ComplianceConfig{Mappings: []string{path}}); err == nil {
+		t.Errorf("Expected an error for a control without tests")
+	}
+}
+
+func TestEvaluateCompliance(t *testing.T) {
+	mapping := complianceMapping{Framework: "Test", Controls: []complianceControl{
+		{ID: "A", Tests: []string{"Auth Test"}},
+		{ID: "B", Tests: []string{"Injection Test", "HTTP Method Test"}},
+		{ID: "C", Tests: []string{"Unmapped Test"}},
+	}}
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Results: []TestResult{
+			{TestName: "Auth Test", Passed: true},
+			{TestName: "Injection Test", Passed: true},
+			{TestName: "HTTP Method Test", Passed: true},
+		}},
+		{URL: "http://example.com/b", Results: []TestResult{
+			{TestName: "Auth Test", Passed: true},
+			{TestName: "Injection Test", Passed: false, Message: "possible SQL injection"},
+		}},
+	}
+
+	report := evaluateCompliance(mapping, results)
+	if report.Passed != 1 || report.Failed != 1 || report.NotTested != 1 {
+		t.Errorf("Expected 1 passed, 1 failed and 1 not tested control, got %+v", report)
+	}
+	want := []string{controlPassed, controlFailed, controlNotTested}
+	for i, control := range report.Controls {
+		if control.Status != want[i] {
+			t.Errorf("Expected control %s to be %s, got %s", control.ID, want[i], control.Status)
+		}
+	}
+	if len(report.Controls[1].Failures) != 1 || report.Controls[1].Failures[0] != "Injection Test on http://example.com/b" {
+		t.Errorf("Expected the failing endpoint to be listed, got %v", report.Controls[1].Failures)
+	}
+
+	text := formatComplianceReport(report, localizer{})
+	if !strings.Contains(text, "1 passed, 1 failed, 1 not tested") || !strings.Contains(text, "- C: NOT TESTED") {
+		t.Errorf("Expected a control summary, got %q", text)
+	}
+}
+
+func TestSkippedTestsAreNotComplianceEvidence(t *testing.T) {
+	mapping := complianceMapping{Framework: "Test", Controls: []complianceControl{{ID: "A", Tests: []string{"Injection Test"}}}}
+	results := []EndpointResult{{URL: "http://example.com", Results: []TestResult{
+		{TestName: "Injection Test", Skipped: true, Message: safeModeSkipMessage},
+	}}}
+	if report := evaluateCompliance(mapping, results); report.Controls[0].Status != controlNotTested {
+		t.Errorf("Expected a skipped test to leave the control untested, got %s", report.Controls[0].Status)
+	}
+}
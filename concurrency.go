@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConcurrencyConfig represents the adaptive per-host concurrency options
+type ConcurrencyConfig struct {
+	Adaptive      bool          `yaml:"adaptive"`
+	Initial       int           `yaml:"initial"`
+	Min           int           `yaml:"min"`
+	Max           int           `yaml:"max"`
+	LatencyTarget time.Duration `yaml:"latency_target"`
+}
+
+// withDefaults fills in the unset options
+func (c ConcurrencyConfig) withDefaults() ConcurrencyConfig {
+	if c.Min <= 0 {
+		c.Min = 1
+	}
+	if c.Max <= 0 {
+		c.Max = 32
+	}
+	if c.Max < c.Min {
+		c.Max = c.Min
+	}
+	if c.Initial <= 0 {
+		c.Initial = 4
+	}
+	if c.Initial < c.Min {
+		c.Initial = c.Min
+	}
+	if c.Initial > c.Max {
+		c.Initial = c.Max
+	}
+	if c.LatencyTarget <= 0 {
+		c.LatencyTarget = 2 * time.Second
+	}
+	return c
+}
+
+// LimiterStats describes how the adaptive concurrency limiter of a host
+// behaved during the scan, to tune the concurrency options with
+type LimiterStats struct {
+	Host         string  `json:"host"`
+	Limit        int     `json:"limit"`
+	MinLimit     int     `json:"min_limit"`
+	PeakInFlight int     `json:"peak_in_flight"`
+	PeakWaiting  int     `json:"peak_waiting"`
+	WaitSeconds  float64 `json:"wait_seconds"`
+	Decreases    int     `json:"decreases"`
+}
+
+// aimdLimiter bounds the number of requests in flight to a host. Like TCP
+// congestion control, the limit grows by about one for every limit's worth of
+// fast, successful responses and is halved on errors, throttling or slow responses.
+type aimdLimiter struct {
+	config ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	wake     chan struct{}
+
+	// Instrumentation for LimiterStats
+	minLimit     float64
+	waiting      int
+	peakInFlight int
+	peakWaiting  int
+	waited       time.Duration
+	decreases    int
+}
+
+func newAIMDLimiter(config ConcurrencyConfig) *aimdLimiter {
+	config = config.withDefaults()
+	return &aimdLimiter{config: config, limit: float64(config.Initial), minLimit: float64(config.Initial), wake: make(chan struct{})}
+}
+
+// acquire waits for a free slot or for ctx to be done
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	var queued time.Time
+	for {
+		l.mu.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			if l.inFlight > l.peakInFlight {
+				l.peakInFlight = l.inFlight
+			}
+			if !queued.IsZero() {
+				l.dequeue(queued)
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		if queued.IsZero() {
+			queued = time.Now()
+			l.waiting++
+			if l.waiting > l.peakWaiting {
+				l.peakWaiting = l.waiting
+			}
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.dequeue(queued)
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// dequeue records the end of a wait for a slot; l.mu must be held
+func (l *aimdLimiter) dequeue(queued time.Time) {
+	l.waiting--
+	l.waited += time.Since(queued)
+}
+
+// release frees a slot and adjusts the limit based on how the request went
+func (l *aimdLimiter) release(success bool, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if !success || latency > l.config.LatencyTarget {
+		l.limit /= 2
+		if l.limit < float64(l.config.Min) {
+			l.limit = float64(l.config.Min)
+		}
+		if l.limit < l.minLimit {
+			l.minLimit = l.limit
+		}
+		l.decreases++
+	} else {
+		l.limit += 1 / l.limit
+		if l.limit > float64(l.config.Max) {
+			l.limit = float64(l.config.Max)
+		}
+	}
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// Limit returns the current number of requests allowed in flight
+func (l *aimdLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Stats returns the limiter's current limit and what it went through so far
+func (l *aimdLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{
+		Limit:        int(l.limit),
+		MinLimit:     int(l.minLimit),
+		PeakInFlight: l.peakInFlight,
+		PeakWaiting:  l.peakWaiting,
+		WaitSeconds:  l.waited.Seconds(),
+		Decreases:    l.decreases,
+	}
+}
+
+// hostLimiters holds one limiter per host, shared by all endpoints on that host
+type hostLimiters struct {
+	config ConcurrencyConfig
+
+	mu       sync.Mutex
+	limiters map[string]*aimdLimiter
+}
+
+func newHostLimiters(config ConcurrencyConfig) *hostLimiters {
+	return &hostLimiters{config: config, limiters: map[string]*aimdLimiter{}}
+}
+
+func (h *hostLimiters) get(host string) *aimdLimiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	limiter, ok := h.limiters[host]
+	if !ok {
+		limiter = newAIMDLimiter(h.config)
+		h.limiters[host] = limiter
+	}
+	return limiter
+}
+
+// stats returns the statistics of the host's limiter, or nil if no request was sent to the host
+func (h *hostLimiters) stats(host string) *LimiterStats {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	limiter, ok := h.limiters[host]
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	stats := limiter.Stats()
+	stats.Host = host
+	return &stats
+}
+
+// limiterTransport holds a slot of the host's limiter for the duration of each request
+type limiterTransport struct {
+	base     http.RoundTripper
+	limiters *hostLimiters
+}
+
+// newLimiterTransport returns base unchanged when adaptive concurrency is disabled
+func newLimiterTransport(base http.RoundTripper, limiters *hostLimiters) http.RoundTripper {
+	if limiters == nil {
+		return base
+	}
+	return &limiterTransport{base: base, limiters: limiters}
+}
+
+func (t *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limiter := t.limiters.get(req.URL.Host)
+	if err := limiter.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	success := err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500
+	limiter.release(success, time.Since(start))
+	return resp, err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAIMDLimiterAdjustsLimit(t *testing.T) {
+	limiter := newAIMDLimiter(ConcurrencyConfig{Initial: 4, Min: 1, Max: 6, LatencyTarget: time.Second})
+
+	for i := 0; i < 100; i++ {
+		limiter.acquire(context.Background())
+		limiter.release(true, 10*time.Millisecond)
+	}
+	if limit := limiter.Limit(); limit != 6 {
+		t.Errorf("Expected limit to grow to the maximum of 6, got %d", limit)
+	}
+
+	limiter.acquire(context.Background())
+	limiter.release(false, 10*time.Millisecond)
+	if limit := limiter.Limit(); limit != 3 {
+		t.Errorf("Expected limit to halve to 3 after an error, got %d", limit)
+	}
+
+	limiter.acquire(context.Background())
+	limiter.release(true, 2*time.Second)
+	limiter.acquire(context.Background())
+	limiter.release(true, 2*time.Second)
+	if limit := limiter.Limit(); limit != 1 {
+		t.Errorf("Expected slow responses to reduce the limit to the minimum of 1, got %d", limit)
+	}
+}
+
+func TestAIMDLimiterAcquireHonorsContext(t *testing.T) {
+	limiter := newAIMDLimiter(ConcurrencyConfig{Initial: 1, Max: 1})
+	limiter.acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.acquire(ctx); err == nil {
+		t.Errorf("Expected acquire to fail when no slot frees up")
+	}
+}
+
+func TestLimiterTransportBoundsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	limiters := newHostLimiters(ConcurrencyConfig{Initial: 2, Max: 2})
+	client := &http.Client{Transport: newLimiterTransport(http.DefaultTransport, limiters)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := client.Get(server.URL); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 requests in flight, got %d", peak)
+	}
+	if limit := limiters.get(server.Listener.Addr().String()).Limit(); limit != 1 {
+		t.Errorf("Expected server errors to reduce the limit to 1, got %d", limit)
+	}
+}
+
+func TestAIMDLimiterStats(t *testing.T) {
+	limiter := newAIMDLimiter(ConcurrencyConfig{Initial: 1, Max: 4, LatencyTarget: time.Second})
+	limiter.acquire(context.Background())
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.acquire(context.Background())
+		close(acquired)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	limiter.release(false, 10*time.Millisecond)
+	<-acquired
+	limiter.release(true, 10*time.Millisecond)
+
+	stats := limiter.Stats()
+	if stats.PeakInFlight != 1 || stats.PeakWaiting != 1 || stats.Decreases != 1 || stats.MinLimit != 1 {
+		t.Errorf("Unexpected limiter statistics: %+v", stats)
+	}
+	if stats.WaitSeconds < 0.015 {
+		t.Errorf("Expected the queued request's wait to be recorded, got %vs", stats.WaitSeconds)
+	}
+
+	limiters := newHostLimiters(ConcurrencyConfig{})
+	if limiters.stats("api.example.com") != nil {
+		t.Errorf("Expected no statistics for a host without requests")
+	}
+	limiters.get("api.example.com")
+	if stats := limiters.stats("api.example.com"); stats == nil || stats.Host != "api.example.com" || stats.Limit != 4 {
+		t.Errorf("Unexpected host statistics: %+v", stats)
+	}
+}
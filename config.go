@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigError lists every problem found in a configuration file
+type ConfigError struct {
+	File     string
+	Problems []string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration in %s:\n  %s", e.File, strings.Join(e.Problems, "\n  "))
+}
+
+func (e ConfigError) Is(target error) bool { return target == ErrConfigInvalid }
+
+// unknownFieldPattern matches the yaml decoder's message for keys that do not exist in the target type
+var unknownFieldPattern = regexp.MustCompile(`^(line \d+): field (\S+) not found in type \S+$`)
+
+// loadConfig loads the configuration from a YAML file, rejecting unknown keys
+// and values of the wrong type instead of silently ignoring them. Overlays are
+// deep-merged over it in order: their mappings are merged key by key, while
+// lists and scalars replace the base value.
+func loadConfig(filename string, overlays ...string) (*Config, error) {
+	var merged interface{}
+	for _, file := range append([]string{filename}, overlays...) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// Decode each file on its own first so that problems are reported with its line numbers
+		var layer Config
+		if err := yaml.UnmarshalStrict(data, &layer); err != nil {
+			return nil, ConfigError{File: file, Problems: configProblems(err)}
+		}
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, ConfigError{File: file, Problems: configProblems(err)}
+		}
+		// An empty file leaves the configuration unchanged
+		if document != nil {
+			merged = mergeYAML(merged, document)
+		}
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge configuration: %v", err)
+	}
+	source := strings.Join(append([]string{filename}, overlays...), " + ")
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, ConfigError{File: source, Problems: configProblems(err)}
+	}
+	if problems := validateConfig(&config); len(problems) > 0 {
+		return nil, ConfigError{File: source, Problems: problems}
+	}
+	return &config, nil
+}
+
+// mergeYAML deep-merges overlay into base
+func mergeYAML(base, overlay interface{}) interface{} {
+	baseMap, baseIsMap := base.(map[interface{}]interface{})
+	overlayMap, overlayIsMap := overlay.(map[interface{}]interface{})
+	if !baseIsMap || !overlayIsMap {
+		return overlay
+	}
+	merged := make(map[interface{}]interface{}, len(baseMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overlayMap {
+		if existing, ok := merged[key]; ok {
+			merged[key] = mergeYAML(existing, value)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// configProblems rewrites the yaml decoder's errors, suggesting the closest
+// known key for unknown ones. A block indented under the wrong parent also
+// shows up as an unknown key, on the line it starts at.
+func configProblems(err error) []string {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	keys := configKeys(reflect.TypeOf(Config{}), map[reflect.Type]bool{})
+	var problems []string
+	for _, message := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(message)
+		if match == nil {
+			problems = append(problems, message)
+			continue
+		}
+		problem := fmt.Sprintf("%s: unknown key %q", match[1], match[2])
+		if suggestion := closestKey(match[2], keys); suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// configKeys returns every YAML key of the configuration types
+func configKeys(t reflect.Type, seen map[reflect.Type]bool) []string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		keys = append(keys, name)
+		keys = append(keys, configKeys(field.Type, seen)...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closestKey returns the known key nearest to key, if it is a likely typo
+func closestKey(key string, keys []string) string {
+	best, bestDistance := "", len(key)/2+1
+	for _, candidate := range keys {
+		if d := editDistance(key, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// validateConfig checks the values that decoding cannot, so that a missing or
+// mistyped setting is reported instead of failing later in the scan
+func validateConfig(config *Config) []string {
+	var problems []string
+	if len(config.APIEndpoints) == 0 {
+		problems = append(problems, "at least one entry in api_endpoints is required")
+	}
+	for i, endpoint := range config.APIEndpoints {
+		if endpoint.URL == "" && len(endpoint.Environments) == 0 {
+			problems = append(problems, fmt.Sprintf("api_endpoints[%d]: url is required", i))
+			continue
+		}
+		if len(endpoint.Environments) > 0 && endpoint.Name == "" {
+			problems = append(problems, fmt.Sprintf("api_endpoints[%d]: endpoints with environments need a name", i))
+		}
+		urls := []string{endpoint.URL}
+		for _, environment := range environmentNames(endpoint) {
+			urls = append(urls, endpoint.Environments[environment])
+		}
+		for _, rawURL := range urls {
+			if rawURL == "" {
+				continue
+			}
+			u, err := url.Parse(rawURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("api_endpoints[%d]: url %q must be an absolute http or https URL", i, rawURL))
+			}
+		}
+	}
+	return problems
+}
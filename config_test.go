@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeTempConfig(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "config*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp config file: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatalf("Failed to write temp config file: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(file.Name()) })
+	return file.Name()
+}
+
+func TestLoadConfigReportsUnknownKeys(t *testing.T) {
+	path := writeTempConfig(t, `api_endpoint:
+  - url: "http://example.com"
+    method: GET
+auth:
+  username: admin
+  pasword: secret
+`)
+	_, err := loadConfig(path)
+	configErr, ok := err.(ConfigError)
+	if !ok {
+		t.Fatalf("Expected a ConfigError, got %v", err)
+	}
+	expected := []string{
+		`line 1: unknown key "api_endpoint" (did you mean "api_endpoints"?)`,
+		`line 6: unknown key "pasword" (did you mean "password"?)`,
+	}
+	if strings.Join(configErr.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, configErr.Problems)
+	}
+}
+
+func TestLoadConfigReportsMisindentedBlocks(t *testing.T) {
+	path := writeTempConfig(t, `api_endpoints:
+  - url: "http://example.com"
+    method: GET
+    auth:
+      username: admin
+`)
+	_, err := loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), `line 4: unknown key "auth"`) {
+		t.Errorf("Expected the misindented block to be reported on its line, got %v", err)
+	}
+}
+
+func TestLoadConfigReportsTypeMismatches(t *testing.T) {
+	path := writeTempConfig(t, `api_endpoints:
+  - url: "http://example.com"
+safe_mode: sometimes
+`)
+	_, err := loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "line 3: cannot unmarshal !!str `sometimes` into bool") {
+		t.Errorf("Expected the type mismatch to be reported, got %v", err)
+	}
+}
+
+func TestLoadConfigValidatesEndpoints(t *testing.T) {
+	_, err := loadConfig(writeTempConfig(t, "auth:\n  username: admin\n"))
+	if err == nil || !strings.Contains(err.Error(), "at least one entry in api_endpoints is required") {
+		t.Errorf("Expected missing endpoints to be reported, got %v", err)
+	}
+
+	_, err = loadConfig(writeTempConfig(t, "api_endpoints:\n  - method: GET\n  - url: example.com/api\n"))
+	configErr, ok := err.(ConfigError)
+	if !ok || len(configErr.Problems) != 2 || configErr.Problems[0] != "api_endpoints[0]: url is required" {
+		t.Errorf("Expected both endpoints to be reported, got %v", err)
+	}
+
+	config, err := loadConfig("config.yaml")
+	if err != nil || len(config.APIEndpoints) != 2 {
+		t.Errorf("Expected the sample configuration to load, got %v", err)
+	}
+}
+
+func TestLoadConfigOverlays(t *testing.T) {
+	base := writeTempConfig(t, `api_endpoints:
+  - url: "https://api.example.com/users"
+    method: GET
+auth:
+  username: dev
+  password: dev
+concurrency:
+  adaptive: true
+  max: 8
+injection_payloads:
+  - "' OR '1'='1"
+`)
+	prod := writeTempConfig(t, `auth:
+  password: vault:secret/data/prod#password
+concurrency:
+  max: 2
+injection_payloads: []
+`)
+	empty := writeTempConfig(t, "")
+
+	config, err := loadConfig(base, prod, empty)
+	if err != nil {
+		t.Fatalf("Expected the overlays to load, got %v", err)
+	}
+	if len(config.APIEndpoints) != 1 || config.APIEndpoints[0].URL != "https://api.example.com/users" {
+		t.Errorf("Expected the endpoints to be shared, got %+v", config.APIEndpoints)
+	}
+	if config.Auth.Username != "dev" || config.Auth.Password != "vault:secret/data/prod#password" {
+		t.Errorf("Expected auth to be deep-merged, got %+v", config.Auth)
+	}
+	if !config.Concurrency.Adaptive || config.Concurrency.Max != 2 {
+		t.Errorf("Expected concurrency to be deep-merged, got %+v", config.Concurrency)
+	}
+	if len(config.InjectionPayloads) != 0 {
+		t.Errorf("Expected lists to be replaced, got %v", config.InjectionPayloads)
+	}
+
+	typo := writeTempConfig(t, "auth:\n  usernam: prod\n")
+	_, err = loadConfig(base, typo)
+	configErr, ok := err.(ConfigError)
+	if !ok || configErr.File != typo || configErr.Problems[0] != `line 2: unknown key "usernam" (did you mean "username"?)` {
+		t.Errorf("Expected the overlay's problem with its own line number, got %v", err)
+	}
+}
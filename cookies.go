@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const cookieTestName = "Cookie Security Test"
+
+// CookiesConfig represents the cookie attribute checks
+type CookiesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Ignore lists cookies that do not carry a session, such as consent or analytics cookies
+	Ignore []string `yaml:"ignore"`
+}
+
+// cookieFindingName is the test name of the result for a single cookie, so that
+// each cookie is reported, fingerprinted and tracked as a finding of its own
+func cookieFindingName(cookie string) string {
+	return cookieTestName + " [" + cookie + "]"
+}
+
+// baseTestName returns the test a result belongs to, without the cookie a cookie finding is about
+func baseTestName(testName string) string {
+	if strings.HasPrefix(testName, cookieTestName+" [") && strings.HasSuffix(testName, "]") {
+		return cookieTestName
+	}
+	return testName
+}
+
+// cookieIssues returns the security attributes the cookie is missing. Secure is
+// only required over HTTPS, since browsers drop Secure cookies set over HTTP.
+func cookieIssues(cookie *http.Cookie, https bool) []string {
+	var missing []string
+	if https && !cookie.Secure {
+		missing = append(missing, "Secure")
+	}
+	if !cookie.HttpOnly {
+		missing = append(missing, "HttpOnly")
+	}
+	switch {
+	case cookie.SameSite == 0 || cookie.SameSite == http.SameSiteDefaultMode:
+		missing = append(missing, "SameSite")
+	case cookie.SameSite == http.SameSiteNoneMode && !cookie.Secure:
+		missing = append(missing, "Secure (required by SameSite=None)")
+	}
+	return missing
+}
+
+// performCookieTest sends the endpoint's request and returns one result per cookie
+// the response sets, along with the score penalty of the cookies that fail
+func performCookieTest(client *http.Client, endpoint APIEndpoint, config CookiesConfig) ([]TestResult, int, error) {
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkWAF(resp); err != nil {
+		return nil, 0, err
+	}
+
+	ignored := map[string]bool{}
+	for _, name := range config.Ignore {
+		ignored[strings.ToLower(name)] = true
+	}
+	https := req.URL.Scheme == "https"
+
+	var results []TestResult
+	penalty := 0
+	for _, cookie := range resp.Cookies() {
+		if ignored[strings.ToLower(cookie.Name)] {
+			continue
+		}
+		name := cookieFindingName(cookie.Name)
+		if missing := cookieIssues(cookie, https); len(missing) > 0 {
+			results = append(results, TestResult{
+				TestName:   name,
+				Message:    fmt.Sprintf("cookie %s is missing %s", cookie.Name, strings.Join(missing, ", ")),
+				Confidence: confidenceHigh,
+			})
+			penalty += severityPenalty("low")
+			continue
+		}
+		results = append(results, TestResult{TestName: name, Passed: true, Message: name + " Passed"})
+	}
+	if len(results) == 0 {
+		results = append(results, TestResult{TestName: cookieTestName, Passed: true, Message: "no cookies to check"})
+	}
+	return results, penalty, nil
+}
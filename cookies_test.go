@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func cookieServer(cookies ...*http.Cookie) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, cookie := range cookies {
+			http.SetCookie(w, cookie)
+		}
+	}))
+}
+
+func TestCookieTestReportsEachCookie(t *testing.T) {
+	server := cookieServer(
+		&http.Cookie{Name: "session", Value: "a", HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		&http.Cookie{Name: "csrf", Value: "b"},
+		&http.Cookie{Name: "_ga", Value: "c"},
+	)
+	defer server.Close()
+
+	results, penalty, err := performCookieTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, CookiesConfig{Enabled: true, Ignore: []string{"_GA"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected a result per checked cookie, got %+v", results)
+	}
+	if results[0].TestName != "Cookie Security Test [session]" || !results[0].Passed {
+		t.Errorf("Expected the session cookie to pass, got %+v", results[0])
+	}
+	if results[1].TestName != "Cookie Security Test [csrf]" || !results[1].Failed() || results[1].Message != "cookie csrf is missing HttpOnly, SameSite" {
+		t.Errorf("Expected the csrf cookie to fail, got %+v", results[1])
+	}
+	if penalty != severityPenalty("low") {
+		t.Errorf("Expected a penalty of %d, got %d", severityPenalty("low"), penalty)
+	}
+}
+
+func TestCookieTestRequiresSecureOverHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "a", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		http.SetCookie(w, &http.Cookie{Name: "cross", Value: "b", HttpOnly: true, SameSite: http.SameSiteNoneMode})
+	}))
+	defer server.Close()
+
+	results, _, err := performCookieTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, CookiesConfig{Enabled: true})
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Expected two results, got %+v (%v)", results, err)
+	}
+	if results[0].Message != "cookie sid is missing Secure" {
+		t.Errorf("Expected Secure to be required over HTTPS, got %q", results[0].Message)
+	}
+	if results[1].Message != "cookie cross is missing Secure, Secure (required by SameSite=None)" {
+		t.Errorf("Expected SameSite=None without Secure to be reported, got %q", results[1].Message)
+	}
+}
+
+func TestCookieFindingsShareTestMetadata(t *testing.T) {
+	name := cookieFindingName("session")
+	if baseTestName(name) != cookieTestName || testCWE(name) != 1004 || testRemediation(name) == "" {
+		t.Errorf("Expected cookie findings to map to the %s metadata", cookieTestName)
+	}
+	if findingFingerprint("http://x", name) == findingFingerprint("http://x", cookieFindingName("csrf")) {
+		t.Errorf("Expected each cookie to have its own fingerprint")
+	}
+	if baseTestName("Auth Test") != "Auth Test" {
+		t.Errorf("Expected other test names to be unchanged")
+	}
+}
+
+func TestRunTestsCookieFindings(t *testing.T) {
+	server := cookieServer(&http.Cookie{Name: "session", Value: "a"})
+	defer server.Close()
+
+	config := &Config{APIEndpoints: []APIEndpoint{{URL: server.URL, Method: "GET"}}, Cookies: CookiesConfig{Enabled: true}}
+	results := runTests(config)
+	found := false
+	for _, r := range results[0].Results {
+		if r.TestName == "Cookie Security Test [session]" {
+			found = r.Failed() && r.CVSSScore > 0
+		}
+	}
+	if !found {
+		t.Errorf("Expected a scored finding for the session cookie, got %+v", results[0].Results)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// criticalityWeights scale the risk of findings by how important the asset is
+// to the business. Endpoints without a criticality count as medium.
+var criticalityWeights = map[string]float64{
+	"low":      0.5,
+	"medium":   1,
+	"high":     2,
+	"critical": 4,
+}
+
+// severityWeights are the base risk of a finding of each severity
+var severityWeights = map[string]float64{
+	"low":      1,
+	"medium":   4,
+	"high":     7,
+	"critical": 10,
+}
+
+func criticalityWeight(criticality string) float64 {
+	if weight, ok := criticalityWeights[strings.ToLower(criticality)]; ok {
+		return weight
+	}
+	return criticalityWeights["medium"]
+}
+
+// findingRisk weights the severity of a finding by the criticality of its
+// endpoint, so that a medium issue on the payments API (4 x 4 = 16) outranks
+// a high issue on a sandbox endpoint (7 x 0.5 = 3.5)
+func findingRisk(severity, criticality string) float64 {
+	return severityWeights[severity] * criticalityWeight(criticality)
+}
+
+// prioritizedFinding is a failed test ranked by its weighted risk
+type prioritizedFinding struct {
+	URL         string
+	TestName    string
+	Severity    string
+	Criticality string
+	Risk        float64
+}
+
+// prioritizeFindings returns the failed tests of all endpoints, highest risk first
+func prioritizeFindings(results []EndpointResult) []prioritizedFinding {
+	var findings []prioritizedFinding
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+			severity := resultSeverity(testResult)
+			findings = append(findings, prioritizedFinding{
+				URL:         result.URL,
+				TestName:    testResult.TestName,
+				Severity:    severity,
+				Criticality: result.Criticality,
+				Risk:        findingRisk(severity, result.Criticality),
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Risk != findings[j].Risk {
+			return findings[i].Risk > findings[j].Risk
+		}
+		if findings[i].URL != findings[j].URL {
+			return findings[i].URL < findings[j].URL
+		}
+		return findings[i].TestName < findings[j].TestName
+	})
+	return findings
+}
+
+// weightedScore averages the endpoint scores weighted by their criticality
+func weightedScore(results []EndpointResult) int {
+	var total, weights float64
+	for _, result := range results {
+		weight := criticalityWeight(result.Criticality)
+		total += float64(result.Score) * weight
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return int(total/weights + 0.5)
+}
+
+// hasCriticality reports whether any endpoint was given a business criticality
+func hasCriticality(results []EndpointResult) bool {
+	for _, result := range results {
+		if result.Criticality != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPrioritizedFindings(findings []prioritizedFinding, l localizer) string {
+	var b strings.Builder
+	for i, finding := range findings {
+		criticality := finding.Criticality
+		if criticality == "" {
+			criticality = "medium"
+		}
+		b.WriteString(l.T("%d. %s on %s (severity %s, criticality %s, risk %.1f)",
+			i+1, l.T(finding.TestName), finding.URL, l.T(finding.Severity), l.T(criticality), finding.Risk) + "\n")
+	}
+	return b.String()
+}
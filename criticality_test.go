@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrioritizeFindingsByCriticality(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "https://sandbox.example.com/api", Criticality: "low", Results: []TestResult{
+			{TestName: "Auth Test", Passed: false},
+		}},
+		{URL: "https://payments.example.com/api", Criticality: "critical", Results: []TestResult{
+			{TestName: "HTTP Method Test", Passed: false},
+			{TestName: "Injection Test", Passed: true},
+		}},
+		{URL: "https://shop.example.com/api", Results: []TestResult{
+			{TestName: "Injection Test", Passed: false},
+			{TestName: "Auth Test", Skipped: true},
+		}},
+	}
+
+	findings := prioritizeFindings(results)
+	if len(findings) != 3 {
+		t.Fatalf("Expected 3 findings, got %d", len(findings))
+	}
+	expected := []string{"https://payments.example.com/api", "https://shop.example.com/api", "https://sandbox.example.com/api"}
+	for i, url := range expected {
+		if findings[i].URL != url {
+			t.Errorf("Expected finding %d on %s, got %s (risk %.1f)", i, url, findings[i].URL, findings[i].Risk)
+		}
+	}
+	if findings[0].Risk != 16 || findings[2].Risk != 3.5 {
+		t.Errorf("Unexpected risks: %.1f and %.1f", findings[0].Risk, findings[2].Risk)
+	}
+}
+
+func TestWeightedScore(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "https://payments.example.com/api", Criticality: "critical", Score: 50},
+		{URL: "https://sandbox.example.com/api", Criticality: "low", Score: 100},
+	}
+	// (50*4 + 100*0.5) / 4.5
+	if score := weightedScore(results); score != 56 {
+		t.Errorf("Expected weighted score 56, got %d", score)
+	}
+
+	assessment := generateOverallAssessment(results, localizer{})
+	if !strings.Contains(assessment, "Criticality-Weighted Security Score: 56/100") {
+		t.Errorf("Expected weighted score in assessment, got %q", assessment)
+	}
+	if !strings.Contains(assessment, "Significant security risks identified") {
+		t.Errorf("Expected the weighted score to decide the posture, got %q", assessment)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// defaultCVSSVectors are the CVSS 3.1 base vectors of each built-in test,
+// overridable per test name with the cvss configuration option
+var defaultCVSSVectors = map[string]string{
+	"Auth Test":                "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:L/A:N",
+	"Default Credentials Test": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+	"HTTP Method Test":         "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:L/A:N",
+	"Injection Test":           "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+	"Security Headers Test":    "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:L/A:N",
+	cookieTestName:             "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:L/A:N",
+	fuzzTestName:               "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:L",
+	exposedServicesTestName:    "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:L",
+	openPortsTestName:          "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	dnsTestName:                "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:L/A:N",
+	urlTokensTestName:          "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:L/A:N",
+	sensitiveDataTestName:      "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+	cloudIAMTestName:           "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:N",
+	oidcTestName:               "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:H/I:H/A:N",
+}
+
+// cvssWeights are the CVSS 3.1 base metric weights. Privileges Required has
+// different weights when the scope changes, keyed "PR:C".
+var cvssWeights = map[string]map[string]float64{
+	"AV":   {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC":   {"L": 0.77, "H": 0.44},
+	"PR":   {"N": 0.85, "L": 0.62, "H": 0.27},
+	"PR:C": {"N": 0.85, "L": 0.68, "H": 0.5},
+	"UI":   {"N": 0.85, "R": 0.62},
+	"S":    {"U": 0, "C": 0},
+	"C":    {"H": 0.56, "L": 0.22, "N": 0},
+	"I":    {"H": 0.56, "L": 0.22, "N": 0},
+	"A":    {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvssBaseScore parses a CVSS 3.1 vector and returns its base score
+func cvssBaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || (parts[0] != "CVSS:3.1" && parts[0] != "CVSS:3.0") {
+		return 0, fmt.Errorf("invalid CVSS vector %q: must start with CVSS:3.1", vector)
+	}
+
+	metrics := map[string]string{}
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			return 0, fmt.Errorf("invalid CVSS vector %q: malformed metric %q", vector, part)
+		}
+		if _, ok := cvssWeights[kv[0]][kv[1]]; !ok {
+			return 0, fmt.Errorf("invalid CVSS vector %q: unknown metric %q", vector, part)
+		}
+		metrics[kv[0]] = kv[1]
+	}
+	for _, metric := range []string{"AV", "AC", "PR", "UI", "S", "C", "I", "A"} {
+		if metrics[metric] == "" {
+			return 0, fmt.Errorf("invalid CVSS vector %q: missing base metric %s", vector, metric)
+		}
+	}
+
+	changed := metrics["S"] == "C"
+	privileges := cvssWeights["PR"][metrics["PR"]]
+	if changed {
+		privileges = cvssWeights["PR:C"][metrics["PR"]]
+	}
+
+	iss := 1 - (1-cvssWeights["C"][metrics["C"]])*(1-cvssWeights["I"][metrics["I"]])*(1-cvssWeights["A"][metrics["A"]])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * cvssWeights["AV"][metrics["AV"]] * cvssWeights["AC"][metrics["AC"]] * privileges * cvssWeights["UI"][metrics["UI"]]
+
+	if impact <= 0 {
+		return 0, nil
+	}
+	if changed {
+		return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return cvssRoundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// cvssRoundUp rounds up to one decimal as defined in CVSS 3.1 appendix A,
+// avoiding floating point artifacts such as 4.000000001 rounding up to 4.1
+func cvssRoundUp(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+// cvssSeverity returns the qualitative rating of a CVSS score
+func cvssSeverity(score float64) string {
+	switch {
+	case score == 0:
+		return "none"
+	case score < 4:
+		return "low"
+	case score < 7:
+		return "medium"
+	case score < 9:
+		return "high"
+	default:
+		return "critical"
+	}
+}
+
+// validateCVSSOverrides checks the vectors configured with the cvss option
+func validateCVSSOverrides(overrides map[string]string) error {
+	for testName, vector := range overrides {
+		if _, err := cvssBaseScore(vector); err != nil {
+			return fmt.Errorf("cvss override for %q: %v", testName, err)
+		}
+	}
+	return nil
+}
+
+// applyCVSS attaches the CVSS vector and base score to every failed test with a known vector
+func applyCVSS(results []EndpointResult, overrides map[string]string) {
+	for i := range results {
+		for j := range results[i].Results {
+			testResult := &results[i].Results[j]
+			if !testResult.Failed() {
+				continue
+			}
+			vector, ok := overrides[testResult.TestName]
+			if !ok {
+				vector, ok = defaultCVSSVectors[baseTestName(testResult.TestName)]
+			}
+			if !ok {
+				continue
+			}
+			if score, err := cvssBaseScore(vector); err == nil {
+				testResult.CVSSVector = vector
+				testResult.CVSSScore = score
+			}
+		}
+	}
+}
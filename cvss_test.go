@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestCVSSBaseScore(t *testing.T) {
+	tests := map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10.0,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N": 6.1,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:L/A:N": 6.5,
+		"CVSS:3.1/AV:L/AC:H/PR:H/UI:R/S:U/C:N/I:N/A:N": 0,
+		"CVSS:3.1/AV:P/AC:H/PR:L/UI:R/S:U/C:L/I:N/A:N": 1.7,
+	}
+	for vector, expected := range tests {
+		score, err := cvssBaseScore(vector)
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %v", vector, err)
+		} else if score != expected {
+			t.Errorf("Expected %s to score %.1f, got %.1f", vector, expected, score)
+		}
+	}
+
+	for _, vector := range []string{"AV:N/AC:L", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"} {
+		if _, err := cvssBaseScore(vector); err == nil {
+			t.Errorf("Expected an error for %s", vector)
+		}
+	}
+}
+
+func TestApplyCVSS(t *testing.T) {
+	results := []EndpointResult{{URL: "http://example.com/a", Results: []TestResult{
+		{TestName: "Injection Test", Passed: false},
+		{TestName: "Auth Test", Passed: false},
+		{TestName: "HTTP Method Test", Passed: true},
+		{TestName: "custom-plugin", Passed: false},
+	}}}
+	applyCVSS(results, map[string]string{"Auth Test": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:L/A:N"})
+
+	tests := results[0].Results
+	if tests[0].CVSSScore != 9.8 || tests[0].CVSSVector != defaultCVSSVectors["Injection Test"] {
+		t.Errorf("Expected default injection vector, got %q %.1f", tests[0].CVSSVector, tests[0].CVSSScore)
+	}
+	if tests[1].CVSSScore != 6.5 {
+		t.Errorf("Expected overridden auth score 6.5, got %.1f", tests[1].CVSSScore)
+	}
+	if tests[2].CVSSVector != "" || tests[3].CVSSVector != "" {
+		t.Errorf("Expected no CVSS for passed tests or tests without a vector")
+	}
+
+	if err := validateCVSSOverrides(map[string]string{"Auth Test": "high"}); err == nil {
+		t.Errorf("Expected an invalid override to be rejected")
+	}
+}
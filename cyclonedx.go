@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// cycloneDXBOM represents a CycloneDX 1.5 vulnerability disclosure report
+type cycloneDXBOM struct {
+	BOMFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	SerialNumber    string                   `json:"serialNumber"`
+	Version         int                      `json:"version"`
+	Metadata        cycloneDXMetadata        `json:"metadata"`
+	Services        []cycloneDXService       `json:"services"`
+	Vulnerabilities []cycloneDXVulnerability `json:"vulnerabilities"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string         `json:"timestamp"`
+	Tools     cycloneDXTools `json:"tools"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type cycloneDXService struct {
+	BOMRef    string   `json:"bom-ref"`
+	Name      string   `json:"name"`
+	Endpoints []string `json:"endpoints"`
+}
+
+type cycloneDXVulnerability struct {
+	BOMRef         string              `json:"bom-ref"`
+	ID             string              `json:"id"`
+	Source         cycloneDXSource     `json:"source"`
+	Ratings        []cycloneDXRating   `json:"ratings"`
+	CWEs           []int               `json:"cwes,omitempty"`
+	Description    string              `json:"description"`
+	Recommendation string              `json:"recommendation"`
+	Analysis       cycloneDXAnalysis   `json:"analysis"`
+	Affects        []cycloneDXAffected `json:"affects"`
+}
+
+type cycloneDXSource struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXRating struct {
+	Score    float64 `json:"score,omitempty"`
+	Severity string  `json:"severity"`
+	Method   string  `json:"method"`
+	Vector   string  `json:"vector,omitempty"`
+}
+
+type cycloneDXAnalysis struct {
+	State  string `json:"state"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type cycloneDXAffected struct {
+	Ref string `json:"ref"`
+}
+
+// testCWE returns the CWE identifier that best describes failures of the given test
+func testCWE(testName string) int {
+	switch baseTestName(testName) {
+	case "Auth Test":
+		return 287
+	case "HTTP Method Test":
+		return 650
+	case "Injection Test":
+		return 89
+	case "Default Credentials Test":
+		return 1392
+	case "Security Headers Test":
+		return 693
+	case cookieTestName:
+		return 1004
+	case fuzzTestName:
+		return 248
+	case exposedServicesTestName:
+		return 306
+	case openPortsTestName:
+		return 668
+	case dnsTestName:
+		return 350
+	case cloudIAMTestName:
+		return 287
+	case oidcTestName:
+		return 1390
+	case urlTokensTestName:
+		return 598
+	case sensitiveDataTestName:
+		return 200
+	default:
+		return 0
+	}
+}
+
+// cycloneDXReport builds a CycloneDX document with one service per endpoint and
+// one vulnerability per failed test, marked exploitable in VEX terms
+func cycloneDXReport(results []EndpointResult, timestamp time.Time) (cycloneDXBOM, error) {
+	serial, err := newUUID()
+	if err != nil {
+		return cycloneDXBOM{}, err
+	}
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{Components: []cycloneDXComponent{
+				{Type: "application", Name: "api-security-scanner"},
+			}},
+		},
+		Services:        []cycloneDXService{},
+		Vulnerabilities: []cycloneDXVulnerability{},
+	}
+
+	for _, result := range results {
+		serviceRef := "service-" + findingFingerprint(result.key(), "")[:16]
+		bom.Services = append(bom.Services, cycloneDXService{
+			BOMRef:    serviceRef,
+			Name:      result.URL,
+			Endpoints: []string{result.URL},
+		})
+
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+
+			fingerprint := findingFingerprint(result.key(), testResult.TestName)
+			vulnerability := cycloneDXVulnerability{
+				BOMRef:         "vuln-" + fingerprint[:16],
+				ID:             "APISEC-" + fingerprint[:12],
+				Source:         cycloneDXSource{Name: "api-security-scanner"},
+				Ratings:        []cycloneDXRating{{Severity: resultSeverity(testResult), Method: "other"}},
+				Description:    fmt.Sprintf("%s: %s", testResult.TestName, testResult.Message),
+				Recommendation: testRemediation(testResult.TestName),
+				Analysis:       cycloneDXAnalysis{State: "exploitable", Detail: "Reproduced by an active scan of the endpoint."},
+				Affects:        []cycloneDXAffected{{Ref: serviceRef}},
+			}
+			if testResult.CVSSVector != "" {
+				vulnerability.Ratings = append(vulnerability.Ratings, cycloneDXRating{
+					Score:    testResult.CVSSScore,
+					Severity: cvssSeverity(testResult.CVSSScore),
+					Method:   "CVSSv31",
+					Vector:   testResult.CVSSVector,
+				})
+			}
+			if cwe := testCWE(testResult.TestName); cwe != 0 {
+				vulnerability.CWEs = []int{cwe}
+			}
+			bom.Vulnerabilities = append(bom.Vulnerabilities, vulnerability)
+		}
+	}
+	return bom, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate UUID: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
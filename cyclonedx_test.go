@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCycloneDXReport(t *testing.T) {
+	bom, err := cycloneDXReport(exportTestResults(), time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if bom.BOMFormat != "CycloneDX" || bom.Metadata.Timestamp != "2024-09-25T00:00:00Z" {
+		t.Errorf("Unexpected BOM header: %+v", bom)
+	}
+	if len(bom.Services) != 3 {
+		t.Errorf("Expected 3 services, got %d", len(bom.Services))
+	}
+	if len(bom.Vulnerabilities) != 2 {
+		t.Fatalf("Expected 2 vulnerabilities, got %d", len(bom.Vulnerabilities))
+	}
+
+	injection := bom.Vulnerabilities[0]
+	if len(injection.CWEs) != 1 || injection.CWEs[0] != 89 {
+		t.Errorf("Expected CWE-89, got %v", injection.CWEs)
+	}
+	if len(injection.Affects) != 1 || injection.Affects[0].Ref != bom.Services[0].BOMRef {
+		t.Errorf("Expected vulnerability to affect the first service, got %v", injection.Affects)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	uuid, err := newUUID()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Errorf("Expected a version 4 UUID, got %s", uuid)
+	}
+}
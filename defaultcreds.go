@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCredentialsDelay       = time.Second
+	defaultCredentialsMaxAttempts = 10
+)
+
+// DefaultCredentialsConfig represents the opt-in check for default credentials on Basic-auth endpoints
+type DefaultCredentialsConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Credentials []Credential  `yaml:"credentials"`
+	Delay       time.Duration `yaml:"delay"`
+	MaxAttempts int           `yaml:"max_attempts"`
+}
+
+// Credential is a username and password pair
+type Credential struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// defaultCredentialList is tried when no credentials are configured
+var defaultCredentialList = []Credential{
+	{Username: "admin", Password: "admin"},
+	{Username: "admin", Password: "password"},
+	{Username: "test", Password: "test"},
+	{Username: "root", Password: "root"},
+	{Username: "guest", Password: "guest"},
+	{Username: "user", Password: "user"},
+}
+
+// DefaultCredentialsError is returned when an endpoint accepts default credentials
+type DefaultCredentialsError struct{ message string }
+
+func (e DefaultCredentialsError) Error() string { return e.message }
+
+// LockoutError is returned when the target starts locking accounts, so that the check stops
+type LockoutError struct{ message string }
+
+func (e LockoutError) Error() string { return e.message }
+
+// defaultCredentials returns the credentials to try, capped at the maximum number of attempts
+func defaultCredentials(config DefaultCredentialsConfig) []Credential {
+	credentials := config.Credentials
+	if len(credentials) == 0 {
+		credentials = defaultCredentialList
+	}
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultCredentialsMaxAttempts
+	}
+	if len(credentials) > maxAttempts {
+		credentials = credentials[:maxAttempts]
+	}
+	return credentials
+}
+
+// performDefaultCredentialsTest tries the configured credentials one at a time
+// against endpoints that ask for Basic authentication. Attempts are spaced by
+// the configured delay and stop as soon as the target shows signs of locking
+// the account.
+func performDefaultCredentialsTest(client *http.Client, endpoint APIEndpoint, config DefaultCredentialsConfig) error {
+	credentials := defaultCredentials(config)
+	delay := config.Delay
+	if delay <= 0 {
+		delay = defaultCredentialsDelay
+	}
+
+	resp, body, err := sendCredentialAttempt(client, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if err := checkWAFBody(resp, body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(strings.ToLower(resp.Header.Get("WWW-Authenticate")), "basic") {
+		return nil // not a Basic-auth endpoint
+	}
+
+	for i, credential := range credentials {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		credential := credential
+		resp, body, err := sendCredentialAttempt(client, endpoint, &credential)
+		if err != nil {
+			return err
+		}
+		// A lockout answered with 403 or 429 can look like a WAF block
+		if reason := lockoutReason(resp, body); reason != "" {
+			return LockoutError{fmt.Sprintf("stopped after %d attempt(s): %s", i+1, reason)}
+		}
+		if err := checkWAFBody(resp, body); err != nil {
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return DefaultCredentialsError{fmt.Sprintf("endpoint accepts default credentials %s/%s", credential.Username, credential.Password)}
+		}
+	}
+	return nil
+}
+
+func sendCredentialAttempt(client *http.Client, endpoint APIEndpoint, credential *Credential) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, strings.NewReader(endpoint.Body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	// The configured auth would replace the credentials tried, or add some to the
+	// baseline, and a retried attempt would try them again against a rate limit
+	req = withoutRetries(withOwnCredentials(req))
+	if credential != nil {
+		req.SetBasicAuth(credential.Username, credential.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return resp, body, nil
+}
+
+// lockoutReason returns why a failed attempt looks like an account lockout or rate limit, or ""
+func lockoutReason(resp *http.Response, body []byte) string {
+	switch {
+	case resp.StatusCode == http.StatusLocked:
+		return "the account is locked (423)"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "the target is rate limiting login attempts (429)"
+	case resp.StatusCode == http.StatusForbidden:
+		// The endpoint answered 401 before any attempt, so 403 means the account was blocked
+		return "the target started rejecting attempts with 403"
+	case resp.Header.Get("Retry-After") != "":
+		return "the target asked to retry later"
+	}
+	lower := strings.ToLower(string(body))
+	for _, marker := range []string{"account locked", "account is locked", "too many attempts", "too many failed"} {
+		if strings.Contains(lower, marker) {
+			return "the response reports " + marker
+		}
+	}
+	return ""
+}
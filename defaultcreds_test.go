@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func basicAuthServer(username, password string, attempts *int32, lockAfter int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if ok {
+			if n := atomic.AddInt32(attempts, 1); lockAfter > 0 && n > lockAfter {
+				w.WriteHeader(http.StatusLocked)
+				return
+			}
+		}
+		if !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="api"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestDefaultCredentialsAccepted(t *testing.T) {
+	var attempts int32
+	server := basicAuthServer("test", "test", &attempts, 0)
+	defer server.Close()
+
+	config := DefaultCredentialsConfig{Delay: time.Millisecond}
+	err := performDefaultCredentialsTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, config)
+	if _, ok := err.(DefaultCredentialsError); !ok || err.Error() != "endpoint accepts default credentials test/test" {
+		t.Errorf("Expected test/test to be flagged, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected the check to stop at the accepted pair, got %d attempts", attempts)
+	}
+}
+
+func TestDefaultCredentialsRejected(t *testing.T) {
+	var attempts int32
+	server := basicAuthServer("admin", "S3cure!", &attempts, 0)
+	defer server.Close()
+
+	config := DefaultCredentialsConfig{Delay: time.Millisecond, MaxAttempts: 2}
+	if err := performDefaultCredentialsTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, config); err != nil {
+		t.Errorf("Expected no finding, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected max_attempts to cap the attempts, got %d", attempts)
+	}
+}
+
+func TestDefaultCredentialsLockout(t *testing.T) {
+	var attempts int32
+	server := basicAuthServer("guest", "guest", &attempts, 2)
+	defer server.Close()
+
+	err := performDefaultCredentialsTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, DefaultCredentialsConfig{Delay: time.Millisecond})
+	result := newTestResult("Default Credentials Test", err, 0)
+	if !result.Skipped || result.Message != "stopped after 3 attempt(s): the account is locked (423)" {
+		t.Errorf("Expected the check to stop on lockout, got %+v", result)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected no attempts after the lockout, got %d", attempts)
+	}
+}
+
+func TestDefaultCredentialsSkipsNonBasicEndpoints(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			atomic.AddInt32(&attempts, 1)
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if err := performDefaultCredentialsTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, DefaultCredentialsConfig{}); err != nil || attempts != 0 {
+		t.Errorf("Expected Bearer endpoints to be left alone, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestDefaultCredentialsStopsOnRateLimitWithoutRetrying(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			atomic.AddInt32(&attempts, 1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="api"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	throttle := newThrottleTransport(http.DefaultTransport)
+	client := &http.Client{Transport: throttle}
+	err := performDefaultCredentialsTest(client, APIEndpoint{URL: server.URL, Method: "GET"}, DefaultCredentialsConfig{Delay: time.Millisecond})
+	if _, ok := err.(LockoutError); !ok || err.Error() != "stopped after 1 attempt(s): the target is rate limiting login attempts (429)" {
+		t.Errorf("Expected the check to stop on the rate limit, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected the rate limited attempt not to be retried, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	dnsTestName = "DNS Security Test"
+
+	defaultDNSTimeout = 3 * time.Second
+
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsTypeDS    = 43
+	dnsTypeCAA   = 257
+
+	dnsRcodeNXDomain = 3
+)
+
+// DNSConfig represents the opt-in check of the DNS records of each endpoint's host
+type DNSConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Resolver string        `yaml:"resolver"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// DNSSecurityError lists the DNS issues found for a host
+type DNSSecurityError struct {
+	message string
+	penalty int
+}
+
+func (e DNSSecurityError) Error() string { return e.message }
+
+// dnsIssue is a weakness of a host's DNS records and its severity
+type dnsIssue struct {
+	message  string
+	severity string
+}
+
+// dnsRecord is a resource record of a DNS answer; Target is set for CNAME records
+type dnsRecord struct {
+	Type   uint16
+	Target string
+}
+
+// dnsResponse is the response code and answer records of a DNS query
+type dnsResponse struct {
+	Rcode   int
+	Answers []dnsRecord
+}
+
+func (r dnsResponse) has(recordType uint16) bool {
+	for _, answer := range r.Answers {
+		if answer.Type == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// dnsResolver returns the configured resolver, or the first nameserver of /etc/resolv.conf
+func dnsResolver(config DNSConfig) (string, error) {
+	if config.Resolver != "" {
+		if _, _, err := net.SplitHostPort(config.Resolver); err != nil {
+			return net.JoinHostPort(config.Resolver, "53"), nil
+		}
+		return config.Resolver, nil
+	}
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("no dns.resolver configured and /etc/resolv.conf is unreadable: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no dns.resolver configured and no nameserver in /etc/resolv.conf")
+}
+
+// dnsQuery sends a recursive query for one record type over UDP, retrying
+// over TCP when the response is truncated
+func dnsQuery(server, name string, recordType uint16, timeout time.Duration) (dnsResponse, error) {
+	id := make([]byte, 2)
+	rand.Read(id)
+	query := append(id, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, byte(recordType>>8), byte(recordType), 0, 1)
+
+	message, err := dnsExchange("udp", server, query, timeout)
+	if err == nil && len(message) > 2 && message[2]&0x02 != 0 {
+		message, err = dnsExchange("tcp", server, query, timeout)
+	}
+	if err != nil {
+		return dnsResponse{}, fmt.Errorf("DNS query for %s failed: %v", name, err)
+	}
+	if len(message) < 12 || message[0] != id[0] || message[1] != id[1] {
+		return dnsResponse{}, fmt.Errorf("DNS query for %s failed: unexpected response", name)
+	}
+	return parseDNSResponse(message)
+}
+
+func dnsExchange(network, server string, query []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout(network, server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		return buf[:n], err
+	}
+	length := []byte{byte(len(query) >> 8), byte(len(query))}
+	if _, err := conn.Write(append(length, query...)); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, err
+	}
+	message := make([]byte, binary.BigEndian.Uint16(length))
+	_, err = io.ReadFull(conn, message)
+	return message, err
+}
+
+func parseDNSResponse(message []byte) (dnsResponse, error) {
+	response := dnsResponse{Rcode: int(message[3] & 0x0f)}
+	questions := int(binary.BigEndian.Uint16(message[4:]))
+	answers := int(binary.BigEndian.Uint16(message[6:]))
+
+	offset := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readDNSName(message, offset)
+		if err != nil {
+			return dnsResponse{}, err
+		}
+		offset = next + 4
+	}
+	for i := 0; i < answers; i++ {
+		_, next, err := readDNSName(message, offset)
+		if err != nil {
+			return dnsResponse{}, err
+		}
+		if next+10 > len(message) {
+			return dnsResponse{}, fmt.Errorf("truncated DNS answer")
+		}
+		record := dnsRecord{Type: binary.BigEndian.Uint16(message[next:])}
+		length := int(binary.BigEndian.Uint16(message[next+8:]))
+		offset = next + 10 + length
+		if offset > len(message) {
+			return dnsResponse{}, fmt.Errorf("truncated DNS answer")
+		}
+		if record.Type == dnsTypeCNAME {
+			if record.Target, _, err = readDNSName(message, next+10); err != nil {
+				return dnsResponse{}, err
+			}
+		}
+		response.Answers = append(response.Answers, record)
+	}
+	return response, nil
+}
+
+// readDNSName reads a possibly compressed name and returns it with the offset that follows it
+func readDNSName(message []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; hops < 64; hops++ {
+		if offset >= len(message) {
+			return "", 0, fmt.Errorf("truncated DNS name")
+		}
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(message) {
+				return "", 0, fmt.Errorf("truncated DNS name")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:]) & 0x3fff)
+		default:
+			if offset+1+length > len(message) {
+				return "", 0, fmt.Errorf("truncated DNS name")
+			}
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, fmt.Errorf("DNS name compression loop")
+}
+
+// dnsAncestors returns a name and its parent domains, without the top-level domain
+func dnsAncestors(name string) []string {
+	var names []string
+	for strings.Contains(name, ".") {
+		names = append(names, name)
+		name = name[strings.Index(name, ".")+1:]
+	}
+	return names
+}
+
+// checkDNSPosture looks for a dangling CNAME, for a missing CAA record and
+// DNSSEC signature on the host or any parent domain, and for wildcard records
+// covering the host
+func checkDNSPosture(host string, config DNSConfig, guard *blackoutGuard) ([]dnsIssue, error) {
+	server, err := dnsResolver(config)
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+	query := func(name string, recordType uint16) (dnsResponse, error) {
+		if err := guard.check(); err != nil {
+			return dnsResponse{}, err
+		}
+		return dnsQuery(server, name, recordType, timeout)
+	}
+	var issues []dnsIssue
+
+	// A CNAME to a name that no longer exists can be claimed by whoever registers it
+	cname, err := query(host, dnsTypeCNAME)
+	if err != nil {
+		return nil, err
+	}
+	for _, answer := range cname.Answers {
+		if answer.Type != dnsTypeCNAME {
+			continue
+		}
+		target, err := query(answer.Target, dnsTypeA)
+		if err != nil {
+			return nil, err
+		}
+		if target.Rcode == dnsRcodeNXDomain {
+			issues = append(issues, dnsIssue{fmt.Sprintf("dangling CNAME %s -> %s, which does not resolve (subdomain takeover risk)", host, answer.Target), "high"})
+		}
+	}
+
+	for _, check := range []struct {
+		recordType uint16
+		message    string
+	}{
+		{dnsTypeCAA, "no CAA record restricts which CAs may issue certificates for %s"},
+		{dnsTypeDS, "%s is not signed with DNSSEC"},
+	} {
+		found := false
+		for _, name := range dnsAncestors(host) {
+			response, err := query(name, check.recordType)
+			if err != nil {
+				return nil, err
+			}
+			if found = response.has(check.recordType); found {
+				break
+			}
+		}
+		if !found {
+			issues = append(issues, dnsIssue{fmt.Sprintf(check.message, host), "low"})
+		}
+	}
+
+	// A random name answering under the host or its parent means a wildcard record
+	random := make([]byte, 8)
+	rand.Read(random)
+	label := "wildcard-check-" + hex.EncodeToString(random)
+	names := dnsAncestors(host)
+	if len(names) > 2 {
+		names = names[:2]
+	}
+	for _, name := range names {
+		response, err := query(label+"."+name, dnsTypeA)
+		if err != nil {
+			return nil, err
+		}
+		if response.has(dnsTypeA) || response.has(dnsTypeAAAA) || response.has(dnsTypeCNAME) {
+			issues = append(issues, dnsIssue{fmt.Sprintf("wildcard record *.%s", name), "low"})
+			break
+		}
+	}
+	return issues, nil
+}
+
+// performDNSSecurityTest checks the DNS records of the endpoint's host until a
+// blackout window opens
+func performDNSSecurityTest(host string, config DNSConfig, guard *blackoutGuard) error {
+	issues, err := checkDNSPosture(host, config, guard)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	var messages []string
+	penalty := 0
+	for _, issue := range issues {
+		messages = append(messages, fmt.Sprintf("%s (%s)", issue.message, issue.severity))
+		penalty += severityPenalty(issue.severity)
+	}
+	return DNSSecurityError{message: strings.Join(messages, "; "), penalty: penalty}
+}
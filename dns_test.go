@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeDNSRecord is an answer of the fake resolver; target is the name of a CNAME
+type fakeDNSRecord struct {
+	recordType uint16
+	target     string
+}
+
+func encodeDNSName(name string) []byte {
+	var encoded []byte
+	for _, label := range strings.Split(name, ".") {
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	return append(encoded, 0)
+}
+
+// serveDNS answers queries over UDP from records keyed by name and type.
+// Names matching nxdomain get NXDOMAIN, and names starting with "*." match any
+// first label.
+func serveDNS(t *testing.T, records map[string][]fakeDNSRecord, nxdomain []string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			name, next, err := readDNSName(query, 12)
+			if err != nil {
+				continue
+			}
+			recordType := binary.BigEndian.Uint16(query[next:])
+			answers, ok := records[name+"|"+dnsTypeNames[recordType]]
+			if !ok && strings.Contains(name, ".") {
+				answers = records["*."+name[strings.Index(name, ".")+1:]+"|"+dnsTypeNames[recordType]]
+			}
+			rcode := byte(0)
+			for _, missing := range nxdomain {
+				if name == missing {
+					rcode = dnsRcodeNXDomain
+				}
+			}
+
+			response := append([]byte{}, query[:2]...)
+			response = append(response, 0x81, 0x80|rcode, 0, 1, 0, byte(len(answers)), 0, 0, 0, 0)
+			response = append(response, query[12:next+4]...)
+			for _, answer := range answers {
+				data := []byte{127, 0, 0, 1}
+				if answer.recordType == dnsTypeCNAME {
+					data = encodeDNSName(answer.target)
+				}
+				response = append(response, 0xc0, 0x0c, byte(answer.recordType>>8), byte(answer.recordType), 0, 1, 0, 0, 0, 60, 0, byte(len(data)))
+				response = append(response, data...)
+			}
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+var dnsTypeNames = map[uint16]string{dnsTypeA: "A", dnsTypeCNAME: "CNAME", dnsTypeAAAA: "AAAA", dnsTypeDS: "DS", dnsTypeCAA: "CAA"}
+
+func TestDNSSecurityTestPassesForWellConfiguredDomain(t *testing.T) {
+	resolver := serveDNS(t, map[string][]fakeDNSRecord{
+		"api.example.com|A":  {{recordType: dnsTypeA}},
+		"example.com|CAA":    {{recordType: dnsTypeCAA}},
+		"example.com|DS":     {{recordType: dnsTypeDS}},
+		"api.example.com|DS": nil,
+	}, nil)
+
+	if err := performDNSSecurityTest("api.example.com", DNSConfig{Resolver: resolver}, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestDNSSecurityTestReportsIssues(t *testing.T) {
+	resolver := serveDNS(t, map[string][]fakeDNSRecord{
+		"api.example.com|CNAME": {{recordType: dnsTypeCNAME, target: "old-app.cloudprovider.net"}},
+		"*.example.com|A":       {{recordType: dnsTypeA}},
+	}, []string{"old-app.cloudprovider.net"})
+
+	err := performDNSSecurityTest("api.example.com", DNSConfig{Resolver: resolver}, nil)
+	var dnsErr DNSSecurityError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("Expected a DNSSecurityError, got %v", err)
+	}
+	for _, expected := range []string{"dangling CNAME api.example.com -> old-app.cloudprovider.net", "no CAA record", "not signed with DNSSEC", "wildcard record *.example.com"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected the error to report %q, got %q", expected, err.Error())
+		}
+	}
+	if expected := severityPenalty("high") + 3*severityPenalty("low"); dnsErr.penalty != expected {
+		t.Errorf("Expected penalty %d, got %d", expected, dnsErr.penalty)
+	}
+}
+
+func TestReadDNSNameFollowsCompression(t *testing.T) {
+	message := append(make([]byte, 12), encodeDNSName("example.com")...)
+	message = append(message, 3, 'a', 'p', 'i', 0xc0, 12)
+	name, next, err := readDNSName(message, 25)
+	if err != nil || name != "api.example.com" || next != len(message) {
+		t.Errorf("Expected api.example.com ending at %d, got %q at %d (%v)", len(message), name, next, err)
+	}
+
+	loop := append(make([]byte, 12), 0xc0, 12)
+	if _, _, err := readDNSName(loop, 12); err == nil {
+		t.Errorf("Expected an error for a compression loop")
+	}
+}
+
+func TestDNSAncestors(t *testing.T) {
+	names := dnsAncestors("v1.api.example.com")
+	if strings.Join(names, ",") != "v1.api.example.com,api.example.com,example.com" {
+		t.Errorf("Unexpected ancestors: %v", names)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// errNoEnvironmentEndpoints is returned by applyEnvironment when no endpoint is
+// defined for the selected environment
+var errNoEnvironmentEndpoints = errors.New("no endpoint is defined for environment")
+
+// applyEnvironment sets the URL of the endpoints defined per environment to
+// the URL of the selected one. Endpoints with no URL for it are left out, and
+// endpoints without environments are scanned in every environment.
+func applyEnvironment(endpoints []APIEndpoint, environment string) ([]APIEndpoint, error) {
+	var selected []APIEndpoint
+	for _, endpoint := range endpoints {
+		if len(endpoint.Environments) == 0 {
+			selected = append(selected, endpoint)
+			continue
+		}
+		if environment == "" {
+			if endpoint.URL == "" {
+				return nil, fmt.Errorf("endpoint %s is defined per environment (%s); select one with -env or environment",
+					endpoint.Name, strings.Join(environmentNames(endpoint), ", "))
+			}
+			selected = append(selected, endpoint)
+			continue
+		}
+		if u, ok := endpoint.Environments[environment]; ok {
+			endpoint.URL = u
+			selected = append(selected, endpoint)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w %q", errNoEnvironmentEndpoints, environment)
+	}
+	return selected, nil
+}
+
+func environmentNames(endpoint APIEndpoint) []string {
+	names := make([]string, 0, len(endpoint.Environments))
+	for name := range endpoint.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// endpointKey identifies an endpoint in fingerprints and feedback: its logical
+// name if it has one, so that findings keep their identity when its URL changes
+func endpointKey(name, url string) string {
+	if name != "" {
+		return name
+	}
+	return url
+}
+
+// key returns the endpointKey of the result's endpoint
+func (r EndpointResult) key() string {
+	return endpointKey(r.Name, r.URL)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var environmentEndpoints = []APIEndpoint{
+	{Name: "users", Environments: map[string]string{"staging": "https://staging.example.com/users", "prod": "https://api.example.com/users"}},
+	{Name: "debug", Environments: map[string]string{"staging": "https://staging.example.com/debug"}},
+	{URL: "https://status.example.com/health"},
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	endpoints, err := applyEnvironment(environmentEndpoints, "prod")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].URL != "https://api.example.com/users" || endpoints[1].URL != "https://status.example.com/health" {
+		t.Errorf("Expected the prod URL and the shared endpoint, got %+v", endpoints)
+	}
+	if environmentEndpoints[0].URL != "" {
+		t.Errorf("Expected the configured endpoints to be left unchanged")
+	}
+
+	if _, err := applyEnvironment(environmentEndpoints, ""); err == nil || !strings.Contains(err.Error(), "(prod, staging)") {
+		t.Errorf("Expected an error listing the environments, got %v", err)
+	}
+	if _, err := applyEnvironment(environmentEndpoints[:2], "dev"); err == nil {
+		t.Errorf("Expected an error when no endpoint is defined for the environment")
+	}
+}
+
+func TestFingerprintsFollowEndpointName(t *testing.T) {
+	staging := EndpointResult{Name: "users", URL: "https://staging.example.com/users"}
+	prod := EndpointResult{Name: "users", URL: "https://api.example.com/users"}
+	if findingFingerprint(staging.key(), "Auth Test") != findingFingerprint(prod.key(), "Auth Test") {
+		t.Errorf("Expected findings of a named endpoint to keep their fingerprint across URLs")
+	}
+	if unnamed := (EndpointResult{URL: "https://api.example.com/users"}); unnamed.key() != unnamed.URL {
+		t.Errorf("Expected unnamed endpoints to be keyed by URL")
+	}
+}
+
+func TestValidateConfigEnvironments(t *testing.T) {
+	config := &Config{APIEndpoints: []APIEndpoint{
+		{Environments: map[string]string{"prod": "https://api.example.com"}},
+		{Name: "orders", Environments: map[string]string{"prod": "api.example.com/orders"}},
+	}}
+	problems := validateConfig(config)
+	expected := []string{
+		"api_endpoints[0]: endpoints with environments need a name",
+		`api_endpoints[1]: url "api.example.com/orders" must be an absolute http or https URL`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, problems)
+	}
+}
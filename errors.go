@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Sentinel errors that classify why a scan or a test could not complete, so
+// that callers check them with errors.Is instead of matching messages. The
+// scanner's error types report their class through an Is method.
+var (
+	ErrTargetUnreachable = errors.New("target unreachable")
+	ErrAuthFailed        = errors.New("authentication failed")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrConfigInvalid     = errors.New("invalid configuration")
+	ErrTestPanicked      = errors.New("test panicked")
+	ErrTestErrored       = errors.New("test errored")
+)
+
+// Exit codes of the scanner besides 0, and 1 for findings in CI mode and other failures
+const (
+	exitConfigInvalid     = 2
+	exitTargetUnreachable = 3
+	exitAuthFailed        = 4
+	exitRateLimited       = 5
+	exitTestPanicked      = 6
+	exitTestErrored       = 7
+)
+
+// errorClasses maps each sentinel error to the error code recorded in test
+// results and to its exit code, in order of precedence
+var errorClasses = []struct {
+	err  error
+	code string
+	exit int
+}{
+	{ErrConfigInvalid, "config_invalid", exitConfigInvalid},
+	{ErrTargetUnreachable, "target_unreachable", exitTargetUnreachable},
+	{ErrAuthFailed, "auth_failed", exitAuthFailed},
+	{ErrRateLimited, "rate_limited", exitRateLimited},
+	{ErrTestPanicked, "test_panicked", exitTestPanicked},
+	{ErrTestErrored, "test_errored", exitTestErrored},
+}
+
+// errorCode returns the code of the error's class, or "" if it has none
+func errorCode(err error) string {
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class.code
+		}
+	}
+	return ""
+}
+
+// invalidConfig marks a problem found while validating the configuration as ErrConfigInvalid
+func invalidConfig(err error) error {
+	return fmt.Errorf("%w: %v", ErrConfigInvalid, err)
+}
+
+// exitCode returns the exit code for an error that stops the scanner
+func exitCode(err error) int {
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class.exit
+		}
+	}
+	return 1
+}
+
+// exitHooks run before the scanner exits, whether it completes or fails
+var exitHooks []func()
+
+// atExit registers a function to run when the scanner exits through exit or
+// fatal, or when main returns after calling runExitHooks
+func atExit(hook func()) {
+	exitHooks = append(exitHooks, hook)
+}
+
+// runExitHooks runs the registered hooks once, the most recent first
+func runExitHooks() {
+	hooks := exitHooks
+	exitHooks = nil
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// exit runs the exit hooks and exits with the code
+func exit(code int) {
+	runExitHooks()
+	os.Exit(code)
+}
+
+// fatal logs the error and exits with the exit code of its class
+func fatal(err error) {
+	log.Print(err)
+	exit(exitCode(err))
+}
+
+// scanExitCode returns the exit code for tests that could not run because a
+// target was unreachable, authentication failed, the target rate limited the
+// scan, the test panicked or a plugin errored, or 0 if none was held back for
+// those reasons
+func scanExitCode(results []EndpointResult) int {
+	codes := map[string]bool{}
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			codes[testResult.ErrorCode] = true
+		}
+	}
+	for _, class := range errorClasses {
+		if codes[class.code] {
+			return class.exit
+		}
+	}
+	return 0
+}
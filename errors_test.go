@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorClasses(t *testing.T) {
+	tests := []struct {
+		err      error
+		sentinel error
+		code     string
+		exit     int
+	}{
+		{UnreachableError{"connection refused"}, ErrTargetUnreachable, "target_unreachable", exitTargetUnreachable},
+		{LoginError{"login returned status 401"}, ErrAuthFailed, "auth_failed", exitAuthFailed},
+		{ThrottledError{RetryAfter: time.Minute}, ErrRateLimited, "rate_limited", exitRateLimited},
+		{ConfigError{File: "config.yaml", Problems: []string{"line 3: unknown field"}}, ErrConfigInvalid, "config_invalid", exitConfigInvalid},
+		{invalidConfig(fmt.Errorf("cloud_iam: provider must be set")), ErrConfigInvalid, "config_invalid", exitConfigInvalid},
+		{fmt.Errorf("request failed: %w", LoginError{"expired"}), ErrAuthFailed, "auth_failed", exitAuthFailed},
+		{TestPanicError{"assignment to entry in nil map"}, ErrTestPanicked, "test_panicked", exitTestPanicked},
+		{PluginError{"invalid plugin output: unexpected end of JSON input"}, ErrTestErrored, "test_errored", exitTestErrored},
+		{RuleError{"rule error: attempt to perform arithmetic on a nil value"}, ErrTestErrored, "test_errored", exitTestErrored},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.sentinel) {
+			t.Errorf("Expected %v to be %v", tt.err, tt.sentinel)
+		}
+		if code := errorCode(tt.err); code != tt.code {
+			t.Errorf("Expected error code %s for %v, got %q", tt.code, tt.err, code)
+		}
+		if exit := exitCode(tt.err); exit != tt.exit {
+			t.Errorf("Expected exit code %d for %v, got %d", tt.exit, tt.err, exit)
+		}
+	}
+
+	other := AuthError{"endpoint accepted the request without credentials"}
+	if errorCode(other) != "" || exitCode(other) != 1 {
+		t.Errorf("Expected findings to have no error class, got %q and %d", errorCode(other), exitCode(other))
+	}
+	if errors.Is(UnreachableError{"timeout"}, ErrAuthFailed) {
+		t.Errorf("Expected an unreachable target not to be an authentication failure")
+	}
+}
+
+func TestNewTestResultRecordsErrorCode(t *testing.T) {
+	if result := newTestResult("Auth Test", LoginError{"login failed"}, 0); !result.Skipped || result.ErrorCode != "auth_failed" {
+		t.Errorf("Expected a skipped result with error code auth_failed, got %+v", result)
+	}
+	if result := newTestResult("Auth Test", WAFBlockedError{Vendor: "Cloudflare"}, 0); result.ErrorCode != "" {
+		t.Errorf("Expected no error code for a WAF block, got %q", result.ErrorCode)
+	}
+}
+
+func TestScanExitCode(t *testing.T) {
+	results := []EndpointResult{{Results: []TestResult{{TestName: "Auth Test", Passed: true}, {TestName: "Injection Test"}}}}
+	if code := scanExitCode(results); code != 0 {
+		t.Errorf("Expected exit code 0 for a complete scan, got %d", code)
+	}
+
+	results = append(results,
+		EndpointResult{Results: []TestResult{{TestName: "Auth Test", Skipped: true, ErrorCode: "rate_limited"}}},
+		EndpointResult{Results: []TestResult{{TestName: "Auth Test", Skipped: true, ErrorCode: "target_unreachable"}}})
+	if code := scanExitCode(results); code != exitTargetUnreachable {
+		t.Errorf("Expected an unreachable target to take precedence, got %d", code)
+	}
+	if code := scanExitCode(results[:2]); code != exitRateLimited {
+		t.Errorf("Expected exit code %d for rate limiting, got %d", exitRateLimited, code)
+	}
+}
+
+func TestSubcommandFlagErrorsAreConfigErrors(t *testing.T) {
+	commands := map[string]func([]string) error{
+		"migrate":  migrateCommand,
+		"verify":   verifyCommand,
+		"feedback": feedbackCommand,
+		"replay":   replayCommand,
+		"pack":     packCommand,
+	}
+	for name, command := range commands {
+		err := command(nil)
+		if exit := exitCode(fmt.Errorf("%s failed: %w", name, err)); exit != exitConfigInvalid {
+			t.Errorf("Expected exit code %d for %s without flags, got %d (%v)", exitConfigInvalid, name, exit, err)
+		}
+	}
+}
+
+func TestExitHooksRunOnceInReverseOrder(t *testing.T) {
+	var calls []string
+	atExit(func() { calls = append(calls, "first") })
+	atExit(func() { calls = append(calls, "second") })
+
+	runExitHooks()
+	runExitHooks()
+	if len(calls) != 2 || calls[0] != "second" || calls[1] != "first" {
+		t.Errorf("Expected the hooks to run once, most recent first, got %v", calls)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// evidenceBodyLimit is how much of each request and response body a finding keeps
+const evidenceBodyLimit = 4096
+
+// maskedValue replaces the values of headers that carry credentials
+const maskedValue = "****"
+
+// sensitiveHeaderWords mark headers whose values are credentials
+var sensitiveHeaderWords = []string{"auth", "cookie", "key", "password", "secret", "session", "signature", "token"}
+
+// RecordedExchange is the last request a test sent before reporting a
+// finding, and the response to it. Credentials are masked unless the scan
+// runs with -reveal-secrets.
+type RecordedExchange struct {
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Status   int               `json:"status"`
+	Response string            `json:"response,omitempty"`
+	// OwnCredentials is set when the request carried credentials of its own,
+	// or deliberately none, instead of the scan's
+	OwnCredentials bool `json:"own_credentials,omitempty"`
+}
+
+// exchangeRecorder keeps the last request sent by a test's client
+type exchangeRecorder struct {
+	base http.RoundTripper
+
+	mu   sync.Mutex
+	last *RecordedExchange
+}
+
+// recordedExchangeKey carries the exchange being recorded down the transport
+// chain. The recorder wraps the client above authTransport, which notes on it
+// the credentials it adds, as does withOwnCredentials when a transport below
+// the recorder sends the request without the scan's.
+type recordedExchangeKey struct{}
+
+// recordedExchange returns the exchange being recorded for req, if any
+func recordedExchange(req *http.Request) *RecordedExchange {
+	exchange, _ := req.Context().Value(recordedExchangeKey{}).(*RecordedExchange)
+	return exchange
+}
+
+// recordExchanges wraps a test's client so that the request behind a finding
+// can be attached to it
+func recordExchanges(client *http.Client) (*http.Client, *exchangeRecorder) {
+	recorder := &exchangeRecorder{base: client.Transport}
+	if recorder.base == nil {
+		recorder.base = http.DefaultTransport
+	}
+	recording := *client
+	recording.Transport = recorder
+	return &recording, recorder
+}
+
+func (r *exchangeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := &RecordedExchange{Method: req.Method, URL: req.URL.String(), Headers: flattenHeaders(req.Header)}
+	exchange.OwnCredentials = req.Context().Value(ownCredentialsKey{}) != nil
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(io.LimitReader(body, evidenceBodyLimit))
+			body.Close()
+			exchange.Body = string(data)
+		}
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), recordedExchangeKey{}, exchange))
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	exchange.Status = resp.StatusCode
+	resp.Body = &recordingBody{ReadCloser: resp.Body, exchange: exchange, recorder: r}
+	r.mu.Lock()
+	r.last = exchange
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// attach adds the last recorded exchange to a failed result
+func (r *exchangeRecorder) attach(result TestResult) TestResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if result.Failed() && r.last != nil {
+		exchange := *r.last
+		result.Request = &exchange
+	}
+	return result
+}
+
+// recordingBody keeps the start of the response body as the test reads it
+type recordingBody struct {
+	io.ReadCloser
+	exchange *RecordedExchange
+	recorder *exchangeRecorder
+	buf      bytes.Buffer
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := evidenceBodyLimit - b.buf.Len(); room > 0 && n > 0 {
+		if room > n {
+			room = n
+		}
+		b.buf.Write(p[:room])
+		b.recorder.mu.Lock()
+		b.exchange.Response = b.buf.String()
+		b.recorder.mu.Unlock()
+	}
+	return n, err
+}
+
+func flattenHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// masked returns a copy of the exchange with the values of credential headers masked
+func (e RecordedExchange) masked() RecordedExchange {
+	headers := make(map[string]string, len(e.Headers))
+	for name, value := range e.Headers {
+		if sensitiveHeader(name) {
+			value = maskedValue
+		}
+		headers[name] = value
+	}
+	if len(headers) > 0 {
+		e.Headers = headers
+	}
+	return e
+}
+
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
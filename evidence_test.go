@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExchangeRecorderAttachesLastRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("You have an error in your SQL syntax"))
+	}))
+	defer server.Close()
+
+	client, recorder := recordExchanges(server.Client())
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: `{"id": "%s"}`}
+	err := testInjection(client, endpoint, []string{"'"}, false)
+	result := recorder.attach(newTestResult("Injection Test", err, 0))
+
+	request := result.Request
+	if request == nil {
+		t.Fatal("Expected the request to be attached to the finding")
+	}
+	if request.Method != "POST" || request.Body != `{"id": "'"}` || request.Status != 500 || request.Response != "You have an error in your SQL syntax" {
+		t.Errorf("Expected the payload request and its response, got %+v", request)
+	}
+
+	if passed := recorder.attach(TestResult{TestName: "Auth Test", Passed: true}); passed.Request != nil {
+		t.Errorf("Expected no request on a passed test, got %+v", passed.Request)
+	}
+}
+
+func TestRecordedExchangeMasked(t *testing.T) {
+	exchange := RecordedExchange{Headers: map[string]string{
+		"Authorization": "Basic dXNlcjpwYXNz",
+		"X-Api-Key":     "secret",
+		"Content-Type":  "application/json",
+	}}
+
+	headers := exchange.masked().Headers
+	if headers["Authorization"] != maskedValue || headers["X-Api-Key"] != maskedValue {
+		t.Errorf("Expected credentials to be masked, got %v", headers)
+	}
+	if headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected other headers to be kept, got %v", headers)
+	}
+	if exchange.Headers["Authorization"] != "Basic dXNlcjpwYXNz" {
+		t.Errorf("Expected the original exchange to be left unmasked, got %v", exchange.Headers)
+	}
+}
+
+func TestExchangeRecorderKeepsScanCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	auth := Auth{Type: "hmac", HMAC: &HMACConfig{Secret: "shared-secret"}}
+	if err := auth.prepare(); err != nil {
+		t.Fatalf("Expected valid hmac settings, got %v", err)
+	}
+	client, recorder := recordExchanges(&http.Client{Transport: newAuthTransport(http.DefaultTransport, auth)})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	request := recorder.attach(TestResult{TestName: "Auth Test"}).Request
+	if request == nil || request.Headers["Authorization"] == "" {
+		t.Fatalf("Expected the signature to be recorded, got %+v", request)
+	}
+	if curl := curlCommand(request.masked()); !strings.Contains(curl, "Authorization: "+maskedValue) {
+		t.Errorf("Expected the curl command to carry the masked signature, got %s", curl)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportFormats lists the formats accepted by the -format flag
+var exportFormats = []string{"json", "defectdojo", "faraday", "cyclonedx", "csv", "xlsx"}
+
+// writeExport encodes the results in the given format and writes them to path,
+// or to stdout if path is empty. The json document is signed when key is set.
+func writeExport(format, path string, results []EndpointResult, metadata map[string]string, key ed25519.PrivateKey) error {
+	if format == "xlsx" && path == "" {
+		return fmt.Errorf("the xlsx export is binary and needs -output")
+	}
+	data, err := encodeExport(format, results, metadata, key)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, data)
+}
+
+// writeOutput writes data to path, or to stdout if path is empty
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+func encodeExport(format string, results []EndpointResult, metadata map[string]string, key ed25519.PrivateKey) ([]byte, error) {
+	switch format {
+	case "json":
+		document := newScanDocument(results, metadata, time.Now())
+		if key != nil {
+			if err := signScanDocument(&document, key); err != nil {
+				return nil, err
+			}
+		}
+		return json.MarshalIndent(document, "", "  ")
+	case "defectdojo":
+		return json.MarshalIndent(defectDojoReport(results, time.Now()), "", "  ")
+	case "faraday":
+		return json.MarshalIndent(faradayReport(results), "", "  ")
+	case "cyclonedx":
+		bom, err := cycloneDXReport(results, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(bom, "", "  ")
+	case "csv":
+		return csvReport(results)
+	case "xlsx":
+		return xlsxReport(results)
+	default:
+		return nil, fmt.Errorf("unknown export format %q (supported: %s)", format, strings.Join(exportFormats, ", "))
+	}
+}
+
+// testRemediation returns remediation guidance for failures of the given test
+func testRemediation(testName string) string {
+	switch baseTestName(testName) {
+	case "Auth Test":
+		return "Require valid credentials on every request and reject missing or incorrect credentials with 401/403."
+	case "HTTP Method Test":
+		return "Only accept the HTTP methods the endpoint is meant to serve and return 405 for all others."
+	case "Injection Test":
+		return "Use parameterized queries and validate all input before it reaches the database layer."
+	case "Security Headers Test":
+		return "Send the security headers required by the header policy on every response, with the expected values."
+	case cookieTestName:
+		return "Set Secure, HttpOnly and SameSite on session cookies, or list cookies that carry no session in cookies.ignore."
+	case fuzzTestName:
+		return "Validate the type, size and range of every input and handle malformed requests with a 4xx response, without exposing stack traces."
+	case exposedServicesTestName:
+		return "Require authentication on brokers and datastores, and bind them to private interfaces or firewall them off from the API's public address."
+	case openPortsTestName:
+		return "Close the ports or restrict them to private networks, or list ports that are meant to be public in port_scan.allowed."
+	case dnsTestName:
+		return "Remove CNAME records to deprovisioned services, publish a CAA record, sign the zone with DNSSEC and avoid wildcard records on API domains."
+	case oidcTestName:
+		return "Require client authentication at the token endpoint, enforce S256 PKCE, publish only asymmetric keys of at least 2048 bits, and protect dynamic client registration with an initial access token."
+	case cloudIAMTestName:
+		return "Require IAM authorization on the API itself (IAP, Cloud Run invoker, API Gateway IAM authorizer), check the token audience, and grant invoke permission only to the identities that need it."
+	case urlTokensTestName:
+		return "Send credentials in headers or request bodies, never in the URL, and rotate any that were logged."
+	case sensitiveDataTestName:
+		return "Remove secrets and personal data from responses, or mask them, and rotate any secrets that were exposed."
+	case "Default Credentials Test":
+		return "Remove or change default accounts and passwords, and lock accounts after repeated failed logins."
+	default:
+		return "Review the failing test details and harden the endpoint accordingly."
+	}
+}
+
+// defectDojoFinding represents a finding in DefectDojo's generic findings import format
+type defectDojoFinding struct {
+	Title          string               `json:"title"`
+	Description    string               `json:"description"`
+	Severity       string               `json:"severity"`
+	Mitigation     string               `json:"mitigation"`
+	Date           string               `json:"date"`
+	UniqueID       string               `json:"unique_id_from_tool"`
+	VulnIDFromTool string               `json:"vuln_id_from_tool"`
+	Active         bool                 `json:"active"`
+	Verified       bool                 `json:"verified"`
+	StaticFinding  bool                 `json:"static_finding"`
+	DynamicFinding bool                 `json:"dynamic_finding"`
+	CVSSv3         string               `json:"cvssv3,omitempty"`
+	CVSSv3Score    float64              `json:"cvssv3_score,omitempty"`
+	Endpoints      []defectDojoEndpoint `json:"endpoints"`
+}
+
+type defectDojoEndpoint struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+func defectDojoReport(results []EndpointResult, date time.Time) map[string][]defectDojoFinding {
+	findings := []defectDojoFinding{}
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+
+			finding := defectDojoFinding{
+				Title:          fmt.Sprintf("%s failed on %s", testResult.TestName, result.URL),
+				Description:    testResult.Message,
+				Severity:       capitalize(resultSeverity(testResult)),
+				Mitigation:     testRemediation(testResult.TestName),
+				Date:           date.Format("2006-01-02"),
+				UniqueID:       findingFingerprint(result.key(), testResult.TestName),
+				VulnIDFromTool: testResult.TestName,
+				Active:         true,
+				DynamicFinding: true,
+				CVSSv3:         testResult.CVSSVector,
+				CVSSv3Score:    testResult.CVSSScore,
+			}
+			if u, err := url.Parse(result.URL); err == nil && u.Host != "" {
+				finding.Endpoints = []defectDojoEndpoint{{
+					Protocol: u.Scheme,
+					Host:     u.Hostname(),
+					Port:     urlPort(u),
+					Path:     u.Path,
+				}}
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return map[string][]defectDojoFinding{"findings": findings}
+}
+
+// faradayHost represents a host in the Faraday JSON import format
+type faradayHost struct {
+	IP        string           `json:"ip"`
+	Hostnames []string         `json:"hostnames"`
+	Services  []faradayService `json:"services"`
+}
+
+type faradayService struct {
+	Name            string                 `json:"name"`
+	Protocol        string                 `json:"protocol"`
+	Port            int                    `json:"port"`
+	Status          string                 `json:"status"`
+	Vulnerabilities []faradayVulnerability `json:"vulnerabilities"`
+}
+
+type faradayVulnerability struct {
+	Name       string `json:"name"`
+	Desc       string `json:"desc"`
+	Severity   string `json:"severity"`
+	Type       string `json:"type"`
+	Website    string `json:"website"`
+	Path       string `json:"path"`
+	Resolution string `json:"resolution"`
+	ExternalID string `json:"external_id"`
+}
+
+func faradayReport(results []EndpointResult) map[string][]faradayHost {
+	hosts := []faradayHost{}
+	hostIndex := map[string]int{}
+	for _, result := range results {
+		u, err := url.Parse(result.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		var vulnerabilities []faradayVulnerability
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+			vulnerabilities = append(vulnerabilities, faradayVulnerability{
+				Name:       testResult.TestName,
+				Desc:       testResult.Message,
+				Severity:   resultSeverity(testResult),
+				Type:       "VulnerabilityWeb",
+				Website:    u.Scheme + "://" + u.Host,
+				Path:       u.Path,
+				Resolution: testRemediation(testResult.TestName),
+				ExternalID: findingFingerprint(result.key(), testResult.TestName),
+			})
+		}
+		if len(vulnerabilities) == 0 {
+			continue
+		}
+
+		i, ok := hostIndex[u.Hostname()]
+		if !ok {
+			i = len(hosts)
+			hostIndex[u.Hostname()] = i
+			hosts = append(hosts, faradayHost{IP: u.Hostname(), Hostnames: []string{u.Hostname()}})
+		}
+		port := urlPort(u)
+		service := -1
+		for j, s := range hosts[i].Services {
+			if s.Port == port {
+				service = j
+			}
+		}
+		if service == -1 {
+			service = len(hosts[i].Services)
+			hosts[i].Services = append(hosts[i].Services, faradayService{Name: u.Scheme, Protocol: "tcp", Port: port, Status: "open"})
+		}
+		hosts[i].Services[service].Vulnerabilities = append(hosts[i].Services[service].Vulnerabilities, vulnerabilities...)
+	}
+	return map[string][]faradayHost{"hosts": hosts}
+}
+
+// urlPort returns the explicit port of u, or the default port for its scheme
+func urlPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
+	}
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func exportTestResults() []EndpointResult {
+	return []EndpointResult{
+		{URL: "https://api.example.com/users", Score: 50, Results: []TestResult{
+			{TestName: "Auth Test", Passed: true, Message: "Auth Test Passed"},
+			{TestName: "Injection Test", Passed: false, Message: "potential SQL injection detected with payload: ' OR '1'='1"},
+		}},
+		{URL: "https://api.example.com/orders", Score: 80, Results: []TestResult{
+			{TestName: "HTTP Method Test", Passed: false, Message: "unexpected status code: 405"},
+		}},
+		{URL: "http://other.example.com:8080/health", Score: 100, Results: []TestResult{
+			{TestName: "Auth Test", Passed: true, Message: "Auth Test Passed"},
+		}},
+	}
+}
+
+func TestDefectDojoReport(t *testing.T) {
+	report := defectDojoReport(exportTestResults(), time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC))
+
+	findings := report["findings"]
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %d", len(findings))
+	}
+	finding := findings[0]
+	if finding.Severity != "Critical" || finding.Date != "2024-09-25" {
+		t.Errorf("Unexpected finding: %+v", finding)
+	}
+	if len(finding.Endpoints) != 1 || finding.Endpoints[0].Host != "api.example.com" || finding.Endpoints[0].Port != 443 {
+		t.Errorf("Unexpected endpoints: %+v", finding.Endpoints)
+	}
+}
+
+func TestFaradayReport(t *testing.T) {
+	report := faradayReport(exportTestResults())
+
+	hosts := report["hosts"]
+	if len(hosts) != 1 {
+		t.Fatalf("Expected 1 host with vulnerabilities, got %d", len(hosts))
+	}
+	if len(hosts[0].Services) != 1 || len(hosts[0].Services[0].Vulnerabilities) != 2 {
+		t.Errorf("Expected both vulnerabilities grouped under one service, got %+v", hosts[0].Services)
+	}
+}
+
+func TestEncodeExportUnknownFormat(t *testing.T) {
+	if _, err := encodeExport("pdf", exportTestResults(), nil, nil); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// Confidence levels attached to failed tests
+const (
+	confidenceHigh = "high"
+	confidenceLow  = "low"
+)
+
+// Analyst verdicts on findings
+const (
+	verdictFalsePositive = "false_positive"
+	verdictConfirmed     = "confirmed"
+)
+
+// FeedbackEntry records an analyst's verdict on a finding
+type FeedbackEntry struct {
+	URL      string    `json:"url"`
+	TestName string    `json:"test_name"`
+	Verdict  string    `json:"verdict"`
+	Note     string    `json:"note,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// feedbackStore holds the verdicts keyed by finding fingerprint
+type feedbackStore struct {
+	Entries map[string]FeedbackEntry `json:"entries"`
+}
+
+// loadFeedback reads the feedback file; a missing file is an empty store
+func loadFeedback(path string) (*feedbackStore, error) {
+	store := &feedbackStore{Entries: map[string]FeedbackEntry{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feedback: %v", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse feedback: %v", err)
+	}
+	if store.Entries == nil {
+		store.Entries = map[string]FeedbackEntry{}
+	}
+	return store, nil
+}
+
+func (s *feedbackStore) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode feedback: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write feedback: %v", err)
+	}
+	return nil
+}
+
+func (s *feedbackStore) mark(entry FeedbackEntry) {
+	s.Entries[findingFingerprint(entry.URL, entry.TestName)] = entry
+}
+
+// verdict returns the recorded verdict for a finding, if there is one
+func (s *feedbackStore) verdict(endpointURL, testName string) (FeedbackEntry, bool) {
+	if s == nil {
+		return FeedbackEntry{}, false
+	}
+	entry, ok := s.Entries[findingFingerprint(endpointURL, testName)]
+	return entry, ok
+}
+
+// falsePositive reports whether the finding was marked as a false positive
+func (s *feedbackStore) falsePositive(endpointURL, testName string) bool {
+	entry, ok := s.verdict(endpointURL, testName)
+	return ok && entry.Verdict == verdictFalsePositive
+}
+
+// applyFeedback overrides the confidence of failed tests that analysts have
+// judged. Feedback is advisory: a false positive still counts as a failed test
+// in the score, tickets, webhooks and CI exit code. Known failures are
+// accepted with the expected posture instead.
+func applyFeedback(results []EndpointResult, store *feedbackStore) {
+	for i := range results {
+		for j := range results[i].Results {
+			testResult := &results[i].Results[j]
+			entry, ok := store.verdict(results[i].key(), testResult.TestName)
+			if !ok || !testResult.Failed() {
+				continue
+			}
+			switch entry.Verdict {
+			case verdictFalsePositive:
+				testResult.Confidence = confidenceLow
+				testResult.Message += fmt.Sprintf(" (marked as a false positive on %s)", entry.Date.Format("2006-01-02"))
+			case verdictConfirmed:
+				testResult.Confidence = confidenceHigh
+			}
+		}
+	}
+}
+
+// feedbackCommand implements the feedback subcommand, which records or lists verdicts
+func feedbackCommand(args []string) error {
+	flags := flag.NewFlagSet("feedback", flag.ExitOnError)
+	file := flags.String("file", "feedback.json", "feedback file to update")
+	endpointURL := flags.String("url", "", "URL of the endpoint the finding was reported on, or its name if it has one")
+	testName := flags.String("test", "", "name of the failed test, e.g. \"Injection Test\"")
+	falsePositive := flags.Bool("false-positive", false, "mark the finding as a false positive")
+	confirm := flags.Bool("confirm", false, "mark the finding as confirmed")
+	note := flags.String("note", "", "optional note explaining the verdict")
+	list := flags.Bool("list", false, "list the recorded verdicts")
+	flags.Parse(args)
+
+	store, err := loadFeedback(*file)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		var entries []FeedbackEntry
+		for _, entry := range store.Entries {
+			entries = append(entries, entry)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].URL != entries[j].URL {
+				return entries[i].URL < entries[j].URL
+			}
+			return entries[i].TestName < entries[j].TestName
+		})
+		for _, entry := range entries {
+			fmt.Printf("%s  %s  %s  %s  %s\n", entry.Date.Format("2006-01-02"), entry.Verdict, entry.URL, entry.TestName, entry.Note)
+		}
+		return nil
+	}
+
+	if *endpointURL == "" || *testName == "" || *falsePositive == *confirm {
+		return invalidConfig(fmt.Errorf("-url, -test and exactly one of -false-positive or -confirm are required"))
+	}
+	verdict := verdictConfirmed
+	if *falsePositive {
+		verdict = verdictFalsePositive
+	}
+	store.mark(FeedbackEntry{URL: *endpointURL, TestName: *testName, Verdict: verdict, Note: *note, Date: time.Now().UTC()})
+	return store.save(*file)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedbackStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feedback")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "feedback.json")
+
+	store, err := loadFeedback(path)
+	if err != nil {
+		t.Fatalf("Expected a missing file to load as an empty store, got %v", err)
+	}
+	store.mark(FeedbackEntry{URL: "http://example.com/a", TestName: "Injection Test", Verdict: verdictFalsePositive, Date: time.Now()})
+	if err := store.save(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	store, err = loadFeedback(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !store.falsePositive("http://example.com/a", "Injection Test") {
+		t.Errorf("Expected the finding to be marked as a false positive")
+	}
+	if store.falsePositive("http://example.com/b", "Injection Test") {
+		t.Errorf("Expected other endpoints to be unaffected")
+	}
+}
+
+func TestApplyFeedback(t *testing.T) {
+	store := &feedbackStore{Entries: map[string]FeedbackEntry{}}
+	store.mark(FeedbackEntry{URL: "http://example.com/a", TestName: "Injection Test", Verdict: verdictFalsePositive})
+	store.mark(FeedbackEntry{URL: "http://example.com/a", TestName: "Auth Test", Verdict: verdictConfirmed})
+
+	results := []EndpointResult{{URL: "http://example.com/a", Results: []TestResult{
+		{TestName: "Injection Test", Message: "potential SQL injection", Confidence: confidenceHigh},
+		{TestName: "Auth Test", Message: "authentication failed", Confidence: confidenceLow},
+	}}}
+	applyFeedback(results, store)
+
+	if got := results[0].Results[0].Confidence; got != confidenceLow {
+		t.Errorf("Expected false positive to have low confidence, got %q", got)
+	}
+	if got := results[0].Results[1].Confidence; got != confidenceHigh {
+		t.Errorf("Expected confirmed finding to have high confidence, got %q", got)
+	}
+	if !results[0].Results[0].Failed() {
+		t.Errorf("Expected feedback to be advisory and the false positive to remain a failure")
+	}
+}
+
+func TestInjectionFeedbackRequiresDirectEvidence(t *testing.T) {
+	// Quotes cause a generic error page rather than a database error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "'") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"items": []}`))
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: "%s"}
+	payloads := []string{"'; DROP TABLE users;--"}
+
+	err := testInjection(http.DefaultClient, endpoint, payloads, false)
+	if result := newTestResult("Injection Test", err, 0); !result.Failed() || result.Confidence != confidenceLow {
+		t.Errorf("Expected a low confidence finding, got %+v", result)
+	}
+	if err := testInjection(http.DefaultClient, endpoint, payloads, true); err != nil {
+		t.Errorf("Expected no finding without direct evidence, got %v", err)
+	}
+}
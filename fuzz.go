@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	fuzzTestName = "Fuzz Test"
+
+	defaultFuzzIterations = 50
+	defaultFuzzCrashDir   = "fuzz-crashes"
+)
+
+// FuzzConfig represents the opt-in fuzzer, which mutates each endpoint's request body
+type FuzzConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Iterations int    `yaml:"iterations"`
+	Seed       int64  `yaml:"seed"`
+	CrashDir   string `yaml:"crash_dir"`
+}
+
+// FuzzError reports mutated requests that crashed the endpoint
+type FuzzError struct{ message string }
+
+func (e FuzzError) Error() string { return e.message }
+
+// fuzzCrash is a mutated request that produced a server error or a stack
+// trace, saved so that it can be sent again
+type fuzzCrash struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Seed   int64  `json:"seed"`
+	Body   string `json:"body"`
+	Status int    `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// stackTracePatterns match the stack traces of common server runtimes
+var stackTracePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`Traceback \(most recent call last\)`),
+	regexp.MustCompile(`goroutine \d+ \[running\]`),
+	regexp.MustCompile(`\bat [\w$.]+\([\w$]+\.java:\d+\)`),
+	regexp.MustCompile(`\bat .+ in .+:line \d+`),
+	regexp.MustCompile(`\bat .+ \(.+\.js:\d+:\d+\)`),
+	regexp.MustCompile(`Stack trace:\s*#0`),
+	regexp.MustCompile(`\.rb:\d+:in `),
+}
+
+// fuzzBoundaryValues are values at the edges of common types and sizes
+var fuzzBoundaryValues = []interface{}{
+	json.Number("0"), json.Number("-1"),
+	json.Number("2147483647"), json.Number("-2147483648"),
+	json.Number("9223372036854775807"), json.Number("-9223372036854775808"),
+	"", strings.Repeat("A", 10000), nil, true, []interface{}{}, map[string]interface{}{},
+}
+
+// fuzzBigNumbers overflow integer and floating point parsers
+var fuzzBigNumbers = []json.Number{
+	"99999999999999999999999999999999", "-99999999999999999999999999999999",
+	"1e309", "-1e309", "1e-400", "0.000000000000000000000000000001",
+}
+
+// fuzzMutations replace a JSON value with a value the endpoint may not expect
+var fuzzMutations = []func(r *rand.Rand, value interface{}) interface{}{
+	flipType,
+	func(r *rand.Rand, value interface{}) interface{} {
+		return fuzzBoundaryValues[r.Intn(len(fuzzBoundaryValues))]
+	},
+	func(r *rand.Rand, value interface{}) interface{} { return string(randomBytes(r)) },
+	func(r *rand.Rand, value interface{}) interface{} { return fuzzBigNumbers[r.Intn(len(fuzzBigNumbers))] },
+}
+
+// flipType replaces a value with one of another JSON type
+func flipType(r *rand.Rand, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return json.Number("1")
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	case nil:
+		return map[string]interface{}{}
+	case map[string]interface{}:
+		return []interface{}{v}
+	default:
+		return "fuzz"
+	}
+}
+
+func randomBytes(r *rand.Rand) []byte {
+	data := make([]byte, 1+r.Intn(64))
+	r.Read(data)
+	return data
+}
+
+// fuzzSeed derives the seed of one mutated request from the scan seed, so
+// that every case can be reproduced on its own
+func fuzzSeed(seed int64, endpoint APIEndpoint, iteration int) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(endpoint.Method + " " + endpoint.URL))
+	return (seed ^ int64(h.Sum64())) + int64(iteration)
+}
+
+// fuzzBody returns a mutation of a known-good body. JSON bodies have one value
+// replaced; other bodies have random bytes flipped, inserted or cut off.
+func fuzzBody(body string, seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err == nil {
+		target := r.Intn(countJSONValues(document))
+		mutate := fuzzMutations[r.Intn(len(fuzzMutations))]
+		counter := 0
+		mutated, err := json.Marshal(replaceJSONValue(document, target, &counter, func(v interface{}) interface{} { return mutate(r, v) }))
+		if err == nil {
+			return string(mutated)
+		}
+	}
+
+	data := []byte(body)
+	switch r.Intn(3) {
+	case 0:
+		if len(data) > 0 {
+			data[r.Intn(len(data))] ^= byte(1 + r.Intn(255))
+			break
+		}
+		fallthrough
+	case 1:
+		i := r.Intn(len(data) + 1)
+		data = append(data[:i], append(randomBytes(r), data[i:]...)...)
+	default:
+		data = data[:r.Intn(len(data)+1)]
+	}
+	return string(data)
+}
+
+func countJSONValues(value interface{}) int {
+	count := 1
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			count += countJSONValues(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			count += countJSONValues(item)
+		}
+	}
+	return count
+}
+
+// replaceJSONValue replaces the target-th value of a depth-first walk, with
+// object keys in sorted order so that the walk is the same on every run
+func replaceJSONValue(value interface{}, target int, counter *int, replace func(interface{}) interface{}) interface{} {
+	if *counter == target {
+		*counter++
+		return replace(value)
+	}
+	*counter++
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v[key] = replaceJSONValue(v[key], target, counter, replace)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = replaceJSONValue(v[i], target, counter, replace)
+		}
+	}
+	return value
+}
+
+// stackTrace returns the first stack trace signature found in a body
+func stackTrace(body []byte) string {
+	for _, pattern := range stackTracePatterns {
+		if match := pattern.Find(body); match != nil {
+			return string(match)
+		}
+	}
+	return ""
+}
+
+func fuzzIterations(config FuzzConfig) int {
+	if config.Iterations <= 0 {
+		return defaultFuzzIterations
+	}
+	return config.Iterations
+}
+
+// performFuzzTest sends mutations of the endpoint's body, filled in with a
+// harmless value at its injection point, and reports the ones that made the
+// endpoint return a server error or a stack trace. Server errors only count if
+// the unmodified body does not already cause one.
+func performFuzzTest(client *http.Client, endpoint APIEndpoint, config FuzzConfig) error {
+	body := endpoint.Body
+	if strings.Contains(body, "%s") {
+		body = fmt.Sprintf(body, "test")
+	}
+	baseline, err := sendProbe(client, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("baseline %w", err)
+	}
+
+	iterations := fuzzIterations(config)
+	var crashes []fuzzCrash
+	for i := 0; i < iterations; i++ {
+		seed := fuzzSeed(config.Seed, endpoint, i)
+		mutated := fuzzBody(body, seed)
+		response, err := sendProbe(client, endpoint, mutated)
+		if err != nil {
+			return err
+		}
+
+		crash := fuzzCrash{URL: endpoint.URL, Method: endpoint.Method, Seed: seed, Body: mutated, Status: response.Status}
+		switch trace := stackTrace(response.Body.Prefix); {
+		case trace != "":
+			crash.Reason = fmt.Sprintf("stack trace %q", trace)
+		case response.Status >= 500 && baseline.Status < 500:
+			crash.Reason = fmt.Sprintf("status %d", response.Status)
+		default:
+			continue
+		}
+		crashes = append(crashes, crash)
+	}
+	if len(crashes) == 0 {
+		return nil
+	}
+
+	dir := config.CrashDir
+	if dir == "" {
+		dir = defaultFuzzCrashDir
+	}
+	if err := saveFuzzCrashes(dir, crashes); err != nil {
+		return err
+	}
+	return FuzzError{fmt.Sprintf("%d of %d mutated requests crashed the endpoint, first with %s (seed %d, saved to %s)",
+		len(crashes), iterations, crashes[0].Reason, crashes[0].Seed, dir)}
+}
+
+func saveFuzzCrashes(dir string, crashes []fuzzCrash) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create crash directory: %v", err)
+	}
+	for _, crash := range crashes {
+		data, err := json.MarshalIndent(crash, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode crash case: %v", err)
+		}
+		name := fmt.Sprintf("%s-%d.json", findingFingerprint(crash.URL, fuzzTestName)[:16], crash.Seed)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return fmt.Errorf("failed to save crash case: %v", err)
+		}
+	}
+	return nil
+}
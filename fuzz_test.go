@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFuzzBodyIsReproducible(t *testing.T) {
+	body := `{"name": "alice", "age": 30, "tags": ["a", "b"], "admin": false}`
+	for seed := int64(0); seed < 20; seed++ {
+		mutated := fuzzBody(body, seed)
+		if mutated != fuzzBody(body, seed) {
+			t.Fatalf("Expected seed %d to produce the same body twice", seed)
+		}
+		if !json.Valid([]byte(mutated)) {
+			t.Errorf("Expected seed %d to produce valid JSON, got %q", seed, mutated)
+		}
+	}
+
+	if mutated := fuzzBody("name=alice", 1); mutated == "name=alice" {
+		t.Errorf("Expected a form body to be mutated, got %q", mutated)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	cases := map[string]bool{
+		"Traceback (most recent call last):\n  File \"app.py\"":                     true,
+		"java.lang.NullPointerException\n\tat com.example.Users.get(Users.java:42)": true,
+		"panic: runtime error\n\ngoroutine 1 [running]:":                            true,
+		`{"error": "invalid age"}`:                                                  false,
+	}
+	for body, expected := range cases {
+		if found := stackTrace([]byte(body)) != ""; found != expected {
+			t.Errorf("Expected stack trace %v for %q", expected, body)
+		}
+	}
+}
+
+func TestPerformFuzzTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &user); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "fuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: `{"name": "%s", "age": 30}`}
+	err = performFuzzTest(server.Client(), endpoint, FuzzConfig{Iterations: 20, Seed: 7, CrashDir: dir})
+	var fuzzErr FuzzError
+	if !errors.As(err, &fuzzErr) {
+		t.Fatalf("Expected a fuzz error, got %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) == 0 {
+		t.Fatal("Expected crash cases to be saved")
+	}
+	data, _ := ioutil.ReadFile(files[0])
+	var crash fuzzCrash
+	if err := json.Unmarshal(data, &crash); err != nil {
+		t.Fatalf("Expected a valid crash case, got %v", err)
+	}
+	if crash.Status != http.StatusInternalServerError || crash.Body != fuzzBody(`{"name": "test", "age": 30}`, crash.Seed) {
+		t.Errorf("Expected the crash to be reproducible from its seed, got %+v", crash)
+	}
+}
+
+func TestPerformFuzzTestIgnoresFailingBaseline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: `{"id": 1}`}
+	if err := performFuzzTest(server.Client(), endpoint, FuzzConfig{Iterations: 5}); err != nil {
+		t.Errorf("Expected no crashes when the baseline already fails, got %v", err)
+	}
+}
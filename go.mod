@@ -2,4 +2,7 @@ module api-security-scanner
 
 go 1.16
 
-require gopkg.in/yaml.v2 v2.4.0 // indirect
+require (
+	github.com/yuin/gopher-lua v1.1.1
+	gopkg.in/yaml.v2 v2.4.0
+)
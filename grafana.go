@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+)
+
+// grafanaDashboard is the subset of the Grafana dashboard model the scanner generates
+type grafanaDashboard struct {
+	Title         string            `json:"title"`
+	UID           string            `json:"uid"`
+	Tags          []string          `json:"tags"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          map[string]string `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name       string                 `json:"name"`
+	Label      string                 `json:"label"`
+	Type       string                 `json:"type"`
+	Query      string                 `json:"query"`
+	Datasource map[string]string      `json:"datasource,omitempty"`
+	Refresh    int                    `json:"refresh,omitempty"`
+	Multi      bool                   `json:"multi,omitempty"`
+	IncludeAll bool                   `json:"includeAll,omitempty"`
+	Current    map[string]interface{} `json:"current,omitempty"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Datasource  map[string]string      `json:"datasource"`
+	GridPos     grafanaGridPos         `json:"gridPos"`
+	Targets     []grafanaTarget        `json:"targets"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+}
+
+// grafanaDatasource refers to the Prometheus data source picked in the dashboard's variable
+var grafanaDatasource = map[string]string{"type": "prometheus", "uid": "${datasource}"}
+
+// grafanaSelector is the label selector every panel filters the scan metrics with
+const grafanaSelector = `{job="$job",endpoint=~"$endpoint"}`
+
+// newGrafanaPanel returns a panel showing the given queries with the given field defaults
+func newGrafanaPanel(id int, kind, title string, defaults map[string]interface{}, pos grafanaGridPos, targets ...grafanaTarget) grafanaPanel {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	return grafanaPanel{
+		ID:          id,
+		Type:        kind,
+		Title:       title,
+		Datasource:  grafanaDatasource,
+		GridPos:     pos,
+		Targets:     targets,
+		FieldConfig: map[string]interface{}{"defaults": defaults, "overrides": []interface{}{}},
+	}
+}
+
+// scoreDefaults shows scores on a 0-100 scale, red below 50, orange below 80 and green otherwise
+var scoreDefaults = map[string]interface{}{
+	"unit":  "none",
+	"min":   0,
+	"max":   100,
+	"color": map[string]string{"mode": "thresholds"},
+	"thresholds": map[string]interface{}{
+		"mode": "absolute",
+		"steps": []map[string]interface{}{
+			{"color": "red", "value": nil},
+			{"color": "orange", "value": 50},
+			{"color": "green", "value": 80},
+		},
+	},
+}
+
+// grafanaScanDashboard builds a dashboard over the metrics pushed by pushMetrics
+func grafanaScanDashboard() grafanaDashboard {
+	count := map[string]interface{}{"unit": "none"}
+	seconds := map[string]interface{}{"unit": "s"}
+	days := map[string]interface{}{"unit": "none", "decimals": 0}
+	stacked := map[string]interface{}{"unit": "none", "custom": map[string]interface{}{"stacking": map[string]string{"mode": "normal"}}}
+
+	return grafanaDashboard{
+		Title:         "API Security Scanner",
+		UID:           "api-security-scanner",
+		Tags:          []string{"security", "api-security-scanner"},
+		SchemaVersion: 39,
+		Refresh:       "5m",
+		Time:          map[string]string{"from": "now-30d", "to": "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{Name: "job", Label: "Job", Type: "query", Query: "label_values(" + metricScore + ", job)", Datasource: grafanaDatasource, Refresh: 2,
+				Current: map[string]interface{}{"text": defaultPushgatewayJob, "value": defaultPushgatewayJob}},
+			{Name: "endpoint", Label: "Endpoint", Type: "query", Query: "label_values(" + metricScore + `{job="$job"}, endpoint)`, Datasource: grafanaDatasource, Refresh: 2,
+				Multi: true, IncludeAll: true, Current: map[string]interface{}{"text": "All", "value": "$__all"}},
+		}},
+		Panels: []grafanaPanel{
+			newGrafanaPanel(1, "gauge", "Average Score", scoreDefaults, grafanaGridPos{H: 6, W: 6, X: 0, Y: 0},
+				grafanaTarget{Expr: "avg(" + metricScore + grafanaSelector + ")", Instant: true}),
+			newGrafanaPanel(2, "stat", "Critical Findings", count, grafanaGridPos{H: 6, W: 6, X: 6, Y: 0},
+				grafanaTarget{Expr: "sum(" + metricFindings + `{job="$job",endpoint=~"$endpoint",severity="critical"})`, Instant: true}),
+			newGrafanaPanel(3, "stat", "Endpoints Scanned", count, grafanaGridPos{H: 6, W: 6, X: 12, Y: 0},
+				grafanaTarget{Expr: "max(" + metricEndpoints + `{job="$job"})`, Instant: true}),
+			newGrafanaPanel(4, "stat", "Time Since Last Scan", seconds, grafanaGridPos{H: 6, W: 6, X: 18, Y: 0},
+				grafanaTarget{Expr: "time() - max(" + metricLastCompletion + `{job="$job"})`, Instant: true}),
+			newGrafanaPanel(5, "timeseries", "Score by Endpoint", scoreDefaults, grafanaGridPos{H: 8, W: 12, X: 0, Y: 6},
+				grafanaTarget{Expr: metricScore + grafanaSelector, LegendFormat: "{{endpoint}}"}),
+			newGrafanaPanel(6, "timeseries", "Findings by Severity", stacked, grafanaGridPos{H: 8, W: 12, X: 12, Y: 6},
+				grafanaTarget{Expr: "sum by (severity) (" + metricFindings + grafanaSelector + ")", LegendFormat: "{{severity}}"}),
+			newGrafanaPanel(7, "table", "Test Outcomes by Endpoint", count, grafanaGridPos{H: 8, W: 12, X: 0, Y: 14},
+				grafanaTarget{Expr: "sum by (endpoint, status) (" + metricTests + grafanaSelector + ")", Instant: true}),
+			newGrafanaPanel(8, "timeseries", "Scan Duration", seconds, grafanaGridPos{H: 8, W: 12, X: 12, Y: 14},
+				grafanaTarget{Expr: metricDuration + `{job="$job"}`, LegendFormat: "{{job}}"}),
+			newGrafanaPanel(9, "timeseries", "Days Until Certificate Expiry", days, grafanaGridPos{H: 8, W: 24, X: 0, Y: 22},
+				grafanaTarget{Expr: "(" + metricCertExpiry + grafanaSelector + " - time()) / 86400", LegendFormat: "{{endpoint}}"}),
+			newGrafanaPanel(10, "timeseries", "Adaptive Concurrency by Host", count, grafanaGridPos{H: 8, W: 12, X: 0, Y: 30},
+				grafanaTarget{Expr: metricLimiterLimit + `{job="$job"}`, LegendFormat: "{{host}} limit"},
+				grafanaTarget{Expr: metricLimiterPeakWaiting + `{job="$job"}`, LegendFormat: "{{host}} peak queued"}),
+			newGrafanaPanel(11, "timeseries", "Throttle Events by Host", stacked, grafanaGridPos{H: 8, W: 12, X: 12, Y: 30},
+				grafanaTarget{Expr: metricThrottleEvents + `{job="$job"}`, LegendFormat: "{{host}}"}),
+		},
+	}
+}
+
+// grafanaCommand writes the Grafana dashboard for the scan metrics
+func grafanaCommand(args []string) error {
+	flags := flag.NewFlagSet("grafana", flag.ExitOnError)
+	output := flags.String("out", "", "file to write the dashboard JSON to (defaults to stdout)")
+	flags.Parse(args)
+
+	data, err := json.MarshalIndent(grafanaScanDashboard(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dashboard: %v", err)
+	}
+	return writeOutput(*output, data)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrafanaDashboardMatchesMetrics(t *testing.T) {
+	dashboard := grafanaScanDashboard()
+	data, err := json.Marshal(dashboard)
+	if err != nil {
+		t.Fatalf("Expected the dashboard to encode, got %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["panels"] == nil {
+		t.Fatalf("Expected valid dashboard JSON, got %v", err)
+	}
+
+	results := []EndpointResult{{URL: "https://api.example.com", Score: 80, Results: []TestResult{{TestName: "Auth Test", Passed: true}},
+		Certificate: &CertificateInfo{Subject: "api.example.com", NotAfter: time.Now().Add(90 * 24 * time.Hour)},
+		Limiter:     &LimiterStats{Host: "api.example.com", Limit: 4}}}
+	exported := scanMetrics(results, time.Second, time.Now())
+
+	metricName := regexp.MustCompile(`api_security_scan_[a-z_]+`)
+	seen := 0
+	for _, panel := range dashboard.Panels {
+		for _, target := range panel.Targets {
+			for _, name := range metricName.FindAllString(target.Expr, -1) {
+				seen++
+				if !strings.Contains(exported, "# TYPE "+name+" ") {
+					t.Errorf("Panel %q queries %s, which the scanner does not export", panel.Title, name)
+				}
+			}
+			for _, label := range []string{"endpoint", "severity", "status"} {
+				if strings.Contains(target.Expr, label) && !strings.Contains(exported, label+"=") {
+					t.Errorf("Panel %q uses label %s, which the scanner does not export", panel.Title, label)
+				}
+			}
+		}
+	}
+	if seen == 0 {
+		t.Errorf("Expected the panels to query the scan metrics")
+	}
+}
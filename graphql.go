@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const defaultSchemaDir = "graphql-schemas"
+
+// graphQLIntrospectionQuery fetches the types and fields needed to track schema drift
+const graphQLIntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      kind
+      name
+      fields(includeDeprecated: true) { name args { name } }
+    }
+  }
+}`
+
+// GraphQLConfig represents the GraphQL schema export
+type GraphQLConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	SchemaDir string `yaml:"schema_dir"`
+}
+
+// SchemaDrift describes the introspected schema of a GraphQL endpoint and how
+// it changed since the schema saved by the previous scan
+type SchemaDrift struct {
+	SchemaFile     string   `json:"schema_file"`
+	Types          int      `json:"types"`
+	FirstScan      bool     `json:"first_scan,omitempty"`
+	AddedMutations []string `json:"added_mutations,omitempty"`
+	AddedFields    []string `json:"added_fields,omitempty"`
+	RemovedFields  []string `json:"removed_fields,omitempty"`
+}
+
+// Changed reports whether the schema differs from the previous scan's
+func (d SchemaDrift) Changed() bool {
+	return len(d.AddedMutations)+len(d.AddedFields)+len(d.RemovedFields) > 0
+}
+
+// graphQLSchema is the __schema object of an introspection response
+type graphQLSchema struct {
+	QueryType        *graphQLTypeRef `json:"queryType"`
+	MutationType     *graphQLTypeRef `json:"mutationType"`
+	SubscriptionType *graphQLTypeRef `json:"subscriptionType"`
+	Types            []graphQLType   `json:"types"`
+}
+
+type graphQLTypeRef struct {
+	Name string `json:"name"`
+}
+
+type graphQLType struct {
+	Kind   string         `json:"kind"`
+	Name   string         `json:"name"`
+	Fields []graphQLField `json:"fields"`
+}
+
+type graphQLField struct {
+	Name string           `json:"name"`
+	Args []graphQLTypeRef `json:"args"`
+}
+
+// isGraphQLEndpoint reports whether the endpoint is marked as GraphQL or served at a /graphql path
+func isGraphQLEndpoint(endpoint APIEndpoint) bool {
+	if endpoint.GraphQL {
+		return true
+	}
+	u, err := url.Parse(endpoint.URL)
+	return err == nil && strings.HasSuffix(strings.ToLower(strings.TrimSuffix(u.Path, "/")), "/graphql")
+}
+
+// fields returns the schema's fields as Type.field, skipping the introspection types
+func (s graphQLSchema) fields() map[string]bool {
+	fields := map[string]bool{}
+	for _, t := range s.Types {
+		if strings.HasPrefix(t.Name, "__") {
+			continue
+		}
+		for _, field := range t.Fields {
+			fields[t.Name+"."+field.Name] = true
+		}
+	}
+	return fields
+}
+
+// diffSchemas compares a schema with the previous one, reporting new mutations separately from other new fields
+func diffSchemas(previous, current graphQLSchema) SchemaDrift {
+	drift := SchemaDrift{Types: len(current.Types)}
+	mutationType := ""
+	if current.MutationType != nil {
+		mutationType = current.MutationType.Name
+	}
+
+	before, after := previous.fields(), current.fields()
+	for field := range after {
+		if before[field] {
+			continue
+		}
+		if mutationType != "" && strings.HasPrefix(field, mutationType+".") {
+			drift.AddedMutations = append(drift.AddedMutations, strings.TrimPrefix(field, mutationType+"."))
+		} else {
+			drift.AddedFields = append(drift.AddedFields, field)
+		}
+	}
+	for field := range before {
+		if !after[field] {
+			drift.RemovedFields = append(drift.RemovedFields, field)
+		}
+	}
+	sort.Strings(drift.AddedMutations)
+	sort.Strings(drift.AddedFields)
+	sort.Strings(drift.RemovedFields)
+	return drift
+}
+
+// schemaFileName derives a stable file name for an endpoint's schema from its host and path
+func schemaFileName(endpointURL string) string {
+	name := endpointURL
+	if u, err := url.Parse(endpointURL); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	return strings.Trim(regexp.MustCompile(`[^A-Za-z0-9.-]+`).ReplaceAllString(name, "_"), "_") + ".json"
+}
+
+// introspectGraphQL sends the introspection query to the endpoint and returns the raw __schema object
+func introspectGraphQL(client *http.Client, endpoint APIEndpoint, auth Auth) (json.RawMessage, error) {
+	payload, _ := json.Marshal(map[string]string{"query": graphQLIntrospectionQuery})
+	req, err := http.NewRequest("POST", endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := auth.apply(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data struct {
+			Schema json.RawMessage `json:"__schema"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(response.Data.Schema) == 0 || string(response.Data.Schema) == "null" {
+		if len(response.Errors) > 0 {
+			return nil, fmt.Errorf("introspection failed: %s", response.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("introspection is not available")
+	}
+	return response.Data.Schema, nil
+}
+
+// exportGraphQLSchema introspects the endpoint's schema, compares it with the
+// schema saved by the previous scan and saves it for the next one
+func exportGraphQLSchema(client *http.Client, endpoint APIEndpoint, auth Auth, config GraphQLConfig) (*SchemaDrift, error) {
+	raw, err := introspectGraphQL(client, endpoint, auth)
+	if err != nil {
+		return nil, err
+	}
+	var current graphQLSchema
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+
+	dir := config.SchemaDir
+	if dir == "" {
+		dir = defaultSchemaDir
+	}
+	path := filepath.Join(dir, schemaFileName(endpoint.URL))
+
+	var drift SchemaDrift
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		var previous graphQLSchema
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return nil, fmt.Errorf("invalid previous schema %s: %v", path, err)
+		}
+		drift = diffSchemas(previous, current)
+	case os.IsNotExist(err):
+		drift = SchemaDrift{Types: len(current.Types), FirstScan: true}
+	default:
+		return nil, fmt.Errorf("failed to read previous schema: %v", err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, raw, "", "  "); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create schema directory: %v", err)
+	}
+	if err := ioutil.WriteFile(path, indented.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write schema: %v", err)
+	}
+	drift.SchemaFile = path
+	return &drift, nil
+}
+
+// formatSchemaDrift describes an endpoint's schema and its drift for the detailed report
+func formatSchemaDrift(drift SchemaDrift, l localizer) string {
+	lines := []string{l.T("GraphQL Schema: %d types, saved to %s", drift.Types, drift.SchemaFile)}
+	switch {
+	case drift.FirstScan:
+		lines = append(lines, "  "+l.T("No previous schema to compare with"))
+	case !drift.Changed():
+		lines = append(lines, "  "+l.T("No schema drift since the last scan"))
+	default:
+		for _, mutation := range drift.AddedMutations {
+			lines = append(lines, "  "+l.T("- New mutation: %s", mutation))
+		}
+		for _, field := range drift.AddedFields {
+			lines = append(lines, "  "+l.T("- New field: %s", field))
+		}
+		for _, field := range drift.RemovedFields {
+			lines = append(lines, "  "+l.T("- Removed field: %s", field))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func graphQLServer(schema *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if r.Method != "POST" || !strings.Contains(request.Query, "__schema") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"__schema":` + *schema + `}}`))
+	}))
+}
+
+func TestIsGraphQLEndpoint(t *testing.T) {
+	for _, test := range []struct {
+		endpoint APIEndpoint
+		expected bool
+	}{
+		{APIEndpoint{URL: "https://api.example.com/graphql"}, true},
+		{APIEndpoint{URL: "https://api.example.com/v1/GraphQL/"}, true},
+		{APIEndpoint{URL: "https://api.example.com/query", GraphQL: true}, true},
+		{APIEndpoint{URL: "https://api.example.com/graphql-docs"}, false},
+	} {
+		if got := isGraphQLEndpoint(test.endpoint); got != test.expected {
+			t.Errorf("Expected %v for %s, got %v", test.expected, test.endpoint.URL, got)
+		}
+	}
+}
+
+func TestExportGraphQLSchemaDrift(t *testing.T) {
+	schema := `{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"},"types":[
+		{"kind":"OBJECT","name":"Query","fields":[{"name":"user","args":[{"name":"id"}]},{"name":"legacyUsers","args":[]}]},
+		{"kind":"OBJECT","name":"Mutation","fields":[{"name":"updateUser","args":[]}]},
+		{"kind":"OBJECT","name":"__Type","fields":[{"name":"name","args":[]}]}]}`
+	server := graphQLServer(&schema)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := GraphQLConfig{Enabled: true, SchemaDir: dir}
+	endpoint := APIEndpoint{URL: server.URL + "/graphql", Method: "POST"}
+
+	drift, err := exportGraphQLSchema(server.Client(), endpoint, Auth{}, config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !drift.FirstScan || drift.Types != 3 {
+		t.Errorf("Expected a first scan with 3 types, got %+v", drift)
+	}
+	if _, err := os.Stat(filepath.Join(dir, schemaFileName(endpoint.URL))); err != nil {
+		t.Errorf("Expected the schema to be saved, got %v", err)
+	}
+
+	schema = `{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"},"types":[
+		{"kind":"OBJECT","name":"Query","fields":[{"name":"user","args":[{"name":"id"}]},{"name":"auditLog","args":[]}]},
+		{"kind":"OBJECT","name":"Mutation","fields":[{"name":"updateUser","args":[]},{"name":"deleteUser","args":[]}]}]}`
+	drift, err = exportGraphQLSchema(server.Client(), endpoint, Auth{}, config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if drift.FirstScan || !drift.Changed() {
+		t.Fatalf("Expected drift from the previous scan, got %+v", drift)
+	}
+	if strings.Join(drift.AddedMutations, ",") != "deleteUser" || strings.Join(drift.AddedFields, ",") != "Query.auditLog" || strings.Join(drift.RemovedFields, ",") != "Query.legacyUsers" {
+		t.Errorf("Unexpected drift: %+v", drift)
+	}
+
+	report := formatSchemaDrift(*drift, localizer{})
+	for _, line := range []string{"- New mutation: deleteUser", "- New field: Query.auditLog", "- Removed field: Query.legacyUsers"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", line, report)
+		}
+	}
+
+	drift, _ = exportGraphQLSchema(server.Client(), endpoint, Auth{}, config)
+	if drift == nil || drift.Changed() {
+		t.Errorf("Expected no drift on an unchanged schema, got %+v", drift)
+	}
+}
+
+func TestExportGraphQLSchemaIntrospectionDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"introspection is disabled"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := exportGraphQLSchema(server.Client(), APIEndpoint{URL: server.URL}, Auth{}, GraphQLConfig{SchemaDir: os.TempDir()})
+	if err == nil || err.Error() != "introspection failed: introspection is disabled" {
+		t.Errorf("Expected the GraphQL error to be reported, got %v", err)
+	}
+}
+
+func TestRunTestsExportsGraphQLSchema(t *testing.T) {
+	schema := `{"queryType":{"name":"Query"},"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"ping","args":[]}]}]}`
+	server := graphQLServer(&schema)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{
+		APIEndpoints: []APIEndpoint{{URL: server.URL + "/graphql", Method: "POST"}, {URL: server.URL + "/rest", Method: "GET"}},
+		GraphQL:      GraphQLConfig{Enabled: true, SchemaDir: dir},
+	}
+	results := runTests(config)
+	if results[0].GraphQL == nil || results[0].GraphQL.Types != 1 {
+		t.Errorf("Expected the GraphQL endpoint's schema to be exported, got %+v", results[0].GraphQL)
+	}
+	if results[1].GraphQL != nil {
+		t.Errorf("Expected other endpoints not to be introspected")
+	}
+}
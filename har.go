@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	urlTokensTestName     = "Tokens in URL Test"
+	sensitiveDataTestName = "Sensitive Data Exposure Test"
+)
+
+// harLog is the subset of the HTTP Archive format the passive analysis reads
+type harLog struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method  string      `json:"method"`
+		URL     string      `json:"url"`
+		Headers []harHeader `json:"headers"`
+	} `json:"request"`
+	Response struct {
+		Status  int         `json:"status"`
+		Headers []harHeader `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// URLTokenError is returned when credentials travel in a URL, where they end up in logs and browser history
+type URLTokenError struct{ message string }
+
+func (e URLTokenError) Error() string { return e.message }
+
+// SensitiveDataError is returned when a response body contains secrets or personal data
+type SensitiveDataError struct{ message string }
+
+func (e SensitiveDataError) Error() string { return e.message }
+
+// sensitiveParamWords mark query parameters whose values are credentials
+var sensitiveParamWords = []string{"token", "key", "secret", "password", "passwd", "session", "auth", "signature", "credential"}
+
+// sensitiveDataPatterns match secrets and personal data that should never appear in a response
+var sensitiveDataPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH )?PRIVATE KEY-----`)},
+	{"password field", regexp.MustCompile(`"(password|passwd|pwd)"\s*:\s*"[^"]+"`)},
+	{"US social security number", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+}
+
+// cardNumberPattern matches candidate payment card numbers, confirmed with the Luhn checksum
+var cardNumberPattern = regexp.MustCompile(`\b[3-6]\d(?:[ -]?\d){11,17}\b`)
+
+// readHAR decodes an HTTP Archive
+func readHAR(data []byte) ([]harEntry, error) {
+	var har harLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %v", err)
+	}
+	return har.Log.Entries, nil
+}
+
+func harHeaders(headers []harHeader) http.Header {
+	header := http.Header{}
+	for _, h := range headers {
+		header.Add(h.Name, h.Value)
+	}
+	return header
+}
+
+func sensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveParamWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveData returns the kinds of sensitive data found in a response body
+func sensitiveData(body string) []string {
+	var found []string
+	for _, p := range sensitiveDataPatterns {
+		if p.pattern.MatchString(body) {
+			found = append(found, p.name)
+		}
+	}
+	for _, candidate := range cardNumberPattern.FindAllString(body, -1) {
+		if luhnValid(strings.NewReplacer(" ", "", "-", "").Replace(candidate)) {
+			found = append(found, "payment card number")
+			break
+		}
+	}
+	return found
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// harEndpoint collects the findings of the recorded requests to one URL
+type harEndpoint struct {
+	result        EndpointResult
+	headerIssues  []string
+	headerPenalty int
+	tokens        map[string]bool
+	sensitive     map[string]bool
+}
+
+// analyzeHAR produces findings from recorded traffic without sending any
+// requests: missing security headers, credentials in URLs and sensitive data
+// in response bodies. Requests are grouped by URL without its query string.
+func analyzeHAR(entries []harEntry, config SecurityHeadersConfig) ([]EndpointResult, error) {
+	policy, err := headerPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var order []string
+	endpoints := map[string]*harEndpoint{}
+	for _, entry := range entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || u.Host == "" || entry.Response.Status == 0 {
+			continue
+		}
+		base := *u
+		base.RawQuery = ""
+		base.Fragment = ""
+		key := base.String()
+		endpoint, ok := endpoints[key]
+		if !ok {
+			endpoint = &harEndpoint{result: EndpointResult{URL: key, Score: 100}, tokens: map[string]bool{}, sensitive: map[string]bool{}}
+			endpoints[key] = endpoint
+			order = append(order, key)
+		}
+
+		// Report the response with the weakest headers
+		resp := &http.Response{Header: harHeaders(entry.Response.Headers), Request: &http.Request{URL: u}}
+		if issues, penalty := checkSecurityHeaders(resp, policy); penalty > endpoint.headerPenalty || endpoint.headerIssues == nil && len(issues) > 0 {
+			endpoint.headerIssues, endpoint.headerPenalty = issues, penalty
+		}
+
+		for _, secret := range urlSecrets(u) {
+			endpoint.tokens[secret] = true
+		}
+
+		body := entry.Response.Content.Text
+		if entry.Response.Content.Encoding == "base64" {
+			if decoded, err := base64.StdEncoding.DecodeString(body); err == nil {
+				body = string(decoded)
+			}
+		}
+		for _, kind := range sensitiveData(body) {
+			endpoint.sensitive[kind] = true
+		}
+	}
+
+	results := make([]EndpointResult, 0, len(order))
+	for _, key := range order {
+		endpoint := endpoints[key]
+		result := endpoint.result
+
+		var headersErr error
+		if len(endpoint.headerIssues) > 0 {
+			headersErr = SecurityHeadersError{message: strings.Join(endpoint.headerIssues, "; "), penalty: endpoint.headerPenalty}
+			result.Score -= endpoint.headerPenalty
+		}
+		result.Results = append(result.Results, newTestResult("Security Headers Test", headersErr, 0))
+
+		var tokensErr error
+		if len(endpoint.tokens) > 0 {
+			tokensErr = URLTokenError{"secrets in URLs: " + strings.Join(sortedKeys(endpoint.tokens), ", ")}
+			result.Score -= severityPenalty(testSeverity(urlTokensTestName))
+		}
+		result.Results = append(result.Results, newTestResult(urlTokensTestName, tokensErr, 0))
+
+		var sensitiveErr error
+		if len(endpoint.sensitive) > 0 {
+			sensitiveErr = SensitiveDataError{"recorded responses expose " + strings.Join(sortedKeys(endpoint.sensitive), ", ")}
+			result.Score -= severityPenalty(testSeverity(sensitiveDataTestName))
+		}
+		result.Results = append(result.Results, newTestResult(sensitiveDataTestName, sensitiveErr, 0))
+		results = append(results, result)
+	}
+	clampScores(results)
+	return results, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// passiveCommand implements the passive subcommand, which reports the
+// findings of recorded traffic for environments where active scanning is not allowed
+func passiveCommand(args []string) error {
+	flags := flag.NewFlagSet("passive", flag.ExitOnError)
+	input := flags.String("har", "", "HTTP Archive (HAR) of the recorded traffic")
+	configFile := flags.String("config", "config.yaml", "configuration with the header policy, CVSS overrides, language and signing key to use, if present")
+	format := flags.String("format", "", "also export the findings in the given format (json, defectdojo, faraday, cyclonedx, csv, xlsx)")
+	output := flags.String("output", "", "file to write the export to (defaults to stdout)")
+	lang := flags.String("lang", "", "language of the text report, en or es")
+	ci := flags.Bool("ci", false, "emit CI annotations and a markdown summary, and exit non-zero on findings")
+	flags.Parse(args)
+	if *input == "" {
+		return invalidConfig(fmt.Errorf("-har is required"))
+	}
+
+	config := &Config{}
+	if _, err := os.Stat(*configFile); err == nil {
+		if config, err = loadConfig(*configFile); err != nil {
+			return invalidConfig(err)
+		}
+	}
+	if *lang != "" {
+		config.Language = *lang
+	}
+	l, err := newLocalizer(config.Language)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	var signingKey ed25519.PrivateKey
+	if config.Signing.PrivateKeyFile != "" {
+		if signingKey, err = loadSigningKey(config.Signing.PrivateKeyFile); err != nil {
+			return invalidConfig(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		return fmt.Errorf("failed to read HAR file: %v", err)
+	}
+	entries, err := readHAR(data)
+	if err != nil {
+		return err
+	}
+	results, err := analyzeHAR(entries, config.SecurityHeaders)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no analyzable entries in %s", *input)
+	}
+	log.Printf("Analyzed %d recorded requests to %d endpoints", len(entries), len(results))
+	applyCVSS(results, config.CVSS)
+
+	generateDetailedReport(results, nil, l)
+	if *format != "" {
+		if err := writeExport(*format, *output, results, nil, signingKey); err != nil {
+			return err
+		}
+	}
+	if *ci {
+		if err := writeCIOutput(results, nil, *configFile); err != nil {
+			return err
+		}
+		if hasFailures(results) {
+			os.Exit(1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Scan engine tests build a fake target, run the scan against it and compare
+// the results with a golden file in testdata/golden. After an intended change
+// to results or scores, rewrite the golden files with:
+//
+//	go test -run Golden -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden result files in testdata/golden")
+
+// fakeTarget builds the HTTP target of a scan engine test from canned
+// responses. Conditional responses are tried first, in the order they were
+// added, then the routes; anything else is answered with 404 Not Found.
+type fakeTarget struct {
+	headers    http.Header
+	routes     map[string]fakeResponse
+	conditions []fakeResponse
+}
+
+type fakeResponse struct {
+	match  func(r *http.Request, body string) bool
+	status int
+	body   string
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{headers: http.Header{}, routes: map[string]fakeResponse{}}
+}
+
+// header adds a header to every response of the target
+func (f *fakeTarget) header(name, value string) *fakeTarget {
+	f.headers.Add(name, value)
+	return f
+}
+
+// respond answers requests for the path with the status and body. An empty
+// method answers requests with any method.
+func (f *fakeTarget) respond(method, path string, status int, body string) *fakeTarget {
+	f.routes[method+" "+path] = fakeResponse{status: status, body: body}
+	return f
+}
+
+// respondWhen answers the requests that match, whatever their path, with the status and body
+func (f *fakeTarget) respondWhen(match func(r *http.Request, body string) bool, status int, body string) *fakeTarget {
+	f.conditions = append(f.conditions, fakeResponse{match: match, status: status, body: body})
+	return f
+}
+
+// start serves the target until the test ends
+func (f *fakeTarget) start(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		for name, values := range f.headers {
+			w.Header()[name] = values
+		}
+		response, ok := f.response(r, string(body))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeTarget) response(r *http.Request, body string) (fakeResponse, bool) {
+	for _, condition := range f.conditions {
+		if condition.match(r, body) {
+			return condition, true
+		}
+	}
+	if response, ok := f.routes[r.Method+" "+r.URL.Path]; ok {
+		return response, true
+	}
+	response, ok := f.routes[" "+r.URL.Path]
+	return response, ok
+}
+
+// checkGolden compares the results with testdata/golden/<name>.json. Durations
+// and the request evidence vary from run to run and are left out, and the
+// target's address is replaced with http://target.
+func checkGolden(t *testing.T, name string, server *httptest.Server, results []EndpointResult) {
+	t.Helper()
+	normalized := make([]EndpointResult, len(results))
+	for i, result := range results {
+		tests := make([]TestResult, len(result.Results))
+		for j, test := range result.Results {
+			test.Duration = 0
+			test.Request = nil
+			test.Curl = ""
+			test.HTTPie = ""
+			tests[j] = test
+		}
+		result.Results = tests
+		normalized[i] = result
+	}
+	data, err := json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode results: %v", err)
+	}
+	got := append(bytes.ReplaceAll(data, []byte(server.URL), []byte("http://target")), '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s (run go test -run Golden -update to create it): %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Results differ from %s (run go test -run Golden -update if the change is intended):\n%s", path, got)
+	}
+}
+
+func TestFakeTarget(t *testing.T) {
+	server := newFakeTarget().
+		header("X-Frame-Options", "DENY").
+		respond(http.MethodGet, "/users", http.StatusOK, "[]").
+		respond("", "/health", http.StatusNoContent, "").
+		respondWhen(func(r *http.Request, body string) bool { return strings.Contains(body, "'") }, http.StatusInternalServerError, "SQL syntax").
+		start(t)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/users", "", http.StatusOK},
+		{http.MethodPost, "/users", "", http.StatusNotFound},
+		{http.MethodDelete, "/health", "", http.StatusNoContent},
+		{http.MethodPost, "/users", "name='", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected status %d for %s %s, got %d", tt.status, tt.method, tt.path, resp.StatusCode)
+		}
+		if resp.Header.Get("X-Frame-Options") != "DENY" {
+			t.Errorf("Expected X-Frame-Options on every response, got %v", resp.Header)
+		}
+	}
+}
+
+func TestGoldenHardenedTarget(t *testing.T) {
+	server := newFakeTarget().
+		header("Content-Type", "application/json").
+		header("X-Content-Type-Options", "nosniff").
+		respond("", "/api/login", http.StatusOK, `{"status":"ok"}`).
+		start(t)
+
+	results := runTests(&Config{
+		APIEndpoints:      []APIEndpoint{{Name: "Login", URL: server.URL + "/api/login", Method: http.MethodPost, Body: "username=%s"}},
+		InjectionPayloads: []string{"' OR '1'='1", "admin' --"},
+		SecurityHeaders:   SecurityHeadersConfig{Enabled: true, Headers: []HeaderPolicy{{Name: "X-Content-Type-Options", Pattern: "^nosniff$"}}},
+	})
+	checkGolden(t, "hardened_target", server, results)
+}
+
+func TestGoldenVulnerableTarget(t *testing.T) {
+	server := newFakeTarget().
+		respondWhen(func(r *http.Request, body string) bool {
+			return r.Method == http.MethodPost && strings.Contains(body, "'")
+		}, http.StatusInternalServerError, "You have an error in your SQL syntax").
+		respond(http.MethodPost, "/api/users", http.StatusForbidden, `{"error":"forbidden"}`).
+		respond(http.MethodGet, "/api/users", http.StatusMethodNotAllowed, "").
+		start(t)
+
+	results := runTests(&Config{
+		APIEndpoints: []APIEndpoint{
+			{Name: "Create User", URL: server.URL + "/api/users?api_key=abc123", Method: http.MethodPost, Body: "name=%s"},
+			{Name: "List Users", URL: server.URL + "/api/users", Method: http.MethodGet},
+		},
+		InjectionPayloads: []string{"' OR '1'='1"},
+		SecurityHeaders:   SecurityHeadersConfig{Enabled: true, Headers: []HeaderPolicy{{Name: "X-Content-Type-Options", Pattern: "^nosniff$"}}},
+	})
+	checkGolden(t, "vulnerable_target", server, results)
+}
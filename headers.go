@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecurityHeadersConfig represents the security header policy checked on every endpoint
+type SecurityHeadersConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	Headers []HeaderPolicy `yaml:"headers"`
+	Ignore  []string       `yaml:"ignore"`
+}
+
+// HeaderPolicy describes a required response header. Pattern, if set, is a
+// regular expression the value must match. Headers marked skip_for_json only
+// matter to browsers rendering the response and are not checked on JSON responses.
+type HeaderPolicy struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Severity    string `yaml:"severity"`
+	SkipForJSON bool   `yaml:"skip_for_json"`
+	HTTPSOnly   bool   `yaml:"https_only"`
+}
+
+// defaultHeaderPolicy is checked when no headers are configured
+var defaultHeaderPolicy = []HeaderPolicy{
+	{Name: "Strict-Transport-Security", Pattern: `max-age=\d+`, Severity: "high", HTTPSOnly: true},
+	{Name: "Content-Security-Policy", Severity: "medium", SkipForJSON: true},
+	{Name: "X-Content-Type-Options", Pattern: `(?i)^nosniff$`, Severity: "medium"},
+	{Name: "X-Frame-Options", Pattern: `(?i)^(deny|sameorigin)$`, Severity: "low", SkipForJSON: true},
+	{Name: "Referrer-Policy", Severity: "low", SkipForJSON: true},
+	{Name: "Permissions-Policy", Severity: "low", SkipForJSON: true},
+}
+
+// hstsMinMaxAge is the shortest HSTS max-age accepted by the browser preload lists (one year)
+const hstsMinMaxAge = 31536000
+
+// headerSeverityPenalty is the score penalty of a single header issue, so that
+// a missing X-Frame-Options costs less than a missing HSTS header
+var headerSeverityPenalty = map[string]int{
+	"low":      2,
+	"medium":   5,
+	"high":     10,
+	"critical": 20,
+}
+
+// SecurityHeadersError lists the header issues found on a response
+type SecurityHeadersError struct {
+	message string
+	penalty int
+}
+
+func (e SecurityHeadersError) Error() string { return e.message }
+
+// headerPolicy returns the configured policy, or the default one, without the ignored headers
+func headerPolicy(config SecurityHeadersConfig) ([]HeaderPolicy, error) {
+	policy := config.Headers
+	if len(policy) == 0 {
+		policy = defaultHeaderPolicy
+	}
+	ignored := map[string]bool{}
+	for _, name := range config.Ignore {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+
+	var checked []HeaderPolicy
+	for _, header := range policy {
+		if header.Name == "" {
+			return nil, fmt.Errorf("security header policy entries need a name")
+		}
+		if header.Severity == "" {
+			header.Severity = "medium"
+		}
+		if _, ok := headerSeverityPenalty[header.Severity]; !ok {
+			return nil, fmt.Errorf("unknown severity %q for header %s", header.Severity, header.Name)
+		}
+		if _, err := regexp.Compile(header.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern for header %s: %v", header.Name, err)
+		}
+		if !ignored[http.CanonicalHeaderKey(header.Name)] {
+			checked = append(checked, header)
+		}
+	}
+	return checked, nil
+}
+
+// headerIssue is a single problem with a response header
+type headerIssue struct {
+	message  string
+	severity string
+}
+
+// checkSecurityHeaders returns one issue per header of the policy the response
+// does not satisfy, followed by the weaknesses of the HSTS and CSP policies it sets
+func checkSecurityHeaders(resp *http.Response, policy []HeaderPolicy) ([]string, int) {
+	json := strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "json")
+	https := resp.Request != nil && resp.Request.URL.Scheme == "https"
+
+	var found []headerIssue
+	for _, header := range policy {
+		if header.SkipForJSON && json || header.HTTPSOnly && !https {
+			continue
+		}
+		value := resp.Header.Get(header.Name)
+		switch {
+		case value == "":
+			found = append(found, headerIssue{"missing " + header.Name, header.Severity})
+		case header.Pattern != "" && !regexp.MustCompile(header.Pattern).MatchString(value):
+			found = append(found, headerIssue{fmt.Sprintf("%s has unexpected value %q", header.Name, value), header.Severity})
+		default:
+			found = append(found, headerQualityIssues(header.Name, value)...)
+		}
+	}
+
+	var issues []string
+	penalty := 0
+	for _, issue := range found {
+		issues = append(issues, fmt.Sprintf("%s (%s)", issue.message, issue.severity))
+		penalty += headerSeverityPenalty[issue.severity]
+	}
+	return issues, penalty
+}
+
+// headerQualityIssues returns the weaknesses of a header's policy, for the headers whose value is a policy
+func headerQualityIssues(name, value string) []headerIssue {
+	switch http.CanonicalHeaderKey(name) {
+	case "Strict-Transport-Security":
+		return hstsIssues(value)
+	case "Content-Security-Policy":
+		return cspIssues(value)
+	}
+	return nil
+}
+
+// hstsIssues checks that an HSTS policy is long-lived, covers subdomains and is eligible for preloading
+func hstsIssues(value string) []headerIssue {
+	maxAge := -1
+	subdomains, preload := false, false
+	for _, directive := range strings.Split(value, ";") {
+		directive = strings.TrimSpace(directive)
+		lower := strings.ToLower(directive)
+		switch {
+		case strings.HasPrefix(lower, "max-age="):
+			if age, err := strconv.Atoi(strings.Trim(directive[len("max-age="):], `"`)); err == nil {
+				maxAge = age
+			}
+		case lower == "includesubdomains":
+			subdomains = true
+		case lower == "preload":
+			preload = true
+		}
+	}
+
+	var issues []headerIssue
+	if maxAge >= 0 && maxAge < hstsMinMaxAge {
+		issues = append(issues, headerIssue{fmt.Sprintf("Strict-Transport-Security max-age=%d is shorter than one year", maxAge), "medium"})
+	}
+	if !subdomains {
+		issues = append(issues, headerIssue{"Strict-Transport-Security does not include subdomains", "low"})
+	}
+	if !preload {
+		issues = append(issues, headerIssue{"Strict-Transport-Security is not eligible for preloading", "low"})
+	}
+	return issues
+}
+
+// cspIssues flags CSP sources that allow inline scripts, eval or scripts from any origin
+func cspIssues(value string) []headerIssue {
+	var issues []headerIssue
+	for _, directive := range strings.Split(value, ";") {
+		fields := strings.Fields(strings.ToLower(directive))
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		scripts := name == "script-src" || name == "default-src"
+		for _, source := range fields[1:] {
+			switch {
+			case source == "'unsafe-inline'" && scripts:
+				issues = append(issues, headerIssue{"Content-Security-Policy allows 'unsafe-inline' in " + name, "medium"})
+			case source == "'unsafe-eval'" && scripts:
+				issues = append(issues, headerIssue{"Content-Security-Policy allows 'unsafe-eval' in " + name, "medium"})
+			case source == "*" || source == "http:" || source == "https:":
+				issues = append(issues, headerIssue{fmt.Sprintf("Content-Security-Policy allows any origin (%s) in %s", source, name), "medium"})
+			}
+		}
+	}
+	return issues
+}
+
+// performSecurityHeadersTest sends the endpoint's request and checks the response headers against the policy
+func performSecurityHeadersTest(client *http.Client, endpoint APIEndpoint, config SecurityHeadersConfig) error {
+	policy, err := headerPolicy(config)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkWAF(resp); err != nil {
+		return err
+	}
+
+	issues, penalty := checkSecurityHeaders(resp, policy)
+	if len(issues) == 0 {
+		return nil
+	}
+	return SecurityHeadersError{message: strings.Join(issues, "; "), penalty: penalty}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func headerServer(contentType string, headers map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+	}))
+}
+
+func TestSecurityHeadersDefaultPolicy(t *testing.T) {
+	server := headerServer("text/html", map[string]string{"X-Content-Type-Options": "nosniff", "X-Frame-Options": "ALLOWALL", "Referrer-Policy": "no-referrer"})
+	defer server.Close()
+
+	err := performSecurityHeadersTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, SecurityHeadersConfig{Enabled: true})
+	headersErr, ok := err.(SecurityHeadersError)
+	if !ok {
+		t.Fatalf("Expected a SecurityHeadersError, got %v", err)
+	}
+	want := `missing Content-Security-Policy (medium); X-Frame-Options has unexpected value "ALLOWALL" (low); missing Permissions-Policy (low)`
+	if headersErr.Error() != want || headersErr.penalty != 9 {
+		t.Errorf("Expected %q with penalty 9, got %q with penalty %d", want, headersErr.Error(), headersErr.penalty)
+	}
+}
+
+func TestSecurityHeadersSkipBrowserHeadersForJSON(t *testing.T) {
+	server := headerServer("application/json", map[string]string{"X-Content-Type-Options": "nosniff"})
+	defer server.Close()
+
+	if err := performSecurityHeadersTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, SecurityHeadersConfig{Enabled: true}); err != nil {
+		t.Errorf("Expected browser-only headers to be skipped for JSON, got %v", err)
+	}
+}
+
+func TestSecurityHeadersHSTSOnlyOverHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}))
+	defer server.Close()
+
+	err := performSecurityHeadersTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, SecurityHeadersConfig{Enabled: true})
+	if err == nil || !strings.Contains(err.Error(), "missing Strict-Transport-Security (high)") {
+		t.Errorf("Expected HSTS to be required over HTTPS, got %v", err)
+	}
+}
+
+func TestSecurityHeadersCustomPolicy(t *testing.T) {
+	server := headerServer("application/json", map[string]string{"Cache-Control": "public"})
+	defer server.Close()
+
+	config := SecurityHeadersConfig{
+		Enabled: true,
+		Headers: []HeaderPolicy{
+			{Name: "Cache-Control", Pattern: "no-store", Severity: "high"},
+			{Name: "X-XSS-Protection", Severity: "low"},
+		},
+		Ignore: []string{"x-xss-protection"},
+	}
+	err := performSecurityHeadersTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, config)
+	if err == nil || err.Error() != `Cache-Control has unexpected value "public" (high)` {
+		t.Errorf("Expected only the Cache-Control issue, got %v", err)
+	}
+
+	for _, bad := range []HeaderPolicy{{Severity: "low"}, {Name: "X", Severity: "severe"}, {Name: "X", Pattern: "("}} {
+		if _, err := headerPolicy(SecurityHeadersConfig{Headers: []HeaderPolicy{bad}}); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestRunTestsSecurityHeadersPenalty(t *testing.T) {
+	server := headerServer("text/html", nil)
+	defer server.Close()
+
+	config := &Config{APIEndpoints: []APIEndpoint{{URL: server.URL, Method: "GET"}}, SecurityHeaders: SecurityHeadersConfig{Enabled: true, Ignore: []string{"X-Frame-Options", "Referrer-Policy", "Permissions-Policy"}}}
+	results := runTests(config)
+	for _, r := range results[0].Results {
+		if r.TestName == "Security Headers Test" && !r.Failed() {
+			t.Errorf("Expected the header test to fail, got %+v", r)
+		}
+	}
+	// Auth Test passes on a 200 and the remaining tests are unaffected, so only the
+	// missing CSP (5) and X-Content-Type-Options (5) count against the score
+	if results[0].Score != 90 {
+		t.Errorf("Expected a score of 90, got %d", results[0].Score)
+	}
+}
+
+func TestSecurityHeadersPolicyQuality(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Strict-Transport-Security", "max-age=86400")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' 'unsafe-eval'; img-src *; style-src 'unsafe-inline'")
+	}))
+	defer server.Close()
+
+	config := SecurityHeadersConfig{Enabled: true, Ignore: []string{"X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy", "Permissions-Policy"}}
+	err := performSecurityHeadersTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, config)
+	headersErr, ok := err.(SecurityHeadersError)
+	if !ok {
+		t.Fatalf("Expected a SecurityHeadersError, got %v", err)
+	}
+	want := []string{
+		"Strict-Transport-Security max-age=86400 is shorter than one year (medium)",
+		"Strict-Transport-Security does not include subdomains (low)",
+		"Strict-Transport-Security is not eligible for preloading (low)",
+		"Content-Security-Policy allows 'unsafe-inline' in script-src (medium)",
+		"Content-Security-Policy allows 'unsafe-eval' in script-src (medium)",
+		"Content-Security-Policy allows any origin (*) in img-src (medium)",
+	}
+	if headersErr.Error() != strings.Join(want, "; ") || headersErr.penalty != 24 {
+		t.Errorf("Expected %q with penalty 24, got %q with penalty %d", strings.Join(want, "; "), headersErr.Error(), headersErr.penalty)
+	}
+}
+
+func TestSecurityHeadersStrongPolicies(t *testing.T) {
+	if issues := hstsIssues("max-age=63072000; includeSubDomains; preload"); len(issues) != 0 {
+		t.Errorf("Expected a preload-ready HSTS policy to pass, got %v", issues)
+	}
+	if issues := cspIssues("default-src 'none'; script-src 'self' 'nonce-abc'; frame-ancestors 'none'"); len(issues) != 0 {
+		t.Errorf("Expected a strict CSP to pass, got %v", issues)
+	}
+}
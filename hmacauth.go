@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStringToSign covers the parts of a request most HMAC schemes sign
+const defaultStringToSign = "{method}\n{path}\n{query}\n{timestamp}\n{body_sha256}"
+
+// HMACConfig represents a custom HMAC request-signing scheme. The string to
+// sign and the header value are templates with {placeholder} fields.
+type HMACConfig struct {
+	KeyID           string `yaml:"key_id"`
+	Secret          string `yaml:"secret"`
+	Algorithm       string `yaml:"algorithm"`
+	Encoding        string `yaml:"encoding"`
+	Header          string `yaml:"header"`
+	StringToSign    string `yaml:"string_to_sign"`
+	HeaderValue     string `yaml:"header_value"`
+	TimestampHeader string `yaml:"timestamp_header"`
+	TimestampFormat string `yaml:"timestamp_format"`
+	NonceHeader     string `yaml:"nonce_header"`
+}
+
+// hmacAlgorithms maps the supported algorithm names to their hash functions
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hmacSigner signs requests according to an HMACConfig
+type hmacSigner struct {
+	config HMACConfig
+	hash   func() hash.Hash
+	now    func() time.Time
+	nonce  func() string
+}
+
+func newHMACSigner(config HMACConfig) (*hmacSigner, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("hmac requires a secret")
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = "sha256"
+	}
+	newHash, ok := hmacAlgorithms[strings.ToLower(config.Algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unknown hmac algorithm %q (supported: sha1, sha256, sha512)", config.Algorithm)
+	}
+	switch config.Encoding {
+	case "":
+		config.Encoding = "hex"
+	case "hex", "base64":
+	default:
+		return nil, fmt.Errorf("unknown hmac encoding %q (supported: hex, base64)", config.Encoding)
+	}
+	switch config.TimestampFormat {
+	case "", "unix", "unix_ms", "rfc3339":
+	default:
+		return nil, fmt.Errorf("unknown hmac timestamp format %q (supported: unix, unix_ms, rfc3339)", config.TimestampFormat)
+	}
+	if config.Header == "" {
+		config.Header = "Authorization"
+	}
+	if config.StringToSign == "" {
+		config.StringToSign = defaultStringToSign
+	}
+	if config.HeaderValue == "" {
+		config.HeaderValue = "{signature}"
+	}
+	return &hmacSigner{config: config, hash: newHash, now: time.Now, nonce: randomNonce}, nil
+}
+
+func randomNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *hmacSigner) timestamp() string {
+	now := s.now().UTC()
+	switch s.config.TimestampFormat {
+	case "unix_ms":
+		return strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	case "rfc3339":
+		return now.Format(time.RFC3339)
+	default:
+		return strconv.FormatInt(now.Unix(), 10)
+	}
+}
+
+// sign computes the signature over the templated string and sets the signature,
+// timestamp and nonce headers
+func (s *hmacSigner) sign(req *http.Request) error {
+	body := []byte{}
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %v", err)
+		}
+		body, err = ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %v", err)
+		}
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	timestamp := s.timestamp()
+	nonce := s.nonce()
+	fields := []string{
+		"{method}", req.Method,
+		"{host}", host,
+		"{path}", path,
+		"{query}", req.URL.RawQuery,
+		"{timestamp}", timestamp,
+		"{nonce}", nonce,
+		"{content_type}", req.Header.Get("Content-Type"),
+		"{body}", string(body),
+		"{body_sha256}", sha256Hex(body),
+		"{key_id}", s.config.KeyID,
+	}
+
+	mac := hmac.New(s.hash, []byte(s.config.Secret))
+	mac.Write([]byte(strings.NewReplacer(fields...).Replace(s.config.StringToSign)))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	if s.config.Encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	fields = append(fields, "{signature}", signature)
+	req.Header.Set(s.config.Header, strings.NewReplacer(fields...).Replace(s.config.HeaderValue))
+	if s.config.TimestampHeader != "" {
+		req.Header.Set(s.config.TimestampHeader, timestamp)
+	}
+	if s.config.NonceHeader != "" {
+		req.Header.Set(s.config.NonceHeader, nonce)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSignature(t *testing.T) {
+	signer, err := newHMACSigner(HMACConfig{
+		KeyID:           "scanner",
+		Secret:          "secret",
+		Header:          "X-Signature",
+		StringToSign:    "{method}\n{path}?{query}\n{timestamp}\n{nonce}\n{body}",
+		HeaderValue:     "HMAC {key_id}:{signature}",
+		TimestampHeader: "X-Timestamp",
+		NonceHeader:     "X-Nonce",
+	})
+	if err != nil {
+		t.Fatalf("Expected valid hmac settings, got %v", err)
+	}
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+	signer.nonce = func() string { return "n1" }
+
+	req, _ := http.NewRequest("POST", "https://api.example.com/orders?id=1", strings.NewReader(`{"a":1}`))
+	if err := signer.sign(req); err != nil {
+		t.Fatalf("Expected the request to be signed, got %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST\n/orders?id=1\n1700000000\nn1\n{\"a\":1}"))
+	want := "HMAC scanner:" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get("X-Signature"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if req.Header.Get("X-Timestamp") != "1700000000" || req.Header.Get("X-Nonce") != "n1" {
+		t.Errorf("Expected the timestamp and nonce headers, got %v", req.Header)
+	}
+}
+
+func TestHMACBase64AndAlgorithms(t *testing.T) {
+	signer, err := newHMACSigner(HMACConfig{Secret: "secret", Encoding: "base64", StringToSign: "{method} {path}", TimestampFormat: "rfc3339"})
+	if err != nil {
+		t.Fatalf("Expected valid hmac settings, got %v", err)
+	}
+	req, _ := http.NewRequest("GET", "https://api.example.com", nil)
+	if err := signer.sign(req); err != nil {
+		t.Fatalf("Expected the request to be signed, got %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("GET /"))
+	if got := req.Header.Get("Authorization"); got != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Expected a base64 signature in the Authorization header, got %q", got)
+	}
+
+	for _, config := range []HMACConfig{
+		{},
+		{Secret: "s", Algorithm: "md5"},
+		{Secret: "s", Encoding: "base32"},
+		{Secret: "s", TimestampFormat: "iso"},
+	} {
+		if _, err := newHMACSigner(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestHMACAuthTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(r.Method + "\n" + r.URL.Path + "\n\n" + r.Header.Get("X-Timestamp") + "\n" + sha256Hex([]byte("payload"))))
+		if r.Header.Get("Authorization") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	auth := Auth{Type: "hmac", HMAC: &HMACConfig{Secret: "secret", TimestampHeader: "X-Timestamp"}}
+	if err := performAuthTest(server.Client(), APIEndpoint{URL: server.URL + "/items", Method: "POST", Body: "payload"}, auth); err != nil {
+		t.Errorf("Expected the signed request to be accepted, got %v", err)
+	}
+}
+
+func TestHMACSignsEachRetry(t *testing.T) {
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.Header.Get("X-Nonce")
+		for _, seen := range nonces {
+			if nonce == seen {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		nonces = append(nonces, nonce)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(r.Method + "\n" + nonce + "\n" + sha256Hex([]byte("payload"))))
+		if r.Header.Get("Authorization") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Throttle the first attempt so that the request is retried
+		if len(nonces) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL + "/items", Method: "POST", Body: "payload"}
+	auth := Auth{Type: "hmac", HMAC: &HMACConfig{Secret: "secret", StringToSign: "{method}\n{nonce}\n{body_sha256}", NonceHeader: "X-Nonce"}}
+	client, stats := newScanClient(&Config{Auth: auth}, endpoint, nil)
+
+	if err := performHTTPMethodTest(client, endpoint); err != nil {
+		t.Errorf("Expected the retried request to be signed afresh, got %v", err)
+	}
+	if len(nonces) != 2 || stats.throttle.Events() != 1 {
+		t.Errorf("Expected a throttled attempt and a retry with a new nonce, got nonces %v and %d throttle events", nonces, stats.throttle.Events())
+	}
+}
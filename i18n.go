@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reportTranslations maps the English strings of the text report to their
+// translation in each supported language. Missing strings fall back to English.
+var reportTranslations = map[string]map[string]string{
+	"es": {
+		"API Security Scan Detailed Report": "Informe Detallado del Escaneo de Seguridad de la API",
+		"Endpoint: %s":                      "Punto de extremidad: %s",
+		"Address Family: %s":                "Familia de Direcciones: %s",
+		"Scan Metadata:":                    "Metadatos del Análisis:",
+		"Overall Score: %d/100":             "Puntuación General: %d/100",
+		"Throttled: %d time(s) by the target, results may be partial": "Limitado: %d vez/veces por el objetivo, los resultados pueden ser parciales",
+		"Test Results:":                       "Resultados de la Prueba:",
+		"PASSED":                              "APROBADO",
+		"SKIPPED":                             "OMITIDO",
+		"FAILED":                              "FALLIDO",
+		"Details: %s":                         "Detalles: %s",
+		"Confidence: %s":                      "Confianza: %s",
+		"Finding ID: %s":                      "ID del Hallazgo: %s",
+		"Reproduce: %s":                       "Reproducir: %s",
+		"NOT TESTED":                          "NO EVALUADO",
+		"Compliance Assessment:":              "Evaluación de Cumplimiento:",
+		"%d passed, %d failed, %d not tested": "%d aprobados, %d fallidos, %d no evaluados",
+		"Duration: %s":                        "Duración: %s",
+		"TLS Certificate: expires %s (%d days left)": "Certificado TLS: caduca el %s (quedan %d días)",
+		"Risk Assessment:":                           "Evaluación de Riesgos:",
+		"Overall Security Assessment:":               "Evaluación de Seguridad General:",
+		"%s Passed":                                  "%s Aprobada",
+		"Auth Test":                                  "Prueba de Autenticación",
+		"HTTP Method Test":                           "Prueba de Método HTTP",
+		"Security Headers Test":                      "Prueba de Cabeceras de Seguridad",
+		"- Missing security headers leave clients exposed to downgrade, clickjacking and content sniffing attacks.": "- La falta de cabeceras de seguridad expone a los clientes a ataques de degradación, clickjacking y detección de contenido.",
+		"Cookie Security Test":                  "Prueba de Seguridad de Cookies",
+		"GraphQL Schema: %d types, saved to %s": "Esquema GraphQL: %d tipos, guardado en %s",
+		"No previous schema to compare with":    "No hay un esquema anterior con el que comparar",
+		"No schema drift since the last scan":   "Sin cambios en el esquema desde el último análisis",
+		"- New mutation: %s":                    "- Nueva mutación: %s",
+		"- New field: %s":                       "- Nuevo campo: %s",
+		"- Removed field: %s":                   "- Campo eliminado: %s",
+		"- Cookies without Secure, HttpOnly or SameSite can be stolen by scripts or sent on cross-site requests.": "- Las cookies sin Secure, HttpOnly o SameSite pueden ser robadas por scripts o enviadas en peticiones entre sitios.",
+		"Fuzz Test": "Prueba de Fuzzing",
+		"- Unexpected input crashes the endpoint, which can leak implementation details or be used to take it down.": "- Las entradas inesperadas hacen fallar el punto de extremidad, lo que puede revelar detalles de la implementación o usarse para dejarlo fuera de servicio.",
+		"Exposed Services Test": "Prueba de Servicios Expuestos",
+		"- Brokers and datastores open to anonymous clients let anyone read or alter the data behind the API.": "- Los brokers y almacenes de datos abiertos a clientes anónimos permiten a cualquiera leer o modificar los datos de la API.",
+		"Open Ports Test": "Prueba de Puertos Abiertos",
+		"- Management and database ports reachable from outside widen the attack surface beyond the API.": "- Los puertos de administración y de bases de datos accesibles desde fuera amplían la superficie de ataque más allá de la API.",
+		"DNS Security Test":   "Prueba de Seguridad DNS",
+		"Cloud IAM Test":      "Prueba de IAM en la Nube",
+		"OpenID Connect Test": "Prueba de OpenID Connect",
+		"- A weakly configured identity provider lets attackers register clients, skip PKCE or forge tokens for the APIs that trust it.": "- Un proveedor de identidad mal configurado permite a los atacantes registrar clientes, omitir PKCE o falsificar tokens para las APIs que confían en él.",
+		"- The API behind cloud IAM accepts requests without valid credentials for it, so the IAM layer does not protect it.":            "- La API protegida por IAM en la nube acepta solicitudes sin credenciales válidas para ella, por lo que la capa de IAM no la protege.",
+		"- Weak DNS records allow subdomain takeover, certificate mis-issuance or spoofed answers for the API's domain.":                 "- Unos registros DNS débiles permiten la toma de subdominios, la emisión indebida de certificados o respuestas falsificadas para el dominio de la API.",
+		"Tokens in URL Test":           "Prueba de Credenciales en la URL",
+		"Sensitive Data Exposure Test": "Prueba de Exposición de Datos Sensibles",
+		"- Credentials in URLs leak through server logs, proxies, browser history and Referer headers.": "- Las credenciales en las URL se filtran a través de los registros del servidor, los proxies, el historial del navegador y la cabecera Referer.",
+		"- Responses expose secrets or personal data to anyone who can see the traffic.":                "- Las respuestas exponen secretos o datos personales a cualquiera que pueda ver el tráfico.",
+		"Default Credentials Test": "Prueba de Credenciales Predeterminadas",
+		"- Default credentials let anyone log in with a well-known password.": "- Las credenciales predeterminadas permiten a cualquiera iniciar sesión con una contraseña conocida.",
+		"Injection Test": "Prueba de Inyección",
+		"Custom Rules":   "Reglas Personalizadas",
+		"low":            "baja",
+		"medium":         "media",
+		"high":           "alta",
+		"critical":       "crítica",
+		"skipped in safe mode: active checks are disabled":                                             "omitida en modo seguro: las comprobaciones activas están desactivadas",
+		"- Authentication vulnerabilities may allow unauthorized access.":                              "- Las vulnerabilidades de autenticación pueden permitir el acceso no autorizado.",
+		"- Improper HTTP method handling could lead to security bypasses.":                             "- El manejo inadecuado de los métodos HTTP podría permitir eludir controles de seguridad.",
+		"- SQL injection vulnerabilities pose a significant data breach risk.":                         "- Las vulnerabilidades de inyección SQL representan un riesgo significativo de violación de datos.",
+		"No significant risks detected.":                                                               "No se han detectado riesgos significativos.",
+		"No endpoints were analyzed.":                                                                  "No se analizó ningún punto de extremidad.",
+		"Average Security Score: %d/100":                                                               "Puntuación de Seguridad Promedio: %d/100",
+		"Criticality-Weighted Security Score: %d/100":                                                  "Puntuación de Seguridad Ponderada por Criticidad: %d/100",
+		"Critical Vulnerabilities Detected: %d":                                                        "Vulnerabilidades Críticas Detectadas: %d",
+		"Endpoints Throttled by Target: %d (results are partial)":                                      "Puntos de Extremidad Limitados por el Objetivo: %d (resultados parciales)",
+		"Prioritized Findings:":                                                                        "Hallazgos Priorizados:",
+		"%d. %s on %s (severity %s, criticality %s, risk %.1f)":                                        "%d. %s en %s (severidad %s, criticidad %s, riesgo %.1f)",
+		"Overall security posture is strong, but continuous monitoring is recommended.":                "La postura de seguridad general es sólida, pero se recomienda un monitoreo continuo.",
+		"Moderate security risks detected. Address identified vulnerabilities promptly.":               "Se han detectado riesgos de seguridad moderados. Aborde las vulnerabilidades identificadas de manera oportuna.",
+		"Significant security risks identified. Immediate action is required to improve API security.": "Se han identificado riesgos de seguridad significativos. Se requiere una acción inmediata para mejorar la seguridad de la API.",
+	},
+}
+
+// localizer translates report strings into a single language
+type localizer struct {
+	messages map[string]string
+}
+
+// newLocalizer returns the localizer for lang; "" and "en" select English
+func newLocalizer(lang string) (localizer, error) {
+	lang = strings.ToLower(lang)
+	if lang == "" || lang == "en" {
+		return localizer{}, nil
+	}
+	messages, ok := reportTranslations[lang]
+	if !ok {
+		languages := []string{"en"}
+		for supported := range reportTranslations {
+			languages = append(languages, supported)
+		}
+		sort.Strings(languages)
+		return localizer{}, fmt.Errorf("unsupported report language %q (supported: %s)", lang, strings.Join(languages, ", "))
+	}
+	return localizer{messages: messages}, nil
+}
+
+// T translates format and formats it with args
+func (l localizer) T(format string, args ...interface{}) string {
+	if translated, ok := l.messages[format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewLocalizer(t *testing.T) {
+	for _, lang := range []string{"", "en", "es", "ES"} {
+		if _, err := newLocalizer(lang); err != nil {
+			t.Errorf("Expected language %q to be supported, got %v", lang, err)
+		}
+	}
+	if _, err := newLocalizer("fr"); err == nil {
+		t.Errorf("Expected an error for an unsupported language")
+	}
+}
+
+func TestLocalizerFallsBackToEnglish(t *testing.T) {
+	l, _ := newLocalizer("es")
+	if got := l.T("Overall Score: %d/100", 80); got != "Puntuación General: 80/100" {
+		t.Errorf("Expected a Spanish score line, got %q", got)
+	}
+	if got := l.T("Untranslated %s", "text"); got != "Untranslated text" {
+		t.Errorf("Expected an untranslated string to fall back to English, got %q", got)
+	}
+	if got := (localizer{}).T("PASSED"); got != "PASSED" {
+		t.Errorf("Expected English to be returned unchanged, got %q", got)
+	}
+}
+
+func TestTranslationsKeepFormatVerbs(t *testing.T) {
+	for lang, messages := range reportTranslations {
+		for english, translated := range messages {
+			if strings.Count(english, "%") != strings.Count(translated, "%") {
+				t.Errorf("Expected the %s translation of %q to keep its format verbs, got %q", lang, english, translated)
+			}
+		}
+	}
+}
+
+func TestOverallAssessmentInSpanish(t *testing.T) {
+	l, _ := newLocalizer("es")
+	results := []EndpointResult{{
+		URL:   "http://example.com/api",
+		Score: 50,
+		Results: []TestResult{
+			{TestName: "Injection Test", Passed: false, Message: "possible SQL injection"},
+		},
+	}}
+
+	assessment := generateOverallAssessment(results, l)
+	for _, want := range []string{"Puntuación de Seguridad Promedio: 50/100", "Vulnerabilidades Críticas Detectadas: 1", "Se han identificado riesgos"} {
+		if !strings.Contains(assessment, want) {
+			t.Errorf("Expected assessment to contain %q, got %q", want, assessment)
+		}
+	}
+
+	risk := generateRiskAssessment(results[0], l)
+	if !strings.Contains(risk, "inyección SQL") {
+		t.Errorf("Expected a Spanish risk assessment, got %q", risk)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// similarityThreshold is the similarity above which two responses are treated as the same page
+const similarityThreshold = 0.9
+
+// probeResponse is a response to an injection probe, summarised without its body
+type probeResponse struct {
+	Status int
+	Body   bodyFingerprint
+}
+
+// sendProbe sends the endpoint's request with value at the injection point
+// and fingerprints the response. A WAF block is returned as WAFBlockedError.
+func sendProbe(client *http.Client, endpoint APIEndpoint, body string) (probeResponse, error) {
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return probeResponse{}, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return probeResponse{}, fmt.Errorf("request failed: %w", err)
+	}
+	fingerprint, err := fingerprintBody(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return probeResponse{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// A WAF block page differs from other responses without proving anything about the API
+	if vendor := detectWAF(resp, fingerprint.Prefix); vendor != "" {
+		return probeResponse{}, WAFBlockedError{Vendor: vendor}
+	}
+	return probeResponse{Status: resp.StatusCode, Body: fingerprint}, nil
+}
+
+// tautologyPattern matches comparisons such as 1=1 or '1'='1 in payloads
+var tautologyPattern = regexp.MustCompile(`(['"]?)(\w+)(['"]?)\s*=\s*(['"]?)(\w+)`)
+
+// booleanCounterpart turns a payload containing an always-true comparison into
+// the same payload with an always-false one, e.g. ' OR '1'='1 into ' OR '1'='2
+func booleanCounterpart(payload string) (string, bool) {
+	for _, match := range tautologyPattern.FindAllStringSubmatchIndex(payload, -1) {
+		left := payload[match[4]:match[5]]
+		right := payload[match[10]:match[11]]
+		if left != right {
+			continue
+		}
+		// The new value must not start with the old one, so that the false
+		// payload does not contain the true one
+		different := "x" + right
+		if n, err := strconv.Atoi(right); err == nil {
+			different = strconv.Itoa(n + 1)
+		}
+		return payload[:match[10]] + different + payload[match[11]:], true
+	}
+	return "", false
+}
+
+// controlValue returns a harmless value as long as payload, used to tell
+// responses to the payload apart from responses to any unexpected input
+func controlValue(payload string) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	value := make([]byte, len(payload))
+	for i := range value {
+		value[i] = letters[random.Intn(len(letters))]
+	}
+	return string(value)
+}
+
+var digitsPattern = regexp.MustCompile(`\d+`)
+
+// responseTokens splits a body into word counts, ignoring reflected input and
+// replacing numbers so that ids, timestamps and counters do not count as changes
+func responseTokens(body []byte, reflected []string) map[string]int {
+	// Longer values first, so that a value containing another is removed whole
+	reflected = append([]string(nil), reflected...)
+	sort.Slice(reflected, func(i, j int) bool { return len(reflected[i]) > len(reflected[j]) })
+
+	text := string(body)
+	for _, value := range reflected {
+		for _, form := range []string{value, html.EscapeString(value), url.QueryEscape(value)} {
+			if form != "" {
+				text = strings.ReplaceAll(text, form, " ")
+			}
+		}
+	}
+	text = digitsPattern.ReplaceAllString(text, "0")
+
+	tokens := map[string]int{}
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		tokens[token]++
+	}
+	return tokens
+}
+
+// responseSimilarity scores how alike two responses are, from 0 (different
+// status codes or no words in common) to 1 (identical after normalisation).
+// Input reflected in the responses is ignored.
+func responseSimilarity(a, b probeResponse, reflected ...string) float64 {
+	if a.Status != b.Status {
+		return 0
+	}
+	if a.Body.SHA256 == b.Body.SHA256 {
+		return 1
+	}
+
+	tokensA := responseTokens(a.Body.Prefix, reflected)
+	tokensB := responseTokens(b.Body.Prefix, reflected)
+	shared, total := 0, 0
+	for token, countA := range tokensA {
+		countB := tokensB[token]
+		if countA < countB {
+			shared += countA
+			total += countB
+		} else {
+			shared += countB
+			total += countA
+		}
+	}
+	for token, countB := range tokensB {
+		if _, ok := tokensA[token]; !ok {
+			total += countB
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(shared) / float64(total)
+}
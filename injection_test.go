@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBooleanCounterpart(t *testing.T) {
+	tests := map[string]string{
+		"' OR '1'='1":         "' OR '1'='2",
+		"1 OR 1=1--":          "1 OR 1=2--",
+		`" OR "a"="a`:         `" OR "a"="xa`,
+		"' OR 'x'='y' OR 2=2": "' OR 'x'='y' OR 2=3",
+	}
+	for payload, expected := range tests {
+		if got, ok := booleanCounterpart(payload); !ok || got != expected {
+			t.Errorf("Expected counterpart of %q to be %q, got %q", payload, expected, got)
+		}
+	}
+	if _, ok := booleanCounterpart("'; DROP TABLE users;--"); ok {
+		t.Errorf("Expected no counterpart for a payload without a comparison")
+	}
+}
+
+func TestResponseSimilarity(t *testing.T) {
+	probe := func(status int, body string) probeResponse {
+		fp, _ := fingerprintBody(strings.NewReader(body))
+		return probeResponse{Status: status, Body: fp}
+	}
+
+	baseline := probe(200, `{"id": 1, "name": "widget", "generated": "2024-01-01T10:00:00Z"}`)
+	sameShape := probe(200, `{"id": 2, "name": "widget", "generated": "2024-01-01T10:00:05Z"}`)
+	reflected := probe(200, `{"id": 1, "name": "widget", "generated": "2024-01-01T10:00:00Z", "q": "abc"}`)
+	allRows := probe(200, `[{"id": 1, "name": "widget"}, {"id": 2, "name": "gadget"}, {"id": 3, "name": "gizmo"}]`)
+
+	if s := responseSimilarity(baseline, sameShape); s < similarityThreshold {
+		t.Errorf("Expected numbers to be ignored, got similarity %.2f", s)
+	}
+	if s := responseSimilarity(baseline, reflected, "abc"); s < similarityThreshold {
+		t.Errorf("Expected reflected input to be ignored, got similarity %.2f", s)
+	}
+	if s := responseSimilarity(baseline, allRows); s >= similarityThreshold {
+		t.Errorf("Expected different pages to be dissimilar, got similarity %.2f", s)
+	}
+	if s := responseSimilarity(baseline, probe(500, "")); s != 0 {
+		t.Errorf("Expected different status codes to score 0, got %.2f", s)
+	}
+}
+
+func TestInjectionBooleanBased(t *testing.T) {
+	// Simulates SELECT ... WHERE name = '<input>': a true condition returns every row
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.HasSuffix(string(body), "' OR '1'='1") {
+			w.Write([]byte(`[{"id": 1, "name": "widget"}, {"id": 2, "name": "gadget"}, {"id": 3, "name": "gizmo"}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: "name=%s"}
+	err := testInjection(http.DefaultClient, endpoint, []string{"' OR '1'='1"}, false)
+	if result := newTestResult("Injection Test", err, 0); !result.Failed() || result.Confidence != confidenceHigh {
+		t.Errorf("Expected a high confidence finding, got %+v", result)
+	}
+}
+
+func TestInjectionIgnoresDynamicPages(t *testing.T) {
+	// Every response differs, which used to be reported as an injection
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"token": "` + strings.Repeat("x", requests) + `"}`))
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "POST", Body: "name=%s"}
+	if err := testInjection(http.DefaultClient, endpoint, []string{"' OR '1'='1", "'; DROP TABLE users;--"}, false); err != nil {
+		t.Errorf("Expected no finding, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IP family preferences accepted by api_endpoints[].ip_family
+const (
+	ipFamilyIPv4 = "ipv4"
+	ipFamilyIPv6 = "ipv6"
+	ipFamilyDual = "dual"
+)
+
+// familyNetwork restricts a "tcp" dial to the preferred IP family
+func familyNetwork(network, family string) string {
+	if network != "tcp" {
+		return network
+	}
+	switch family {
+	case ipFamilyIPv4:
+		return "tcp4"
+	case ipFamilyIPv6:
+		return "tcp6"
+	default:
+		return network
+	}
+}
+
+// dialRecorder records the IP families of the connections made for an endpoint
+type dialRecorder struct {
+	mu       sync.Mutex
+	families map[string]bool
+}
+
+func (r *dialRecorder) record(conn net.Conn) {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return
+	}
+	family := ipFamilyIPv6
+	if addr.IP.To4() != nil {
+		family = ipFamilyIPv4
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.families == nil {
+		r.families = map[string]bool{}
+	}
+	r.families[family] = true
+}
+
+// Family returns the families used, e.g. "ipv4", or "ipv4+ipv6" if both were
+func (r *dialRecorder) Family() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var families []string
+	for family := range r.families {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	return strings.Join(families, "+")
+}
+
+// expandIPFamilies replaces every dual-stack endpoint with one IPv4 and one
+// IPv6 copy when its host has addresses in both families, so that each stack
+// is tested separately. Hosts with a single family are pinned to that family.
+func expandIPFamilies(endpoints []APIEndpoint, lookup func(host string) ([]net.IP, error)) []APIEndpoint {
+	var expanded []APIEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.IPFamily != ipFamilyDual {
+			expanded = append(expanded, endpoint)
+			continue
+		}
+
+		var has4, has6 bool
+		if u, err := url.Parse(endpoint.URL); err == nil {
+			ips := []net.IP{net.ParseIP(u.Hostname())}
+			if ips[0] == nil {
+				ips, _ = lookup(u.Hostname())
+			}
+			for _, ip := range ips {
+				if ip.To4() != nil {
+					has4 = true
+				} else if ip != nil {
+					has6 = true
+				}
+			}
+		}
+
+		switch {
+		case has4 && has6:
+			v4, v6 := endpoint, endpoint
+			v4.IPFamily, v6.IPFamily = ipFamilyIPv4, ipFamilyIPv6
+			expanded = append(expanded, v4, v6)
+		case has6:
+			endpoint.IPFamily = ipFamilyIPv6
+			expanded = append(expanded, endpoint)
+		case has4:
+			endpoint.IPFamily = ipFamilyIPv4
+			expanded = append(expanded, endpoint)
+		default:
+			endpoint.IPFamily = ""
+			expanded = append(expanded, endpoint)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandIPFamilies(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		switch host {
+		case "dual.example.com":
+			return []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}, nil
+		case "v6.example.com":
+			return []net.IP{net.ParseIP("2001:db8::2")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host}
+	}
+
+	endpoints := expandIPFamilies([]APIEndpoint{
+		{URL: "https://dual.example.com/api", IPFamily: "dual"},
+		{URL: "https://v6.example.com/api", IPFamily: "dual"},
+		{URL: "https://missing.example.com/api", IPFamily: "dual"},
+		{URL: "https://v4.example.com/api", IPFamily: "ipv4"},
+	}, lookup)
+
+	expected := []string{"ipv4", "ipv6", "ipv6", "", "ipv4"}
+	if len(endpoints) != len(expected) {
+		t.Fatalf("Expected %d endpoints, got %d", len(expected), len(endpoints))
+	}
+	for i, family := range expected {
+		if endpoints[i].IPFamily != family {
+			t.Errorf("Expected endpoint %d to use %q, got %q", i, family, endpoints[i].IPFamily)
+		}
+	}
+}
+
+func TestScanClientRecordsIPFamily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "GET", IPFamily: "ipv4"}
+	client, stats := newScanClient(&Config{}, endpoint, nil)
+	if err := performHTTPMethodTest(client, endpoint); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if family := stats.dial.Family(); family != "ipv4" {
+		t.Errorf("Expected ipv4, got %q", family)
+	}
+
+	endpoint.IPFamily = "ipv6"
+	client, _ = newScanClient(&Config{}, endpoint, nil)
+	if err := performHTTPMethodTest(client, endpoint); err == nil {
+		t.Errorf("Expected an IPv4-only server to be unreachable over IPv6")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultMaxResponseBytes bounds how much of a response body is read when
+// limits.max_response_bytes is not set
+const defaultMaxResponseBytes = 10 << 20
+
+// LimitsConfig represents the resource limits applied to every endpoint
+type LimitsConfig struct {
+	MaxResponseBytes int64         `yaml:"max_response_bytes"`
+	TestTimeout      time.Duration `yaml:"test_timeout"`
+}
+
+// TimeBudgetError is returned when a test runs out of its time budget
+type TimeBudgetError struct{ Budget time.Duration }
+
+func (e TimeBudgetError) Error() string {
+	return fmt.Sprintf("test exceeded its time budget of %s", e.Budget)
+}
+
+// limitTransport truncates response bodies to a maximum size so that an
+// endpoint streaming an unbounded response cannot exhaust the scanner's memory
+type limitTransport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+func newLimitTransport(base http.RoundTripper, maxBytes int64) *limitTransport {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResponseBytes
+	}
+	return &limitTransport{base: base, maxBytes: maxBytes}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &limitedBody{Reader: io.LimitReader(resp.Body, t.maxBytes), closer: resp.Body}
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *limitedBody) Close() error { return b.closer.Close() }
+
+// withTimeBudget returns a copy of client whose requests, including reading
+// their bodies, must all complete within budget from now. The copy shares the
+// original client's cookie jar. A zero budget returns client unchanged.
+func withTimeBudget(client *http.Client, budget time.Duration) *http.Client {
+	if budget <= 0 {
+		return client
+	}
+	budgeted := *client
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	budgeted.Transport = &budgetTransport{base: base, budget: budget, deadline: time.Now().Add(budget)}
+	return &budgeted
+}
+
+type budgetTransport struct {
+	base     http.RoundTripper
+	budget   time.Duration
+	deadline time.Time
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !time.Now().Before(t.deadline) {
+		return nil, TimeBudgetError{Budget: t.budget}
+	}
+	ctx, cancel := context.WithDeadline(req.Context(), t.deadline)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, t.translate(ctx, err)
+	}
+	resp.Body = &budgetBody{ReadCloser: resp.Body, transport: t, ctx: ctx, cancel: cancel}
+	return resp, nil
+}
+
+// translate reports errors caused by the budget running out as TimeBudgetError
+func (t *budgetTransport) translate(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded && !time.Now().Before(t.deadline) {
+		return TimeBudgetError{Budget: t.budget}
+	}
+	return err
+}
+
+// budgetBody releases the request's deadline once the body is closed
+type budgetBody struct {
+	io.ReadCloser
+	transport *budgetTransport
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (b *budgetBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.transport.translate(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *budgetBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitTransportTruncatesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 4096)))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 100)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(body) != 100 {
+		t.Errorf("Expected body truncated to 100 bytes, got %d", len(body))
+	}
+}
+
+func TestTimeBudgetExceeded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	endpoint := APIEndpoint{URL: server.URL, Method: "GET"}
+	client, _ := newScanClient(&Config{}, endpoint, nil)
+	err := performHTTPMethodTest(withTimeBudget(client, 50*time.Millisecond), endpoint)
+
+	var budgetErr TimeBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Expected TimeBudgetError, got %v", err)
+	}
+	if result := newTestResult("HTTP Method Test", err, 0); !result.Skipped {
+		t.Errorf("Expected an exceeded budget to skip the test, got %+v", result)
+	}
+}
+
+func TestTimeBudgetDisabled(t *testing.T) {
+	client := &http.Client{}
+	if withTimeBudget(client, 0) != client {
+		t.Errorf("Expected a zero budget to leave the client unchanged")
+	}
+}
@@ -1,44 +1,364 @@
 package main
 
 import (
-	"io/ioutil"
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
 
-	"gopkg.in/yaml.v2"
+var (
+	ciMode        = flag.Bool("ci", false, "emit CI annotations and a markdown summary, and exit non-zero on failed tests")
+	exportFormat  = flag.String("format", "", "also export the results in the given format (json, defectdojo, faraday, cyclonedx, csv, xlsx)")
+	exportOutput  = flag.String("output", "", "file to write the export to (defaults to stdout)")
+	safeMode      = flag.Bool("safe", false, "only run non-destructive checks (same as safe_mode: true)")
+	reportLang    = flag.String("lang", "", "language of the text report, en or es (overrides language in config.yaml)")
+	endpointOnly  = flag.String("endpoint", "", "only scan the endpoints whose URL matches one of these comma-separated URLs or * patterns")
+	configFile    = flag.String("config", "config.yaml", "configuration file")
+	environment   = flag.String("env", "", "environment whose URLs to scan for endpoints defined per environment (overrides environment in the configuration)")
+	postureFile   = flag.String("expect", "", "expected posture file; deviations from it, not failed tests, decide the -ci exit status (overrides expected_posture in the configuration)")
+	updatePosture = flag.Bool("update-posture", false, "write the outcome of this scan to the expected posture file")
+	changedSince  = flag.String("changed-since", "", "baseline configuration file; only scan the endpoints that are new or changed since it")
+	revealSecrets = flag.Bool("reveal-secrets", false, "keep credentials unmasked in the requests and curl commands attached to findings")
+	preflight     = flag.Bool("preflight", false, "only check that the endpoints are reachable (DNS, TCP, TLS and HTTP) and exit non-zero if any is not")
+	showProgress  = flag.Bool("progress", false, "draw a progress bar with the estimated time left on standard error")
+	anonymize     = flag.Bool("anonymize", false, "hash hostnames, generalize URLs and leave out evidence, credentials and metadata in the -format export")
+	overlays      stringList
+	metaFlags     stringList
 )
 
+func init() {
+	flag.Var(&overlays, "config-overlay", "configuration file deep-merged over -config; repeat to layer several")
+	flag.Var(&metaFlags, "meta", "key=value metadata attached to the results, such as a build ID or operator; repeat for several (overrides metadata in the configuration)")
+}
+
+// stringList is a flag that can be repeated, collecting every value
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
-	// Load configuration from the YAML file
-	config, err := loadConfig("config.yaml")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			if err := benchCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Benchmark failed: %w", err))
+			}
+			return
+		case "capabilities":
+			if err := capabilitiesCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Failed to list capabilities: %w", err))
+			}
+			return
+		case "feedback":
+			if err := feedbackCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Failed to record feedback: %w", err))
+			}
+			return
+		case "grafana":
+			if err := grafanaCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Failed to generate dashboard: %w", err))
+			}
+			return
+		case "migrate":
+			if err := migrateCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Failed to migrate results: %w", err))
+			}
+			return
+		case "pack":
+			if err := packCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Scan pack failed: %w", err))
+			}
+			return
+		case "passive":
+			if err := passiveCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Passive analysis failed: %w", err))
+			}
+			return
+		case "replay":
+			if err := replayCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Replay failed: %w", err))
+			}
+			return
+		case "testserver":
+			if err := testServerCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Test server failed: %w", err))
+			}
+			return
+		case "verify":
+			if err := verifyCommand(os.Args[2:]); err != nil {
+				fatal(fmt.Errorf("Verification failed: %w", err))
+			}
+			return
+		}
+	}
+
+	flag.Parse()
+
+	// Load configuration from the YAML file and its overlays
+	config, err := loadConfig(*configFile, overlays...)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal(invalidConfig(fmt.Errorf("failed to load configuration: %v", err)))
+	}
+	if *safeMode {
+		config.SafeMode = true
+	}
+	config.revealSecrets = *revealSecrets
+	if *reportLang != "" {
+		config.Language = *reportLang
+	}
+	if *environment != "" {
+		config.Environment = *environment
+	}
+	if *postureFile != "" {
+		config.ExpectedPosture = *postureFile
+	}
+	if config.APIEndpoints, err = applyEnvironment(config.APIEndpoints, config.Environment); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if *endpointOnly != "" {
+		if config.APIEndpoints, err = selectEndpoints(config.APIEndpoints, strings.Split(*endpointOnly, ",")); err != nil {
+			fatal(invalidConfig(fmt.Errorf("invalid -endpoint: %v", err)))
+		}
+	}
+	if *changedSince != "" {
+		baseline, err := loadBaselineEndpoints(*changedSince, overlays, config.Environment)
+		if err != nil {
+			fatal(invalidConfig(fmt.Errorf("failed to load baseline configuration: %v", err)))
+		}
+		changed := changedEndpoints(config.APIEndpoints, baseline)
+		log.Printf("%d of %d endpoints changed since %s", len(changed), len(config.APIEndpoints), *changedSince)
+		if len(changed) == 0 {
+			log.Printf("No endpoint changed; not starting the scan")
+			return
+		}
+		config.APIEndpoints = changed
 	}
 
-	// Debug logging
+	// Debug logging, before secret references are resolved
 	log.Printf("Loaded configuration: %+v", config)
 	for _, endpoint := range config.APIEndpoints {
 		log.Printf("Endpoint: %s, Method: %s", endpoint.URL, endpoint.Method)
 	}
 
+	if *preflight {
+		if !runPreflight(config, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	secrets, err := resolveSecrets(config)
+	if err != nil {
+		fatal(invalidConfig(fmt.Errorf("failed to resolve secrets: %v", err)))
+	}
+	// Revoke the short-lived secrets however the scan ends; releasing them
+	// again after the scan does nothing
+	atExit(func() {
+		for _, err := range secrets.release() {
+			log.Printf("Failed to release secrets: %v", err)
+		}
+	})
+	defer runExitHooks()
+	l, err := newLocalizer(config.Language)
+	if err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := config.Auth.prepare(); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := config.Auth.authorize(); err != nil {
+		fatal(err)
+	}
+	if err := validateCVSSOverrides(config.CVSS); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := validateBlackouts(config); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := validateServices(config.Services); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := validatePortScan(config.PortScan); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := validateCloudIAM(config.CloudIAM); err != nil {
+		fatal(invalidConfig(err))
+	}
+	if err := validateOIDC(config.OIDC); err != nil {
+		fatal(invalidConfig(err))
+	}
+	endpoints, skipped := outsideBlackouts(config, time.Now())
+	for _, message := range skipped {
+		log.Printf("Not scanning %s", message)
+	}
+	if len(endpoints) == 0 {
+		log.Printf("All endpoints are in a blackout window; not starting the scan")
+		return
+	}
+	config.APIEndpoints = endpoints
+	reports, err := loadCustomReports(config.ReportTemplates, l)
+	if err != nil {
+		fatal(invalidConfig(err))
+	}
+	compliance, err := loadComplianceMappings(config.Compliance)
+	if err != nil {
+		fatal(invalidConfig(err))
+	}
+	if config.FeedbackFile != "" {
+		if config.feedback, err = loadFeedback(config.FeedbackFile); err != nil {
+			fatal(invalidConfig(fmt.Errorf("failed to load feedback: %v", err)))
+		}
+	}
+	if *updatePosture && config.ExpectedPosture == "" {
+		fatal(invalidConfig(errors.New("-update-posture requires -expect or expected_posture")))
+	}
+	if *anonymize && *exportFormat == "" {
+		fatal(invalidConfig(errors.New("-anonymize requires -format")))
+	}
+	metadata, err := scanMetadata(config, metaFlags, os.Getenv)
+	if err != nil {
+		fatal(invalidConfig(fmt.Errorf("invalid -meta: %v", err)))
+	}
+	var signingKey ed25519.PrivateKey
+	if config.Signing.PrivateKeyFile != "" {
+		if signingKey, err = loadSigningKey(config.Signing.PrivateKeyFile); err != nil {
+			fatal(invalidConfig(err))
+		}
+	}
+	var posture Posture
+	if config.ExpectedPosture != "" && !*updatePosture {
+		if posture, err = loadPosture(config.ExpectedPosture); err != nil {
+			fatal(invalidConfig(err))
+		}
+	}
+
+	// Log the fuzzer's seed so that the scan can be reproduced with fuzz.seed
+	if config.Fuzz.Enabled {
+		if config.Fuzz.Seed == 0 {
+			config.Fuzz.Seed = time.Now().UnixNano()
+		}
+		log.Printf("Fuzzing with seed %d", config.Fuzz.Seed)
+	}
+
 	// Run the security tests
+	if *showProgress {
+		config.progress = newScanProgress(progressBar(os.Stderr))
+	}
+	start := time.Now()
 	results := runTests(config)
+	duration := time.Since(start)
 
 	// Generate detailed report
-	generateDetailedReport(results)
-}
+	generateDetailedReport(results, metadata, l)
 
-// loadConfig loads the configuration from a YAML file
-func loadConfig(filename string) (*Config, error) {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
+	var deviations []PostureDeviation
+	if posture != nil {
+		deviations = comparePosture(posture, results)
+		writePostureReport(os.Stdout, deviations)
+	}
+	if *updatePosture {
+		if err := postureFromResults(results).save(config.ExpectedPosture); err != nil {
+			fatal(fmt.Errorf("Failed to update posture: %w", err))
+		}
 	}
 
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, err
+	if err := writeComplianceReports(config.Compliance, compliance, results, l); err != nil {
+		fatal(fmt.Errorf("Failed to write compliance reports: %w", err))
+	}
+
+	// Open or update tickets for findings
+	if err := syncTickets(config.Ticketing, results); err != nil {
+		log.Printf("Failed to sync tickets: %v", err)
 	}
 
-	return &config, nil
+	// Deliver the results to the configured webhooks
+	for _, err := range sendWebhooks(&http.Client{Timeout: 10 * time.Second}, config.Webhooks, results, metadata, time.Now()) {
+		log.Printf("Failed to send webhook: %v", err)
+	}
+	for _, err := range sendCertificateAlerts(&http.Client{Timeout: 10 * time.Second}, config.Webhooks, results, config.Certificates, time.Now()) {
+		log.Printf("Failed to send certificate alert: %v", err)
+	}
+	if err := pushMetrics(&http.Client{Timeout: 10 * time.Second}, config.Pushgateway, scanMetrics(results, duration, time.Now())); err != nil {
+		log.Printf("Failed to push metrics: %v", err)
+	}
+
+	// Revoke the short-lived secrets once nothing else needs them
+	for _, err := range secrets.release() {
+		log.Printf("Failed to release secrets: %v", err)
+	}
+
+	if err := writeCustomReports(reports, newReportData(results, config.Language, metadata, time.Now())); err != nil {
+		fatal(fmt.Errorf("Failed to write custom reports: %w", err))
+	}
+
+	if *exportFormat != "" {
+		exported, exportMetadata := results, metadata
+		if *anonymize {
+			anonymizer, err := newAnonymizer()
+			if err != nil {
+				fatal(fmt.Errorf("Failed to anonymize results: %w", err))
+			}
+			exported, exportMetadata = anonymizer.results(results), nil
+		}
+		if err := writeExport(*exportFormat, *exportOutput, exported, exportMetadata, signingKey); err != nil {
+			fatal(fmt.Errorf("Failed to export results: %w", err))
+		}
+	}
+
+	if *ciMode {
+		if err := writeCIOutput(results, metadata, *configFile); err != nil {
+			fatal(fmt.Errorf("Failed to write CI output: %w", err))
+		}
+		// With an expected posture, only deviations from it fail the build
+		failed := hasFailures(results)
+		if posture != nil {
+			failed = len(deviations) > 0
+		}
+		if failed {
+			exit(1)
+		}
+		// Tests held back by unreachable targets, failed logins, rate limiting or panics leave the results incomplete
+		if code := scanExitCode(results); code != 0 {
+			exit(code)
+		}
+	}
+}
+
+// selectEndpoints returns the endpoints whose URL matches one of the patterns,
+// where * matches any sequence of characters, so that a single fixed endpoint
+// can be re-tested without scanning the others
+func selectEndpoints(endpoints []APIEndpoint, patterns []string) ([]APIEndpoint, error) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			quoted := strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+			matchers = append(matchers, regexp.MustCompile("^"+quoted+"$"))
+		}
+	}
+
+	var selected []APIEndpoint
+	for _, endpoint := range endpoints {
+		for _, matcher := range matchers {
+			if matcher.MatchString(endpoint.URL) {
+				selected = append(selected, endpoint)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no endpoint in the configuration matches %s", strings.Join(patterns, ", "))
+	}
+	return selected, nil
 }
@@ -72,3 +72,26 @@ injection_payloads:
 	// Run the tests
 	main()
 }
+
+func TestSelectEndpoints(t *testing.T) {
+	endpoints := []APIEndpoint{
+		{URL: "https://api.example.com/users", Method: "GET"},
+		{URL: "https://api.example.com/users", Method: "POST"},
+		{URL: "https://api.example.com/orders/1", Method: "GET"},
+		{URL: "https://admin.example.com/users", Method: "GET"},
+	}
+
+	selected, err := selectEndpoints(endpoints, []string{"https://api.example.com/users"})
+	if err != nil || len(selected) != 2 || selected[1].Method != "POST" {
+		t.Errorf("Expected both methods of the exact URL, got %+v (%v)", selected, err)
+	}
+
+	selected, err = selectEndpoints(endpoints, []string{"*/orders/*", " https://admin.example.com/* "})
+	if err != nil || len(selected) != 2 || selected[0].URL != "https://api.example.com/orders/1" || selected[1].URL != "https://admin.example.com/users" {
+		t.Errorf("Expected the pattern matches in configuration order, got %+v (%v)", selected, err)
+	}
+
+	if _, err := selectEndpoints(endpoints, []string{"https://api.example.com/user"}); err == nil {
+		t.Errorf("Expected an error when nothing matches")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ciMetadataVariables maps metadata keys to the CI variables they are read from
+var ciMetadataVariables = []struct {
+	key       string
+	variables []string
+}{
+	{"git_commit", []string{"GITHUB_SHA", "CI_COMMIT_SHA"}},
+	{"build_id", []string{"GITHUB_RUN_ID", "CI_PIPELINE_ID"}},
+	{"trigger", []string{"GITHUB_EVENT_NAME", "CI_PIPELINE_SOURCE"}},
+	{"triggered_by", []string{"GITHUB_ACTOR", "GITLAB_USER_LOGIN"}},
+}
+
+// scanMetadata returns the metadata attached to the scan's results so that
+// findings can be traced to the deployment that introduced them. The GitHub or
+// GitLab build, the environment, the metadata in the configuration and the
+// -meta key=value flags are combined, later sources taking precedence.
+func scanMetadata(config *Config, flags []string, getenv func(string) string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, ci := range ciMetadataVariables {
+		for _, variable := range ci.variables {
+			if value := getenv(variable); value != "" {
+				metadata[ci.key] = value
+				break
+			}
+		}
+	}
+	if config.Environment != "" {
+		metadata["environment"] = config.Environment
+	}
+	for key, value := range config.Metadata {
+		metadata[key] = value
+	}
+	for _, flag := range flags {
+		i := strings.Index(flag, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("metadata %q must be key=value", flag)
+		}
+		metadata[flag[:i]] = flag[i+1:]
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// metadataKeys returns the keys of the metadata in a stable order for reports
+func metadataKeys(metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanMetadata(t *testing.T) {
+	env := map[string]string{"GITHUB_SHA": "abc123", "GITHUB_ACTOR": "ci-bot", "CI_COMMIT_SHA": "ignored"}
+	config := &Config{Environment: "staging", Metadata: map[string]string{"operator": "alice", "triggered_by": "config"}}
+
+	metadata, err := scanMetadata(config, []string{"build_id=42", "operator=bob", "note=a=b"}, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]string{
+		"git_commit":   "abc123",
+		"triggered_by": "config",
+		"environment":  "staging",
+		"build_id":     "42",
+		"operator":     "bob",
+		"note":         "a=b",
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v, got %v", expected, metadata)
+	}
+
+	if _, err := scanMetadata(&Config{}, []string{"=value"}, func(string) string { return "" }); err == nil {
+		t.Error("Expected an error for metadata without a key")
+	}
+	if metadata, _ := scanMetadata(&Config{}, nil, func(string) string { return "" }); metadata != nil {
+		t.Errorf("Expected no metadata, got %v", metadata)
+	}
+}
+
+func TestMetadataInResults(t *testing.T) {
+	metadata := map[string]string{"git_commit": "abc123", "build_id": "42"}
+
+	data, err := json.Marshal(newScanDocument(nil, metadata, time.Now()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(document.Metadata, metadata) {
+		t.Errorf("Expected metadata %v, got %v", metadata, document.Metadata)
+	}
+
+	summary := ciSummaryMarkdown(nil, metadata)
+	if !strings.Contains(summary, "- **build_id**: 42\n- **git_commit**: abc123\n") {
+		t.Errorf("Expected the metadata in the summary, got %q", summary)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPushgatewayJob = "api_security_scanner"
+
+// Names of the scan metrics, shared with the Grafana dashboard
+const (
+	metricScore          = "api_security_scan_score"
+	metricTests          = "api_security_scan_tests"
+	metricFindings       = "api_security_scan_findings"
+	metricEndpoints      = "api_security_scan_endpoints"
+	metricDuration       = "api_security_scan_duration_seconds"
+	metricLastCompletion = "api_security_scan_last_completion_timestamp_seconds"
+	metricCertExpiry     = "api_security_scan_certificate_expiry_timestamp_seconds"
+
+	metricThrottleEvents      = "api_security_scan_throttle_events"
+	metricLimiterLimit        = "api_security_scan_limiter_concurrency_limit"
+	metricLimiterMinLimit     = "api_security_scan_limiter_min_concurrency_limit"
+	metricLimiterPeakInFlight = "api_security_scan_limiter_peak_in_flight_requests"
+	metricLimiterPeakWaiting  = "api_security_scan_limiter_peak_queued_requests"
+	metricLimiterWait         = "api_security_scan_limiter_wait_seconds"
+	metricLimiterDecreases    = "api_security_scan_limiter_decreases"
+)
+
+// PushgatewayConfig represents the Prometheus Pushgateway that receives the
+// metrics of a finished scan, since one-shot scans exit before they can be scraped
+type PushgatewayConfig struct {
+	URL      string            `yaml:"url"`
+	Job      string            `yaml:"job"`
+	Labels   map[string]string `yaml:"labels"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	b       strings.Builder
+	written map[string]bool
+}
+
+// metric writes a sample, preceded by the HELP and TYPE lines the first time the metric is written
+func (w *metricsWriter) metric(name, kind, help string, labels []string, value float64) {
+	if w.written == nil {
+		w.written = map[string]bool{}
+	}
+	if !w.written[name] {
+		fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		w.written[name] = true
+	}
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		var pairs []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+		}
+		w.b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.b.WriteString(" " + strconv.FormatFloat(value, 'f', -1, 64) + "\n")
+}
+
+// endpointLabels returns the labels that tell an endpoint's series apart:
+// its URL, and its name, method and IP family when it has them, since
+// endpoints can share a URL and dual-stack endpoints are scanned once per
+// family. The Pushgateway rejects pushes with duplicate series.
+func endpointLabels(result EndpointResult, labels ...string) []string {
+	endpoint := []string{"endpoint", result.URL}
+	for _, label := range [][2]string{{"name", result.Name}, {"method", result.Method}, {"ip_family", result.IPFamily}} {
+		if label[1] != "" {
+			endpoint = append(endpoint, label[0], label[1])
+		}
+	}
+	return append(endpoint, labels...)
+}
+
+// scanMetrics returns the metrics of a finished scan in the Prometheus text format
+func scanMetrics(results []EndpointResult, duration time.Duration, finished time.Time) string {
+	var w metricsWriter
+	for _, result := range results {
+		w.metric(metricScore, "gauge", "Security score of the endpoint (0-100).",
+			endpointLabels(result), float64(result.Score))
+	}
+
+	for _, result := range results {
+		statuses := map[string]int{"passed": 0, "failed": 0, "skipped": 0}
+		findings := map[string]int{}
+		for _, testResult := range result.Results {
+			switch {
+			case testResult.Skipped:
+				statuses["skipped"]++
+			case testResult.Passed:
+				statuses["passed"]++
+			default:
+				statuses["failed"]++
+				findings[resultSeverity(testResult)]++
+			}
+		}
+		for _, status := range []string{"passed", "failed", "skipped"} {
+			w.metric(metricTests, "gauge", "Number of tests run against the endpoint by outcome.",
+				endpointLabels(result, "status", status), float64(statuses[status]))
+		}
+		for _, severity := range []string{"critical", "high", "medium", "low"} {
+			w.metric(metricFindings, "gauge", "Number of failed tests on the endpoint by severity.",
+				endpointLabels(result, "severity", severity), float64(findings[severity]))
+		}
+	}
+
+	for _, result := range results {
+		if result.Certificate != nil {
+			w.metric(metricCertExpiry, "gauge", "Unix time the endpoint's TLS certificate expires at.",
+				endpointLabels(result), float64(result.Certificate.NotAfter.Unix()))
+		}
+	}
+
+	// Throttling and adaptive concurrency are per host, shared by its endpoints
+	var hosts []string
+	throttled := map[string]int{}
+	limiters := map[string]*LimiterStats{}
+	for _, result := range results {
+		u, err := url.Parse(result.URL)
+		if err != nil {
+			continue
+		}
+		if _, ok := throttled[u.Host]; !ok {
+			hosts = append(hosts, u.Host)
+		}
+		throttled[u.Host] += result.Throttled
+		if result.Limiter != nil {
+			limiters[u.Host] = result.Limiter
+		}
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		w.metric(metricThrottleEvents, "gauge", "Number of times the host rate limited the scan.",
+			[]string{"host", host}, float64(throttled[host]))
+	}
+	for _, m := range []struct {
+		name, help string
+		value      func(LimiterStats) float64
+	}{
+		{metricLimiterLimit, "Concurrency limit of the host's adaptive limiter when the scan ended.", func(s LimiterStats) float64 { return float64(s.Limit) }},
+		{metricLimiterMinLimit, "Lowest concurrency limit the host's adaptive limiter reached.", func(s LimiterStats) float64 { return float64(s.MinLimit) }},
+		{metricLimiterPeakInFlight, "Most requests in flight to the host at once.", func(s LimiterStats) float64 { return float64(s.PeakInFlight) }},
+		{metricLimiterPeakWaiting, "Most requests queued for a slot of the host's limiter at once.", func(s LimiterStats) float64 { return float64(s.PeakWaiting) }},
+		{metricLimiterWait, "Total time requests to the host spent queued for a limiter slot.", func(s LimiterStats) float64 { return s.WaitSeconds }},
+		{metricLimiterDecreases, "Number of times the host's limit was halved after errors, throttling or slow responses.", func(s LimiterStats) float64 { return float64(s.Decreases) }},
+	} {
+		for _, host := range hosts {
+			if stats := limiters[host]; stats != nil {
+				w.metric(m.name, "gauge", m.help, []string{"host", host}, m.value(*stats))
+			}
+		}
+	}
+
+	w.metric(metricEndpoints, "gauge", "Number of endpoints scanned.", nil, float64(len(results)))
+	w.metric(metricDuration, "gauge", "Duration of the scan in seconds.", nil, duration.Seconds())
+	w.metric(metricLastCompletion, "gauge", "Unix time the scan completed at.", nil, float64(finished.Unix()))
+	return w.b.String()
+}
+
+// pushgatewayURL returns the URL of the metrics group for the job and grouping labels
+func pushgatewayURL(config PushgatewayConfig) string {
+	job := config.Job
+	if job == "" {
+		job = defaultPushgatewayJob
+	}
+	path := strings.TrimSuffix(config.URL, "/") + "/metrics/job/" + url.PathEscape(job)
+
+	names := make([]string, 0, len(config.Labels))
+	for name := range config.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path += "/" + url.PathEscape(name) + "/" + url.PathEscape(config.Labels[name])
+	}
+	return path
+}
+
+// pushMetrics replaces the job's metrics group on the Pushgateway with the scan's metrics
+func pushMetrics(client *http.Client, config PushgatewayConfig, metrics string) error {
+	if config.URL == "" {
+		return nil
+	}
+	req, err := http.NewRequest("PUT", pushgatewayURL(config), strings.NewReader(metrics))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	if config.Username != "" || config.Password != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanMetrics(t *testing.T) {
+	results := []EndpointResult{{
+		URL:   "http://api.example.com/users",
+		Score: 50,
+		Results: []TestResult{
+			{TestName: "Auth Test", Passed: true},
+			{TestName: "Injection Test", Passed: false},
+			{TestName: "Default Credentials Test", Skipped: true},
+		},
+	}}
+	metrics := scanMetrics(results, 1500*time.Millisecond, time.Unix(1700000000, 0))
+
+	for _, line := range []string{
+		"# TYPE api_security_scan_score gauge",
+		`api_security_scan_score{endpoint="http://api.example.com/users"} 50`,
+		`api_security_scan_tests{endpoint="http://api.example.com/users",status="passed"} 1`,
+		`api_security_scan_tests{endpoint="http://api.example.com/users",status="skipped"} 1`,
+		`api_security_scan_findings{endpoint="http://api.example.com/users",severity="critical"} 1`,
+		`api_security_scan_findings{endpoint="http://api.example.com/users",severity="high"} 0`,
+		"api_security_scan_endpoints 1",
+		"api_security_scan_duration_seconds 1.5",
+		"api_security_scan_last_completion_timestamp_seconds 1700000000",
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+	if strings.Count(metrics, "# HELP api_security_scan_tests ") != 1 {
+		t.Errorf("Expected a single HELP line per metric")
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	var method, path, body, user string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		user, _, _ = r.BasicAuth()
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	config := PushgatewayConfig{URL: server.URL + "/", Labels: map[string]string{"pipeline": "nightly", "env": "staging/eu"}, Username: "ci"}
+	if err := pushMetrics(server.Client(), config, "api_security_scan_endpoints 1\n"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if method != "PUT" || path != "/metrics/job/api_security_scanner/env/staging%2Feu/pipeline/nightly" {
+		t.Errorf("Unexpected request %s %s", method, path)
+	}
+	if body != "api_security_scan_endpoints 1\n" || user != "ci" {
+		t.Errorf("Unexpected body %q or user %q", body, user)
+	}
+
+	if err := pushMetrics(server.Client(), PushgatewayConfig{}, ""); err != nil {
+		t.Errorf("Expected no push without a URL, got %v", err)
+	}
+}
+
+func TestScanMetricsPerHost(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "https://api.example.com/users", Throttled: 2, Limiter: &LimiterStats{Host: "api.example.com", Limit: 3, MinLimit: 1, PeakInFlight: 4, PeakWaiting: 6, WaitSeconds: 1.25, Decreases: 2}},
+		{URL: "https://api.example.com/orders", Throttled: 1, Limiter: &LimiterStats{Host: "api.example.com", Limit: 3, MinLimit: 1, PeakInFlight: 4, PeakWaiting: 6, WaitSeconds: 1.25, Decreases: 2}},
+		{URL: "https://auth.example.com/token", Limiter: &LimiterStats{Host: "auth.example.com", Limit: 8, MinLimit: 4, PeakInFlight: 2}},
+	}
+	metrics := scanMetrics(results, time.Second, time.Unix(1700000000, 0))
+
+	for _, line := range []string{
+		`api_security_scan_throttle_events{host="api.example.com"} 3`,
+		`api_security_scan_throttle_events{host="auth.example.com"} 0`,
+		`api_security_scan_limiter_concurrency_limit{host="api.example.com"} 3`,
+		`api_security_scan_limiter_min_concurrency_limit{host="auth.example.com"} 4`,
+		`api_security_scan_limiter_peak_queued_requests{host="api.example.com"} 6`,
+		`api_security_scan_limiter_wait_seconds{host="api.example.com"} 1.25`,
+		`api_security_scan_limiter_decreases{host="api.example.com"} 2`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+
+	// The samples of a metric must follow its HELP and TYPE lines without interruption
+	limit := strings.Index(metrics, `api_security_scan_limiter_concurrency_limit{host="api.example.com"}`)
+	minLimit := strings.Index(metrics, "# HELP api_security_scan_limiter_min_concurrency_limit")
+	if other := strings.Index(metrics, `api_security_scan_limiter_concurrency_limit{host="auth.example.com"}`); other < limit || other > minLimit {
+		t.Errorf("Expected the samples of each metric to be grouped, got:\n%s", metrics)
+	}
+}
+
+func TestScanMetricsHaveNoDuplicateSeries(t *testing.T) {
+	results := []EndpointResult{
+		{URL: "http://api.example.com/users", Method: "GET", IPFamily: "ipv4", Score: 100},
+		{URL: "http://api.example.com/users", Method: "GET", IPFamily: "ipv6", Score: 80},
+		{URL: "http://api.example.com/users", Method: "POST", IPFamily: "ipv4", Score: 50},
+		{Name: "users-admin", URL: "http://api.example.com/users", Method: "POST", IPFamily: "ipv4", Score: 70},
+	}
+	metrics := scanMetrics(results, time.Second, time.Unix(1700000000, 0))
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(metrics, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := line[:strings.LastIndex(line, " ")]
+		if seen[series] {
+			t.Errorf("Expected every series once, got %s twice in:\n%s", series, metrics)
+		}
+		seen[series] = true
+	}
+	if !strings.Contains(metrics, `api_security_scan_score{endpoint="http://api.example.com/users",method="GET",ip_family="ipv6"} 80`+"\n") {
+		t.Errorf("Expected the method and IP family labels, got:\n%s", metrics)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 grant types
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+	grantDeviceCode        = "device_code"
+
+	deviceCodeGrantURN = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// tokenExpiryLeeway renews tokens slightly before they expire so that they do not lapse mid-request
+const tokenExpiryLeeway = 30 * time.Second
+
+// OAuth2Config represents how the scanner obtains OAuth2 access tokens
+type OAuth2Config struct {
+	GrantType              string   `yaml:"grant_type"`
+	TokenURL               string   `yaml:"token_url"`
+	DeviceAuthorizationURL string   `yaml:"device_authorization_url"`
+	ClientID               string   `yaml:"client_id"`
+	ClientSecret           string   `yaml:"client_secret"`
+	Scopes                 []string `yaml:"scopes"`
+	RefreshToken           string   `yaml:"refresh_token"`
+	TokenFile              string   `yaml:"token_file"`
+}
+
+// oauth2Token is a token response, also used as the format of the token file
+type oauth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int       `json:"expires_in,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// authorization returns the Authorization header value for the token
+func (t oauth2Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+func (t oauth2Token) valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(tokenExpiryLeeway).Before(t.Expiry))
+}
+
+// oauth2Error is the error response of a token or device authorization endpoint
+type oauth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e oauth2Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// deviceAuthorization is the response of a device authorization endpoint
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// oauth2TokenSource fetches, refreshes and persists access tokens
+type oauth2TokenSource struct {
+	config OAuth2Config
+	client *http.Client
+	now    func() time.Time
+	sleep  func(time.Duration)
+
+	mu      sync.Mutex
+	current oauth2Token
+}
+
+func newOAuth2TokenSource(config OAuth2Config) (*oauth2TokenSource, error) {
+	if config.GrantType == "" {
+		config.GrantType = grantClientCredentials
+	}
+	if config.TokenURL == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("oauth2 requires token_url and client_id")
+	}
+
+	switch config.GrantType {
+	case grantClientCredentials:
+		if config.ClientSecret == "" {
+			return nil, fmt.Errorf("oauth2 grant %q requires client_secret", config.GrantType)
+		}
+	case grantRefreshToken:
+		if config.RefreshToken == "" && config.TokenFile == "" {
+			return nil, fmt.Errorf("oauth2 grant %q requires refresh_token or token_file", config.GrantType)
+		}
+	case grantDeviceCode:
+		if config.DeviceAuthorizationURL == "" {
+			return nil, fmt.Errorf("oauth2 grant %q requires device_authorization_url", config.GrantType)
+		}
+	default:
+		return nil, fmt.Errorf("unknown oauth2 grant type %q (supported: %s, %s, %s)",
+			config.GrantType, grantClientCredentials, grantRefreshToken, grantDeviceCode)
+	}
+
+	source := &oauth2TokenSource{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+	source.current.RefreshToken = config.RefreshToken
+
+	// A token file from an earlier scan takes precedence over the configured refresh token,
+	// since identity providers that rotate refresh tokens invalidate the old one
+	if config.TokenFile != "" {
+		data, err := ioutil.ReadFile(config.TokenFile)
+		switch {
+		case err == nil:
+			var saved oauth2Token
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return nil, fmt.Errorf("invalid oauth2 token file: %v", err)
+			}
+			if saved.RefreshToken == "" {
+				saved.RefreshToken = config.RefreshToken
+			}
+			source.current = saved
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to read oauth2 token file: %v", err)
+		}
+	}
+	return source, nil
+}
+
+// token returns a valid access token, refreshing or requesting one as needed
+func (s *oauth2TokenSource) token() (oauth2Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current.valid(s.now()) {
+		return s.current, nil
+	}
+
+	var token oauth2Token
+	var err error
+	if s.current.RefreshToken != "" {
+		token, err = s.refresh(s.current.RefreshToken)
+		if err != nil && s.config.GrantType == grantRefreshToken {
+			return oauth2Token{}, fmt.Errorf("refresh failed: %v", err)
+		}
+		if err != nil {
+			log.Printf("OAuth2 token refresh failed, requesting a new token: %v", err)
+		}
+	}
+	if s.current.RefreshToken == "" || err != nil {
+		switch s.config.GrantType {
+		case grantClientCredentials:
+			token, err = s.clientCredentials()
+		case grantDeviceCode:
+			token, err = s.deviceCode()
+		default:
+			err = fmt.Errorf("no refresh token available")
+		}
+		if err != nil {
+			return oauth2Token{}, err
+		}
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = s.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	// Providers that do not rotate refresh tokens omit them from refresh responses
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.current.RefreshToken
+	}
+	s.current = token
+	if err := s.save(); err != nil {
+		log.Printf("Failed to save OAuth2 tokens: %v", err)
+	}
+	return token, nil
+}
+
+// save persists the current tokens so that the next scan can refresh instead of logging in again
+func (s *oauth2TokenSource) save() error {
+	if s.config.TokenFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.current, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.config.TokenFile, data, 0600)
+}
+
+func (s *oauth2TokenSource) clientCredentials() (oauth2Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", grantClientCredentials)
+	return s.requestToken(form)
+}
+
+func (s *oauth2TokenSource) refresh(refreshToken string) (oauth2Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", grantRefreshToken)
+	form.Set("refresh_token", refreshToken)
+	return s.requestToken(form)
+}
+
+// deviceCode runs the device authorization grant: it asks the user to approve
+// the scanner on another device and polls the token endpoint until they do
+func (s *oauth2TokenSource) deviceCode() (oauth2Token, error) {
+	form := url.Values{}
+	form.Set("client_id", s.config.ClientID)
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	var authorization deviceAuthorization
+	if err := s.post(s.config.DeviceAuthorizationURL, form, &authorization); err != nil {
+		return oauth2Token{}, fmt.Errorf("device authorization failed: %v", err)
+	}
+
+	if authorization.VerificationURIComplete != "" {
+		log.Printf("To authorize the scanner, visit %s", authorization.VerificationURIComplete)
+	} else {
+		log.Printf("To authorize the scanner, visit %s and enter the code %s", authorization.VerificationURI, authorization.UserCode)
+	}
+
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := s.now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+
+	poll := url.Values{}
+	poll.Set("grant_type", deviceCodeGrantURN)
+	poll.Set("device_code", authorization.DeviceCode)
+	for authorization.ExpiresIn <= 0 || s.now().Before(deadline) {
+		s.sleep(interval)
+		token, err := s.requestToken(poll)
+		if err == nil {
+			return token, nil
+		}
+		oauthErr, ok := err.(oauth2Error)
+		switch {
+		case ok && oauthErr.Code == "authorization_pending":
+		case ok && oauthErr.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return oauth2Token{}, fmt.Errorf("device authorization failed: %v", err)
+		}
+	}
+	return oauth2Token{}, fmt.Errorf("device authorization failed: the code expired before it was approved")
+}
+
+// requestToken sends a token request, authenticating the client with HTTP basic
+// auth when it has a secret and with its client_id otherwise
+func (s *oauth2TokenSource) requestToken(form url.Values) (oauth2Token, error) {
+	if len(s.config.Scopes) > 0 && form.Get("grant_type") != deviceCodeGrantURN {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	var token oauth2Token
+	if err := s.post(s.config.TokenURL, form, &token); err != nil {
+		return oauth2Token{}, err
+	}
+	if token.AccessToken == "" {
+		return oauth2Token{}, fmt.Errorf("token response did not include an access token")
+	}
+	return token, nil
+}
+
+func (s *oauth2TokenSource) post(endpoint string, form url.Values, out interface{}) error {
+	if s.config.ClientSecret == "" {
+		form.Set("client_id", s.config.ClientID)
+	}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var oauthErr oauth2Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return oauthErr
+		}
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
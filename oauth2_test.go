@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	requests := 0
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		if id, secret, ok := r.BasicAuth(); !ok || id != "scanner" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			t.Errorf("Expected a client_credentials request with scopes, got %v", r.Form)
+		}
+		fmt.Fprint(w, `{"access_token":"token-1","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer idp.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	auth := Auth{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: idp.URL, ClientID: "scanner", ClientSecret: "s3cret", Scopes: []string{"read", "write"}}}
+	if err := auth.prepare(); err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := performAuthTest(api.Client(), APIEndpoint{URL: api.URL, Method: "GET"}, auth); err != nil {
+			t.Errorf("Expected the bearer token to be accepted, got %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the token to be reused across requests, got %d token requests", requests)
+	}
+}
+
+func TestOAuth2RefreshTokenPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth2")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "tokens.json")
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("client_id") != "public-client" {
+			t.Errorf("Expected a refresh request from a public client, got %v", r.Form)
+		}
+		// The provider rotates refresh tokens and rejects used ones
+		switch r.Form.Get("refresh_token") {
+		case "refresh-1":
+			fmt.Fprint(w, `{"access_token":"access-1","refresh_token":"refresh-2","expires_in":1}`)
+		case "refresh-2":
+			fmt.Fprint(w, `{"access_token":"access-2","refresh_token":"refresh-3","expires_in":3600}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"refresh token reused"}`)
+		}
+	}))
+	defer idp.Close()
+
+	config := OAuth2Config{GrantType: "refresh_token", TokenURL: idp.URL, ClientID: "public-client", RefreshToken: "refresh-1", TokenFile: tokenFile}
+	source, err := newOAuth2TokenSource(config)
+	if err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	token, err := source.token()
+	if err != nil || token.AccessToken != "access-1" {
+		t.Fatalf("Expected access-1, got %+v, %v", token, err)
+	}
+
+	// A later scan starts from the rotated refresh token in the token file
+	source, err = newOAuth2TokenSource(config)
+	if err != nil {
+		t.Fatalf("Expected the token file to load, got %v", err)
+	}
+	if token, err := source.token(); err != nil || token.AccessToken != "access-2" {
+		t.Fatalf("Expected the saved refresh token to be used, got %+v, %v", token, err)
+	}
+
+	var saved oauth2Token
+	data, _ := ioutil.ReadFile(tokenFile)
+	if err := json.Unmarshal(data, &saved); err != nil || saved.RefreshToken != "refresh-3" {
+		t.Errorf("Expected the rotated refresh token to be saved, got %s", data)
+	}
+
+	source.current = oauth2Token{RefreshToken: "revoked"}
+	if _, err := source.token(); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected a refresh failure to be reported, got %v", err)
+	}
+}
+
+func TestOAuth2DeviceCode(t *testing.T) {
+	polls := 0
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/device":
+			fmt.Fprintf(w, `{"device_code":"dev-1","user_code":"ABCD-EFGH","verification_uri":"%s/activate","expires_in":600,"interval":1}`, idp.URL)
+		case "/token":
+			if r.Form.Get("grant_type") != deviceCodeGrantURN || r.Form.Get("device_code") != "dev-1" {
+				t.Errorf("Expected a device code poll, got %v", r.Form)
+			}
+			polls++
+			w.Header().Set("Content-Type", "application/json")
+			switch polls {
+			case 1:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"authorization_pending"}`)
+			case 2:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"slow_down"}`)
+			default:
+				fmt.Fprint(w, `{"access_token":"device-token","refresh_token":"device-refresh","expires_in":3600}`)
+			}
+		}
+	}))
+	defer idp.Close()
+
+	source, err := newOAuth2TokenSource(OAuth2Config{GrantType: "device_code", TokenURL: idp.URL + "/token", DeviceAuthorizationURL: idp.URL + "/device", ClientID: "cli"})
+	if err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	var waits []time.Duration
+	source.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	token, err := source.token()
+	if err != nil || token.AccessToken != "device-token" || token.RefreshToken != "device-refresh" {
+		t.Fatalf("Expected a device token, got %+v, %v", token, err)
+	}
+	if len(waits) != 3 || waits[0] != time.Second || waits[2] != 6*time.Second {
+		t.Errorf("Expected polling to honour the interval and slow_down, got %v", waits)
+	}
+}
+
+func TestInvalidOAuth2Settings(t *testing.T) {
+	for _, auth := range []Auth{
+		{Type: "oauth2"},
+		{Type: "kerberos"},
+		{Type: "oauth2", OAuth2: &OAuth2Config{ClientID: "scanner", ClientSecret: "s"}},
+		{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: "http://idp", ClientID: "scanner"}},
+		{Type: "oauth2", OAuth2: &OAuth2Config{GrantType: "device_code", TokenURL: "http://idp", ClientID: "scanner"}},
+		{Type: "oauth2", OAuth2: &OAuth2Config{GrantType: "password", TokenURL: "http://idp", ClientID: "scanner"}},
+	} {
+		if err := auth.prepare(); err == nil {
+			t.Errorf("Expected an error for %+v", auth)
+		}
+	}
+}
+
+func TestOAuth2TokenFailureSkipsAuthTest(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client"}`)
+	}))
+	defer idp.Close()
+
+	auth := Auth{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: idp.URL, ClientID: "scanner", ClientSecret: "wrong"}}
+	err := performAuthTest(http.DefaultClient, APIEndpoint{URL: idp.URL, Method: "GET"}, auth)
+	if result := newTestResult("Auth Test", err, 0); !result.Skipped {
+		t.Errorf("Expected the test to be skipped when no token can be obtained, got %+v", result)
+	}
+}
+
+func TestRunTestsSendsBearerTokenOnEveryRequest(t *testing.T) {
+	tokenRequests := 0
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		fmt.Fprint(w, `{"access_token":"access-1","refresh_token":"refresh-2","expires_in":3600}`)
+	}))
+	defer idp.Close()
+
+	var mu sync.Mutex
+	var anonymous []string
+	injectionProbes := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			anonymous = append(anonymous, r.Method+" "+string(body))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(string(body), "' OR '1'='1") {
+			injectionProbes++
+		}
+	}))
+	defer api.Close()
+
+	config := &Config{
+		APIEndpoints:      []APIEndpoint{{URL: api.URL + "/orders", Method: "POST", Body: "q=%s"}},
+		InjectionPayloads: []string{"' OR '1'='1"},
+		Auth:              Auth{Type: "oauth2", OAuth2: &OAuth2Config{GrantType: "refresh_token", TokenURL: idp.URL, ClientID: "public-client", RefreshToken: "refresh-1"}},
+	}
+	if err := config.Auth.prepare(); err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	runTests(config)
+
+	if len(anonymous) > 0 || injectionProbes == 0 {
+		t.Errorf("Expected every request, injection probes included, to carry the token, got %d probes and requests without it: %v", injectionProbes, anonymous)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected one token for the whole scan, got %d token requests", tokenRequests)
+	}
+}
+
+func TestAuthorizeObtainsTokenBeforeScan(t *testing.T) {
+	tokenRequests := 0
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		fmt.Fprint(w, `{"access_token":"access-1","expires_in":3600}`)
+	}))
+	defer idp.Close()
+
+	auth := Auth{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: idp.URL, ClientID: "scanner", ClientSecret: "s"}}
+	if err := auth.prepare(); err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	if err := auth.authorize(); err != nil || tokenRequests != 1 {
+		t.Fatalf("Expected a token before the scan, got %v after %d token requests", err, tokenRequests)
+	}
+	req, _ := http.NewRequest("GET", "http://api.example.com", nil)
+	if err := auth.apply(req); err != nil || req.Header.Get("Authorization") != "Bearer access-1" || tokenRequests != 1 {
+		t.Errorf("Expected scan requests to reuse the token, got %q after %d token requests", req.Header.Get("Authorization"), tokenRequests)
+	}
+
+	idp.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client"}`)
+	})
+	failing := Auth{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: idp.URL, ClientID: "scanner", ClientSecret: "wrong"}}
+	if err := failing.prepare(); err != nil {
+		t.Fatalf("Expected valid oauth2 settings, got %v", err)
+	}
+	if err := failing.authorize(); exitCode(err) != exitAuthFailed {
+		t.Errorf("Expected an authentication failure, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	oidcTestName = "OpenID Connect Test"
+
+	oidcProviderName  = "OpenID Connect Provider"
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+	// oidcCheckRedirectURI is the redirect URI of the client registered to check for open registration
+	oidcCheckRedirectURI = "https://oidc-check.invalid/callback"
+)
+
+// OIDCConfig represents the opt-in assessment of an OpenID Connect provider
+type OIDCConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Discovery is the issuer or the URL of its discovery document
+	Discovery string `yaml:"discovery"`
+	// ClientID and RedirectURI of a registered client enable the check that
+	// authorization requests without PKCE are rejected
+	ClientID    string `yaml:"client_id"`
+	RedirectURI string `yaml:"redirect_uri"`
+}
+
+// OIDCSecurityError lists the weaknesses found in an OpenID Connect provider
+type OIDCSecurityError struct {
+	message string
+	penalty int
+}
+
+func (e OIDCSecurityError) Error() string { return e.message }
+
+// oidcIssue is a weakness of an OpenID Connect provider and its severity
+type oidcIssue struct {
+	message  string
+	severity string
+}
+
+// oidcProviderMetadata is the subset of the discovery document the assessment reads
+type oidcProviderMetadata struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	RegistrationEndpoint     string   `json:"registration_endpoint"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethods     []string `json:"code_challenge_methods_supported"`
+	IDTokenSigningAlgs       []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// jsonWebKey is the subset of a JWK the assessment reads
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+}
+
+// validateOIDC checks that the discovery document is set and that the PKCE
+// check has both a client and a redirect URI
+func validateOIDC(config OIDCConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(config.Discovery); err != nil || u.Host == "" {
+		return fmt.Errorf("oidc: discovery must be the issuer URL or its discovery document, got %q", config.Discovery)
+	}
+	if (config.ClientID == "") != (config.RedirectURI == "") {
+		return fmt.Errorf("oidc: client_id and redirect_uri must be set together")
+	}
+	return nil
+}
+
+// oidcDiscoveryURL returns the discovery document of an issuer, or the URL itself if it is one
+func oidcDiscoveryURL(discovery string) string {
+	if strings.HasSuffix(discovery, oidcDiscoveryPath) {
+		return discovery
+	}
+	return strings.TrimSuffix(discovery, "/") + oidcDiscoveryPath
+}
+
+func getJSON(client *http.Client, u string, v interface{}) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON from %s: %v", u, err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOIDCMetadata reviews what the discovery document advertises
+func checkOIDCMetadata(metadata oidcProviderMetadata, discoveryURL string) []oidcIssue {
+	var issues []oidcIssue
+	if metadata.Issuer != "" && oidcDiscoveryURL(metadata.Issuer) != discoveryURL {
+		issues = append(issues, oidcIssue{fmt.Sprintf("the issuer %s does not match the discovery document's location (mix-up risk)", metadata.Issuer), "medium"})
+	}
+	if containsString(metadata.TokenEndpointAuthMethods, "none") {
+		issues = append(issues, oidcIssue{"the token endpoint accepts clients without authentication (none)", "medium"})
+	}
+	switch {
+	case len(metadata.CodeChallengeMethods) == 0:
+		issues = append(issues, oidcIssue{"PKCE is not advertised (no code_challenge_methods_supported)", "medium"})
+	case containsString(metadata.CodeChallengeMethods, "plain"):
+		issues = append(issues, oidcIssue{"the plain PKCE method is allowed, which does not protect the code verifier", "low"})
+	}
+	if containsString(metadata.IDTokenSigningAlgs, "none") {
+		issues = append(issues, oidcIssue{"unsigned ID tokens (alg none) are supported", "high"})
+	}
+	return issues
+}
+
+// checkJWKS looks for symmetric or unsigned keys and short RSA keys in the provider's key set
+func checkJWKS(client *http.Client, jwksURI string) ([]oidcIssue, error) {
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(client, jwksURI, &keySet); err != nil {
+		return nil, err
+	}
+	var issues []oidcIssue
+	for _, key := range keySet.Keys {
+		name := key.Kid
+		if name == "" {
+			name = "without kid"
+		}
+		switch {
+		case key.Kty == "oct":
+			issues = append(issues, oidcIssue{fmt.Sprintf("JWKS publishes symmetric key %s", name), "high"})
+		case key.Alg == "none" || strings.HasPrefix(key.Alg, "HS"):
+			issues = append(issues, oidcIssue{fmt.Sprintf("JWKS key %s is for the weak alg %s", name, key.Alg), "high"})
+		case key.Kty == "RSA":
+			modulus, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.N, "="))
+			if err != nil {
+				continue
+			}
+			if bits := len(bytes.TrimLeft(modulus, "\x00")) * 8; bits < 2048 {
+				issues = append(issues, oidcIssue{fmt.Sprintf("JWKS key %s is a %d-bit RSA key", name, bits), "high"})
+			}
+		}
+	}
+	return issues, nil
+}
+
+// checkPKCEEnforced sends an authorization request without a code_challenge.
+// The provider should reject it with a 400 or an error redirect instead of
+// showing its login page or issuing a code.
+func checkPKCEEnforced(client *http.Client, metadata oidcProviderMetadata, config OIDCConfig) ([]oidcIssue, error) {
+	if metadata.AuthorizationEndpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization_endpoint: %v", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", config.RedirectURI)
+	query.Set("scope", "openid")
+	query.Set("state", "api-security-scanner")
+	u.RawQuery = query.Encode()
+
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := noRedirects.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, evidenceBodyLimit))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, nil
+	}
+	if location, err := resp.Location(); err == nil && location.Query().Get("error") != "" {
+		return nil, nil
+	}
+	return []oidcIssue{{"an authorization request without a code_challenge was accepted, so PKCE is not enforced", "high"}}, nil
+}
+
+// checkOpenRegistration registers a client without an initial access token
+// and deletes it again when the provider allows it
+func checkOpenRegistration(client *http.Client, registrationEndpoint string) ([]oidcIssue, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"client_name":   "api-security-scanner registration check",
+		"redirect_uris": []string{oidcCheckRedirectURI},
+	})
+	resp, err := client.Post(registrationEndpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, nil
+	}
+	var registered struct {
+		ClientID                string `json:"client_id"`
+		RegistrationClientURI   string `json:"registration_client_uri"`
+		RegistrationAccessToken string `json:"registration_access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil || registered.ClientID == "" {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("dynamic client registration is open: registered client %s without an initial access token", registered.ClientID)
+	deleted := false
+	if registered.RegistrationClientURI != "" && registered.RegistrationAccessToken != "" {
+		req, err := http.NewRequest(http.MethodDelete, registered.RegistrationClientURI, nil)
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+registered.RegistrationAccessToken)
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+				deleted = resp.StatusCode < 300
+			}
+		}
+	}
+	if !deleted {
+		message += " (it could not be deleted; remove it from the provider)"
+	}
+	return []oidcIssue{{message, "high"}}, nil
+}
+
+// performOIDCTest assesses the provider behind a discovery document: the
+// token endpoint's client authentication methods, PKCE support and
+// enforcement, the keys in its JWKS and open dynamic client registration.
+// Registration creates a client, so it is not attempted in safe mode.
+func performOIDCTest(client *http.Client, config OIDCConfig, safeMode bool) error {
+	discoveryURL := oidcDiscoveryURL(config.Discovery)
+	var metadata oidcProviderMetadata
+	if err := getJSON(client, discoveryURL, &metadata); err != nil {
+		return err
+	}
+	issues := checkOIDCMetadata(metadata, discoveryURL)
+
+	if metadata.JWKSURI != "" {
+		jwksIssues, err := checkJWKS(client, metadata.JWKSURI)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, jwksIssues...)
+	}
+	if config.ClientID != "" {
+		pkceIssues, err := checkPKCEEnforced(client, metadata, config)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, pkceIssues...)
+	}
+	if metadata.RegistrationEndpoint != "" && !safeMode {
+		registrationIssues, err := checkOpenRegistration(client, metadata.RegistrationEndpoint)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, registrationIssues...)
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	var messages []string
+	penalty := 0
+	for _, issue := range issues {
+		messages = append(messages, fmt.Sprintf("%s (%s)", issue.message, issue.severity))
+		penalty += severityPenalty(issue.severity)
+	}
+	return OIDCSecurityError{message: strings.Join(messages, "; "), penalty: penalty}
+}
+
+// runOIDCAssessment assesses the configured provider and reports it as an endpoint of its own
+func runOIDCAssessment(config *Config, limiters *hostLimiters) (result EndpointResult) {
+	endpoint := APIEndpoint{Name: oidcProviderName, URL: oidcDiscoveryURL(config.OIDC.Discovery), Method: http.MethodGet}
+	result = EndpointResult{Name: endpoint.Name, URL: endpoint.URL, Score: 100}
+	client, stats := newScanClient(config, endpoint, limiters)
+	// The credentials are for the scanned API, not for its identity provider
+	client = withoutCredentials(client)
+	precheck := newEndpointPrecheck(client, config.Precheck, endpoint)
+	collector := &endpointCollector{result: &result}
+	defer collector.collect()
+	defer recoverTest(collector, collector.slot(), oidcTestName)
+
+	start := time.Now()
+	testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+	err := precheck.check()
+	if err == nil {
+		err = performOIDCTest(testClient, config.OIDC, config.SafeMode)
+	}
+	testResult := recorder.attach(newTestResult(oidcTestName, err, time.Since(start)))
+	result.Results = append(result.Results, testResult)
+	var oidcErr OIDCSecurityError
+	if errors.As(err, &oidcErr) {
+		result.Score -= oidcErr.penalty
+	} else if testResult.Failed() {
+		result.Score -= severityPenalty("low")
+	}
+	result.Throttled = stats.throttle.Events()
+	result.IPFamily = stats.dial.Family()
+	result.Certificate = stats.certs.Certificate()
+	if u, err := url.Parse(endpoint.URL); err == nil {
+		result.Limiter = limiters.stats(u.Host)
+	}
+	return result
+}
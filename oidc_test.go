@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeOIDCProvider serves a discovery document, a JWKS and the authorization
+// and registration endpoints. pkce makes the authorization endpoint reject
+// requests without a code_challenge; open makes registration succeed.
+type fakeOIDCProvider struct {
+	metadata   map[string]interface{}
+	keys       []jsonWebKey
+	pkce       bool
+	open       bool
+	registered int
+	deleted    int
+}
+
+func (p *fakeOIDCProvider) serve(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case oidcDiscoveryPath:
+			metadata := map[string]interface{}{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"jwks_uri":               server.URL + "/jwks",
+				"registration_endpoint":  server.URL + "/register",
+			}
+			for key, value := range p.metadata {
+				metadata[key] = value
+			}
+			json.NewEncoder(w).Encode(metadata)
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": p.keys})
+		case "/authorize":
+			if p.pkce && r.URL.Query().Get("code_challenge") == "" {
+				http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?error=invalid_request", http.StatusFound)
+				return
+			}
+			w.Write([]byte("<form>login</form>"))
+		case "/register":
+			if !p.open {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			p.registered++
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{
+				"client_id":                 "client-1",
+				"registration_client_uri":   server.URL + "/register/client-1",
+				"registration_access_token": "registration-token",
+			})
+		case "/register/client-1":
+			if r.Method == http.MethodDelete && r.Header.Get("Authorization") == "Bearer registration-token" {
+				p.deleted++
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func rsaJWK(kid string, bits int) jsonWebKey {
+	modulus := make([]byte, bits/8)
+	modulus[0] = 0xc1
+	return jsonWebKey{Kty: "RSA", Alg: "RS256", Kid: kid, N: base64.RawURLEncoding.EncodeToString(modulus)}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	if err := validateOIDC(OIDCConfig{Enabled: true, Discovery: "https://idp.example.com"}); err != nil {
+		t.Errorf("Expected issuer URL to be valid, got %v", err)
+	}
+	if err := validateOIDC(OIDCConfig{Enabled: true}); err == nil {
+		t.Error("Expected missing discovery to be rejected")
+	}
+	if err := validateOIDC(OIDCConfig{Enabled: true, Discovery: "https://idp.example.com", ClientID: "app"}); err == nil {
+		t.Error("Expected client_id without redirect_uri to be rejected")
+	}
+}
+
+func TestOIDCDiscoveryURL(t *testing.T) {
+	for _, discovery := range []string{"https://idp.example.com", "https://idp.example.com/", "https://idp.example.com/.well-known/openid-configuration"} {
+		if got := oidcDiscoveryURL(discovery); got != "https://idp.example.com/.well-known/openid-configuration" {
+			t.Errorf("Expected discovery document of %s, got %s", discovery, got)
+		}
+	}
+}
+
+func TestOIDCTestHardenedProvider(t *testing.T) {
+	provider := &fakeOIDCProvider{
+		metadata: map[string]interface{}{
+			"token_endpoint_auth_methods_supported": []string{"private_key_jwt"},
+			"code_challenge_methods_supported":      []string{"S256"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		},
+		keys: []jsonWebKey{rsaJWK("key-1", 2048), {Kty: "EC", Alg: "ES256", Kid: "key-2"}},
+		pkce: true,
+	}
+	server := provider.serve(t)
+	config := OIDCConfig{Enabled: true, Discovery: server.URL, ClientID: "app", RedirectURI: "https://app.example.com/callback"}
+
+	if err := performOIDCTest(server.Client(), config, false); err != nil {
+		t.Errorf("Expected hardened provider to pass, got %v", err)
+	}
+	if provider.registered != 0 {
+		t.Errorf("Expected no client to be registered, got %d", provider.registered)
+	}
+}
+
+func TestOIDCTestWeakProvider(t *testing.T) {
+	provider := &fakeOIDCProvider{
+		metadata: map[string]interface{}{
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "none"},
+			"code_challenge_methods_supported":      []string{"plain", "S256"},
+			"id_token_signing_alg_values_supported": []string{"RS256", "none"},
+		},
+		keys: []jsonWebKey{rsaJWK("short", 1024), {Kty: "oct", Kid: "shared"}, {Kty: "RSA", Alg: "HS256", Kid: "confused"}},
+		open: true,
+	}
+	server := provider.serve(t)
+	config := OIDCConfig{Enabled: true, Discovery: server.URL + oidcDiscoveryPath, ClientID: "app", RedirectURI: "https://app.example.com/callback"}
+
+	err := performOIDCTest(server.Client(), config, false)
+	var oidcErr OIDCSecurityError
+	if !errors.As(err, &oidcErr) {
+		t.Fatalf("Expected OIDCSecurityError, got %v", err)
+	}
+	for _, want := range []string{
+		"without authentication (none)",
+		"plain PKCE method",
+		"alg none",
+		"short is a 1024-bit RSA key",
+		"symmetric key shared",
+		"confused is for the weak alg HS256",
+		"PKCE is not enforced",
+		"registered client client-1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+	if provider.deleted != 1 {
+		t.Errorf("Expected the registered client to be deleted, got %d deletions", provider.deleted)
+	}
+	if want := severityPenalty("medium") + severityPenalty("low") + 6*severityPenalty("high"); oidcErr.penalty != want {
+		t.Errorf("Expected penalty %d, got %d", want, oidcErr.penalty)
+	}
+}
+
+func TestOIDCTestSafeModeSkipsRegistration(t *testing.T) {
+	provider := &fakeOIDCProvider{
+		metadata: map[string]interface{}{"code_challenge_methods_supported": []string{"S256"}},
+		open:     true,
+	}
+	server := provider.serve(t)
+
+	if err := performOIDCTest(server.Client(), OIDCConfig{Enabled: true, Discovery: server.URL}, true); err != nil {
+		t.Errorf("Expected safe mode to skip registration, got %v", err)
+	}
+	if provider.registered != 0 {
+		t.Errorf("Expected no client to be registered in safe mode, got %d", provider.registered)
+	}
+}
+
+func TestOIDCTestIssuerMismatch(t *testing.T) {
+	provider := &fakeOIDCProvider{metadata: map[string]interface{}{
+		"issuer":                           "https://other.example.com",
+		"code_challenge_methods_supported": []string{"S256"},
+	}}
+	server := provider.serve(t)
+
+	err := performOIDCTest(server.Client(), OIDCConfig{Enabled: true, Discovery: server.URL}, true)
+	if err == nil || !strings.Contains(err.Error(), "mix-up") {
+		t.Errorf("Expected issuer mismatch to be reported, got %v", err)
+	}
+}
+
+func TestRunTestsReportsOIDCProvider(t *testing.T) {
+	provider := &fakeOIDCProvider{metadata: map[string]interface{}{"code_challenge_methods_supported": []string{"S256"}}}
+	server := provider.serve(t)
+
+	results := runTests(&Config{OIDC: OIDCConfig{Enabled: true, Discovery: server.URL}})
+	if len(results) != 1 || results[0].Name != oidcProviderName || results[0].URL != server.URL+oidcDiscoveryPath {
+		t.Fatalf("Expected the provider as an endpoint of its own, got %+v", results)
+	}
+	if len(results[0].Results) != 1 || !results[0].Results[0].Passed || results[0].Score != 100 {
+		t.Errorf("Expected a passing OpenID Connect test, got %+v", results[0])
+	}
+}
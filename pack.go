@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// packVersionPattern matches the MAJOR.MINOR.PATCH versions of scan packs
+var packVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// packNamePattern keeps pack names usable as file names
+var packNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// packConfig is the part of a configuration a scan pack shares: what to scan
+// and with which payloads and request profile, but no credentials or delivery
+// settings. Endpoint login sessions and the request profile headers that carry
+// credentials are left out.
+type packConfig struct {
+	APIEndpoints      []APIEndpoint         `yaml:"api_endpoints"`
+	InjectionPayloads []string              `yaml:"injection_payloads,omitempty"`
+	RequestProfile    RequestProfileConfig  `yaml:"request_profile,omitempty"`
+	SecurityHeaders   SecurityHeadersConfig `yaml:"security_headers,omitempty"`
+	Rules             []RuleConfig          `yaml:"rules,omitempty"`
+	ExpectedPosture   string                `yaml:"expected_posture,omitempty"`
+}
+
+// scanPack is a signed, versioned scan that teams can share
+type scanPack struct {
+	Name      string           `json:"name"`
+	Version   string           `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Config    string           `json:"config"`
+	Posture   Posture          `json:"posture,omitempty"`
+	Signature *resultSignature `json:"signature,omitempty"`
+}
+
+// newScanPack packages the shareable part of a configuration and its expected posture
+func newScanPack(name, version string, config *Config, createdAt time.Time) (scanPack, error) {
+	if !packNamePattern.MatchString(name) {
+		return scanPack{}, fmt.Errorf("pack name %q must be lowercase letters, digits, - and _", name)
+	}
+	if !packVersionPattern.MatchString(version) {
+		return scanPack{}, fmt.Errorf("pack version %q must be MAJOR.MINOR.PATCH", version)
+	}
+	data, err := yaml.Marshal(packConfig{
+		APIEndpoints:      packEndpoints(config.APIEndpoints),
+		InjectionPayloads: config.InjectionPayloads,
+		RequestProfile:    packProfile(config.RequestProfile),
+		SecurityHeaders:   config.SecurityHeaders,
+		Rules:             config.Rules,
+	})
+	if err != nil {
+		return scanPack{}, fmt.Errorf("failed to encode pack configuration: %v", err)
+	}
+	pack := scanPack{Name: name, Version: version, CreatedAt: createdAt, Config: string(data)}
+	if config.ExpectedPosture != "" {
+		if pack.Posture, err = loadPosture(config.ExpectedPosture); err != nil {
+			return scanPack{}, err
+		}
+	}
+	return pack, nil
+}
+
+// packEndpoints returns copies of the endpoints without their login sessions,
+// whose bodies and headers hold usernames, passwords or API keys
+func packEndpoints(endpoints []APIEndpoint) []APIEndpoint {
+	shared := make([]APIEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpoint.Session = nil
+		if endpoint.RequestProfile != nil {
+			profile := packProfile(*endpoint.RequestProfile)
+			endpoint.RequestProfile = &profile
+		}
+		shared[i] = endpoint
+	}
+	return shared
+}
+
+// packProfile returns a copy of the request profile without the headers that carry credentials
+func packProfile(profile RequestProfileConfig) RequestProfileConfig {
+	if len(profile.Headers) == 0 {
+		return profile
+	}
+	headers := map[string]string{}
+	for name, value := range profile.Headers {
+		if !sensitiveHeader(name) {
+			headers[name] = value
+		}
+	}
+	profile.Headers = headers
+	return profile
+}
+
+func (p *scanPack) sign(key ed25519.PrivateKey) error {
+	unsigned := *p
+	unsigned.Signature = nil
+	signature, err := signJSON(unsigned, key)
+	if err != nil {
+		return err
+	}
+	p.Signature = signature
+	return nil
+}
+
+func (p scanPack) verify(key ed25519.PublicKey) error {
+	signature := p.Signature
+	p.Signature = nil
+	return verifyJSON(p, signature, key, "scan pack")
+}
+
+// readScanPack decodes a pack and checks its signature and, when pinned, its version
+func readScanPack(data []byte, key ed25519.PublicKey, pinnedVersion string) (scanPack, error) {
+	var pack scanPack
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return scanPack{}, fmt.Errorf("invalid scan pack: %v", err)
+	}
+	if err := pack.verify(key); err != nil {
+		return scanPack{}, err
+	}
+	if pinnedVersion != "" && pack.Version != pinnedVersion {
+		return scanPack{}, fmt.Errorf("scan pack %s is version %s, but version %s is pinned", pack.Name, pack.Version, pinnedVersion)
+	}
+	if !packNamePattern.MatchString(pack.Name) {
+		return scanPack{}, fmt.Errorf("invalid scan pack name %q", pack.Name)
+	}
+	var config packConfig
+	if err := yaml.UnmarshalStrict([]byte(pack.Config), &config); err != nil {
+		return scanPack{}, fmt.Errorf("invalid scan pack configuration: %v", err)
+	}
+	return pack, nil
+}
+
+// install writes the pack's configuration, to be used as a -config-overlay,
+// and its expected posture to dir, and returns the configuration's path
+func (p scanPack) install(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create pack directory: %v", err)
+	}
+	var config packConfig
+	if err := yaml.Unmarshal([]byte(p.Config), &config); err != nil {
+		return "", fmt.Errorf("invalid scan pack configuration: %v", err)
+	}
+	base := filepath.Join(dir, p.Name)
+	if p.Posture != nil {
+		config.ExpectedPosture = base + ".posture.yaml"
+		if err := p.Posture.save(config.ExpectedPosture); err != nil {
+			return "", err
+		}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode pack configuration: %v", err)
+	}
+	header := fmt.Sprintf("# Scan pack %s %s, signed by key %s. Reinstall the pack instead of editing this file.\n", p.Name, p.Version, p.Signature.KeyID)
+	if err := ioutil.WriteFile(base+".yaml", append([]byte(header), data...), 0644); err != nil {
+		return "", fmt.Errorf("failed to write pack configuration: %v", err)
+	}
+	return base + ".yaml", nil
+}
+
+// packCommand implements the pack subcommand, which exports a signed scan
+// pack from a configuration or installs one
+func packCommand(args []string) error {
+	usage := invalidConfig(fmt.Errorf("usage: pack export|install [flags]"))
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("pack export", flag.ExitOnError)
+		configFile := flags.String("config", "config.yaml", "configuration to package")
+		name := flags.String("name", "", "pack name")
+		version := flags.String("version", "", "pack version, MAJOR.MINOR.PATCH")
+		privateKey := flags.String("key", "", "PEM-encoded Ed25519 private key to sign the pack with")
+		output := flags.String("out", "", "file to write the pack to (defaults to <name>-<version>.pack.json)")
+		flags.Parse(args[1:])
+		if *privateKey == "" {
+			return invalidConfig(fmt.Errorf("-key is required: scan packs are always signed"))
+		}
+
+		key, err := loadSigningKey(*privateKey)
+		if err != nil {
+			return invalidConfig(err)
+		}
+		config, err := loadConfig(*configFile)
+		if err != nil {
+			return invalidConfig(err)
+		}
+		pack, err := newScanPack(*name, *version, config, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if err := pack.sign(key); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(pack, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode scan pack: %v", err)
+		}
+		if *output == "" {
+			*output = fmt.Sprintf("%s-%s.pack.json", pack.Name, pack.Version)
+		}
+		if err := writeOutput(*output, data); err != nil {
+			return err
+		}
+		fmt.Printf("Exported scan pack %s %s to %s\n", pack.Name, pack.Version, *output)
+		return nil
+
+	case "install":
+		flags := flag.NewFlagSet("pack install", flag.ExitOnError)
+		input := flags.String("in", "", "scan pack to install")
+		publicKey := flags.String("key", "", "PEM-encoded Ed25519 public key the pack must be signed with")
+		version := flags.String("version", "", "only install this version of the pack")
+		dir := flags.String("dir", "packs", "directory to install the pack's configuration and posture to")
+		flags.Parse(args[1:])
+		if *input == "" || *publicKey == "" {
+			return invalidConfig(fmt.Errorf("-in and -key are required"))
+		}
+
+		key, err := loadVerifyKey(*publicKey)
+		if err != nil {
+			return invalidConfig(err)
+		}
+		data, err := ioutil.ReadFile(*input)
+		if err != nil {
+			return fmt.Errorf("failed to read scan pack: %v", err)
+		}
+		pack, err := readScanPack(data, key, *version)
+		if err != nil {
+			return err
+		}
+		path, err := pack.install(*dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Installed scan pack %s %s; scan with -config-overlay %s\n", pack.Name, pack.Version, path)
+		return nil
+
+	default:
+		return usage
+	}
+}
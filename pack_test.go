@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func packTestConfig(t *testing.T, dir string) *Config {
+	posturePath := filepath.Join(dir, "posture.yaml")
+	if err := (Posture{"http://api.example.com/users": {"Auth Test": posturePass}}).save(posturePath); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return &Config{
+		APIEndpoints:      []APIEndpoint{{URL: "http://api.example.com/users", Method: "GET"}},
+		InjectionPayloads: []string{"' OR '1'='1"},
+		Auth:              Auth{Username: "admin", Password: "secret"},
+		ExpectedPosture:   posturePath,
+	}
+}
+
+func TestScanPackRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packs")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+
+	pack, err := newScanPack("payments", "1.2.0", packTestConfig(t, dir), time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := pack.sign(private); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, _ := json.Marshal(pack)
+
+	installed, err := readScanPack(data, public, "1.2.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	path, err := installed.install(filepath.Join(dir, "installed"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected the installed pack to be a valid configuration, got %v", err)
+	}
+	if len(config.APIEndpoints) != 1 || config.APIEndpoints[0].URL != "http://api.example.com/users" || len(config.InjectionPayloads) != 1 {
+		t.Errorf("Unexpected installed configuration: %+v", config)
+	}
+	if config.Auth.Password != "" {
+		t.Errorf("Expected credentials to stay out of the pack, got %+v", config.Auth)
+	}
+	posture, err := loadPosture(config.ExpectedPosture)
+	if err != nil || posture["http://api.example.com/users"]["Auth Test"] != posturePass {
+		t.Errorf("Expected the pack's posture to be installed, got %v (%v)", posture, err)
+	}
+}
+
+func TestReadScanPackRejectsTamperingAndOtherVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packs")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+
+	pack, err := newScanPack("payments", "1.2.0", packTestConfig(t, dir), time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pack.sign(private)
+	data, _ := json.Marshal(pack)
+
+	if _, err := readScanPack(data, public, "1.3.0"); err == nil {
+		t.Errorf("Expected an error for a version other than the pinned one")
+	}
+
+	pack.Config += "\ninjection_payloads: [\"x\"]\n"
+	tampered, _ := json.Marshal(pack)
+	var sigErr SignatureError
+	if _, err := readScanPack(tampered, public, ""); !errors.As(err, &sigErr) {
+		t.Errorf("Expected a SignatureError for a modified pack, got %v", err)
+	}
+
+	pack.Signature = nil
+	unsigned, _ := json.Marshal(pack)
+	if _, err := readScanPack(unsigned, public, ""); err == nil {
+		t.Errorf("Expected an error for an unsigned pack")
+	}
+}
+
+func TestNewScanPackValidatesNameAndVersion(t *testing.T) {
+	config := &Config{APIEndpoints: []APIEndpoint{{URL: "http://api.example.com"}}}
+	if _, err := newScanPack("../payments", "1.0.0", config, time.Now()); err == nil {
+		t.Errorf("Expected an error for a name that is not a file name")
+	}
+	if _, err := newScanPack("payments", "latest", config, time.Now()); err == nil {
+		t.Errorf("Expected an error for a version that is not MAJOR.MINOR.PATCH")
+	}
+}
+
+func TestScanPackLeavesOutEndpointCredentials(t *testing.T) {
+	config := &Config{
+		APIEndpoints: []APIEndpoint{{
+			URL:            "http://api.example.com/users",
+			Method:         "GET",
+			Session:        &SessionConfig{Login: &LoginConfig{URL: "http://api.example.com/login", Body: `{"user":"admin","password":"hunter2"}`, Headers: map[string]string{"X-Api-Key": "key-123"}}},
+			RequestProfile: &RequestProfileConfig{Headers: map[string]string{"Authorization": "Bearer abc", "X-Team": "payments"}},
+		}},
+		RequestProfile: RequestProfileConfig{UserAgent: "scanner", Headers: map[string]string{"Cookie": "sid=xyz"}},
+	}
+	pack, err := newScanPack("payments", "1.0.0", config, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, secret := range []string{"hunter2", "key-123", "Bearer abc", "sid=xyz", "login"} {
+		if strings.Contains(pack.Config, secret) {
+			t.Errorf("Expected %q to stay out of the pack, got:\n%s", secret, pack.Config)
+		}
+	}
+	if !strings.Contains(pack.Config, "X-Team: payments") || !strings.Contains(pack.Config, "user_agent: scanner") {
+		t.Errorf("Expected the rest of the request profile to be shared, got:\n%s", pack.Config)
+	}
+	if config.APIEndpoints[0].Session == nil || config.APIEndpoints[0].RequestProfile.Headers["Authorization"] == "" {
+		t.Errorf("Expected the scan's own configuration to be left alone")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const defaultPluginTimeout = 30 * time.Second
+
+// PluginConfig represents an external checker invoked once per endpoint
+type PluginConfig struct {
+	Name    string                 `yaml:"name"`
+	Command string                 `yaml:"command"`
+	Args    []string               `yaml:"args"`
+	Timeout time.Duration          `yaml:"timeout"`
+	Dir     string                 `yaml:"dir"`
+	Env     []string               `yaml:"env"`
+	Sandbox bool                   `yaml:"sandbox"`
+	Safe    bool                   `yaml:"safe"`
+	Config  map[string]interface{} `yaml:"config"`
+}
+
+// pluginRequest is written as JSON to the plugin's stdin
+type pluginRequest struct {
+	Endpoint pluginEndpoint         `json:"endpoint"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+}
+
+type pluginEndpoint struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Body   string `json:"body,omitempty"`
+}
+
+// pluginResponse is read as JSON from the plugin's stdout
+type pluginResponse struct {
+	Results []pluginResult `json:"results"`
+}
+
+// PluginError is returned when a plugin crashed, timed out or wrote invalid
+// output, so that its tests are recorded as errored rather than as findings
+type PluginError struct{ message string }
+
+func (e PluginError) Error() string { return e.message }
+
+func (e PluginError) Is(target error) bool {
+	return target == ErrTestErrored
+}
+
+type pluginResult struct {
+	TestName string `json:"test_name"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// runPlugin executes the plugin for an endpoint and returns the test results it
+// reports along with the score penalty for its failures. The plugin does not
+// start during a blackout window and is stopped when one opens.
+func runPlugin(plugin PluginConfig, endpoint APIEndpoint, guard *blackoutGuard) ([]TestResult, int, error) {
+	if err := guard.check(); err != nil {
+		return nil, 0, err
+	}
+	timeout := plugin.Timeout
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+	deadline := timeout
+	if untilBlackout, ok := guard.untilNextWindow(timeout); ok {
+		deadline = untilBlackout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	config := map[string]interface{}{}
+	for key, value := range plugin.Config {
+		config[key] = normalizeYAML(value)
+	}
+	input, err := json.Marshal(pluginRequest{
+		Endpoint: pluginEndpoint{URL: endpoint.URL, Method: endpoint.Method, Body: endpoint.Body},
+		Config:   config,
+	})
+	if err != nil {
+		return nil, 0, PluginError{fmt.Sprintf("failed to encode plugin input: %v", err)}
+	}
+
+	cmd := exec.CommandContext(ctx, plugin.Command, plugin.Args...)
+	cmd.Dir = plugin.Dir
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Sandboxed plugins only see the variables listed in their config
+	if plugin.Sandbox {
+		cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, plugin.Env...)
+	} else {
+		cmd.Env = append(os.Environ(), plugin.Env...)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			if err := guard.check(); err != nil {
+				return nil, 0, err
+			}
+			return nil, 0, PluginError{fmt.Sprintf("plugin timed out after %s", timeout)}
+		}
+		return nil, 0, PluginError{fmt.Sprintf("plugin failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))}
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, 0, PluginError{fmt.Sprintf("invalid plugin output: %v", err)}
+	}
+
+	var results []TestResult
+	penalty := 0
+	for _, r := range response.Results {
+		testName := r.TestName
+		if testName == "" {
+			testName = plugin.Name
+		}
+		results = append(results, TestResult{TestName: testName, Passed: r.Passed, Message: r.Message, Severity: r.Severity})
+		if !r.Passed {
+			penalty += severityPenalty(r.Severity)
+		}
+	}
+	return results, penalty, nil
+}
+
+// severityPenalty returns the score deduction for a failure of the given severity
+func severityPenalty(severity string) int {
+	switch severity {
+	case "critical":
+		return 50
+	case "high":
+		return 30
+	case "medium":
+		return 20
+	case "low":
+		return 10
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPlugin(t *testing.T) {
+	plugin := PluginConfig{
+		Name:    "echo-plugin",
+		Command: "sh",
+		Args: []string{"-c", `input=$(cat); case "$input" in
+*'"url":"http://example.com/api"'*'"mode":"strict"'*) echo '{"results": [{"test_name": "XSS Test", "passed": false, "message": "reflected input", "severity": "high"}, {"passed": true, "message": "ok"}]}' ;;
+*) echo '{"results": []}' ;;
+esac`},
+		Config: map[string]interface{}{"mode": "strict"},
+	}
+
+	results, penalty, err := runPlugin(plugin, APIEndpoint{URL: "http://example.com/api", Method: "GET"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].TestName != "XSS Test" || results[0].Passed {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if severity := resultSeverity(results[0]); severity != "high" {
+		t.Errorf("Expected the severity reported by the plugin, got %q", severity)
+	}
+	if results[1].TestName != "echo-plugin" || !results[1].Passed {
+		t.Errorf("Expected plugin name as default test name, got %+v", results[1])
+	}
+	if penalty != 30 {
+		t.Errorf("Expected penalty 30, got %d", penalty)
+	}
+}
+
+func TestRunPluginTimeout(t *testing.T) {
+	plugin := PluginConfig{Name: "slow", Command: "sleep", Args: []string{"5"}, Timeout: 50 * time.Millisecond}
+
+	_, _, err := runPlugin(plugin, APIEndpoint{URL: "http://example.com/api"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+}
+
+func TestRunPluginErrorsAreNotFindings(t *testing.T) {
+	plugins := []PluginConfig{
+		{Name: "crash", Command: "sh", Args: []string{"-c", "cat >/dev/null; exit 3"}},
+		{Name: "garbage", Command: "sh", Args: []string{"-c", "cat >/dev/null; echo not json"}},
+	}
+	for _, plugin := range plugins {
+		_, _, err := runPlugin(plugin, APIEndpoint{URL: "http://example.com/api"}, nil)
+		result := newTestResult(plugin.Name, err, 0)
+		if result.Failed() || !result.Skipped || result.ErrorCode != "test_errored" {
+			t.Errorf("Expected %s to be recorded as errored, got %+v", plugin.Name, result)
+		}
+	}
+}
+
+func TestRunPluginSandboxEnv(t *testing.T) {
+	os.Setenv("SCANNER_SECRET", "leaked")
+	defer os.Unsetenv("SCANNER_SECRET")
+	plugin := PluginConfig{
+		Name:    "env",
+		Command: "sh",
+		Args:    []string{"-c", `cat >/dev/null; echo "{\"results\": [{\"passed\": true, \"message\": \"$SCANNER_SECRET$ALLOWED\"}]}"`},
+		Env:     []string{"ALLOWED=yes"},
+		Sandbox: true,
+	}
+
+	results, _, err := runPlugin(plugin, APIEndpoint{URL: "http://example.com/api"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if results[0].Message != "yes" {
+		t.Errorf("Expected only allowed variables in sandbox, got %q", results[0].Message)
+	}
+}
+
+func TestRunPluginStopsAtBlackoutWindow(t *testing.T) {
+	blackouts, _ := parseBlackouts([]BlackoutWindow{{Cron: "0 2 * * *", Duration: time.Hour, Timezone: "UTC"}})
+	guard := newBlackoutGuard(blackouts)
+	guard.now = func() time.Time { return time.Date(2024, 3, 4, 2, 30, 0, 0, time.UTC) }
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "ran")
+	plugin := PluginConfig{Name: "marker", Command: "touch", Args: []string{marker}}
+
+	_, _, err = runPlugin(plugin, APIEndpoint{URL: "http://example.com/api"}, guard)
+	var blackoutErr BlackoutError
+	if !errors.As(err, &blackoutErr) {
+		t.Errorf("Expected a blackout error, got %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the plugin not to start during the blackout window")
+	}
+
+	// The window opens 200ms into a plugin that would run for 5s
+	started := time.Now()
+	guard.now = func() time.Time {
+		return time.Date(2024, 3, 4, 1, 59, 59, 800000000, time.UTC).Add(time.Since(started))
+	}
+	slow := PluginConfig{Name: "slow", Command: "sleep", Args: []string{"5"}}
+	_, _, err = runPlugin(slow, APIEndpoint{URL: "http://example.com/api"}, guard)
+	if !errors.As(err, &blackoutErr) || time.Since(started) > 2*time.Second {
+		t.Errorf("Expected the plugin to be stopped when the window opened, got %v after %s", err, time.Since(started))
+	}
+}
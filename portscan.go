@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openPortsTestName = "Open Ports Test"
+
+	defaultPortScanRate    = 10
+	defaultPortScanTimeout = 2 * time.Second
+)
+
+// PortScanConfig represents the opt-in TCP connect scan of each endpoint's
+// host for management and database ports that should not be reachable
+type PortScanConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Ports   []int         `yaml:"ports"`
+	Allowed []int         `yaml:"allowed"`
+	Rate    float64       `yaml:"rate"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// OpenPortsError is returned when a host accepts connections on unexpected ports
+type OpenPortsError struct{ message string }
+
+func (e OpenPortsError) Error() string { return e.message }
+
+// defaultScanPorts are the management and database ports scanned when none are configured
+var defaultScanPorts = []int{21, 22, 23, 2375, 3306, 5432, 5601, 6379, 8080, 8443, 9200, 11211, 15672, 27017}
+
+// portNames describe the services usually found on the scanned ports
+var portNames = map[int]string{
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	2375:  "docker",
+	3306:  "mysql",
+	5432:  "postgresql",
+	5601:  "kibana",
+	6379:  "redis",
+	8080:  "http-alt",
+	8443:  "https-alt",
+	9200:  "elasticsearch",
+	11211: "memcached",
+	15672: "rabbitmq-management",
+	27017: "mongodb",
+}
+
+// validatePortScan checks the configured ports and rate
+func validatePortScan(config PortScanConfig) error {
+	for _, port := range append(append([]int{}, config.Ports...), config.Allowed...) {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("port_scan: invalid port %d", port)
+		}
+	}
+	if config.Rate < 0 {
+		return fmt.Errorf("port_scan: rate must not be negative")
+	}
+	return nil
+}
+
+// scanPorts returns the ports to scan on an endpoint's host: the configured
+// ones, less the allowed ones and the endpoint's own port
+func scanPorts(config PortScanConfig, endpointPort int) []int {
+	ports := config.Ports
+	if len(ports) == 0 {
+		ports = defaultScanPorts
+	}
+	skip := map[int]bool{endpointPort: true}
+	for _, port := range config.Allowed {
+		skip[port] = true
+	}
+	var scanned []int
+	for _, port := range ports {
+		if !skip[port] {
+			skip[port] = true
+			scanned = append(scanned, port)
+		}
+	}
+	return scanned
+}
+
+// performPortScan connects to each port of the shortlist on the endpoint's
+// host, no faster than the configured rate, and fails if any of them accepts
+// the connection. Connections are closed as soon as they are established, and
+// the scan stops when a blackout window opens.
+func performPortScan(endpoint APIEndpoint, config PortScanConfig, resolve map[string]string, guard *blackoutGuard) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	endpointPort := 80
+	if u.Scheme == "https" {
+		endpointPort = 443
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		endpointPort = port
+	}
+	rate := config.Rate
+	if rate <= 0 {
+		rate = defaultPortScanRate
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultPortScanTimeout
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	var open []string
+	for i, port := range scanPorts(config, endpointPort) {
+		if i > 0 {
+			<-ticker.C
+		}
+		if err := guard.check(); err != nil {
+			return err
+		}
+		addr := resolveAddress(resolve, net.JoinHostPort(u.Hostname(), strconv.Itoa(port)))
+		conn, err := net.DialTimeout(familyNetwork("tcp", endpoint.IPFamily), addr, timeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		if name, ok := portNames[port]; ok {
+			open = append(open, fmt.Sprintf("%d (%s)", port, name))
+		} else {
+			open = append(open, strconv.Itoa(port))
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return OpenPortsError{fmt.Sprintf("unexpected open ports on %s: %s", u.Hostname(), strings.Join(open, ", "))}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerformPortScanReportsOpenPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer listener.Close()
+	open := listener.Addr().(*net.TCPAddr).Port
+	closedListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := closedListener.Addr().(*net.TCPAddr).Port
+	closedListener.Close()
+
+	config := PortScanConfig{Ports: []int{open, closed}, Rate: 1000, Timeout: time.Second}
+	err = performPortScan(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil)
+	var portsErr OpenPortsError
+	if !errors.As(err, &portsErr) {
+		t.Fatalf("Expected an OpenPortsError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(open)) || strings.Contains(err.Error(), strconv.Itoa(closed)) {
+		t.Errorf("Expected only port %d to be reported, got %q", open, err.Error())
+	}
+
+	config.Allowed = []int{open}
+	if err := performPortScan(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil); err != nil {
+		t.Errorf("Expected an allowed port not to be reported, got %v", err)
+	}
+}
+
+func TestPerformPortScanIsRateLimited(t *testing.T) {
+	config := PortScanConfig{Ports: []int{1, 2, 3}, Rate: 20, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	performPortScan(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected 3 ports at 20 per second to take at least 100ms, took %s", elapsed)
+	}
+}
+
+func TestScanPortsSkipsEndpointPort(t *testing.T) {
+	ports := scanPorts(PortScanConfig{Ports: []int{22, 8443, 8443, 5432}, Allowed: []int{22}}, 8443)
+	if len(ports) != 1 || ports[0] != 5432 {
+		t.Errorf("Expected only port 5432, got %v", ports)
+	}
+	if ports := scanPorts(PortScanConfig{}, 443); len(ports) != len(defaultScanPorts) {
+		t.Errorf("Expected the default shortlist, got %v", ports)
+	}
+}
+
+func TestValidatePortScan(t *testing.T) {
+	if err := validatePortScan(PortScanConfig{Ports: []int{5432}, Allowed: []int{8080}, Rate: 5}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := validatePortScan(PortScanConfig{Ports: []int{0}}); err == nil {
+		t.Errorf("Expected an error for port 0")
+	}
+	if err := validatePortScan(PortScanConfig{Rate: -1}); err == nil {
+		t.Errorf("Expected an error for a negative rate")
+	}
+}
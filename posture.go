@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Expected outcomes of a test in a posture file
+const (
+	postureFail = "fail"
+	posturePass = "pass"
+)
+
+// Posture declares the expected outcome of each test, keyed by endpoint name
+// (or URL, for endpoints without a name) and then by test name
+type Posture map[string]map[string]string
+
+// PostureDeviation is a test whose outcome differs from the posture file
+type PostureDeviation struct {
+	Endpoint string
+	TestName string
+	Expected string
+	Message  string
+}
+
+// Kind describes the deviation as a new failure or an unexpected pass
+func (d PostureDeviation) Kind() string {
+	if d.Expected == posturePass {
+		return "new failure"
+	}
+	return "unexpected pass"
+}
+
+// loadPosture reads an expected posture file
+func loadPosture(path string) (Posture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read posture file: %v", err)
+	}
+	var posture Posture
+	if err := yaml.UnmarshalStrict(data, &posture); err != nil {
+		return nil, fmt.Errorf("failed to parse posture file: %v", err)
+	}
+	for endpoint, tests := range posture {
+		for test, expected := range tests {
+			if expected != posturePass && expected != postureFail {
+				return nil, fmt.Errorf("posture of %s on %s must be %q or %q, got %q", test, endpoint, posturePass, postureFail, expected)
+			}
+		}
+	}
+	return posture, nil
+}
+
+// postureFromResults records the outcome of every test that ran, so that a
+// scan can be accepted as the expected posture
+func postureFromResults(results []EndpointResult) Posture {
+	posture := Posture{}
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if testResult.Skipped {
+				continue
+			}
+			if posture[result.key()] == nil {
+				posture[result.key()] = map[string]string{}
+			}
+			expected := posturePass
+			if testResult.Failed() {
+				expected = postureFail
+			}
+			posture[result.key()][testResult.TestName] = expected
+		}
+	}
+	return posture
+}
+
+func (p Posture) save(path string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode posture: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write posture file: %v", err)
+	}
+	return nil
+}
+
+// comparePosture returns the tests whose outcome deviates from the posture.
+// Skipped tests and tests the posture does not mention are not deviations.
+func comparePosture(posture Posture, results []EndpointResult) []PostureDeviation {
+	var deviations []PostureDeviation
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			expected := posture[result.key()][testResult.TestName]
+			if (expected == posturePass && testResult.Failed()) || (expected == postureFail && testResult.Passed) {
+				deviations = append(deviations, PostureDeviation{
+					Endpoint: result.key(),
+					TestName: testResult.TestName,
+					Expected: expected,
+					Message:  testResult.Message,
+				})
+			}
+		}
+	}
+	return deviations
+}
+
+func writePostureReport(w io.Writer, deviations []PostureDeviation) {
+	fmt.Fprintln(w, "Posture Deviations:")
+	if len(deviations) == 0 {
+		fmt.Fprintln(w, "- none: every test matched the expected posture")
+		return
+	}
+	for _, d := range deviations {
+		if d.Message != "" {
+			fmt.Fprintf(w, "- %s on %s: %s (%s)\n", d.TestName, d.Endpoint, d.Kind(), d.Message)
+		} else {
+			fmt.Fprintf(w, "- %s on %s: %s\n", d.TestName, d.Endpoint, d.Kind())
+		}
+	}
+}
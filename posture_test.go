@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComparePosture(t *testing.T) {
+	posture := Posture{
+		"users":                     {"Auth Test": "pass", "HTTP Method Test": "fail", "Injection Test": "pass"},
+		"http://example.com/orders": {"Auth Test": "fail"},
+	}
+	results := []EndpointResult{
+		{Name: "users", URL: "http://example.com/users", Results: []TestResult{
+			{TestName: "Auth Test", Passed: true},
+			{TestName: "HTTP Method Test", Passed: true},
+			{TestName: "Injection Test", Message: "payload reflected"},
+			{TestName: "Cookie Security Test", Message: "not in the posture"},
+		}},
+		{URL: "http://example.com/orders", Results: []TestResult{
+			{TestName: "Auth Test", Skipped: true},
+		}},
+	}
+
+	expected := []PostureDeviation{
+		{Endpoint: "users", TestName: "HTTP Method Test", Expected: "fail"},
+		{Endpoint: "users", TestName: "Injection Test", Expected: "pass", Message: "payload reflected"},
+	}
+	deviations := comparePosture(posture, results)
+	if !reflect.DeepEqual(deviations, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, deviations)
+	}
+	if deviations[0].Kind() != "unexpected pass" || deviations[1].Kind() != "new failure" {
+		t.Errorf("Expected an unexpected pass and a new failure, got %q and %q", deviations[0].Kind(), deviations[1].Kind())
+	}
+
+	var out bytes.Buffer
+	writePostureReport(&out, deviations)
+	if !strings.Contains(out.String(), "- Injection Test on users: new failure (payload reflected)\n") {
+		t.Errorf("Expected the new failure to be reported, got %q", out.String())
+	}
+}
+
+func TestPostureRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "posture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "posture.yaml")
+
+	results := []EndpointResult{{URL: "http://example.com", Results: []TestResult{
+		{TestName: "Auth Test", Passed: true},
+		{TestName: "Injection Test"},
+		{TestName: "HTTP Method Test", Skipped: true},
+	}}}
+	if err := postureFromResults(results).save(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	posture, err := loadPosture(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := Posture{"http://example.com": {"Auth Test": "pass", "Injection Test": "fail"}}
+	if !reflect.DeepEqual(posture, expected) {
+		t.Errorf("Expected %v, got %v", expected, posture)
+	}
+	if deviations := comparePosture(posture, results); len(deviations) != 0 {
+		t.Errorf("Expected no deviations from the recorded posture, got %+v", deviations)
+	}
+}
+
+func TestLoadPostureRejectsUnknownOutcome(t *testing.T) {
+	f, err := ioutil.TempFile("", "posture*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("users:\n  Auth Test: passes\n")
+	f.Close()
+
+	if _, err := loadPosture(f.Name()); err == nil || !strings.Contains(err.Error(), `got "passes"`) {
+		t.Errorf("Expected an invalid outcome error, got %v", err)
+	}
+}
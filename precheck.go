@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const defaultPrecheckTimeout = 5 * time.Second
+
+// PrecheckConfig represents the reachability check run before an endpoint's tests
+type PrecheckConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// UnreachableError is returned for the tests of an endpoint that failed the pre-check
+type UnreachableError struct{ reason string }
+
+func (e UnreachableError) Error() string { return "skipped: unreachable (" + e.reason + ")" }
+
+func (e UnreachableError) Is(target error) bool { return target == ErrTargetUnreachable }
+
+// endpointPrecheck sends a single HEAD request before an endpoint's tests, so
+// that an endpoint that is down does not burn payload requests or show up as
+// failing authentication
+type endpointPrecheck struct {
+	client   *http.Client
+	endpoint APIEndpoint
+	config   PrecheckConfig
+
+	once sync.Once
+	err  error
+}
+
+func newEndpointPrecheck(client *http.Client, config PrecheckConfig, endpoint APIEndpoint) *endpointPrecheck {
+	return &endpointPrecheck{client: client, endpoint: endpoint, config: config}
+}
+
+// check runs the pre-check on first use and returns its outcome. Any HTTP
+// response counts as reachable, whatever its status; connection, DNS and TLS
+// errors do not.
+func (p *endpointPrecheck) check() error {
+	if p == nil || p.config.Disabled {
+		return nil
+	}
+	p.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), precheckTimeout(p.config))
+		defer cancel()
+
+		req, err := http.NewRequest(http.MethodHead, p.endpoint.URL, nil)
+		if err != nil {
+			p.err = UnreachableError{err.Error()}
+			return
+		}
+		resp, err := p.client.Do(req.WithContext(ctx))
+		if err != nil {
+			// Drop the method and URL the client prefixes errors with
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			// Requests the scanner itself held back, and credentials it could
+			// not obtain, are reported as such rather than as unreachable
+			var blackoutErr BlackoutError
+			var wafErr WAFBlockedError
+			if errors.As(err, &blackoutErr) || errors.As(err, &wafErr) || errorCode(err) != "" {
+				p.err = err
+				return
+			}
+			p.err = UnreachableError{err.Error()}
+			return
+		}
+		resp.Body.Close()
+	})
+	return p.err
+}
+
+func precheckTimeout(config PrecheckConfig) time.Duration {
+	if config.Timeout <= 0 {
+		return defaultPrecheckTimeout
+	}
+	return config.Timeout
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEndpointPrecheckSendsOneHEADRequest(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	precheck := newEndpointPrecheck(server.Client(), PrecheckConfig{}, APIEndpoint{URL: server.URL, Method: "POST"})
+	for i := 0; i < 3; i++ {
+		if err := precheck.check(); err != nil {
+			t.Errorf("Expected any response to count as reachable, got %v", err)
+		}
+	}
+	if len(methods) != 1 || methods[0] != "HEAD" {
+		t.Errorf("Expected a single HEAD request, got %v", methods)
+	}
+}
+
+func TestEndpointPrecheckUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := newEndpointPrecheck(http.DefaultClient, PrecheckConfig{}, APIEndpoint{URL: server.URL}).check()
+	if _, ok := err.(UnreachableError); !ok {
+		t.Fatalf("Expected unreachable error, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "skipped: unreachable (") || strings.Contains(err.Error(), server.URL) {
+		t.Errorf("Expected the connection error without the URL, got %q", err.Error())
+	}
+
+	if err := newEndpointPrecheck(http.DefaultClient, PrecheckConfig{Disabled: true}, APIEndpoint{URL: server.URL}).check(); err != nil {
+		t.Errorf("Expected a disabled pre-check to pass, got %v", err)
+	}
+}
+
+func TestRunTestsSkipsUnreachableEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	config := &Config{
+		APIEndpoints:      []APIEndpoint{{URL: server.URL, Method: "GET"}},
+		InjectionPayloads: []string{"' OR '1'='1"},
+	}
+	results := runTests(config)
+
+	if len(results[0].Results) == 0 {
+		t.Fatal("Expected test results")
+	}
+	for _, result := range results[0].Results {
+		if !result.Skipped || !strings.HasPrefix(result.Message, "skipped: unreachable") {
+			t.Errorf("Expected %s to be skipped as unreachable, got %+v", result.TestName, result)
+		}
+	}
+	if results[0].Score != 100 {
+		t.Errorf("Expected score 100, got %d", results[0].Score)
+	}
+}
+
+func TestEndpointPrecheckKeepsErrorClasses(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client"}`)
+	}))
+	defer idp.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	auth := Auth{Type: "oauth2", OAuth2: &OAuth2Config{TokenURL: idp.URL, ClientID: "scanner", ClientSecret: "wrong"}}
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport, auth)}
+	err := newEndpointPrecheck(client, PrecheckConfig{}, APIEndpoint{URL: server.URL}).check()
+	if code := errorCode(err); code != "auth_failed" {
+		t.Errorf("Expected a failed login to be reported as auth_failed, got %q (%v)", code, err)
+	}
+	if result := newTestResult("Auth Test", err, 0); !result.Skipped || result.ErrorCode != "auth_failed" {
+		t.Errorf("Expected the test to be skipped as auth_failed, got %+v", result)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// tlsVersions names the TLS versions shown in the preflight table
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// reachabilityResult is the outcome of each stage of connecting to an endpoint.
+// Stages after the first failure, and TLS for plain http, are left as "-".
+type reachabilityResult struct {
+	URL  string
+	DNS  string
+	TCP  string
+	TLS  string
+	HTTP string
+	Err  error
+}
+
+// probeReachability resolves, connects to, negotiates TLS with and sends a HEAD
+// request to the endpoint, honouring its resolve overrides and IP family
+func probeReachability(config *Config, endpoint APIEndpoint) reachabilityResult {
+	result := reachabilityResult{URL: endpoint.URL, DNS: "-", TCP: "-", TLS: "-", HTTP: "-"}
+	fail := func(stage *string, err error) reachabilityResult {
+		*stage = "failed"
+		result.Err = err
+		return result
+	}
+
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return fail(&result.DNS, fmt.Errorf("invalid URL: %v", err))
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	timeout := precheckTimeout(config.Precheck)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addr := resolveAddress(config.Resolve, net.JoinHostPort(u.Hostname(), port))
+	host, _, _ := net.SplitHostPort(addr)
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fail(&result.DNS, fmt.Errorf("dns: %v", err))
+	}
+	result.DNS = addrs[0].IP.String()
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, familyNetwork("tcp", endpoint.IPFamily), addr)
+	if err != nil {
+		return fail(&result.TCP, fmt.Errorf("tcp: %v", err))
+	}
+	defer conn.Close()
+	result.TCP = time.Since(start).Round(time.Millisecond).String()
+
+	if u.Scheme == "https" {
+		deadline, _ := ctx.Deadline()
+		conn.SetDeadline(deadline)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return fail(&result.TLS, fmt.Errorf("tls: %v", err))
+		}
+		result.TLS = tlsVersions[tlsConn.ConnectionState().Version]
+	}
+
+	client, _ := newScanClient(config, endpoint, nil)
+	client.Timeout = timeout
+	req, err := http.NewRequest(http.MethodHead, endpoint.URL, nil)
+	if err != nil {
+		return fail(&result.HTTP, fmt.Errorf("http: %v", err))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(&result.HTTP, fmt.Errorf("http: %v", err))
+	}
+	resp.Body.Close()
+	result.HTTP = fmt.Sprint(resp.StatusCode)
+	return result
+}
+
+// runPreflight probes every endpoint concurrently, writes a summary table and
+// the reason each unreachable endpoint failed, and reports whether all were reachable
+func runPreflight(config *Config, w io.Writer) bool {
+	results := make([]reachabilityResult, len(config.APIEndpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range config.APIEndpoints {
+		wg.Add(1)
+		go func(i int, endpoint APIEndpoint) {
+			defer wg.Done()
+			results[i] = probeReachability(config, endpoint)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ENDPOINT\tDNS\tTCP\tTLS\tHTTP")
+	reachable := true
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", result.URL, result.DNS, result.TCP, result.TLS, result.HTTP)
+		if result.Err != nil {
+			reachable = false
+		}
+	}
+	table.Flush()
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(w, "- %s: %v\n", result.URL, result.Err)
+		}
+	}
+	return reachable
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeReachability(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	result := probeReachability(&Config{}, APIEndpoint{URL: server.URL})
+	if result.Err != nil {
+		t.Fatalf("Expected no error, got %v", result.Err)
+	}
+	if result.DNS != "127.0.0.1" || result.TCP == "-" || result.TLS != "-" || result.HTTP != "204" {
+		t.Errorf("Expected every stage but TLS to succeed, got %+v", result)
+	}
+}
+
+func TestProbeReachabilityTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	result := probeReachability(&Config{}, APIEndpoint{URL: server.URL})
+	if result.TLS != "failed" || result.HTTP != "-" || !strings.HasPrefix(result.Err.Error(), "tls: ") {
+		t.Errorf("Expected the untrusted certificate to fail the TLS stage, got %+v", result)
+	}
+}
+
+func TestRunPreflight(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	config := &Config{APIEndpoints: []APIEndpoint{{URL: up.URL}, {URL: down.URL}}}
+	var out bytes.Buffer
+	if runPreflight(config, &out) {
+		t.Error("Expected the closed server to make the preflight fail")
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "ENDPOINT") {
+		t.Fatalf("Expected a summary table, got %q", out.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 5 || fields[0] != up.URL || fields[4] != "200" {
+		t.Errorf("Expected the reachable endpoint to return 200, got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 5 || fields[2] != "failed" {
+		t.Errorf("Expected the closed endpoint to fail at TCP, got %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "- "+down.URL+": tcp: ") {
+		t.Errorf("Expected the TCP error to be listed, got %q", lines[3])
+	}
+}
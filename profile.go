@@ -0,0 +1,70 @@
+package main
+
+import "net/http"
+
+// RequestProfileConfig represents the identity the scanner presents to targets,
+// so that scans can be attributed in their logs and allowlisted
+type RequestProfileConfig struct {
+	UserAgent string            `yaml:"user_agent"`
+	Accept    string            `yaml:"accept"`
+	Headers   map[string]string `yaml:"headers"`
+}
+
+// requestProfile merges the endpoint's profile over the global one; endpoint
+// values win and headers are combined
+func requestProfile(config *Config, endpoint APIEndpoint) RequestProfileConfig {
+	profile := RequestProfileConfig{
+		UserAgent: config.RequestProfile.UserAgent,
+		Accept:    config.RequestProfile.Accept,
+		Headers:   map[string]string{},
+	}
+	for name, value := range config.RequestProfile.Headers {
+		profile.Headers[name] = value
+	}
+	if override := endpoint.RequestProfile; override != nil {
+		if override.UserAgent != "" {
+			profile.UserAgent = override.UserAgent
+		}
+		if override.Accept != "" {
+			profile.Accept = override.Accept
+		}
+		for name, value := range override.Headers {
+			profile.Headers[name] = value
+		}
+	}
+	return profile
+}
+
+// profileTransport adds the profile headers to requests that do not set them already
+type profileTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// newProfileTransport returns base unchanged when the profile sets no headers
+func newProfileTransport(base http.RoundTripper, profile RequestProfileConfig) http.RoundTripper {
+	headers := http.Header{}
+	for name, value := range profile.Headers {
+		headers.Set(name, value)
+	}
+	if profile.UserAgent != "" {
+		headers.Set("User-Agent", profile.UserAgent)
+	}
+	if profile.Accept != "" {
+		headers.Set("Accept", profile.Accept)
+	}
+	if len(headers) == 0 {
+		return base
+	}
+	return &profileTransport{base: base, headers: headers}
+}
+
+func (t *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = values
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestProfileMerge(t *testing.T) {
+	config := &Config{RequestProfile: RequestProfileConfig{
+		UserAgent: "CompanySec-Scanner/4.0 contact:sec@corp",
+		Accept:    "application/json",
+		Headers:   map[string]string{"X-Scan-Team": "appsec", "X-Tenant": "default"},
+	}}
+	endpoint := APIEndpoint{RequestProfile: &RequestProfileConfig{Accept: "*/*", Headers: map[string]string{"X-Tenant": "acme"}}}
+
+	profile := requestProfile(config, endpoint)
+	if profile.UserAgent != "CompanySec-Scanner/4.0 contact:sec@corp" {
+		t.Errorf("Expected global user agent, got %q", profile.UserAgent)
+	}
+	if profile.Accept != "*/*" {
+		t.Errorf("Expected endpoint accept header, got %q", profile.Accept)
+	}
+	if profile.Headers["X-Scan-Team"] != "appsec" || profile.Headers["X-Tenant"] != "acme" {
+		t.Errorf("Expected merged headers, got %v", profile.Headers)
+	}
+	if config.RequestProfile.Headers["X-Tenant"] != "default" {
+		t.Errorf("Expected global profile to be left unchanged")
+	}
+}
+
+func TestScanClientAppliesProfile(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{RequestProfile: RequestProfileConfig{
+		UserAgent: "CompanySec-Scanner/4.0",
+		Headers:   map[string]string{"Authorization": "Bearer profile", "X-Scan-Team": "appsec"},
+	}}
+	endpoint := APIEndpoint{URL: server.URL, Method: "GET"}
+	client, _ := newScanClient(config, endpoint, nil)
+	if err := performAuthTest(client, endpoint, Auth{Username: "admin", Password: "password"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := received.Get("User-Agent"); got != "CompanySec-Scanner/4.0" {
+		t.Errorf("Expected profile user agent, got %q", got)
+	}
+	if got := received.Get("X-Scan-Team"); got != "appsec" {
+		t.Errorf("Expected profile header, got %q", got)
+	}
+	if got := received.Get("Authorization"); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("Expected the test's own Authorization header to win, got %q", got)
+	}
+}
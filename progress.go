@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanProgress is a snapshot of how far a scan has got: the (endpoint, test)
+// pairs started so far, how many of them completed and an estimate of the
+// time left, which is zero until the first test completes
+type ScanProgress struct {
+	Total     int           `json:"total"`
+	Completed int           `json:"completed"`
+	Percent   float64       `json:"percent"`
+	ETA       time.Duration `json:"eta"`
+}
+
+// scanProgress counts the tests of a scan as they start and complete. Its
+// methods do nothing on a nil *scanProgress, so scans without progress
+// reporting need no checks.
+type scanProgress struct {
+	mu        sync.Mutex
+	total     int
+	completed int
+	started   time.Time
+	now       func() time.Time
+	onUpdate  func(ScanProgress)
+}
+
+// newScanProgress starts tracking a scan; onUpdate, if set, is called with a
+// snapshot whenever a test completes and must not call back into the tracker
+func newScanProgress(onUpdate func(ScanProgress)) *scanProgress {
+	return &scanProgress{started: time.Now(), now: time.Now, onUpdate: onUpdate}
+}
+
+// add counts tests about to start
+func (p *scanProgress) add(tests int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += tests
+}
+
+// complete counts a test that finished, whatever its outcome. Updates are
+// delivered one at a time and in order.
+func (p *scanProgress) complete() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed++
+	if p.onUpdate != nil {
+		p.onUpdate(p.snapshot())
+	}
+}
+
+// Progress returns a snapshot of the scan's progress
+func (p *scanProgress) Progress() ScanProgress {
+	if p == nil {
+		return ScanProgress{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshot()
+}
+
+// snapshot estimates the time left from the rate tests completed at so far.
+// The tests of a scan run concurrently, so the observed time per test is the
+// elapsed time divided by the completed tests rather than the test latency.
+func (p *scanProgress) snapshot() ScanProgress {
+	snapshot := ScanProgress{Total: p.total, Completed: p.completed}
+	if p.total == 0 {
+		return snapshot
+	}
+	snapshot.Percent = float64(p.completed) * 100 / float64(p.total)
+	if p.completed > 0 {
+		perTest := p.now().Sub(p.started) / time.Duration(p.completed)
+		snapshot.ETA = perTest * time.Duration(p.total-p.completed)
+	}
+	return snapshot
+}
+
+// progressBarWidth is the number of characters of the bar drawn by progressBar
+const progressBarWidth = 30
+
+// progressBar returns a callback that redraws a progress bar with the
+// percentage and the estimated time left on a single line of w, such as a
+// terminal's standard error
+func progressBar(w io.Writer) func(ScanProgress) {
+	return func(progress ScanProgress) {
+		filled := progressBarWidth * progress.Completed / progress.Total
+		line := fmt.Sprintf("\r[%s%s] %3.0f%% %d/%d tests", strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), progress.Percent, progress.Completed, progress.Total)
+		if progress.Completed < progress.Total {
+			line += fmt.Sprintf(", ETA %s ", progress.ETA.Round(time.Second))
+		} else {
+			line += "          \n"
+		}
+		io.WriteString(w, line)
+	}
+}
+
+// testGroup waits for the tests of a scan like a sync.WaitGroup and reports
+// their progress
+type testGroup struct {
+	wg       sync.WaitGroup
+	progress *scanProgress
+}
+
+func (g *testGroup) Add(tests int) {
+	g.wg.Add(tests)
+	g.progress.add(tests)
+}
+
+func (g *testGroup) Done() {
+	g.progress.complete()
+	g.wg.Done()
+}
+
+func (g *testGroup) Wait() {
+	g.wg.Wait()
+}
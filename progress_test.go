@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanProgress(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var updates []ScanProgress
+	progress := &scanProgress{started: now, now: func() time.Time { return now }, onUpdate: func(p ScanProgress) { updates = append(updates, p) }}
+
+	progress.add(4)
+	if got := progress.Progress(); got.Total != 4 || got.Completed != 0 || got.Percent != 0 || got.ETA != 0 {
+		t.Errorf("Expected no progress and no estimate yet, got %+v", got)
+	}
+
+	now = now.Add(10 * time.Second)
+	progress.complete()
+	if got := progress.Progress(); got.Completed != 1 || got.Percent != 25 || got.ETA != 30*time.Second {
+		t.Errorf("Expected 25%% with 30s left, got %+v", got)
+	}
+
+	now = now.Add(10 * time.Second)
+	progress.complete()
+	if got := progress.Progress(); got.Percent != 50 || got.ETA != 20*time.Second {
+		t.Errorf("Expected 50%% with 20s left, got %+v", got)
+	}
+	if len(updates) != 2 || updates[1].Completed != 2 {
+		t.Errorf("Expected an update per completed test, got %+v", updates)
+	}
+
+	var disabled *scanProgress
+	disabled.add(1)
+	disabled.complete()
+	if got := disabled.Progress(); got != (ScanProgress{}) {
+		t.Errorf("Expected a nil tracker to report nothing, got %+v", got)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	draw := progressBar(&out)
+
+	draw(ScanProgress{Total: 4, Completed: 1, Percent: 25, ETA: 30 * time.Second})
+	if got := out.String(); !strings.HasPrefix(got, "\r[#######.......................]  25% 1/4 tests, ETA 30s") {
+		t.Errorf("Unexpected progress bar: %q", got)
+	}
+
+	out.Reset()
+	draw(ScanProgress{Total: 4, Completed: 4, Percent: 100})
+	if got := out.String(); !strings.Contains(got, "100% 4/4 tests") || !strings.HasSuffix(got, "\n") || strings.Contains(got, "ETA") {
+		t.Errorf("Expected the finished bar to end the line, got %q", got)
+	}
+}
+
+func TestRunTestsReportsProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var last ScanProgress
+	config := &Config{
+		APIEndpoints:    []APIEndpoint{{URL: server.URL, Method: "GET"}, {URL: server.URL + "/users", Method: "GET"}},
+		SecurityHeaders: SecurityHeadersConfig{Enabled: true},
+	}
+	config.progress = newScanProgress(func(p ScanProgress) { last = p })
+	runTests(config)
+
+	// Auth, HTTP Method, Injection and Security Headers for each endpoint
+	if got := config.progress.Progress(); got.Total != 8 || got.Completed != 8 || got.Percent != 100 {
+		t.Errorf("Expected 8 of 8 tests completed, got %+v", got)
+	}
+	if last.Completed != 8 || last.ETA != 0 {
+		t.Errorf("Expected a final update with nothing left, got %+v", last)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// findingIDLength is how many characters of the fingerprint the report shows as the finding ID
+const findingIDLength = 12
+
+// findingID returns the short ID of a finding, which the replay subcommand accepts
+func findingID(endpointKey, testName string) string {
+	return findingFingerprint(endpointKey, testName)[:findingIDLength]
+}
+
+// storedFinding is a failed test found in a results document
+type storedFinding struct {
+	Endpoint EndpointResult
+	Test     TestResult
+}
+
+// findFinding looks a finding up by its fingerprint or any unambiguous prefix of it
+func findFinding(results []EndpointResult, id string) (storedFinding, error) {
+	if len(id) < 8 {
+		return storedFinding{}, fmt.Errorf("finding ID %q is too short", id)
+	}
+	var matches []storedFinding
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if testResult.Failed() && strings.HasPrefix(findingFingerprint(result.key(), testResult.TestName), id) {
+				matches = append(matches, storedFinding{Endpoint: result, Test: testResult})
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return storedFinding{}, fmt.Errorf("no finding with ID %s", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return storedFinding{}, fmt.Errorf("finding ID %s is ambiguous; use more characters", id)
+	}
+}
+
+// replayFinding sends the recorded request of a finding again, with the
+// credentials of the configuration in place of the masked ones, and writes
+// the difference between the recorded and the live response. Requests that
+// a test sent with credentials of its own, or without any, are sent as they
+// were recorded.
+func replayFinding(w io.Writer, config *Config, finding storedFinding) error {
+	recorded := finding.Test.Request
+	if recorded == nil {
+		return fmt.Errorf("finding has no recorded request")
+	}
+
+	// Use the endpoint's settings, such as its Host header and session, when it is still configured
+	endpoint := APIEndpoint{URL: recorded.URL, Method: recorded.Method}
+	for _, e := range config.APIEndpoints {
+		if endpointKey(e.Name, e.URL) == finding.Endpoint.key() {
+			endpoint = e
+		}
+	}
+	client, _ := newScanClient(config, endpoint, nil)
+	if recorded.OwnCredentials {
+		for _, value := range recorded.Headers {
+			if value == maskedValue {
+				return fmt.Errorf("finding was sent with credentials of its own, which are masked; scan with -reveal-secrets to replay it")
+			}
+		}
+		client = withoutCredentials(client)
+	} else if err := newEndpointSession(client, config, endpoint).ensure(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(recorded.Method, recorded.URL, strings.NewReader(recorded.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	for name, value := range recorded.Headers {
+		if value != maskedValue {
+			req.Header.Set(name, value)
+		}
+	}
+	if !recorded.OwnCredentials {
+		if err := config.Auth.apply(req); err != nil {
+			return err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	live, err := ioutil.ReadAll(io.LimitReader(resp.Body, evidenceBodyLimit))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	fmt.Fprintf(w, "Finding %s: %s on %s\n", findingID(finding.Endpoint.key(), finding.Test.TestName), finding.Test.TestName, finding.Endpoint.key())
+	fmt.Fprintf(w, "Recorded result: %s\n", finding.Test.Message)
+	fmt.Fprintf(w, "Request: %s %s\n", recorded.Method, recorded.URL)
+	if recorded.Status == resp.StatusCode {
+		fmt.Fprintf(w, "Status: %d (unchanged)\n", resp.StatusCode)
+	} else {
+		fmt.Fprintf(w, "Status: %d -> %d\n", recorded.Status, resp.StatusCode)
+	}
+	if recorded.Response == string(live) {
+		fmt.Fprintln(w, "Response: unchanged")
+		return nil
+	}
+	fmt.Fprintln(w, "Response:")
+	for _, line := range lineDiff(strings.Split(recorded.Response, "\n"), strings.Split(string(live), "\n")) {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// lineDiff returns the lines of a and b prefixed with "- " when only in a,
+// "+ " when only in b and "  " when in both, using their longest common subsequence
+func lineDiff(a, b []string) []string {
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+	return lines
+}
+
+// replayCommand implements the replay subcommand
+func replayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	input := flags.String("results", "results.json", "results document written by -format json")
+	configFile := flags.String("config", "config.yaml", "configuration with the credentials to replay the request with")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return invalidConfig(fmt.Errorf("usage: replay [-results results.json] [-config config.yaml] <finding-id>"))
+	}
+
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		return fmt.Errorf("failed to read results: %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		return err
+	}
+
+	config := &Config{}
+	if _, err := os.Stat(*configFile); err == nil {
+		if config, err = loadConfig(*configFile); err != nil {
+			return invalidConfig(err)
+		}
+		// Refuse to replay requests from a document that was modified after the scan
+		if config.Signing.PublicKeyFile != "" {
+			key, err := loadVerifyKey(config.Signing.PublicKeyFile)
+			if err != nil {
+				return invalidConfig(err)
+			}
+			if err := verifyScanDocument(document, key); err != nil {
+				return err
+			}
+		}
+		secrets, err := resolveSecrets(config)
+		if err != nil {
+			return invalidConfig(err)
+		}
+		defer secrets.release()
+	}
+	finding, err := findFinding(document.Results, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return replayFinding(os.Stdout, config, finding)
+}
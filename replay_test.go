@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindFinding(t *testing.T) {
+	results := []EndpointResult{{Name: "users", URL: "http://example.com/users", Results: []TestResult{
+		{TestName: "Auth Test", Passed: true},
+		{TestName: "Injection Test", Message: "potential SQL injection"},
+	}}}
+
+	finding, err := findFinding(results, findingID("users", "Injection Test"))
+	if err != nil || finding.Test.TestName != "Injection Test" || finding.Endpoint.Name != "users" {
+		t.Errorf("Expected the injection finding, got %+v, %v", finding, err)
+	}
+	if _, err := findFinding(results, findingID("users", "Auth Test")); err == nil {
+		t.Error("Expected passed tests not to be findings")
+	}
+	if _, err := findFinding(results, "abc"); err == nil {
+		t.Error("Expected an error for a too short ID")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	lines := lineDiff([]string{"a", "b", "c"}, []string{"a", "c", "d"})
+	expected := []string{"  a", "- b", "  c", "+ d"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestReplayFinding(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("{\n\"error\": \"invalid id\"\n}"))
+	}))
+	defer server.Close()
+
+	finding := storedFinding{
+		Endpoint: EndpointResult{URL: server.URL},
+		Test: TestResult{TestName: "Injection Test", Message: "potential SQL injection", Request: &RecordedExchange{
+			Method:   "POST",
+			URL:      server.URL,
+			Headers:  map[string]string{"Authorization": maskedValue},
+			Body:     `{"id": "'"}`,
+			Status:   500,
+			Response: "{\n\"error\": \"SQL syntax\"\n}",
+		}},
+	}
+	config := &Config{Auth: Auth{Username: "user", Password: "pass"}}
+
+	var out bytes.Buffer
+	if err := replayFinding(&out, config, finding); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if authorization != "Basic dXNlcjpwYXNz" {
+		t.Errorf("Expected the configured credentials in place of the masked ones, got %q", authorization)
+	}
+	for _, line := range []string{"Status: 500 -> 200", "- \"error\": \"SQL syntax\"", "+ \"error\": \"invalid id\""} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected %q in the output, got %q", line, out.String())
+		}
+	}
+}
+
+func TestReplayFindingKeepsOwnCredentials(t *testing.T) {
+	var authorization []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{Auth: Auth{Type: "hmac", HMAC: &HMACConfig{Secret: "shared-secret"}}}
+	if err := config.Auth.prepare(); err != nil {
+		t.Fatalf("Expected valid hmac settings, got %v", err)
+	}
+	client, _ := newScanClient(config, APIEndpoint{}, nil)
+	testClient, recorder := recordExchanges(withoutCredentials(client))
+	resp, err := testClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	recorded := recorder.attach(TestResult{TestName: cloudIAMTestName}).Request
+	if recorded == nil || !recorded.OwnCredentials {
+		t.Fatalf("Expected the request to be recorded as sent without the scan's credentials, got %+v", recorded)
+	}
+
+	finding := storedFinding{Endpoint: EndpointResult{URL: server.URL}, Test: TestResult{TestName: cloudIAMTestName, Request: recorded}}
+	var out bytes.Buffer
+	if err := replayFinding(&out, config, finding); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(authorization) != 2 || authorization[1] != "" {
+		t.Errorf("Expected the replay to be sent without credentials, got %q", authorization)
+	}
+	if !strings.Contains(out.String(), "Status: 200 (unchanged)") {
+		t.Errorf("Expected an unchanged status, got %q", out.String())
+	}
+
+	recorded.Headers = map[string]string{"Authorization": maskedValue}
+	if err := replayFinding(&out, config, finding); err == nil {
+		t.Error("Expected an error for masked credentials of the finding's own")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// applyReproductions masks the credentials of the requests attached to
+// findings, unless reveal is set, and adds the curl and HTTPie commands that
+// send them again
+func applyReproductions(results []EndpointResult, reveal bool) {
+	for i := range results {
+		for j := range results[i].Results {
+			testResult := &results[i].Results[j]
+			if testResult.Request == nil {
+				continue
+			}
+			if !reveal {
+				masked := testResult.Request.masked()
+				testResult.Request = &masked
+			}
+			testResult.Curl = curlCommand(*testResult.Request)
+			testResult.HTTPie = httpieCommand(*testResult.Request)
+		}
+	}
+}
+
+// curlCommand returns a curl command line that sends the exchange's request
+func curlCommand(e RecordedExchange) string {
+	args := []string{"curl"}
+	switch {
+	case e.Method == "HEAD":
+		args = append(args, "--head")
+	case e.Method != "GET" || e.Body != "":
+		args = append(args, "-X", e.Method)
+	}
+	args = append(args, shellQuote(e.URL))
+	for _, name := range sortedHeaderNames(e.Headers) {
+		args = append(args, "-H", shellQuote(name+": "+e.Headers[name]))
+	}
+	if e.Body != "" {
+		args = append(args, "--data-raw", shellQuote(e.Body))
+	}
+	return strings.Join(args, " ")
+}
+
+// httpieCommand returns an HTTPie command line that sends the exchange's request
+func httpieCommand(e RecordedExchange) string {
+	args := []string{"http", e.Method, shellQuote(e.URL)}
+	for _, name := range sortedHeaderNames(e.Headers) {
+		args = append(args, shellQuote(name+":"+e.Headers[name]))
+	}
+	if e.Body != "" {
+		args = append(args, "--raw", shellQuote(e.Body))
+	}
+	return strings.Join(args, " ")
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestCurlCommand(t *testing.T) {
+	exchange := RecordedExchange{
+		Method:  "POST",
+		URL:     "https://api.example.com/users?id=1",
+		Headers: map[string]string{"Content-Type": "application/json", "Authorization": maskedValue},
+		Body:    `{"name": "O'Brien"}`,
+	}
+	expected := `curl -X POST 'https://api.example.com/users?id=1' -H 'Authorization: ****' -H 'Content-Type: application/json' --data-raw '{"name": "O'\''Brien"}'`
+	if command := curlCommand(exchange); command != expected {
+		t.Errorf("Expected %s, got %s", expected, command)
+	}
+
+	expected = `http POST 'https://api.example.com/users?id=1' 'Authorization:****' 'Content-Type:application/json' --raw '{"name": "O'\''Brien"}'`
+	if command := httpieCommand(exchange); command != expected {
+		t.Errorf("Expected %s, got %s", expected, command)
+	}
+
+	if command := curlCommand(RecordedExchange{Method: "HEAD", URL: "http://example.com"}); command != "curl --head 'http://example.com'" {
+		t.Errorf("Expected a HEAD request, got %s", command)
+	}
+	if command := curlCommand(RecordedExchange{Method: "GET", URL: "http://example.com"}); command != "curl 'http://example.com'" {
+		t.Errorf("Expected a plain GET request, got %s", command)
+	}
+}
+
+func TestApplyReproductions(t *testing.T) {
+	newResults := func() []EndpointResult {
+		return []EndpointResult{{URL: "http://example.com", Results: []TestResult{
+			{TestName: "Auth Test", Request: &RecordedExchange{Method: "GET", URL: "http://example.com", Headers: map[string]string{"Authorization": "Bearer abc"}}},
+			{TestName: "HTTP Method Test", Passed: true},
+		}}}
+	}
+
+	results := newResults()
+	applyReproductions(results, false)
+	if finding := results[0].Results[0]; finding.Request.Headers["Authorization"] != maskedValue || finding.Curl != "curl 'http://example.com' -H 'Authorization: ****'" {
+		t.Errorf("Expected the token to be masked, got %+v", finding)
+	}
+	if results[0].Results[1].Curl != "" {
+		t.Errorf("Expected no command for a test without a request, got %q", results[0].Results[1].Curl)
+	}
+
+	results = newResults()
+	applyReproductions(results, true)
+	if finding := results[0].Results[0]; finding.HTTPie != "http GET 'http://example.com' 'Authorization:Bearer abc'" {
+		t.Errorf("Expected the token to be revealed, got %+v", finding)
+	}
+}
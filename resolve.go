@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// resolveAddress maps a dial address using the configured overrides, which are
+// keyed by "host:port" or by "host" alone and point at an IP with an optional port
+func resolveAddress(resolve map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	target, ok := resolve[addr]
+	if !ok {
+		if target, ok = resolve[host]; !ok {
+			return addr
+		}
+	}
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(target, port)
+}
+
+// scanDialContext dials overridden hosts at their configured address, forces
+// the endpoint's IP family if one is set, and records the family of every
+// connection. Only the connection target changes: the URL, Host header and
+// TLS server name still use the original hostname, like curl --resolve.
+func scanDialContext(resolve map[string]string, family string, recorder *dialRecorder) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, familyNetwork(network, family), resolveAddress(resolve, addr))
+		if err != nil {
+			return nil, err
+		}
+		recorder.record(conn)
+		return conn, nil
+	}
+}
+
+// hostHeaderTransport overrides the Host header of requests sent to the endpoint's host
+type hostHeaderTransport struct {
+	base       http.RoundTripper
+	targetHost string
+	host       string
+}
+
+// newHostHeaderTransport returns base unchanged when the endpoint has no Host override
+func newHostHeaderTransport(base http.RoundTripper, endpoint APIEndpoint) http.RoundTripper {
+	u, err := url.Parse(endpoint.URL)
+	if endpoint.HostHeader == "" || err != nil {
+		return base
+	}
+	return &hostHeaderTransport{base: base, targetHost: u.Host, host: endpoint.HostHeader}
+}
+
+func (t *hostHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.targetHost {
+		req = req.Clone(req.Context())
+		req.Host = t.host
+	}
+	return t.base.RoundTrip(req)
+}
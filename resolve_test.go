@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveAddress(t *testing.T) {
+	resolve := map[string]string{
+		"api.example.com:443": "10.0.0.1",
+		"api.example.com":     "10.0.0.2",
+		"v6.example.com":      "::1",
+		"moved.example.com":   "10.0.0.3:8443",
+	}
+	tests := map[string]string{
+		"api.example.com:443":   "10.0.0.1:443",
+		"api.example.com:80":    "10.0.0.2:80",
+		"v6.example.com:443":    "[::1]:443",
+		"moved.example.com:443": "10.0.0.3:8443",
+		"other.example.com:443": "other.example.com:443",
+	}
+	for addr, expected := range tests {
+		if got := resolveAddress(resolve, addr); got != expected {
+			t.Errorf("Expected %s to resolve to %s, got %s", addr, expected, got)
+		}
+	}
+}
+
+func TestScanClientResolveAndHostHeader(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	endpoint := APIEndpoint{URL: "http://staging.internal.invalid:" + port + "/api", Method: "GET", HostHeader: "tenant.example.com"}
+	config := &Config{Resolve: map[string]string{"staging.internal.invalid": "127.0.0.1"}}
+
+	client, _ := newScanClient(config, endpoint, nil)
+	if err := performHTTPMethodTest(client, endpoint); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(hosts) != 1 || hosts[0] != "tenant.example.com" {
+		t.Errorf("Expected overridden Host header, got %v", hosts)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const ruleTimeout = 5 * time.Second
+
+// RuleConfig represents a custom response-analysis rule written in Lua.
+// The script sees the response as the globals status, body, headers and json
+// (nil unless the body is valid JSON), plus url and method, and flags the
+// endpoint by returning true, optionally followed by a message.
+type RuleConfig struct {
+	Name      string   `yaml:"name"`
+	Script    string   `yaml:"script"`
+	Severity  string   `yaml:"severity"`
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// RuleError is returned when a rule script fails to run, so that the rule is
+// recorded as errored rather than as a finding
+type RuleError struct{ message string }
+
+func (e RuleError) Error() string { return e.message }
+
+func (e RuleError) Is(target error) bool {
+	return target == ErrTestErrored
+}
+
+// applies reports whether a global rule should run against the given endpoint
+func (r RuleConfig) applies(endpointURL string) bool {
+	if len(r.Endpoints) == 0 {
+		return true
+	}
+	for _, u := range r.Endpoints {
+		if u == endpointURL {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointRules returns the global rules that apply to the endpoint followed by its own rules
+func endpointRules(config *Config, endpoint APIEndpoint) []RuleConfig {
+	var rules []RuleConfig
+	for _, rule := range config.Rules {
+		if rule.applies(endpoint.URL) {
+			rules = append(rules, rule)
+		}
+	}
+	return append(rules, endpoint.Rules...)
+}
+
+// ruleResponse is the response data exposed to rule scripts
+type ruleResponse struct {
+	URL     string
+	Method  string
+	Status  int
+	Headers http.Header
+	Body    []byte
+}
+
+// runRules sends the endpoint's request once and evaluates every rule against
+// the response, returning one test result per rule and the total score penalty
+func runRules(client *http.Client, endpoint APIEndpoint, rules []RuleConfig) ([]TestResult, int, error) {
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if vendor := detectWAF(resp, body); vendor != "" {
+		return nil, 0, WAFBlockedError{Vendor: vendor}
+	}
+	response := ruleResponse{URL: endpoint.URL, Method: endpoint.Method, Status: resp.StatusCode, Headers: resp.Header, Body: body}
+
+	var results []TestResult
+	penalty := 0
+	for _, rule := range rules {
+		flagged, message, err := evaluateRule(rule, response)
+		switch {
+		case err != nil:
+			results = append(results, newTestResult(rule.Name, RuleError{fmt.Sprintf("rule error: %v", err)}, 0))
+		case flagged:
+			if message == "" {
+				message = fmt.Sprintf("custom rule %q matched the response", rule.Name)
+			}
+			results = append(results, TestResult{TestName: rule.Name, Passed: false, Message: message, Severity: rule.Severity})
+			penalty += severityPenalty(rule.Severity)
+		default:
+			results = append(results, TestResult{TestName: rule.Name, Passed: true, Message: rule.Name + " Passed"})
+		}
+	}
+	return results, penalty, nil
+}
+
+// evaluateRule runs the rule script in a fresh interpreter with only the base,
+// string, table and math libraries available
+func evaluateRule(rule RuleConfig, response ruleResponse) (bool, string, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.StringLibName, lua.OpenString},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// Scripts must not read files or load other code
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ruleTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	headers := L.NewTable()
+	for name, values := range response.Headers {
+		headers.RawSetString(strings.ToLower(name), lua.LString(strings.Join(values, ", ")))
+	}
+	L.SetGlobal("url", lua.LString(response.URL))
+	L.SetGlobal("method", lua.LString(response.Method))
+	L.SetGlobal("status", lua.LNumber(response.Status))
+	L.SetGlobal("body", lua.LString(response.Body))
+	L.SetGlobal("headers", headers)
+
+	var decoded interface{}
+	if err := json.Unmarshal(response.Body, &decoded); err == nil {
+		L.SetGlobal("json", toLuaValue(L, decoded))
+	}
+
+	top := L.GetTop()
+	if err := L.DoString(rule.Script); err != nil {
+		return false, "", err
+	}
+	if L.GetTop() == top {
+		return false, "", nil
+	}
+	flagged := lua.LVAsBool(L.Get(top + 1))
+	message := ""
+	if L.GetTop() > top+1 {
+		message = L.Get(top + 2).String()
+	}
+	return flagged, message, nil
+}
+
+// toLuaValue converts a decoded JSON value into the equivalent Lua value
+func toLuaValue(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		table := L.NewTable()
+		for _, item := range v {
+			table.Append(toLuaValue(L, item))
+		}
+		return table
+	case map[string]interface{}:
+		table := L.NewTable()
+		for key, item := range v {
+			table.RawSetString(key, toLuaValue(L, item))
+		}
+		return table
+	default:
+		return lua.LNil
+	}
+}
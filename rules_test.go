@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "Express")
+		w.Write([]byte(`{"user": {"name": "admin"}, "debug": true}`))
+	}))
+	defer server.Close()
+
+	rules := []RuleConfig{
+		{Name: "Debug Flag", Severity: "high", Script: `if status == 200 and json.debug then return true, "debug mode enabled" end`},
+		{Name: "Powered By", Severity: "low", Script: `return headers["x-powered-by"] ~= nil`},
+		{Name: "Admin Leak", Severity: "medium", Script: `return json.user.name == "root"`},
+		{Name: "Broken", Script: `return nil + 1`},
+	}
+
+	results, penalty, err := runRules(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, rules)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	if results[0].Passed || results[0].Message != "debug mode enabled" {
+		t.Errorf("Expected debug rule to fail with message, got %+v", results[0])
+	}
+	if severity := resultSeverity(results[0]); severity != "high" {
+		t.Errorf("Expected the severity of the rule, got %q", severity)
+	}
+	if results[1].Passed {
+		t.Errorf("Expected powered-by rule to fail, got %+v", results[1])
+	}
+	if !results[2].Passed {
+		t.Errorf("Expected admin rule to pass, got %+v", results[2])
+	}
+	if results[3].Failed() || results[3].ErrorCode != "test_errored" || !strings.HasPrefix(results[3].Message, "rule error:") {
+		t.Errorf("Expected script error to be reported as errored, got %+v", results[3])
+	}
+	if penalty != 40 {
+		t.Errorf("Expected penalty 40, got %d", penalty)
+	}
+}
+
+func TestEvaluateRuleSandbox(t *testing.T) {
+	for _, script := range []string{`return os.exit(1)`, `return io.open("/etc/passwd")`, `return require("os")`} {
+		if _, _, err := evaluateRule(RuleConfig{Name: "escape", Script: script}, ruleResponse{}); err == nil {
+			t.Errorf("Expected script %q to fail in the sandbox", script)
+		}
+	}
+}
+
+func TestEndpointRules(t *testing.T) {
+	config := &Config{Rules: []RuleConfig{
+		{Name: "global"},
+		{Name: "scoped", Endpoints: []string{"http://example.com/other"}},
+	}}
+	endpoint := APIEndpoint{URL: "http://example.com/api", Rules: []RuleConfig{{Name: "local"}}}
+
+	rules := endpointRules(config, endpoint)
+	if len(rules) != 2 || rules[0].Name != "global" || rules[1].Name != "local" {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const safeModeSkipMessage = "skipped in safe mode: active checks are disabled"
+
+// dangerousMethods are methods an API should not advertise in its Allow header
+var dangerousMethods = []string{"TRACE", "TRACK", "CONNECT", "DEBUG"}
+
+// isSafeMethod reports whether requests with the method cannot modify server state
+func isSafeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// safeEndpoint returns the endpoint with non-safe methods replaced by HEAD and
+// the body dropped, so that checks cannot create or change data
+func safeEndpoint(endpoint APIEndpoint) APIEndpoint {
+	if isSafeMethod(endpoint.Method) {
+		return endpoint
+	}
+	endpoint.Method = http.MethodHead
+	endpoint.Body = ""
+	return endpoint
+}
+
+// performOptionsMethodTest enumerates the endpoint's methods with OPTIONS and
+// fails if any dangerous method is advertised
+func performOptionsMethodTest(client *http.Client, endpoint APIEndpoint) error {
+	req, err := http.NewRequest(http.MethodOptions, endpoint.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkWAF(resp); err != nil {
+		return err
+	}
+
+	allow := resp.Header.Get("Allow")
+	if allow == "" {
+		allow = resp.Header.Get("Access-Control-Allow-Methods")
+	}
+
+	var exposed []string
+	for _, method := range strings.Split(allow, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		for _, dangerous := range dangerousMethods {
+			if method == dangerous {
+				exposed = append(exposed, method)
+			}
+		}
+	}
+	if len(exposed) > 0 {
+		return HTTPMethodError{fmt.Sprintf("dangerous methods allowed: %s", strings.Join(exposed, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRunTestsSafeMode(t *testing.T) {
+	var mu sync.Mutex
+	methods := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.Method]++
+		mu.Unlock()
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", "GET, POST, TRACE")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIEndpoints:      []APIEndpoint{{URL: server.URL, Method: "POST", Body: `{"key": "%s"}`}},
+		InjectionPayloads: []string{"' OR '1'='1"},
+		Plugins:           []PluginConfig{{Name: "active-plugin", Command: "false"}},
+		SafeMode:          true,
+	}
+	results := runTests(config)
+
+	if methods["POST"] != 0 {
+		t.Errorf("Expected no POST requests in safe mode, got %d", methods["POST"])
+	}
+	// One HEAD request is the pre-check, the other the auth test
+	if methods["HEAD"] != 2 || methods["OPTIONS"] != 1 {
+		t.Errorf("Expected two HEAD and one OPTIONS request, got %v", methods)
+	}
+
+	byName := map[string]TestResult{}
+	for _, r := range results[0].Results {
+		byName[r.TestName] = r
+	}
+	if !byName["Injection Test"].Skipped || !byName["active-plugin"].Skipped {
+		t.Errorf("Expected active checks to be skipped, got %+v", results[0].Results)
+	}
+	if !byName["Auth Test"].Passed {
+		t.Errorf("Expected auth test to pass, got %+v", byName["Auth Test"])
+	}
+	if method := byName["HTTP Method Test"]; !method.Failed() || method.Message != "dangerous methods allowed: TRACE" {
+		t.Errorf("Expected TRACE to be flagged, got %+v", method)
+	}
+	if results[0].Score != 80 {
+		t.Errorf("Expected score 80, got %d", results[0].Score)
+	}
+}
+
+func TestSafeEndpoint(t *testing.T) {
+	endpoint := safeEndpoint(APIEndpoint{URL: "http://example.com", Method: "DELETE", Body: "x"})
+	if endpoint.Method != "HEAD" || endpoint.Body != "" {
+		t.Errorf("Expected HEAD without body, got %+v", endpoint)
+	}
+	if endpoint := safeEndpoint(APIEndpoint{Method: "GET", Body: "x"}); endpoint.Method != "GET" || endpoint.Body != "x" {
+		t.Errorf("Expected GET endpoint to be unchanged, got %+v", endpoint)
+	}
+}
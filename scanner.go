@@ -2,10 +2,16 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
-	"sort"
+	"net/http/cookiejar"
+	"net/url"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
@@ -13,28 +19,89 @@ import (
 
 // Config represents the overall configuration
 type Config struct {
-	APIEndpoints      []APIEndpoint `yaml:"api_endpoints"`
-	Auth              Auth          `yaml:"auth"`
-	InjectionPayloads []string      `yaml:"injection_payloads"`
+	APIEndpoints      []APIEndpoint            `yaml:"api_endpoints"`
+	Auth              Auth                     `yaml:"auth"`
+	InjectionPayloads []string                 `yaml:"injection_payloads"`
+	Ticketing         TicketingConfig          `yaml:"ticketing"`
+	Plugins           []PluginConfig           `yaml:"plugins"`
+	Rules             []RuleConfig             `yaml:"rules"`
+	WAF               WAFConfig                `yaml:"waf"`
+	SafeMode          bool                     `yaml:"safe_mode"`
+	Session           SessionConfig            `yaml:"session"`
+	Resolve           map[string]string        `yaml:"resolve"`
+	RequestProfile    RequestProfileConfig     `yaml:"request_profile"`
+	Limits            LimitsConfig             `yaml:"limits"`
+	Concurrency       ConcurrencyConfig        `yaml:"concurrency"`
+	FeedbackFile      string                   `yaml:"feedback_file"`
+	CVSS              map[string]string        `yaml:"cvss"`
+	Language          string                   `yaml:"language"`
+	ReportTemplates   []ReportTemplateConfig   `yaml:"report_templates"`
+	Compliance        ComplianceConfig         `yaml:"compliance"`
+	Secrets           SecretsConfig            `yaml:"secrets"`
+	DefaultCreds      DefaultCredentialsConfig `yaml:"default_credentials"`
+	SecurityHeaders   SecurityHeadersConfig    `yaml:"security_headers"`
+	Cookies           CookiesConfig            `yaml:"cookies"`
+	Fuzz              FuzzConfig               `yaml:"fuzz"`
+	Services          ServicesConfig           `yaml:"services"`
+	PortScan          PortScanConfig           `yaml:"port_scan"`
+	DNS               DNSConfig                `yaml:"dns"`
+	Certificates      CertificatesConfig       `yaml:"certificates"`
+	CloudIAM          CloudIAMConfig           `yaml:"cloud_iam"`
+	OIDC              OIDCConfig               `yaml:"oidc"`
+	GraphQL           GraphQLConfig            `yaml:"graphql"`
+	Webhooks          []WebhookConfig          `yaml:"webhooks"`
+	Pushgateway       PushgatewayConfig        `yaml:"pushgateway"`
+	Blackouts         []BlackoutWindow         `yaml:"blackouts"`
+	Environment       string                   `yaml:"environment"`
+	Metadata          map[string]string        `yaml:"metadata"`
+	Precheck          PrecheckConfig           `yaml:"precheck"`
+	ExpectedPosture   string                   `yaml:"expected_posture"`
+	Signing           SigningConfig            `yaml:"signing"`
+
+	feedback      *feedbackStore
+	revealSecrets bool
+	progress      *scanProgress
 }
 
 // APIEndpoint represents a single API endpoint configuration
 type APIEndpoint struct {
-	URL    string `yaml:"url"`
-	Method string `yaml:"method"`
-	Body   string `yaml:"body"`
+	Name           string                `yaml:"name"`
+	URL            string                `yaml:"url"`
+	Method         string                `yaml:"method"`
+	Body           string                `yaml:"body"`
+	Rules          []RuleConfig          `yaml:"rules"`
+	Session        *SessionConfig        `yaml:"session"`
+	HostHeader     string                `yaml:"host_header"`
+	IPFamily       string                `yaml:"ip_family"`
+	RequestProfile *RequestProfileConfig `yaml:"request_profile"`
+	Criticality    string                `yaml:"criticality"`
+	GraphQL        bool                  `yaml:"graphql"`
+	Blackouts      []BlackoutWindow      `yaml:"blackouts"`
+	Environments   map[string]string     `yaml:"environments"`
 }
 
 // Auth represents authentication credentials
 type Auth struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Type     string        `yaml:"type"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	OAuth2   *OAuth2Config `yaml:"oauth2"`
+	SigV4    *SigV4Config  `yaml:"sigv4"`
+	HMAC     *HMACConfig   `yaml:"hmac"`
+
+	// The state of the auth type is shared by all endpoints so that a scan fetches one token
+	tokens *oauth2TokenSource
+	signer *sigV4Signer
+	hmac   *hmacSigner
 }
 
 // Custom error types
 type AuthError struct{ message string }
 type HTTPMethodError struct{ message string }
-type InjectionError struct{ message string }
+type InjectionError struct {
+	message    string
+	confidence string
+}
 
 func (e AuthError) Error() string       { return e.message }
 func (e HTTPMethodError) Error() string { return e.message }
@@ -42,77 +109,586 @@ func (e InjectionError) Error() string  { return e.message }
 
 // EndpointResult represents the results of tests for a single endpoint
 type EndpointResult struct {
-	URL     string
-	Score   int
-	Results []TestResult
+	Name        string           `json:"name,omitempty"`
+	URL         string           `json:"url"`
+	Method      string           `json:"method,omitempty"`
+	Score       int              `json:"score"`
+	Results     []TestResult     `json:"results"`
+	Throttled   int              `json:"throttled,omitempty"`
+	IPFamily    string           `json:"ip_family,omitempty"`
+	Criticality string           `json:"criticality,omitempty"`
+	GraphQL     *SchemaDrift     `json:"graphql_schema,omitempty"`
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+	Limiter     *LimiterStats    `json:"limiter,omitempty"`
 }
 
 // TestResult represents the result of a single test
 type TestResult struct {
-	TestName string
-	Passed   bool
-	Message  string
+	TestName   string            `json:"test_name"`
+	Passed     bool              `json:"passed"`
+	Skipped    bool              `json:"skipped,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Message    string            `json:"message"`
+	Duration   time.Duration     `json:"duration"`
+	Confidence string            `json:"confidence,omitempty"`
+	Severity   string            `json:"severity,omitempty"`
+	CVSSVector string            `json:"cvss_vector,omitempty"`
+	CVSSScore  float64           `json:"cvss_score,omitempty"`
+	Request    *RecordedExchange `json:"request,omitempty"`
+	Curl       string            `json:"curl,omitempty"`
+	HTTPie     string            `json:"httpie,omitempty"`
+}
+
+// Failed reports whether the test ran to completion and found a problem
+func (t TestResult) Failed() bool {
+	return !t.Passed && !t.Skipped
+}
+
+// newTestResult converts the outcome of a test into a TestResult
+func newTestResult(testName string, err error, elapsed time.Duration) TestResult {
+	var wafErr WAFBlockedError
+	var throttledErr ThrottledError
+	var loginErr LoginError
+	var budgetErr TimeBudgetError
+	var lockoutErr LockoutError
+	var blackoutErr BlackoutError
+	var unreachableErr UnreachableError
+	var panicErr TestPanicError
+	var injectionErr InjectionError
+	switch {
+	case err == nil:
+		return TestResult{TestName: testName, Passed: true, Message: testName + " Passed", Duration: elapsed}
+	case errors.As(err, &wafErr), errors.As(err, &throttledErr), errors.As(err, &loginErr), errors.As(err, &budgetErr), errors.As(err, &lockoutErr), errors.As(err, &blackoutErr), errors.As(err, &unreachableErr), errors.As(err, &panicErr), errors.Is(err, ErrTestErrored):
+		return TestResult{TestName: testName, Skipped: true, Message: err.Error(), Duration: elapsed, ErrorCode: errorCode(err)}
+	case errors.As(err, &injectionErr) && injectionErr.confidence != "":
+		return TestResult{TestName: testName, Passed: false, Message: err.Error(), Duration: elapsed, Confidence: injectionErr.confidence}
+	default:
+		return TestResult{TestName: testName, Passed: false, Message: err.Error(), Duration: elapsed, Confidence: confidenceHigh}
+	}
+}
+
+// TestPanicError is returned when a test panicked, on a malformed response or a bug in a template or plugin
+type TestPanicError struct {
+	Value interface{}
+}
+
+func (e TestPanicError) Error() string {
+	return fmt.Sprintf("test panicked: %v", e.Value)
+}
+
+func (e TestPanicError) Is(target error) bool {
+	return target == ErrTestPanicked
+}
+
+// recoverTest records a panic in one of the endpoint's tests as an errored
+// test instead of crashing the whole scan. It must be deferred.
+func recoverTest(collector *endpointCollector, slot int, testName string) {
+	if r := recover(); r != nil {
+		log.Printf("%s panicked on %s: %v\n%s", testName, collector.result.URL, r, debug.Stack())
+		collector.record(slot, newTestResult(testName, TestPanicError{r}, 0))
+	}
+}
+
+// endpointCollector guards the results of an endpoint, which its tests record
+// concurrently. Each test records its results in the slot it was given when
+// it started, so that they are reported in the order the tests started in
+// rather than the order they finished in.
+type endpointCollector struct {
+	mu     sync.Mutex
+	result *EndpointResult
+	slots  [][]TestResult
+}
+
+// slot reserves the slot of a test about to start
+func (c *endpointCollector) slot() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots = append(c.slots, nil)
+	return len(c.slots) - 1
+}
+
+// record adds results to a test's slot
+func (c *endpointCollector) record(slot int, tests ...TestResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots[slot] = append(c.slots[slot], tests...)
+}
+
+// collect appends the recorded results to the endpoint's results, slot by
+// slot, once its tests have finished
+func (c *endpointCollector) collect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tests := range c.slots {
+		c.result.Results = append(c.result.Results, tests...)
+	}
+	c.slots = nil
+}
+
+// deduct subtracts a penalty from the endpoint's score
+func (c *endpointCollector) deduct(penalty int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result.Score -= penalty
+}
+
+// clientStats exposes what a scan client observed while testing an endpoint
+type clientStats struct {
+	throttle  *throttleTransport
+	dial      *dialRecorder
+	certs     *certificateRecorder
+	redirects *redirectRecorder
+}
+
+// newScanClient returns the HTTP client shared by the tests of a single endpoint,
+// with its own cookie jar, along with the statistics it collects. limiters is
+// nil when adaptive concurrency is disabled.
+func newScanClient(config *Config, endpoint APIEndpoint, limiters *hostLimiters) (*http.Client, clientStats) {
+	recorder := &dialRecorder{}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = scanDialContext(config.Resolve, endpoint.IPFamily, recorder)
+
+	certs := &certificateRecorder{base: base}
+	redirects := &redirectRecorder{base: certs}
+
+	var transport http.RoundTripper = newLimitTransport(redirects, config.Limits.MaxResponseBytes)
+	transport = newAuthTransport(transport, config.Auth)
+	transport = newLimiterTransport(transport, limiters)
+	// Windows were validated when the configuration was loaded
+	blackouts, _ := endpointBlackouts(config, endpoint)
+	transport = newBlackoutTransport(transport, blackouts)
+	if config.WAF.Evasion.RandomizeHeaders || config.WAF.Evasion.Pacing > 0 {
+		transport = newEvasionTransport(transport, config.WAF.Evasion)
+	}
+	transport = newProfileTransport(transport, requestProfile(config, endpoint))
+	transport = newHostHeaderTransport(transport, endpoint)
+	throttle := newThrottleTransport(transport)
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Timeout: 10 * time.Second, Transport: throttle, Jar: jar}, clientStats{throttle: throttle, dial: recorder, certs: certs, redirects: redirects}
 }
 
 // runTests runs all security tests concurrently and returns a slice of EndpointResult
 func runTests(config *Config) []EndpointResult {
-	var wg sync.WaitGroup
-	results := make([]EndpointResult, len(config.APIEndpoints))
+	wg := testGroup{progress: config.progress}
+	endpoints := expandIPFamilies(config.APIEndpoints, net.LookupIP)
+	results := make([]EndpointResult, len(endpoints))
+	stats := make([]clientStats, len(endpoints))
+	prechecks := make([]*endpointPrecheck, len(endpoints))
+	collectors := make([]*endpointCollector, len(endpoints))
+	var limiters *hostLimiters
+	if config.Concurrency.Adaptive {
+		limiters = newHostLimiters(config.Concurrency)
+	}
+	firstOnHost := serviceHosts(endpoints)
+	// The OpenID Connect provider is assessed once the endpoints' tests are done
+	if config.OIDC.Enabled {
+		config.progress.add(1)
+	}
 
-	for i, endpoint := range config.APIEndpoints {
-		wg.Add(3)
-		results[i] = EndpointResult{URL: endpoint.URL, Score: 100}
+	for i, endpoint := range endpoints {
+		wg.Add(3 + len(config.Plugins))
+		results[i] = EndpointResult{Name: endpoint.Name, URL: endpoint.URL, Method: endpoint.Method, Score: 100, Criticality: endpoint.Criticality}
+		collectors[i] = &endpointCollector{result: &results[i]}
+		client, clientStats := newScanClient(config, endpoint, limiters)
+		stats[i] = clientStats
+		session := newEndpointSession(client, config, endpoint)
+		precheck := newEndpointPrecheck(client, config.Precheck, endpoint)
+		prechecks[i] = precheck
+		// The scan client stops at blackout windows; guard covers the tests that do not use it
+		blackouts, _ := endpointBlackouts(config, endpoint)
+		guard := newBlackoutGuard(blackouts)
+		// ready checks that the endpoint is up and logs in before a test sends its requests
+		ready := func() error {
+			if err := precheck.check(); err != nil {
+				return err
+			}
+			return session.ensure()
+		}
 
-		go func(e APIEndpoint, i int) {
+		go func(e APIEndpoint, i, slot int) {
 			defer wg.Done()
-			if err := testAuth(e, config.Auth); err != nil {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "Auth Test", Passed: false, Message: err.Error()})
-				results[i].Score -= 30
-			} else {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "Auth Test", Passed: true, Message: "Auth Test Passed"})
+			defer recoverTest(collectors[i], slot, "Auth Test")
+			start := time.Now()
+			if config.SafeMode {
+				e = safeEndpoint(e)
 			}
-		}(endpoint, i)
+			testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+			err := ready()
+			if err == nil {
+				err = performAuthTest(testClient, e, config.Auth)
+			}
+			result := recorder.attach(newTestResult("Auth Test", err, time.Since(start)))
+			collectors[i].record(slot, result)
+			if result.Failed() {
+				collectors[i].deduct(30)
+			}
+		}(endpoint, i, collectors[i].slot())
 
-		go func(e APIEndpoint, i int) {
+		go func(e APIEndpoint, i, slot int) {
 			defer wg.Done()
-			if err := testHTTPMethod(e); err != nil {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "HTTP Method Test", Passed: false, Message: err.Error()})
-				results[i].Score -= 20
-			} else {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "HTTP Method Test", Passed: true, Message: "HTTP Method Test Passed"})
+			defer recoverTest(collectors[i], slot, "HTTP Method Test")
+			start := time.Now()
+			testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+			err := ready()
+			if err == nil {
+				if config.SafeMode {
+					err = performOptionsMethodTest(testClient, e)
+				} else {
+					err = performHTTPMethodTest(testClient, e)
+				}
 			}
-		}(endpoint, i)
+			result := recorder.attach(newTestResult("HTTP Method Test", err, time.Since(start)))
+			collectors[i].record(slot, result)
+			if result.Failed() {
+				collectors[i].deduct(20)
+			}
+		}(endpoint, i, collectors[i].slot())
 
-		go func(e APIEndpoint, i int) {
+		go func(e APIEndpoint, i, slot int) {
 			defer wg.Done()
-			if err := testInjection(e, config.InjectionPayloads); err != nil {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "Injection Test", Passed: false, Message: err.Error()})
-				results[i].Score -= 50
-			} else {
-				results[i].Results = append(results[i].Results, TestResult{TestName: "Injection Test", Passed: true, Message: "Injection Test Passed"})
+			defer recoverTest(collectors[i], slot, "Injection Test")
+			if config.SafeMode {
+				collectors[i].record(slot, TestResult{TestName: "Injection Test", Skipped: true, Message: safeModeSkipMessage})
+				return
+			}
+			start := time.Now()
+			testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+			err := ready()
+			if err == nil {
+				// Endpoints whose injection findings were false positives need direct evidence
+				requireDirectEvidence := config.feedback.falsePositive(endpointKey(e.Name, e.URL), "Injection Test")
+				err = testInjection(testClient, e, config.InjectionPayloads, requireDirectEvidence)
 			}
-		}(endpoint, i)
+			result := recorder.attach(newTestResult("Injection Test", err, time.Since(start)))
+			collectors[i].record(slot, result)
+			if result.Failed() {
+				collectors[i].deduct(50)
+			}
+		}(endpoint, i, collectors[i].slot())
+
+		if config.SecurityHeaders.Enabled {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, "Security Headers Test")
+				if config.SafeMode {
+					e = safeEndpoint(e)
+				}
+				start := time.Now()
+				testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+				err := ready()
+				if err == nil {
+					err = performSecurityHeadersTest(testClient, e, config.SecurityHeaders)
+				}
+				result := recorder.attach(newTestResult("Security Headers Test", err, time.Since(start)))
+				collectors[i].record(slot, result)
+				var headersErr SecurityHeadersError
+				if errors.As(err, &headersErr) {
+					collectors[i].deduct(headersErr.penalty)
+				} else if result.Failed() {
+					collectors[i].deduct(severityPenalty("low"))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.Cookies.Enabled {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, cookieTestName)
+				if config.SafeMode {
+					e = safeEndpoint(e)
+				}
+				start := time.Now()
+				var cookieResults []TestResult
+				var penalty int
+				testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+				err := ready()
+				if err == nil {
+					cookieResults, penalty, err = performCookieTest(testClient, e, config.Cookies)
+				}
+				elapsed := time.Since(start)
+				if err != nil {
+					collectors[i].record(slot, recorder.attach(newTestResult(cookieTestName, err, elapsed)))
+					return
+				}
+				for _, r := range cookieResults {
+					r.Duration = elapsed
+					collectors[i].record(slot, recorder.attach(r))
+				}
+				collectors[i].deduct(penalty)
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.Fuzz.Enabled {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, fuzzTestName)
+				if config.SafeMode {
+					collectors[i].record(slot, TestResult{TestName: fuzzTestName, Skipped: true, Message: safeModeSkipMessage})
+					return
+				}
+				if strings.TrimSpace(e.Body) == "" {
+					collectors[i].record(slot, TestResult{TestName: fuzzTestName, Skipped: true, Message: "no request body to mutate"})
+					return
+				}
+				start := time.Now()
+				testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+				err := ready()
+				if err == nil {
+					err = performFuzzTest(testClient, e, config.Fuzz)
+				}
+				result := recorder.attach(newTestResult(fuzzTestName, err, time.Since(start)))
+				collectors[i].record(slot, result)
+				if result.Failed() {
+					collectors[i].deduct(severityPenalty(testSeverity(fuzzTestName)))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.DefaultCreds.Enabled {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, "Default Credentials Test")
+				if config.SafeMode {
+					collectors[i].record(slot, TestResult{TestName: "Default Credentials Test", Skipped: true, Message: safeModeSkipMessage})
+					return
+				}
+				start := time.Now()
+				testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+				err := precheck.check()
+				if err == nil {
+					err = performDefaultCredentialsTest(testClient, e, config.DefaultCreds)
+				}
+				result := recorder.attach(newTestResult("Default Credentials Test", err, time.Since(start)))
+				collectors[i].record(slot, result)
+				if result.Failed() {
+					collectors[i].deduct(30)
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		// The services, ports and DNS records of a host are reported on its first endpoint
+		if config.Services.Enabled && firstOnHost[i] {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, exposedServicesTestName)
+				start := time.Now()
+				err := precheck.check()
+				if err == nil {
+					err = performExposedServicesTest(e, config.Services, config.Resolve, guard)
+				}
+				result := newTestResult(exposedServicesTestName, err, time.Since(start))
+				collectors[i].record(slot, result)
+				if result.Failed() {
+					collectors[i].deduct(severityPenalty(testSeverity(exposedServicesTestName)))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.PortScan.Enabled && firstOnHost[i] {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, openPortsTestName)
+				start := time.Now()
+				err := precheck.check()
+				if err == nil {
+					err = performPortScan(e, config.PortScan, config.Resolve, guard)
+				}
+				result := newTestResult(openPortsTestName, err, time.Since(start))
+				collectors[i].record(slot, result)
+				if result.Failed() {
+					collectors[i].deduct(severityPenalty(testSeverity(openPortsTestName)))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.DNS.Enabled && firstOnHost[i] {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, dnsTestName)
+				u, err := url.Parse(e.URL)
+				if err != nil || net.ParseIP(u.Hostname()) != nil {
+					collectors[i].record(slot, TestResult{TestName: dnsTestName, Skipped: true, Message: "the endpoint's host is not a domain name"})
+					return
+				}
+				start := time.Now()
+				err = performDNSSecurityTest(u.Hostname(), config.DNS, guard)
+				result := newTestResult(dnsTestName, err, time.Since(start))
+				collectors[i].record(slot, result)
+				var dnsErr DNSSecurityError
+				if errors.As(err, &dnsErr) {
+					collectors[i].deduct(dnsErr.penalty)
+				} else if result.Failed() {
+					collectors[i].deduct(severityPenalty("low"))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.CloudIAM.Enabled {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, cloudIAMTestName)
+				if config.SafeMode {
+					e = safeEndpoint(e)
+				}
+				start := time.Now()
+				testClient, recorder := recordExchanges(withTimeBudget(withoutCredentials(client), config.Limits.TestTimeout))
+				err := precheck.check()
+				if err == nil {
+					err = performCloudIAMTest(testClient, e, config.CloudIAM)
+				}
+				result := recorder.attach(newTestResult(cloudIAMTestName, err, time.Since(start)))
+				collectors[i].record(slot, result)
+				if result.Failed() {
+					collectors[i].deduct(severityPenalty(testSeverity(cloudIAMTestName)))
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		if config.GraphQL.Enabled && isGraphQLEndpoint(endpoint) {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, "GraphQL Schema Export")
+				err := ready()
+				if err == nil {
+					results[i].GraphQL, err = exportGraphQLSchema(withTimeBudget(client, config.Limits.TestTimeout), e, config.Auth, config.GraphQL)
+				}
+				if err != nil {
+					log.Printf("GraphQL schema export failed for %s: %v", e.URL, err)
+				}
+			}(endpoint, i, collectors[i].slot())
+		}
+
+		for _, plugin := range config.Plugins {
+			go func(e APIEndpoint, i, slot int, p PluginConfig) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, p.Name)
+				if config.SafeMode && !p.Safe {
+					collectors[i].record(slot, TestResult{TestName: p.Name, Skipped: true, Message: safeModeSkipMessage})
+					return
+				}
+				if err := precheck.check(); err != nil {
+					collectors[i].record(slot, newTestResult(p.Name, err, 0))
+					return
+				}
+				start := time.Now()
+				pluginResults, penalty, err := runPlugin(p, e, guard)
+				elapsed := time.Since(start)
+				if err != nil {
+					collectors[i].record(slot, newTestResult(p.Name, err, elapsed))
+					return
+				}
+				for _, r := range pluginResults {
+					r.Duration = elapsed
+					collectors[i].record(slot, r)
+				}
+				collectors[i].deduct(penalty)
+			}(endpoint, i, collectors[i].slot(), plugin)
+		}
+
+		if rules := endpointRules(config, endpoint); len(rules) > 0 {
+			wg.Add(1)
+			go func(e APIEndpoint, i, slot int, rules []RuleConfig) {
+				defer wg.Done()
+				defer recoverTest(collectors[i], slot, "Custom Rules")
+				if config.SafeMode {
+					e = safeEndpoint(e)
+				}
+				start := time.Now()
+				var ruleResults []TestResult
+				var penalty int
+				testClient, recorder := recordExchanges(withTimeBudget(client, config.Limits.TestTimeout))
+				err := ready()
+				if err == nil {
+					ruleResults, penalty, err = runRules(testClient, e, rules)
+				}
+				elapsed := time.Since(start)
+				if err != nil {
+					collectors[i].record(slot, recorder.attach(newTestResult("Custom Rules", err, elapsed)))
+					return
+				}
+				for _, r := range ruleResults {
+					r.Duration = elapsed
+					collectors[i].record(slot, recorder.attach(r))
+				}
+				collectors[i].deduct(penalty)
+			}(endpoint, i, collectors[i].slot(), rules)
+		}
 	}
 
 	wg.Wait()
+	for i := range results {
+		collectors[i].collect()
+		results[i].Throttled = stats[i].throttle.Events()
+		results[i].IPFamily = stats[i].dial.Family()
+		results[i].Certificate = stats[i].certs.Certificate()
+		if u, err := url.Parse(endpoints[i].URL); err == nil {
+			results[i].Limiter = limiters.stats(u.Host)
+		}
+
+		// Secrets in the target URL and in the redirects other tests followed
+		err := prechecks[i].check()
+		if err == nil {
+			err = checkURLSecrets(endpoints[i], stats[i].redirects.Redirects())
+		}
+		result := newTestResult(urlTokensTestName, err, 0)
+		results[i].Results = append(results[i].Results, result)
+		if result.Failed() {
+			results[i].Score -= severityPenalty(testSeverity(urlTokensTestName))
+		}
+	}
+	if config.OIDC.Enabled {
+		results = append(results, runOIDCAssessment(config, limiters))
+		config.progress.complete()
+	}
+	clampScores(results)
+	applyCVSS(results, config.CVSS)
+	applyFeedback(results, config.feedback)
+	applyReproductions(results, config.revealSecrets)
 	return results
 }
 
-func testAuth(endpoint APIEndpoint, auth Auth) error {
-	client := &http.Client{Timeout: 10 * time.Second}
+// clampScores keeps the scores of endpoints whose penalties add up to more
+// than 100 at 0
+func clampScores(results []EndpointResult) {
+	for i := range results {
+		if results[i].Score < 0 {
+			results[i].Score = 0
+		}
+	}
+}
+
+func performAuthTest(client *http.Client, endpoint APIEndpoint, auth Auth) error {
 	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 
-	req.SetBasicAuth(auth.Username, auth.Password)
+	if endpoint.HostHeader != "" {
+		req.Host = endpoint.HostHeader
+	}
+	if err := auth.apply(req); err != nil {
+		return err
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := checkWAF(resp); err != nil {
+		return err
+	}
+
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
 		return nil
@@ -125,8 +701,7 @@ func testAuth(endpoint APIEndpoint, auth Auth) error {
 	}
 }
 
-func testHTTPMethod(endpoint APIEndpoint) error {
-	client := &http.Client{Timeout: 10 * time.Second}
+func performHTTPMethodTest(client *http.Client, endpoint APIEndpoint) error {
 	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
@@ -134,10 +709,14 @@ func testHTTPMethod(endpoint APIEndpoint) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := checkWAF(resp); err != nil {
+		return err
+	}
+
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusUnauthorized:
 		return nil // Consider 401 as "expected" for protected endpoints
@@ -146,167 +725,257 @@ func testHTTPMethod(endpoint APIEndpoint) error {
 	}
 }
 
-func testInjection(endpoint APIEndpoint, payloads []string) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	// First, send a request with no payload to get a baseline response
-	baselineReq, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(endpoint.Body))
-	if err != nil {
-		return fmt.Errorf("failed to create baseline request: %v", err)
-	}
+// performInjectionTest tests a single payload
+func performInjectionTest(client *http.Client, endpoint APIEndpoint, payload string) error {
+	return testInjection(client, endpoint, []string{payload}, false)
+}
 
-	baselineResp, err := client.Do(baselineReq)
+// testInjection reports payloads that leak SQL errors or that behave like SQL
+// conditions. Payloads with an always-true comparison are sent alongside their
+// always-false counterpart, and the endpoint is reported when the two
+// consistently produce different responses. Other payloads are compared with a
+// harmless control value of the same length, which is only weak evidence and is
+// skipped with requireDirectEvidence or when the page changes on every request.
+func testInjection(client *http.Client, endpoint APIEndpoint, payloads []string, requireDirectEvidence bool) error {
+	// First, send a request with no payload twice to get a baseline response,
+	// which also tells WAF blocks apart from blocked payloads and pages that
+	// change on every request apart from pages that change with the input
+	baseline, err := sendProbe(client, endpoint, endpoint.Body)
 	if err != nil {
-		return fmt.Errorf("baseline request failed: %v", err)
+		return fmt.Errorf("baseline %w", err)
 	}
-	defer baselineResp.Body.Close()
-
-	baselineBody, err := ioutil.ReadAll(baselineResp.Body)
+	repeatedBaseline, err := sendProbe(client, endpoint, endpoint.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read baseline response body: %v", err)
+		return fmt.Errorf("baseline %w", err)
 	}
+	dynamic := responseSimilarity(baseline, repeatedBaseline) < similarityThreshold
 
 	for _, payload := range payloads {
-		reqBody := fmt.Sprintf(endpoint.Body, payload)
-		req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewBufferString(reqBody))
+		response, err := sendProbe(client, endpoint, fmt.Sprintf(endpoint.Body, payload))
 		if err != nil {
-			return fmt.Errorf("failed to create request: %v", err)
+			return err
+		}
+		if response.Body.SQLError != "" {
+			return InjectionError{fmt.Sprintf("potential SQL injection detected with payload: %s (database error %q)", payload, response.Body.SQLError), confidenceHigh}
 		}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("request failed: %v", err)
+		if falsePayload, ok := booleanCounterpart(payload); ok {
+			falseResponse, err := sendProbe(client, endpoint, fmt.Sprintf(endpoint.Body, falsePayload))
+			if err != nil {
+				return err
+			}
+			similarity := responseSimilarity(response, falseResponse, payload, falsePayload)
+			if similarity >= similarityThreshold {
+				continue
+			}
+
+			// Make sure the difference is not just a page that changes on every request
+			repeated, err := sendProbe(client, endpoint, fmt.Sprintf(endpoint.Body, payload))
+			if err != nil {
+				return err
+			}
+			if responseSimilarity(response, repeated, payload) >= similarityThreshold {
+				return InjectionError{fmt.Sprintf("potential SQL injection detected with payload: %s (true and false conditions differ, similarity %.2f)", payload, similarity), confidenceHigh}
+			}
+			continue
 		}
-		defer resp.Body.Close()
 
-		body, err := ioutil.ReadAll(resp.Body)
+		if requireDirectEvidence || dynamic {
+			continue
+		}
+		control := controlValue(payload)
+		controlResponse, err := sendProbe(client, endpoint, fmt.Sprintf(endpoint.Body, control))
 		if err != nil {
-			return fmt.Errorf("failed to read response body: %v", err)
+			return err
 		}
-
-		// Check for indicators of successful SQL injection
-		if indicatorsOfSQLInjection(string(body), string(baselineBody)) {
-			return InjectionError{fmt.Sprintf("potential SQL injection detected with payload: %s", payload)}
+		if similarity := responseSimilarity(response, controlResponse, payload, control); similarity < similarityThreshold {
+			return InjectionError{fmt.Sprintf("potential SQL injection detected with payload: %s (response differs from a harmless value, similarity %.2f)", payload, similarity), confidenceLow}
 		}
 	}
 	return nil
 }
 
-func indicatorsOfSQLInjection(responseBody, baselineBody string) bool {
-	// List of common SQL error messages
-	sqlErrorMessages := []string{
-		"SQL syntax",
-		"mysql_fetch_array",
-		"ORA-01756",
-		"SQLite3::SQLException",
-		"PostgreSQL ERROR",
-		"Incorrect syntax near",
-		"SQLSTATE[",
-		"JDBC Driver",
-		"Microsoft SQL Server",
-		"You have an error in your SQL syntax",
-	}
-
-	// Check if the response contains any SQL error messages
-	for _, errorMsg := range sqlErrorMessages {
-		if strings.Contains(responseBody, errorMsg) {
-			return true
+func generateDetailedReport(results []EndpointResult, metadata map[string]string, l localizer) {
+	fmt.Println("\n" + l.T("API Security Scan Detailed Report"))
+	fmt.Println("==================================")
+	if len(metadata) > 0 {
+		fmt.Println(l.T("Scan Metadata:"))
+		for _, key := range metadataKeys(metadata) {
+			fmt.Printf("- %s: %s\n", key, metadata[key])
 		}
 	}
 
-	// Check for significant differences in response length
-	if len(responseBody) > len(baselineBody)*2 || len(responseBody) < len(baselineBody)/2 {
-		return true
-	}
-
-	// Check for changes in response structure
-	if strings.Count(responseBody, "{") != strings.Count(baselineBody, "{") ||
-		strings.Count(responseBody, "}") != strings.Count(baselineBody, "}") {
-		return true
-	}
-
-	return false
-}
-
-func generateDetailedReport(results []EndpointResult) {
-	fmt.Println("\nAPI Security Scan Detailed Report")
-	fmt.Println("==================================")
-
 	for _, result := range results {
-		fmt.Printf("\nEndpoint: %s\n", result.URL)
-		fmt.Printf("Overall Score: %d/100\n", result.Score)
-		fmt.Println("Test Results:")
-
-		// Sort test results for consistent output
-		sort.Slice(result.Results, func(i, j int) bool {
-			return result.Results[i].TestName < result.Results[j].TestName
-		})
+		fmt.Println("\n" + l.T("Endpoint: %s", result.URL))
+		if result.IPFamily != "" {
+			fmt.Println(l.T("Address Family: %s", result.IPFamily))
+		}
+		if result.Certificate != nil {
+			fmt.Println(l.T("TLS Certificate: expires %s (%d days left)", result.Certificate.NotAfter.Format("2006-01-02"), result.Certificate.DaysLeft(time.Now())))
+		}
+		fmt.Println(l.T("Overall Score: %d/100", result.Score))
+		if result.Throttled > 0 {
+			fmt.Println(l.T("Throttled: %d time(s) by the target, results may be partial", result.Throttled))
+		}
+		fmt.Println(l.T("Test Results:"))
 
+		// Test results are listed in the order the tests started, the same in every run
 		for _, testResult := range result.Results {
 			status := "PASSED"
-			if !testResult.Passed {
+			if testResult.Skipped {
+				status = "SKIPPED"
+			} else if !testResult.Passed {
 				status = "FAILED"
 			}
-			fmt.Printf("- %s: %s\n", testResult.TestName, status)
-			fmt.Printf("  Details: %s\n", formatTestMessage(testResult.Message))
+			message := l.T(formatTestMessage(testResult.Message))
+			if testResult.Passed && testResult.Message == testResult.TestName+" Passed" {
+				message = l.T("%s Passed", l.T(testResult.TestName))
+			}
+			fmt.Printf("- %s: %s\n", l.T(testResult.TestName), l.T(status))
+			fmt.Println("  " + l.T("Details: %s", message))
+			if testResult.Failed() && testResult.Confidence != "" {
+				fmt.Println("  " + l.T("Confidence: %s", l.T(testResult.Confidence)))
+			}
+			if testResult.CVSSVector != "" {
+				fmt.Printf("  CVSS: %.1f (%s)\n", testResult.CVSSScore, testResult.CVSSVector)
+			}
+			if testResult.Failed() {
+				fmt.Println("  " + l.T("Finding ID: %s", findingID(result.key(), testResult.TestName)))
+			}
+			if testResult.Curl != "" {
+				fmt.Println("  " + l.T("Reproduce: %s", testResult.Curl))
+			}
+			fmt.Println("  " + l.T("Duration: %s", testResult.Duration.Round(time.Millisecond)))
 		}
 
-		fmt.Println("Risk Assessment:")
-		fmt.Println(generateRiskAssessment(result))
+		if result.GraphQL != nil {
+			fmt.Println(formatSchemaDrift(*result.GraphQL, l))
+		}
+		fmt.Println(l.T("Risk Assessment:"))
+		fmt.Println(generateRiskAssessment(result, l))
 		fmt.Println("------------------------")
 	}
 
-	fmt.Println("\nOverall Security Assessment:")
-	fmt.Println(generateOverallAssessment(results))
+	fmt.Println("\n" + l.T("Overall Security Assessment:"))
+	fmt.Println(generateOverallAssessment(results, l))
 }
 
 func formatTestMessage(message string) string {
 	return strings.TrimSpace(strings.TrimPrefix(message, "Test Failed for http://127.0.0.1:5000/post:"))
 }
 
-func generateRiskAssessment(result EndpointResult) string {
+func generateRiskAssessment(result EndpointResult, l localizer) string {
 	var risks []string
 	for _, testResult := range result.Results {
-		if !testResult.Passed {
-			switch testResult.TestName {
+		if testResult.Failed() {
+			switch baseTestName(testResult.TestName) {
 			case "Auth Test":
-				risks = append(risks, "- Authentication vulnerabilities may allow unauthorized access.")
+				risks = append(risks, l.T("- Authentication vulnerabilities may allow unauthorized access."))
 			case "HTTP Method Test":
-				risks = append(risks, "- Improper HTTP method handling could lead to security bypasses.")
+				risks = append(risks, l.T("- Improper HTTP method handling could lead to security bypasses."))
 			case "Injection Test":
-				risks = append(risks, "- SQL injection vulnerabilities pose a significant data breach risk.")
+				risks = append(risks, l.T("- SQL injection vulnerabilities pose a significant data breach risk."))
+			case "Security Headers Test":
+				risks = append(risks, l.T("- Missing security headers leave clients exposed to downgrade, clickjacking and content sniffing attacks."))
+			case "Default Credentials Test":
+				risks = append(risks, l.T("- Default credentials let anyone log in with a well-known password."))
+			case cookieTestName:
+				risks = append(risks, l.T("- Cookies without Secure, HttpOnly or SameSite can be stolen by scripts or sent on cross-site requests."))
+			case fuzzTestName:
+				risks = append(risks, l.T("- Unexpected input crashes the endpoint, which can leak implementation details or be used to take it down."))
+			case exposedServicesTestName:
+				risks = append(risks, l.T("- Brokers and datastores open to anonymous clients let anyone read or alter the data behind the API."))
+			case openPortsTestName:
+				risks = append(risks, l.T("- Management and database ports reachable from outside widen the attack surface beyond the API."))
+			case urlTokensTestName:
+				risks = append(risks, l.T("- Credentials in URLs leak through server logs, proxies, browser history and Referer headers."))
+			case sensitiveDataTestName:
+				risks = append(risks, l.T("- Responses expose secrets or personal data to anyone who can see the traffic."))
+			case dnsTestName:
+				risks = append(risks, l.T("- Weak DNS records allow subdomain takeover, certificate mis-issuance or spoofed answers for the API's domain."))
+			case oidcTestName:
+				risks = append(risks, l.T("- A weakly configured identity provider lets attackers register clients, skip PKCE or forge tokens for the APIs that trust it."))
+			case cloudIAMTestName:
+				risks = append(risks, l.T("- The API behind cloud IAM accepts requests without valid credentials for it, so the IAM layer does not protect it."))
 			}
 		}
 	}
 
 	if len(risks) == 0 {
-		return "No significant risks detected."
+		return l.T("No significant risks detected.")
 	}
 	return strings.Join(risks, "\n")
 }
 
-func generateOverallAssessment(results []EndpointResult) string {
+// resultSeverity returns the severity of a test result, which plugins and
+// custom rules set themselves, or else the severity of its test
+func resultSeverity(testResult TestResult) string {
+	if testResult.Severity != "" {
+		return testResult.Severity
+	}
+	return testSeverity(testResult.TestName)
+}
+
+// testSeverity returns the severity assigned to failures of the given test
+func testSeverity(testName string) string {
+	switch baseTestName(testName) {
+	case "Injection Test", "Default Credentials Test", cloudIAMTestName:
+		return "critical"
+	case "Auth Test", oidcTestName, exposedServicesTestName, sensitiveDataTestName, urlTokensTestName:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+// findingFingerprint returns a stable identifier for a test failing on an endpoint
+func findingFingerprint(url, testName string) string {
+	sum := sha256.Sum256([]byte(url + "|" + testName))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateOverallAssessment(results []EndpointResult, l localizer) string {
+	if len(results) == 0 {
+		return l.T("No endpoints were analyzed.")
+	}
 	totalScore := 0
 	criticalVulnerabilities := 0
+	throttledEndpoints := 0
 	for _, result := range results {
 		totalScore += result.Score
+		if result.Throttled > 0 {
+			throttledEndpoints++
+		}
 		for _, testResult := range result.Results {
-			if !testResult.Passed && testResult.TestName == "Injection Test" {
+			if testResult.Failed() && testResult.TestName == "Injection Test" {
 				criticalVulnerabilities++
 			}
 		}
 	}
 	averageScore := totalScore / len(results)
 
-	assessment := fmt.Sprintf("Average Security Score: %d/100\n", averageScore)
-	assessment += fmt.Sprintf("Critical Vulnerabilities Detected: %d\n\n", criticalVulnerabilities)
+	// Business criticality decides the posture when endpoints have one
+	postureScore := averageScore
+	assessment := l.T("Average Security Score: %d/100", averageScore) + "\n"
+	if hasCriticality(results) {
+		postureScore = weightedScore(results)
+		assessment += l.T("Criticality-Weighted Security Score: %d/100", postureScore) + "\n"
+	}
+	assessment += l.T("Critical Vulnerabilities Detected: %d", criticalVulnerabilities) + "\n"
+	if throttledEndpoints > 0 {
+		assessment += l.T("Endpoints Throttled by Target: %d (results are partial)", throttledEndpoints) + "\n"
+	}
+	if findings := prioritizeFindings(results); len(findings) > 0 && hasCriticality(results) {
+		assessment += "\n" + l.T("Prioritized Findings:") + "\n" + formatPrioritizedFindings(findings, l)
+	}
+	assessment += "\n"
 
-	if averageScore >= 90 {
-		assessment += "Overall security posture is strong, but continuous monitoring is recommended."
-	} else if averageScore >= 70 {
-		assessment += "Moderate security risks detected. Address identified vulnerabilities promptly."
+	if postureScore >= 90 {
+		assessment += l.T("Overall security posture is strong, but continuous monitoring is recommended.")
+	} else if postureScore >= 70 {
+		assessment += l.T("Moderate security risks detected. Address identified vulnerabilities promptly.")
 	} else {
-		assessment += "Significant security risks identified. Immediate action is required to improve API security."
+		assessment += l.T("Significant security risks identified. Immediate action is required to improve API security.")
 	}
 
 	return assessment
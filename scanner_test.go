@@ -1,11 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestPerformAuthTest(t *testing.T) {
@@ -86,3 +89,75 @@ func TestPerformInjectionTest(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 }
+
+func TestRecoverTest(t *testing.T) {
+	result := EndpointResult{URL: "https://api.example.com/users", Score: 100}
+	collector := &endpointCollector{result: &result}
+	slot := collector.slot()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer recoverTest(collector, slot, "Custom Rules")
+		var matches []string
+		_ = matches[1]
+	}()
+	wg.Wait()
+	collector.collect()
+
+	if len(result.Results) != 1 {
+		t.Fatalf("Expected the panic to be recorded, got %+v", result.Results)
+	}
+	recorded := result.Results[0]
+	if recorded.TestName != "Custom Rules" || !recorded.Skipped || recorded.ErrorCode != "test_panicked" || !strings.Contains(recorded.Message, "index out of range") {
+		t.Errorf("Expected an errored Custom Rules test, got %+v", recorded)
+	}
+	if code := scanExitCode([]EndpointResult{result}); code != exitTestPanicked {
+		t.Errorf("Expected exit code %d, got %d", exitTestPanicked, code)
+	}
+}
+
+func TestEndpointCollector(t *testing.T) {
+	result := EndpointResult{Score: 100}
+	collector := &endpointCollector{result: &result}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			// Later tests finish first
+			time.Sleep(time.Duration(50-slot) * time.Millisecond)
+			collector.record(slot, TestResult{TestName: fmt.Sprintf("Rule %d", slot)})
+			collector.deduct(2)
+		}(collector.slot())
+	}
+	wg.Wait()
+	collector.collect()
+
+	if len(result.Results) != 50 || result.Score != 0 {
+		t.Fatalf("Expected 50 results and a score of 0, got %d results and a score of %d", len(result.Results), result.Score)
+	}
+	for i, test := range result.Results {
+		if want := fmt.Sprintf("Rule %d", i); test.TestName != want {
+			t.Errorf("Expected %s at position %d, got %s", want, i, test.TestName)
+		}
+	}
+}
+
+func TestGenerateDetailedReportKeepsResultOrder(t *testing.T) {
+	results := []EndpointResult{{URL: "https://api.example.com/users", Score: 100, Results: []TestResult{
+		{TestName: "Injection Test", Passed: true},
+		{TestName: "Auth Test", Passed: true},
+		{TestName: "Custom Rule", Message: "second"},
+		{TestName: "Custom Rule", Message: "first"},
+	}}}
+	generateDetailedReport(results, nil, localizer{})
+
+	var order []string
+	for _, result := range results[0].Results {
+		order = append(order, result.TestName+" "+result.Message)
+	}
+	if want := "Injection Test ,Auth Test ,Custom Rule second,Custom Rule first"; strings.Join(order, ",") != want {
+		t.Errorf("Expected the report to leave the results in start order, got %v", order)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// resultSchemaVersion is the version of the JSON results document. Bump it
+// whenever a change would stop older documents from decoding correctly, and
+// add a migration from the previous version to resultMigrations.
+const resultSchemaVersion = 1
+
+// scanDocument is the versioned JSON results document written by -format json
+type scanDocument struct {
+	SchemaVersion int               `json:"schema_version"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Results       []EndpointResult  `json:"results"`
+	Signature     *resultSignature  `json:"signature,omitempty"`
+}
+
+// resultMigrations upgrades a document from the version at its index to the next version
+var resultMigrations = []func(data []byte) ([]byte, error){
+	// Version 0 was a bare array of endpoint results
+	func(data []byte) ([]byte, error) {
+		var results []json.RawMessage
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]interface{}{"schema_version": 1, "results": results})
+	},
+}
+
+func newScanDocument(results []EndpointResult, metadata map[string]string, generatedAt time.Time) scanDocument {
+	return scanDocument{SchemaVersion: resultSchemaVersion, GeneratedAt: generatedAt, Metadata: metadata, Results: results}
+}
+
+// resultDocumentVersion returns the schema version of an encoded document
+func resultDocumentVersion(data []byte) (int, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return 0, nil
+	}
+	var header struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, fmt.Errorf("invalid results document: %v", err)
+	}
+	if header.SchemaVersion == nil {
+		return 0, fmt.Errorf("invalid results document: missing schema_version")
+	}
+	return *header.SchemaVersion, nil
+}
+
+// readScanDocument decodes a results document of any known version, migrating
+// it to the current one
+func readScanDocument(data []byte) (scanDocument, error) {
+	version, err := resultDocumentVersion(data)
+	if err != nil {
+		return scanDocument{}, err
+	}
+	if version > resultSchemaVersion {
+		return scanDocument{}, fmt.Errorf("results document has schema version %d, but this scanner only reads up to version %d; upgrade the scanner", version, resultSchemaVersion)
+	}
+	if version < 0 {
+		return scanDocument{}, fmt.Errorf("invalid results document: schema version %d", version)
+	}
+
+	for ; version < resultSchemaVersion; version++ {
+		if data, err = resultMigrations[version](data); err != nil {
+			return scanDocument{}, fmt.Errorf("failed to migrate results from schema version %d: %v", version, err)
+		}
+	}
+
+	var document scanDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return scanDocument{}, fmt.Errorf("invalid results document: %v", err)
+	}
+	return document, nil
+}
+
+// migrateCommand implements the migrate subcommand, which rewrites a results
+// document in the current schema version
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	input := flags.String("in", "", "results document to migrate")
+	output := flags.String("out", "", "file to write the migrated document to (defaults to stdout)")
+	flags.Parse(args)
+
+	if *input == "" {
+		return invalidConfig(fmt.Errorf("-in is required"))
+	}
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		return fmt.Errorf("failed to read results: %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %v", err)
+	}
+	return writeOutput(*output, data)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadScanDocumentMigratesBareArray(t *testing.T) {
+	v0 := `[{"url": "http://example.com/a", "score": 50, "results": [{"test_name": "Injection Test", "passed": false, "message": "potential SQL injection", "duration": 1000000}]}]`
+
+	document, err := readScanDocument([]byte(v0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if document.SchemaVersion != resultSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", resultSchemaVersion, document.SchemaVersion)
+	}
+	if len(document.Results) != 1 || document.Results[0].Score != 50 || document.Results[0].Results[0].Duration != time.Millisecond {
+		t.Errorf("Unexpected results: %+v", document.Results)
+	}
+}
+
+func TestReadScanDocumentRoundTrip(t *testing.T) {
+	results := []EndpointResult{{URL: "http://example.com/a", Score: 100, Results: []TestResult{{TestName: "Auth Test", Passed: true}}}}
+	data, err := json.Marshal(newScanDocument(results, nil, time.Now()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	document, err := readScanDocument(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(document.Results) != 1 || document.Results[0].URL != "http://example.com/a" {
+		t.Errorf("Unexpected results: %+v", document.Results)
+	}
+}
+
+func TestReadScanDocumentRejectsNewerVersions(t *testing.T) {
+	_, err := readScanDocument([]byte(`{"schema_version": 99, "results": []}`))
+	if err == nil || !strings.Contains(err.Error(), "upgrade the scanner") {
+		t.Errorf("Expected an error asking to upgrade, got %v", err)
+	}
+
+	if _, err := readScanDocument([]byte(`{"results": []}`)); err == nil {
+		t.Errorf("Expected an error for a document without a schema version")
+	}
+}
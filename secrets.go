@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Prefixes of configuration values that are fetched from a secret store at runtime
+const (
+	vaultSecretPrefix = "vault:"
+	awsSecretPrefix   = "aws-sm:"
+)
+
+// SecretsConfig represents the secret stores configuration values can reference
+type SecretsConfig struct {
+	Vault *VaultConfig             `yaml:"vault"`
+	AWS   *AWSSecretsManagerConfig `yaml:"aws_secrets_manager"`
+}
+
+// VaultConfig represents a HashiCorp Vault server. The token is read from
+// VAULT_TOKEN, or obtained by an AppRole login with the secret ID in VAULT_SECRET_ID.
+type VaultConfig struct {
+	Address   string `yaml:"address"`
+	Namespace string `yaml:"namespace"`
+	RoleID    string `yaml:"role_id"`
+}
+
+// AWSSecretsManagerConfig represents the AWS Secrets Manager region and credentials
+type AWSSecretsManagerConfig struct {
+	Region  string `yaml:"region"`
+	Profile string `yaml:"profile"`
+}
+
+// secretResolver fetches referenced secrets and releases their leases when the scan is done
+type secretResolver struct {
+	config SecretsConfig
+	client *http.Client
+	getenv func(string) string
+
+	vaultAddress string
+	vaultToken   string
+	ownsToken    bool
+	leases       []string
+	aws          *sigV4Signer
+	awsEndpoint  string
+	cache        map[string]string
+}
+
+func newSecretResolver(config SecretsConfig) *secretResolver {
+	return &secretResolver{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		getenv: os.Getenv,
+		cache:  map[string]string{},
+	}
+}
+
+// resolveSecrets replaces every string in the configuration that references a
+// secret store with the secret's value, so that credentials never have to be
+// written to config.yaml
+func resolveSecrets(config *Config) (*secretResolver, error) {
+	resolver := newSecretResolver(config.Secrets)
+	if err := resolver.resolveValue(reflect.ValueOf(config).Elem()); err != nil {
+		resolver.release()
+		return nil, err
+	}
+	return resolver, nil
+}
+
+func (r *secretResolver) resolveValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return r.resolveValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			if err := r.resolveValue(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.resolveValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			value, err := r.resolve(v.MapIndex(key).String())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(key, reflect.ValueOf(value).Convert(v.Type().Elem()))
+		}
+	case reflect.String:
+		value, err := r.resolve(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(value)
+	}
+	return nil
+}
+
+// resolve returns the secret a reference points to, or value itself if it is not a reference
+func (r *secretResolver) resolve(value string) (string, error) {
+	var fetch func(string, string) (string, error)
+	var reference string
+	switch {
+	case strings.HasPrefix(value, vaultSecretPrefix):
+		fetch, reference = r.vaultSecret, strings.TrimPrefix(value, vaultSecretPrefix)
+	case strings.HasPrefix(value, awsSecretPrefix):
+		fetch, reference = r.awsSecret, strings.TrimPrefix(value, awsSecretPrefix)
+	default:
+		return value, nil
+	}
+	if secret, ok := r.cache[value]; ok {
+		return secret, nil
+	}
+
+	path, field := reference, ""
+	if i := strings.LastIndex(reference, "#"); i >= 0 {
+		path, field = reference[:i], reference[i+1:]
+	}
+	secret, err := fetch(path, field)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret %s: %v", value, err)
+	}
+	r.cache[value] = secret
+	return secret, nil
+}
+
+// vaultSecret reads a field of a Vault secret. KV version 2 responses, which
+// nest the fields under data.data, are unwrapped.
+func (r *secretResolver) vaultSecret(path, field string) (string, error) {
+	if field == "" {
+		return "", fmt.Errorf("vault references need a #field")
+	}
+	if err := r.vaultLogin(); err != nil {
+		return "", err
+	}
+
+	var response struct {
+		LeaseID       string                 `json:"lease_id"`
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := r.vaultRequest("GET", "/v1/"+strings.TrimPrefix(path, "/"), nil, &response); err != nil {
+		return "", err
+	}
+	if response.LeaseID != "" {
+		r.leases = append(r.leases, response.LeaseID)
+	}
+
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// vaultLogin uses VAULT_TOKEN or logs in with AppRole, whose short-lived token
+// is revoked when the scan is done
+func (r *secretResolver) vaultLogin() error {
+	if r.vaultToken != "" {
+		return nil
+	}
+	if r.config.Vault == nil {
+		r.config.Vault = &VaultConfig{}
+	}
+	r.vaultAddress = strings.TrimSuffix(r.config.Vault.Address, "/")
+	if r.vaultAddress == "" {
+		r.vaultAddress = strings.TrimSuffix(r.getenv("VAULT_ADDR"), "/")
+	}
+	if r.vaultAddress == "" {
+		return fmt.Errorf("no Vault address (set secrets.vault.address or VAULT_ADDR)")
+	}
+
+	if token := r.getenv("VAULT_TOKEN"); token != "" {
+		r.vaultToken = token
+		return nil
+	}
+	if r.config.Vault.RoleID == "" {
+		return fmt.Errorf("no Vault token (set VAULT_TOKEN or secrets.vault.role_id with VAULT_SECRET_ID)")
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	login := map[string]string{"role_id": r.config.Vault.RoleID, "secret_id": r.getenv("VAULT_SECRET_ID")}
+	if err := r.vaultRequest("POST", "/v1/auth/approle/login", login, &response); err != nil {
+		return fmt.Errorf("vault login failed: %v", err)
+	}
+	r.vaultToken = response.Auth.ClientToken
+	r.ownsToken = true
+	return nil
+}
+
+func (r *secretResolver) vaultRequest(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, r.vaultAddress+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if r.vaultToken != "" {
+		req.Header.Set("X-Vault-Token", r.vaultToken)
+	}
+	if r.config.Vault != nil && r.config.Vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.config.Vault.Namespace)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return nil
+}
+
+// awsSecret reads a secret from AWS Secrets Manager. With a #key, the secret
+// string is decoded as JSON and the key's value is returned.
+func (r *secretResolver) awsSecret(id, key string) (string, error) {
+	if r.aws == nil {
+		config := AWSSecretsManagerConfig{}
+		if r.config.AWS != nil {
+			config = *r.config.AWS
+		}
+		signer, err := newSigV4Signer(SigV4Config{Service: "secretsmanager", Region: config.Region, Profile: config.Profile})
+		if err != nil {
+			return "", err
+		}
+		r.aws = signer
+		if r.awsEndpoint == "" {
+			r.awsEndpoint = "https://secretsmanager." + signer.config.Region + ".amazonaws.com/"
+		}
+	}
+
+	payload, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequest("POST", r.awsEndpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if err := r.aws.sign(req); err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode response: %v", err)
+	}
+	if key == "" {
+		return response.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(response.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %v", err)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// release revokes the Vault leases and the AppRole token obtained for the scan
+func (r *secretResolver) release() []error {
+	if r == nil || r.vaultToken == "" {
+		return nil
+	}
+	var errs []error
+	for _, lease := range r.leases {
+		if err := r.vaultRequest("PUT", "/v1/sys/leases/revoke", map[string]string{"lease_id": lease}, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revoke lease %s: %v", lease, err))
+		}
+	}
+	r.leases = nil
+	if r.ownsToken {
+		if err := r.vaultRequest("POST", "/v1/auth/token/revoke-self", nil, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revoke vault token: %v", err))
+		}
+	}
+	r.vaultToken = ""
+	return errs
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveVaultSecrets(t *testing.T) {
+	var revoked []string
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role_id"] != "scanner-role" || login["secret_id"] != "secret-id" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"auth": {"client_token": "approle-token", "lease_duration": 300}}`)
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "approle-token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/scanner":
+			fmt.Fprint(w, `{"data": {"data": {"username": "admin", "password": "s3cret"}, "metadata": {"version": 3}}}`)
+		case "/v1/database/creds/scanner":
+			fmt.Fprint(w, `{"lease_id": "database/creds/scanner/abc", "lease_duration": 60, "data": {"password": "dynamic"}}`)
+		case "/v1/sys/leases/revoke":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			revoked = append(revoked, body["lease_id"])
+		case "/v1/auth/token/revoke-self":
+			revoked = append(revoked, "token")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	config := &Config{
+		Auth:      Auth{Username: "vault:secret/data/scanner#username", Password: "vault:secret/data/scanner#password"},
+		Ticketing: TicketingConfig{Jira: &JiraConfig{APIToken: "vault:database/creds/scanner#password", Project: "SEC"}},
+		Resolve:   map[string]string{"api.example.com": "127.0.0.1"},
+		Secrets:   SecretsConfig{Vault: &VaultConfig{Address: vault.URL, Namespace: "team", RoleID: "scanner-role"}},
+	}
+
+	resolver := newSecretResolver(config.Secrets)
+	resolver.getenv = func(key string) string { return map[string]string{"VAULT_SECRET_ID": "secret-id"}[key] }
+	if err := resolver.resolveValue(reflect.ValueOf(config).Elem()); err != nil {
+		t.Fatalf("Expected secrets to resolve, got %v", err)
+	}
+	if config.Auth.Username != "admin" || config.Auth.Password != "s3cret" || config.Ticketing.Jira.APIToken != "dynamic" {
+		t.Errorf("Expected secret references to be replaced, got %+v, %+v", config.Auth, config.Ticketing.Jira)
+	}
+	if config.Ticketing.Jira.Project != "SEC" || config.Resolve["api.example.com"] != "127.0.0.1" {
+		t.Errorf("Expected plain values to be left alone, got %+v", config)
+	}
+
+	if errs := resolver.release(); len(errs) != 0 {
+		t.Errorf("Expected leases to be released, got %v", errs)
+	}
+	if strings.Join(revoked, ",") != "database/creds/scanner/abc,token" {
+		t.Errorf("Expected the lease and the AppRole token to be revoked, got %v", revoked)
+	}
+	if errs := resolver.release(); len(errs) != 0 || len(revoked) != 2 {
+		t.Errorf("Expected a second release to do nothing, got %v and %v", errs, revoked)
+	}
+}
+
+func TestResolveSecretErrors(t *testing.T) {
+	for _, value := range []string{"vault:secret/data/scanner", "vault:secret/data/scanner#password"} {
+		resolver := newSecretResolver(SecretsConfig{})
+		resolver.getenv = func(string) string { return "" }
+		if _, err := resolver.resolve(value); err == nil {
+			t.Errorf("Expected %s to fail without a field or a Vault address", value)
+		}
+	}
+}
+
+func TestResolveAWSSecret(t *testing.T) {
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || !strings.Contains(r.Header.Get("Authorization"), "/secretsmanager/aws4_request") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch body["SecretId"] {
+		case "prod/scanner":
+			fmt.Fprint(w, `{"SecretString": "{\"password\": \"from-aws\"}"}`)
+		case "prod/token":
+			fmt.Fprint(w, `{"SecretString": "plain-token"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer sm.Close()
+
+	resolver := newSecretResolver(SecretsConfig{})
+	resolver.aws = testSigner(t, SigV4Config{Service: "secretsmanager", Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil)
+	resolver.awsEndpoint = sm.URL
+
+	if got, err := resolver.resolve("aws-sm:prod/scanner#password"); err != nil || got != "from-aws" {
+		t.Errorf("Expected the JSON key to be read, got %q, %v", got, err)
+	}
+	if got, err := resolver.resolve("aws-sm:prod/token"); err != nil || got != "plain-token" {
+		t.Errorf("Expected the secret string to be returned, got %q, %v", got, err)
+	}
+	if _, err := resolver.resolve("aws-sm:prod/scanner#missing"); err == nil {
+		t.Errorf("Expected an error for a missing key")
+	}
+}
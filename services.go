@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exposedServicesTestName = "Exposed Services Test"
+
+	defaultServiceTimeout = 3 * time.Second
+
+	// mqttClientID identifies the scanner's connection in the broker's logs
+	mqttClientID = "api-security-scanner"
+)
+
+// ServicesConfig represents the opt-in check for services commonly deployed
+// next to an API, such as message brokers and datastores, that accept
+// connections without authentication on the API's host
+type ServicesConfig struct {
+	Enabled  bool           `yaml:"enabled"`
+	Services []string       `yaml:"services"`
+	Ports    map[string]int `yaml:"ports"`
+	Timeout  time.Duration  `yaml:"timeout"`
+}
+
+// ExposedServiceError is returned when a service on the host accepts unauthenticated access
+type ExposedServiceError struct{ message string }
+
+func (e ExposedServiceError) Error() string { return e.message }
+
+// serviceProbe checks whether a service lets an anonymous client in, returning
+// what showed that it did. An error means the port is not that service.
+type serviceProbe func(conn net.Conn, host string) (exposed bool, evidence string, err error)
+
+// serviceProbes are the supported services, with their default ports
+var serviceProbes = map[string]struct {
+	port  int
+	probe serviceProbe
+}{
+	"amqp":          {5672, probeAMQP},
+	"elasticsearch": {9200, probeElasticsearch},
+	"mqtt":          {1883, probeMQTT},
+	"redis":         {6379, probeRedis},
+}
+
+// serviceExposure is a service found accepting unauthenticated access
+type serviceExposure struct {
+	Service  string
+	Port     int
+	Evidence string
+}
+
+func serviceNames(config ServicesConfig) ([]string, error) {
+	names := config.Services
+	if len(names) == 0 {
+		for name := range serviceProbes {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := serviceProbes[name]; !ok {
+			return nil, fmt.Errorf("unknown service %q", name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// validateServices checks the configured services and ports
+func validateServices(config ServicesConfig) error {
+	if _, err := serviceNames(config); err != nil {
+		return fmt.Errorf("services: %v", err)
+	}
+	for name, port := range config.Ports {
+		if _, ok := serviceProbes[name]; !ok {
+			return fmt.Errorf("services: port for unknown service %q", name)
+		}
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("services: invalid port %d for %s", port, name)
+		}
+	}
+	return nil
+}
+
+// performExposedServicesTest connects to each configured service on the
+// endpoint's host and fails if any of them accepts an anonymous client. Closed
+// ports and ports running something else are ignored. The test stops when a
+// blackout window opens.
+func performExposedServicesTest(endpoint APIEndpoint, config ServicesConfig, resolve map[string]string, guard *blackoutGuard) error {
+	names, err := serviceNames(config)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultServiceTimeout
+	}
+
+	var exposures []serviceExposure
+	for _, name := range names {
+		port := serviceProbes[name].port
+		if configured, ok := config.Ports[name]; ok {
+			port = configured
+		}
+		if err := guard.check(); err != nil {
+			return err
+		}
+		addr := resolveAddress(resolve, net.JoinHostPort(u.Hostname(), strconv.Itoa(port)))
+		conn, err := net.DialTimeout(familyNetwork("tcp", endpoint.IPFamily), addr, timeout)
+		if err != nil {
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(timeout))
+		exposed, evidence, err := serviceProbes[name].probe(conn, u.Hostname())
+		conn.Close()
+		if err == nil && exposed {
+			exposures = append(exposures, serviceExposure{Service: name, Port: port, Evidence: evidence})
+		}
+	}
+	if len(exposures) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(exposures))
+	for i, exposure := range exposures {
+		messages[i] = fmt.Sprintf("%s on port %d (%s)", exposure.Service, exposure.Port, exposure.Evidence)
+	}
+	return ExposedServiceError{"unauthenticated access to " + strings.Join(messages, ", ")}
+}
+
+// probeRedis sends PING, which Redis only answers with PONG when no password is required
+func probeRedis(conn net.Conn, host string) (bool, string, error) {
+	if _, err := io.WriteString(conn, "PING\r\n"); err != nil {
+		return false, "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false, "", err
+	}
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "+PONG":
+		return true, "PING answered without authentication", nil
+	case strings.HasPrefix(line, "-"):
+		return false, "", nil
+	default:
+		return false, "", fmt.Errorf("not a Redis server")
+	}
+}
+
+// probeMQTT connects without a username or password and reads the broker's CONNACK
+func probeMQTT(conn net.Conn, host string) (bool, string, error) {
+	// MQTT 3.1.1 CONNECT with a clean session and a 60 second keep-alive
+	variable := []byte{0x00, 0x04, 'M', 'Q', 'T', 'T', 0x04, 0x02, 0x00, 0x3c}
+	payload := append([]byte{0x00, byte(len(mqttClientID))}, mqttClientID...)
+	packet := append([]byte{0x10, byte(len(variable) + len(payload))}, variable...)
+	if _, err := conn.Write(append(packet, payload...)); err != nil {
+		return false, "", err
+	}
+
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		return false, "", err
+	}
+	if connack[0] != 0x20 || connack[1] != 0x02 {
+		return false, "", fmt.Errorf("not an MQTT broker")
+	}
+	if connack[3] != 0x00 {
+		return false, "", nil
+	}
+	conn.Write([]byte{0xe0, 0x00})
+	return true, "anonymous CONNECT accepted", nil
+}
+
+// probeAMQP sends the AMQP 0-9-1 protocol header and reads the SASL
+// mechanisms the broker offers in its Connection.Start
+func probeAMQP(conn net.Conn, host string) (bool, string, error) {
+	if _, err := io.WriteString(conn, "AMQP\x00\x00\x09\x01"); err != nil {
+		return false, "", err
+	}
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return false, "", err
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if header[0] != 1 || size > 1<<20 {
+		return false, "", fmt.Errorf("not an AMQP broker")
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(conn, frame); err != nil {
+		return false, "", err
+	}
+
+	// Connection.Start: class 10, method 10, version, server properties, mechanisms
+	if len(frame) < 10 || binary.BigEndian.Uint16(frame) != 10 || binary.BigEndian.Uint16(frame[2:]) != 10 {
+		return false, "", fmt.Errorf("not an AMQP Connection.Start")
+	}
+	offset := 6 + 4 + int(binary.BigEndian.Uint32(frame[6:]))
+	if offset+4 > len(frame) {
+		return false, "", fmt.Errorf("truncated AMQP Connection.Start")
+	}
+	end := offset + 4 + int(binary.BigEndian.Uint32(frame[offset:]))
+	if end > len(frame) {
+		return false, "", fmt.Errorf("truncated AMQP Connection.Start")
+	}
+	for _, mechanism := range strings.Fields(string(frame[offset+4 : end])) {
+		if mechanism == "ANONYMOUS" {
+			return true, "ANONYMOUS login offered", nil
+		}
+	}
+	return false, "", nil
+}
+
+// probeElasticsearch requests the cluster information, which needs credentials when security is enabled
+func probeElasticsearch(conn net.Conn, host string) (bool, string, error) {
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+	if err != nil {
+		return false, "", err
+	}
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		return false, "", err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", nil
+	}
+
+	var info struct {
+		ClusterName string `json:"cluster_name"`
+		Version     struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return false, "", err
+	}
+	if err := json.Unmarshal(data, &info); err != nil || info.ClusterName == "" {
+		return false, "", fmt.Errorf("not an Elasticsearch cluster")
+	}
+	return true, fmt.Sprintf("cluster %q, version %s, readable without authentication", info.ClusterName, info.Version.Number), nil
+}
+
+// serviceHosts returns, for each endpoint, whether it is the first one on its
+// host, so that each host's services are checked once per scan
+func serviceHosts(endpoints []APIEndpoint) []bool {
+	first := make([]bool, len(endpoints))
+	seen := map[string]bool{}
+	for i, endpoint := range endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			continue
+		}
+		key := u.Hostname() + "|" + endpoint.IPFamily
+		if !seen[key] {
+			seen[key] = true
+			first[i] = true
+		}
+	}
+	return first
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveService accepts connections on a local port and answers each with handler
+func serveService(t *testing.T, handler func(conn net.Conn)) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func redisServer(reply string) func(net.Conn) {
+	return func(conn net.Conn) {
+		bufio.NewReader(conn).ReadString('\n')
+		io.WriteString(conn, reply+"\r\n")
+	}
+}
+
+func mqttServer(returnCode byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		header := make([]byte, 2)
+		io.ReadFull(conn, header)
+		io.ReadFull(conn, make([]byte, header[1]))
+		conn.Write([]byte{0x20, 0x02, 0x00, returnCode})
+	}
+}
+
+func amqpServer(mechanisms string) func(net.Conn) {
+	return func(conn net.Conn) {
+		io.ReadFull(conn, make([]byte, 8))
+		payload := []byte{0, 10, 0, 10, 0, 9, 0, 0, 0, 0}
+		for _, s := range []string{mechanisms, "en_US"} {
+			payload = append(payload, 0, 0, 0, byte(len(s)))
+			payload = append(payload, s...)
+		}
+		frame := []byte{1, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(frame[3:], uint32(len(payload)))
+		conn.Write(append(append(frame, payload...), 0xce))
+	}
+}
+
+func elasticsearchServer(status string) func(net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || line == "\r\n" {
+				break
+			}
+		}
+		body := `{"cluster_name": "search", "version": {"number": "7.17.0"}}`
+		fmt.Fprintf(conn, "HTTP/1.1 %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", status, len(body), body)
+	}
+}
+
+func TestExposedServicesTestReportsAnonymousAccess(t *testing.T) {
+	config := ServicesConfig{Ports: map[string]int{
+		"redis":         serveService(t, redisServer("+PONG")),
+		"mqtt":          serveService(t, mqttServer(0)),
+		"amqp":          serveService(t, amqpServer("PLAIN ANONYMOUS")),
+		"elasticsearch": serveService(t, elasticsearchServer("200 OK")),
+	}}
+
+	err := performExposedServicesTest(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil)
+	var exposedErr ExposedServiceError
+	if !errors.As(err, &exposedErr) {
+		t.Fatalf("Expected an ExposedServiceError, got %v", err)
+	}
+	for _, expected := range []string{"redis", "mqtt", "amqp", `cluster "search"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected the error to report %s, got %q", expected, err.Error())
+		}
+	}
+}
+
+func TestExposedServicesTestPassesWithAuthentication(t *testing.T) {
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	config := ServicesConfig{Ports: map[string]int{
+		"redis":         serveService(t, redisServer("-NOAUTH Authentication required.")),
+		"mqtt":          serveService(t, mqttServer(5)),
+		"amqp":          serveService(t, amqpServer("PLAIN AMQPLAIN")),
+		"elasticsearch": closedPort,
+	}}
+	if err := performExposedServicesTest(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	config = ServicesConfig{Services: []string{"elasticsearch"}, Ports: map[string]int{"elasticsearch": serveService(t, elasticsearchServer("401 Unauthorized"))}}
+	if err := performExposedServicesTest(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil); err != nil {
+		t.Errorf("Expected no error for an Elasticsearch cluster requiring credentials, got %v", err)
+	}
+}
+
+func TestExposedServicesTestIgnoresOtherProtocols(t *testing.T) {
+	// An HTTP server on the Redis and MQTT ports is not an exposed service
+	port := serveService(t, elasticsearchServer("400 Bad Request"))
+	config := ServicesConfig{Services: []string{"mqtt", "redis"}, Ports: map[string]int{"redis": port, "mqtt": port}, Timeout: 200 * time.Millisecond}
+	if err := performExposedServicesTest(APIEndpoint{URL: "http://127.0.0.1/api"}, config, nil, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestServiceHosts(t *testing.T) {
+	endpoints := []APIEndpoint{
+		{URL: "http://api.example.com/a"},
+		{URL: "https://api.example.com:8443/b"},
+		{URL: "http://other.example.com/c"},
+		{URL: "http://api.example.com/a", IPFamily: ipFamilyIPv6},
+	}
+	first := serviceHosts(endpoints)
+	expected := []bool{true, false, true, true}
+	for i := range expected {
+		if first[i] != expected[i] {
+			t.Errorf("Expected endpoint %d first on its host to be %v, got %v", i, expected[i], first[i])
+		}
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	if err := validateServices(ServicesConfig{Services: []string{"redis"}, Ports: map[string]int{"redis": 6380}}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := validateServices(ServicesConfig{Services: []string{"memcached"}}); err == nil {
+		t.Errorf("Expected an error for an unknown service")
+	}
+	if err := validateServices(ServicesConfig{Ports: map[string]int{"redis": 70000}}); err == nil {
+		t.Errorf("Expected an error for an invalid port")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SessionConfig represents how a cookie session is established before testing
+type SessionConfig struct {
+	Login *LoginConfig `yaml:"login"`
+}
+
+// LoginConfig represents the login request whose cookies, and optionally an
+// extracted token, are reused by the tests
+type LoginConfig struct {
+	URL         string            `yaml:"url"`
+	Method      string            `yaml:"method"`
+	Body        string            `yaml:"body"`
+	Headers     map[string]string `yaml:"headers"`
+	Extract     string            `yaml:"extract"`
+	TokenHeader string            `yaml:"token_header"`
+	TokenFormat string            `yaml:"token_format"`
+}
+
+// LoginError is returned when the login step of a session fails
+type LoginError struct{ message string }
+
+func (e LoginError) Error() string { return e.message }
+
+func (e LoginError) Is(target error) bool { return target == ErrAuthFailed }
+
+// loginRequestKey marks the login request so that the session transport passes it through
+type loginRequestKey struct{}
+
+// endpointSession logs in once per endpoint so that the session cookies land
+// in the endpoint client's cookie jar before any test request is sent. When a
+// token is extracted from the login response, it is added to every request and
+// the session logs in again if a request is rejected with 401.
+type endpointSession struct {
+	client *http.Client
+	login  *LoginConfig
+	auth   Auth
+
+	once sync.Once
+	err  error
+
+	mu    sync.Mutex
+	token string
+}
+
+// newEndpointSession uses the endpoint's session settings, falling back to the global ones
+func newEndpointSession(client *http.Client, config *Config, endpoint APIEndpoint) *endpointSession {
+	session := config.Session
+	if endpoint.Session != nil {
+		session = *endpoint.Session
+	}
+	s := &endpointSession{client: client, login: session.Login, auth: config.Auth}
+	if client != nil && s.login != nil && s.login.Extract != "" {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client.Transport = &sessionTransport{base: base, session: s}
+	}
+	return s
+}
+
+// ensure performs the login step on first use and returns its outcome
+func (s *endpointSession) ensure() error {
+	if s == nil || s.login == nil {
+		return nil
+	}
+	s.once.Do(func() {
+		token, err := performLogin(s.client, *s.login, s.auth)
+		s.mu.Lock()
+		s.token, s.err = token, err
+		s.mu.Unlock()
+	})
+	return s.err
+}
+
+// currentToken returns the token extracted by the last successful login
+func (s *endpointSession) currentToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// relogin logs in again unless another request already replaced the stale token
+func (s *endpointSession) relogin(stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != stale {
+		return s.token, nil
+	}
+	token, err := performLogin(s.client, *s.login, s.auth)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	return token, nil
+}
+
+// headerValue formats the token for the token header
+func (s *endpointSession) headerValue(token string) (string, string) {
+	name := s.login.TokenHeader
+	if name == "" {
+		name = "Authorization"
+	}
+	format := s.login.TokenFormat
+	if format == "" {
+		format = "Bearer {token}"
+	}
+	return name, strings.Replace(format, "{token}", token, -1)
+}
+
+// sessionTransport adds the session token to requests that do not set the
+// token header themselves and logs in again when the token has expired
+type sessionTransport struct {
+	base    http.RoundTripper
+	session *endpointSession
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(loginRequestKey{}) != nil {
+		return t.base.RoundTrip(req)
+	}
+	token := t.session.currentToken()
+	name, _ := t.session.headerValue(token)
+	if token == "" || req.Header.Get(name) != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(t.withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+
+	// The token expired mid-scan: log in again and retry the request once
+	fresh, loginErr := t.session.relogin(token)
+	if loginErr != nil {
+		return resp, nil
+	}
+	retry := t.withToken(req, fresh)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+func (t *sessionTransport) withToken(req *http.Request, token string) *http.Request {
+	name, value := t.session.headerValue(token)
+	req = req.Clone(req.Context())
+	req.Header.Set(name, value)
+	return req
+}
+
+// performLogin sends the login request and returns the token extracted from
+// the response, if an extraction expression is configured. The {username} and
+// {password} fields of the body are filled in from the auth settings.
+func performLogin(client *http.Client, login LoginConfig, auth Auth) (string, error) {
+	method := login.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	body := strings.NewReplacer("{username}", auth.Username, "{password}", auth.Password).Replace(login.Body)
+	req, err := http.NewRequest(method, login.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return "", LoginError{fmt.Sprintf("login failed: %v", err)}
+	}
+	req = req.WithContext(context.WithValue(req.Context(), loginRequestKey{}, true))
+	for name, value := range login.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", LoginError{fmt.Sprintf("login failed: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return "", LoginError{fmt.Sprintf("login failed: unexpected status code: %d", resp.StatusCode)}
+	}
+	if login.Extract == "" {
+		return "", nil
+	}
+
+	token, err := extractToken(resp, login.Extract)
+	if err != nil {
+		return "", LoginError{fmt.Sprintf("login failed: %v", err)}
+	}
+	return token, nil
+}
+
+// extractToken evaluates an extraction expression against the login response:
+// json:<dot.path> reads a field of a JSON body (numbers index arrays),
+// header:<name> reads a response header and regex:<pattern> returns the first
+// capture group of the body
+func extractToken(resp *http.Response, expression string) (string, error) {
+	parts := strings.SplitN(expression, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("invalid extract expression %q", expression)
+	}
+	kind, arg := parts[0], parts[1]
+
+	if kind == "header" {
+		if value := resp.Header.Get(arg); value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("response has no %s header", arg)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	switch kind {
+	case "json":
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("response is not JSON: %v", err)
+		}
+		for _, key := range strings.Split(arg, ".") {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value = v[key]
+			case []interface{}:
+				index, err := strconv.Atoi(key)
+				if err != nil || index < 0 || index >= len(v) {
+					return "", fmt.Errorf("no %s in response", arg)
+				}
+				value = v[index]
+			default:
+				return "", fmt.Errorf("no %s in response", arg)
+			}
+		}
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				return v, nil
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		return "", fmt.Errorf("no %s in response", arg)
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid extract pattern: %v", err)
+		}
+		match := re.FindSubmatch(body)
+		if len(match) < 2 {
+			return "", fmt.Errorf("extract pattern did not match the response")
+		}
+		return string(match[1]), nil
+	default:
+		return "", fmt.Errorf("unknown extract type %q (supported: json, header, regex)", kind)
+	}
+}
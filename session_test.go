@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunTestsWithLoginSession(t *testing.T) {
+	var logins int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&logins, 1)
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := &Config{
+		APIEndpoints: []APIEndpoint{{URL: server.URL + "/api", Method: "GET"}},
+		Session: SessionConfig{Login: &LoginConfig{
+			URL:     server.URL + "/login",
+			Body:    `{"username": "admin", "password": "password"}`,
+			Headers: map[string]string{"Content-Type": "application/json"},
+		}},
+	}
+	results := runTests(config)
+
+	for _, r := range results[0].Results {
+		if r.TestName == "Auth Test" && !r.Passed {
+			t.Errorf("Expected auth test to pass with the session cookie, got %+v", r)
+		}
+	}
+	if atomic.LoadInt32(&logins) != 1 {
+		t.Errorf("Expected a single login per endpoint, got %d", logins)
+	}
+}
+
+func TestLoginFailureSkipsTests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client, _ := newScanClient(&Config{}, APIEndpoint{}, nil)
+	config := &Config{Session: SessionConfig{Login: &LoginConfig{URL: server.URL + "/login"}}}
+	session := newEndpointSession(client, config, APIEndpoint{URL: server.URL})
+
+	result := newTestResult("Auth Test", session.ensure(), 0)
+	if !result.Skipped || result.Message != "login failed: unexpected status code: 401" {
+		t.Errorf("Expected skipped result after failed login, got %+v", result)
+	}
+}
+
+func TestEndpointSessionOverride(t *testing.T) {
+	config := &Config{Session: SessionConfig{Login: &LoginConfig{URL: "http://example.com/global"}}}
+	endpoint := APIEndpoint{Session: &SessionConfig{}}
+
+	if session := newEndpointSession(nil, config, endpoint); session.login != nil {
+		t.Errorf("Expected endpoint session to disable the global login, got %+v", session.login)
+	}
+}
+
+func TestLoginTokenExtraction(t *testing.T) {
+	body := `{"data": {"tokens": [{"value": "tok-1"}]}, "ttl": 60}`
+	for expression, want := range map[string]string{
+		"json:data.tokens.0.value": "tok-1",
+		"json:ttl":                 "60",
+		"header:X-Auth-Token":      "header-token",
+		`regex:"value": "([^"]+)"`: "tok-1",
+	} {
+		resp := &http.Response{Header: http.Header{"X-Auth-Token": {"header-token"}}, Body: ioutil.NopCloser(strings.NewReader(body))}
+		if got, err := extractToken(resp, expression); err != nil || got != want {
+			t.Errorf("Expected %s to extract %q, got %q, %v", expression, want, got, err)
+		}
+	}
+	for _, expression := range []string{"json:data.missing", "json:data.tokens.5", "header:X-Missing", "regex:nomatch(x)", "xpath://token", "json"} {
+		resp := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}
+		if _, err := extractToken(resp, expression); err == nil {
+			t.Errorf("Expected %s to fail", expression)
+		}
+	}
+}
+
+func TestSessionTokenReloginOn401(t *testing.T) {
+	var logins, apiCalls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&logins, 1)
+		data, _ := ioutil.ReadAll(r.Body)
+		if string(data) != `{"user":"admin","pass":"password"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "token-%d"}`, n)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		// The first token expires after the first API call
+		n := atomic.AddInt32(&apiCalls, 1)
+		if got := r.Header.Get("X-Token"); got != "Token token-2" && (n > 1 || got != "Token token-1") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, string(data))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := &Config{
+		Auth: Auth{Username: "admin", Password: "password"},
+		Session: SessionConfig{Login: &LoginConfig{
+			URL:         server.URL + "/login",
+			Body:        `{"user":"{username}","pass":"{password}"}`,
+			Extract:     "json:access_token",
+			TokenHeader: "X-Token",
+			TokenFormat: "Token {token}",
+		}},
+	}
+	endpoint := APIEndpoint{URL: server.URL + "/api", Method: "POST", Body: "payload"}
+	client, _ := newScanClient(config, endpoint, nil)
+	session := newEndpointSession(client, config, endpoint)
+	if err := session.ensure(); err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(endpoint.URL, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("Expected request to succeed, got %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(data) != "payload" {
+			t.Errorf("Expected request %d to succeed with its body after re-login, got %d %q", i, resp.StatusCode, data)
+		}
+	}
+	if atomic.LoadInt32(&logins) != 2 {
+		t.Errorf("Expected a second login after the token expired, got %d logins", logins)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+const signatureAlgorithm = "ed25519"
+
+// SigningConfig represents the Ed25519 keys that sign and verify results
+// documents, in PEM-encoded PKCS #8 and PKIX form as written by openssl
+type SigningConfig struct {
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// resultSignature is the signature embedded in a signed results document
+type resultSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	Value     string `json:"value"`
+}
+
+// SignatureError is returned for documents whose signature does not verify
+type SignatureError struct{ message string }
+
+func (e SignatureError) Error() string { return e.message }
+
+func loadPEM(path, kind string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", kind, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s %s is not PEM-encoded", kind, path)
+	}
+	return block.Bytes, nil
+}
+
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := loadPEM(path, "signing key")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %v", err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not an Ed25519 key")
+	}
+	return private, nil
+}
+
+func loadVerifyKey(path string) (ed25519.PublicKey, error) {
+	der, err := loadPEM(path, "public key")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an Ed25519 key")
+	}
+	return public, nil
+}
+
+// keyID identifies a public key by the start of its SHA-256 digest
+func keyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signJSON signs the JSON encoding of a value, which must not include its own signature
+func signJSON(v interface{}, key ed25519.PrivateKey) (*resultSignature, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signed content: %v", err)
+	}
+	return &resultSignature{
+		Algorithm: signatureAlgorithm,
+		KeyID:     keyID(key.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, content)),
+	}, nil
+}
+
+// verifyJSON checks a signature made by signJSON over the value, which is
+// described as what in errors
+func verifyJSON(v interface{}, signature *resultSignature, key ed25519.PublicKey, what string) error {
+	if signature == nil {
+		return SignatureError{what + " is not signed"}
+	}
+	if signature.Algorithm != signatureAlgorithm {
+		return SignatureError{fmt.Sprintf("unsupported signature algorithm %q", signature.Algorithm)}
+	}
+	if signature.KeyID != keyID(key) {
+		return SignatureError{fmt.Sprintf("%s was signed with key %s, not %s", what, signature.KeyID, keyID(key))}
+	}
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return SignatureError{fmt.Sprintf("invalid signature: %v", err)}
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode signed content: %v", err)
+	}
+	if !ed25519.Verify(key, content, value) {
+		return SignatureError{fmt.Sprintf("signature does not match: the %s was modified after it was signed", what)}
+	}
+	return nil
+}
+
+// signScanDocument embeds an Ed25519 signature of the document
+func signScanDocument(document *scanDocument, key ed25519.PrivateKey) error {
+	unsigned := *document
+	unsigned.Signature = nil
+	signature, err := signJSON(unsigned, key)
+	if err != nil {
+		return err
+	}
+	document.Signature = signature
+	return nil
+}
+
+// verifyScanDocument checks that the document was signed with the key and not modified since
+func verifyScanDocument(document scanDocument, key ed25519.PublicKey) error {
+	signature := document.Signature
+	document.Signature = nil
+	return verifyJSON(document, signature, key, "results document")
+}
+
+// verifyCommand implements the verify subcommand, which checks the signature of a results document
+func verifyCommand(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	input := flags.String("in", "", "signed results document")
+	publicKey := flags.String("key", "", "PEM-encoded Ed25519 public key")
+	flags.Parse(args)
+
+	if *input == "" || *publicKey == "" {
+		return invalidConfig(fmt.Errorf("-in and -key are required"))
+	}
+	key, err := loadVerifyKey(*publicKey)
+	if err != nil {
+		return invalidConfig(err)
+	}
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		return fmt.Errorf("failed to read results: %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		return err
+	}
+	if err := verifyScanDocument(document, key); err != nil {
+		return err
+	}
+	fmt.Printf("%s: valid signature by key %s\n", *input, document.Signature.KeyID)
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func signingTestResults() []EndpointResult {
+	return []EndpointResult{{
+		URL:   "http://example.com/a",
+		Score: 70,
+		Results: []TestResult{
+			{TestName: "Auth Test", Passed: true, Duration: 1500},
+			{TestName: "Injection Test", Message: "potential SQL injection", CVSSScore: 9.8,
+				Request: &RecordedExchange{Method: "POST", URL: "http://example.com/a", Body: `{"q":"<'"}`, Status: 500}},
+		},
+	}}
+}
+
+func generateTestKey(t *testing.T, dir string) (ed25519.PrivateKey, string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+	ioutil.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600)
+	ioutil.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+	return private, privatePath, publicPath
+}
+
+func TestSignedExportVerifies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	_, privatePath, publicPath := generateTestKey(t, dir)
+
+	private, err := loadSigningKey(privatePath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	public, err := loadVerifyKey(publicPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := encodeExport("json", signingTestResults(), map[string]string{"build_id": "42"}, private)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if document.Signature == nil || document.Signature.KeyID != keyID(public) {
+		t.Fatalf("Expected a signature by key %s, got %+v", keyID(public), document.Signature)
+	}
+	if err := verifyScanDocument(document, public); err != nil {
+		t.Errorf("Expected the signature to verify, got %v", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	public := private.Public().(ed25519.PublicKey)
+
+	data, err := encodeExport("json", signingTestResults(), nil, private)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	document, err := readScanDocument(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	document.Results[0].Results[1].Passed = true
+
+	var sigErr SignatureError
+	if err := verifyScanDocument(document, public); !errors.As(err, &sigErr) {
+		t.Errorf("Expected a SignatureError for a modified document, got %v", err)
+	}
+}
+
+func TestVerifyRejectsOtherKeysAndUnsignedDocuments(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	document := newScanDocument(signingTestResults(), nil, time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC))
+	if err := verifyScanDocument(document, other); err == nil {
+		t.Errorf("Expected an error for an unsigned document")
+	}
+	if err := signScanDocument(&document, private); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := verifyScanDocument(document, other); err == nil {
+		t.Errorf("Expected an error for a document signed with another key")
+	}
+}
+
+func TestLoadSigningKeyRejectsInvalidKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	_, _, publicPath := generateTestKey(t, dir)
+
+	if _, err := loadSigningKey(publicPath); err == nil {
+		t.Errorf("Expected an error for a public key used as the signing key")
+	}
+	garbage := filepath.Join(dir, "garbage.pem")
+	ioutil.WriteFile(garbage, []byte("not a key"), 0600)
+	if _, err := loadVerifyKey(garbage); err == nil {
+		t.Errorf("Expected an error for a file that is not PEM-encoded")
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// SigV4Config represents the AWS Signature Version 4 settings. Credentials not
+// set here are read from the environment, IRSA or the shared credentials file.
+type SigV4Config struct {
+	Service         string `yaml:"service"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	Profile         string `yaml:"profile"`
+}
+
+// awsCredentials are the keys a request is signed with
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// sigV4Signer signs requests, caching temporary credentials until they expire
+type sigV4Signer struct {
+	config      SigV4Config
+	client      *http.Client
+	now         func() time.Time
+	getenv      func(string) string
+	stsEndpoint string
+
+	mu          sync.Mutex
+	credentials awsCredentials
+}
+
+func newSigV4Signer(config SigV4Config) (*sigV4Signer, error) {
+	if config.Service == "" {
+		return nil, fmt.Errorf("aws_sigv4 requires a service")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if config.Region == "" {
+		return nil, fmt.Errorf("aws_sigv4 requires a region (or AWS_REGION)")
+	}
+	if (config.AccessKeyID == "") != (config.SecretAccessKey == "") {
+		return nil, fmt.Errorf("aws_sigv4 requires both access_key_id and secret_access_key")
+	}
+	return &sigV4Signer{
+		config:      config,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		getenv:      os.Getenv,
+		stsEndpoint: "https://sts." + config.Region + ".amazonaws.com/",
+	}, nil
+}
+
+// sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to req
+func (s *sigV4Signer) sign(req *http.Request) error {
+	credentials, err := s.resolveCredentials()
+	if err != nil {
+		return err
+	}
+
+	payload := []byte{}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %v", err)
+		}
+		payload, err = ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %v", err)
+		}
+	}
+	payloadHash := sha256Hex(payload)
+
+	now := s.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+	if s.config.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := sigV4CanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req.URL, s.config.Service),
+		sigV4CanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, s.config.Region, s.config.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, now.Format(sigV4TimeFormat), scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	for _, part := range []string{s.config.Region, s.config.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sigV4Escape percent-encodes everything except the RFC 3986 unreserved characters
+func sigV4Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || keepSlash && c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sigV4CanonicalURI encodes the path once more on top of its URL escaping, as
+// all services but S3 expect
+func sigV4CanonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+	return sigV4Escape(path, true)
+}
+
+func sigV4CanonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key, false)+"="+sigV4Escape(value, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4CanonicalHeaders signs the host, the content type and all X-Amz headers
+func sigV4CanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(strings.Fields(strings.Join(values, ",")), " ")
+		}
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// resolveCredentials looks for credentials in the configuration, the
+// environment, IRSA (a web identity token and role) and the shared credentials file
+func (s *sigV4Signer) resolveCredentials() (awsCredentials, error) {
+	if s.config.AccessKeyID != "" {
+		return awsCredentials{AccessKeyID: s.config.AccessKeyID, SecretAccessKey: s.config.SecretAccessKey, SessionToken: s.config.SessionToken}, nil
+	}
+	if id, secret := s.getenv("AWS_ACCESS_KEY_ID"), s.getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: s.getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	if tokenFile, role := s.getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), s.getenv("AWS_ROLE_ARN"); tokenFile != "" && role != "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.credentials.AccessKeyID != "" && s.now().Add(tokenExpiryLeeway).Before(s.credentials.Expiration) {
+			return s.credentials, nil
+		}
+		credentials, err := s.assumeRoleWithWebIdentity(tokenFile, role)
+		if err != nil {
+			return awsCredentials{}, LoginError{fmt.Sprintf("failed to assume role %s: %v", role, err)}
+		}
+		s.credentials = credentials
+		return credentials, nil
+	}
+
+	profile := s.config.Profile
+	if profile == "" {
+		profile = s.getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	path := s.getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	credentials, err := readSharedCredentials(path, profile)
+	if err != nil {
+		return awsCredentials{}, LoginError{fmt.Sprintf("no AWS credentials found: %v", err)}
+	}
+	return credentials, nil
+}
+
+// readSharedCredentials reads a profile from an AWS shared credentials file
+func readSharedCredentials(path, profile string) (awsCredentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer f.Close()
+
+	var credentials awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "aws_access_key_id":
+			credentials.AccessKeyID = value
+		case "aws_secret_access_key":
+			credentials.SecretAccessKey = value
+		case "aws_session_token":
+			credentials.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, err
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	return credentials, nil
+}
+
+// assumeRoleWithWebIdentity exchanges the service account token mounted by IRSA
+// for temporary credentials. The STS call itself is not signed.
+func (s *sigV4Signer) assumeRoleWithWebIdentity(tokenFile, role string) (awsCredentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read web identity token: %v", err)
+	}
+	sessionName := s.getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "api-security-scanner"
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", role)
+	query.Set("RoleSessionName", sessionName)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	resp, err := s.client.Get(s.stsEndpoint + "?" + query.Encode())
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return awsCredentials{
+		AccessKeyID:     response.Credentials.AccessKeyID,
+		SecretAccessKey: response.Credentials.SecretAccessKey,
+		SessionToken:    response.Credentials.SessionToken,
+		Expiration:      response.Credentials.Expiration,
+	}, nil
+}
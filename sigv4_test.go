@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSigner(t *testing.T, config SigV4Config, env map[string]string) *sigV4Signer {
+	signer, err := newSigV4Signer(config)
+	if err != nil {
+		t.Fatalf("Expected valid sigv4 settings, got %v", err)
+	}
+	signer.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	signer.getenv = func(key string) string { return env[key] }
+	return signer
+}
+
+// TestSigV4Vanilla checks the get-vanilla case of the AWS Signature Version 4 test suite
+func TestSigV4Vanilla(t *testing.T) {
+	signer := testSigner(t, SigV4Config{
+		Service:         "service",
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := signer.sign(req); err != nil {
+		t.Fatalf("Expected the request to be signed, got %v", err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("Expected the X-Amz-Date header, got %q", got)
+	}
+}
+
+func TestSigV4CanonicalRequestParts(t *testing.T) {
+	u, _ := http.NewRequest("GET", "https://example.com/a b/c?b=2&a=1&a=0&sp=x y", nil)
+	if got := sigV4CanonicalURI(u.URL, "execute-api"); got != "/a%2520b/c" {
+		t.Errorf("Expected a double-encoded path, got %q", got)
+	}
+	if got := sigV4CanonicalURI(u.URL, "s3"); got != "/a%20b/c" {
+		t.Errorf("Expected S3 paths to be encoded once, got %q", got)
+	}
+	if got := sigV4CanonicalQuery(u.URL.Query()); got != "a=0&a=1&b=2&sp=x%20y" {
+		t.Errorf("Expected a sorted, percent-encoded query, got %q", got)
+	}
+}
+
+func TestSigV4SharedCredentialsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigv4")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(path, []byte("[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = x\n\n[scanner]\naws_access_key_id = SCANNER\naws_secret_access_key = y\naws_session_token = session\n"), 0600)
+
+	signer := testSigner(t, SigV4Config{Service: "execute-api", Region: "eu-west-1"}, map[string]string{
+		"AWS_SHARED_CREDENTIALS_FILE": path,
+		"AWS_PROFILE":                 "scanner",
+	})
+	req, _ := http.NewRequest("POST", "https://api.example.com/items", strings.NewReader(`{"a":1}`))
+	if err := signer.sign(req); err != nil {
+		t.Fatalf("Expected the request to be signed, got %v", err)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "Credential=SCANNER/20150830/eu-west-1/execute-api/aws4_request") {
+		t.Errorf("Expected the scanner profile to be used, got %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" || !strings.Contains(req.Header.Get("Authorization"), "x-amz-security-token") {
+		t.Errorf("Expected the session token to be sent and signed, got %v", req.Header)
+	}
+
+	signer = testSigner(t, SigV4Config{Service: "execute-api", Region: "eu-west-1", Profile: "missing"}, map[string]string{"AWS_SHARED_CREDENTIALS_FILE": path})
+	err = signer.sign(req)
+	if result := newTestResult("Auth Test", err, 0); !result.Skipped {
+		t.Errorf("Expected missing credentials to skip the test, got %+v", result)
+	}
+}
+
+func TestSigV4WebIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigv4")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("jwt-token\n"), 0600)
+
+	calls := 0
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		q := r.URL.Query()
+		if q.Get("Action") != "AssumeRoleWithWebIdentity" || q.Get("WebIdentityToken") != "jwt-token" || q.Get("RoleArn") != "arn:aws:iam::123456789012:role/scanner" {
+			t.Errorf("Expected an AssumeRoleWithWebIdentity call, got %v", q)
+		}
+		fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>temp-session</SessionToken>
+<Expiration>2015-08-30T13:36:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`)
+	}))
+	defer sts.Close()
+
+	signer := testSigner(t, SigV4Config{Service: "execute-api", Region: "us-east-1"}, map[string]string{
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile,
+		"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/scanner",
+	})
+	signer.stsEndpoint = sts.URL + "/"
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://api.example.com/", nil)
+		if err := signer.sign(req); err != nil {
+			t.Fatalf("Expected the request to be signed, got %v", err)
+		}
+		if !strings.Contains(req.Header.Get("Authorization"), "Credential=ASIATEMP/") || req.Header.Get("X-Amz-Security-Token") != "temp-session" {
+			t.Errorf("Expected the assumed role credentials to be used, got %v", req.Header)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the temporary credentials to be cached, got %d STS calls", calls)
+	}
+}
+
+func TestSigV4AuthTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	auth := Auth{Type: "aws_sigv4", SigV4: &SigV4Config{Service: "execute-api", Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}}
+	if err := auth.prepare(); err != nil {
+		t.Fatalf("Expected valid sigv4 settings, got %v", err)
+	}
+	if err := performAuthTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, auth); err != nil {
+		t.Errorf("Expected the signed request to be accepted, got %v", err)
+	}
+
+	if err := (&Auth{Type: "aws_sigv4", SigV4: &SigV4Config{Region: "us-east-1"}}).prepare(); err == nil {
+		t.Errorf("Expected an error when the service is missing")
+	}
+}
+
+func TestRunTestsSignsEveryRequest(t *testing.T) {
+	var mu sync.Mutex
+	var unsigned []string
+	probes := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			unsigned = append(unsigned, r.Method+" "+string(body))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case strings.Contains(string(body), "' OR '1'='1"):
+			probes["injection"]++
+		case r.Method == http.MethodPut:
+			probes["method"]++
+		}
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+
+	results := runTests(&Config{
+		APIEndpoints:      []APIEndpoint{{URL: server.URL + "/items", Method: http.MethodPut, Body: "q=%s"}},
+		InjectionPayloads: []string{"' OR '1'='1"},
+		Auth:              Auth{Type: "aws_sigv4", SigV4: &SigV4Config{Service: "execute-api", Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+	})
+
+	if len(unsigned) > 0 {
+		t.Errorf("Expected every request to be signed, got unsigned %v", unsigned)
+	}
+	if probes["injection"] == 0 || probes["method"] == 0 {
+		t.Errorf("Expected signed Injection and HTTP Method probes, got %v", probes)
+	}
+	for _, result := range results[0].Results {
+		if !result.Passed {
+			t.Errorf("Expected %s to pass with signed requests, got %s", result.TestName, result.Message)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// findingColumns are the columns of the CSV and XLSX exports
+var findingColumns = []string{"Endpoint", "Test", "Severity", "CVSS", "Confidence", "Details", "Remediation", "Fingerprint"}
+
+// findingRows returns one row per failed test, highest risk first, for spreadsheet exports
+func findingRows(results []EndpointResult) [][]string {
+	type row struct {
+		risk   float64
+		values []string
+	}
+	var rows []row
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if !testResult.Failed() {
+				continue
+			}
+			severity := resultSeverity(testResult)
+			cvss := ""
+			if testResult.CVSSVector != "" {
+				cvss = strconv.FormatFloat(testResult.CVSSScore, 'f', 1, 64)
+			}
+			rows = append(rows, row{
+				risk: findingRisk(severity, result.Criticality),
+				values: []string{result.URL, testResult.TestName, severity, cvss, testResult.Confidence,
+					testResult.Message, testRemediation(testResult.TestName), findingFingerprint(result.key(), testResult.TestName)},
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].risk > rows[j].risk })
+
+	values := make([][]string, len(rows))
+	for i, r := range rows {
+		values[i] = r.values
+	}
+	return values
+}
+
+// csvReport encodes the findings as CSV with a header row
+func csvReport(results []EndpointResult) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(findingColumns); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(findingRows(results)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxSeverityStyles maps severities to the cell styles of styles.xml, whose
+// fills color the severity column the way the text report ranks them
+var xlsxSeverityStyles = map[string]int{"critical": 2, "high": 3, "medium": 4, "low": 5}
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="6"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFC00000"/></patternFill></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFFF6600"/></patternFill></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFFFC000"/></patternFill></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FF92D050"/></patternFill></fill></fills>
+<borders count="1"><border/></borders>
+<cellStyleXfs count="1"><xf/></cellStyleXfs>
+<cellXfs count="6"><xf/><xf fontId="1" applyFont="1"/>` +
+	`<xf fillId="2" applyFill="1"/><xf fillId="3" applyFill="1"/><xf fillId="4" applyFill="1"/><xf fillId="5" applyFill="1"/></cellXfs>
+</styleSheet>`
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Findings" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// xlsxColumn returns the column letters of a zero-based column index
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSheet builds the worksheet, with a bold header row and the severity cells colored
+func xlsxSheet(rows [][]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range append([][]string{findingColumns}, rows...) {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range row {
+			style := 0
+			switch {
+			case r == 0:
+				style = 1
+			case c == 2:
+				style = xlsxSeverityStyles[value]
+			}
+			var escaped bytes.Buffer
+			xml.EscapeText(&escaped, []byte(value))
+			fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, xlsxColumn(c), r+1, style, escaped.String())
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxReport encodes the findings as an Excel workbook with a single sheet
+func xlsxReport(results []EndpointResult) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(findingRows(results))},
+	} {
+		f, err := w.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
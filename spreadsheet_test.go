@@ -0,0 +1,87 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var spreadsheetResults = []EndpointResult{{
+	URL: "http://api.example.com/users",
+	Results: []TestResult{
+		{TestName: "HTTP Method Test", Passed: false, Message: "method rejected"},
+		{TestName: "Auth Test", Passed: true},
+		{TestName: "Injection Test", Passed: false, Message: `error near "<script>"`, CVSSVector: "CVSS:3.1/AV:N", CVSSScore: 9.8},
+	},
+}}
+
+func TestCSVReport(t *testing.T) {
+	data, err := encodeExport("csv", spreadsheetResults, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(findingColumns, ",") {
+		t.Fatalf("Expected a header and two findings, got %v", records)
+	}
+	if records[1][1] != "Injection Test" || records[1][2] != "critical" || records[1][3] != "9.8" || records[1][5] != `error near "<script>"` {
+		t.Errorf("Expected the critical finding first, got %v", records[1])
+	}
+	if records[2][1] != "HTTP Method Test" || records[2][7] != findingFingerprint("http://api.example.com/users", "HTTP Method Test") {
+		t.Errorf("Unexpected second finding %v", records[2])
+	}
+}
+
+func TestXLSXReport(t *testing.T) {
+	data, err := encodeExport("xlsx", spreadsheetResults, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("Expected the workbook to contain %s", name)
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">Endpoint</t></is></c>`,
+		`<c r="C2" t="inlineStr" s="2"><is><t xml:space="preserve">critical</t></is></c>`,
+		`<c r="C3" t="inlineStr" s="4"><is><t xml:space="preserve">medium</t></is></c>`,
+		`error near &#34;&lt;script&gt;&#34;`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("Expected the sheet to contain %s, got %s", cell, sheet)
+		}
+	}
+}
+
+func TestXLSXExportNeedsOutput(t *testing.T) {
+	if err := writeExport("xlsx", "", spreadsheetResults, nil, nil); err == nil {
+		t.Errorf("Expected an error when writing the workbook to stdout")
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 7: "H", 25: "Z", 26: "AA", 27: "AB"} {
+		if got := xlsxColumn(i); got != expected {
+			t.Errorf("Expected column %d to be %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// ReportTemplateConfig represents a custom report rendered from a Go template
+type ReportTemplateConfig struct {
+	Template string `yaml:"template"`
+	Output   string `yaml:"output"`
+}
+
+// reportData is the findings model passed to custom report templates
+type reportData struct {
+	GeneratedAt    time.Time
+	Language       string
+	Metadata       map[string]string
+	Results        []EndpointResult
+	Findings       []prioritizedFinding
+	AverageScore   int
+	HasCriticality bool
+	WeightedScore  int
+}
+
+// reportTemplate is implemented by both text and HTML templates
+type reportTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// customReport is a parsed template and the file it renders to
+type customReport struct {
+	template reportTemplate
+	output   string
+}
+
+func newReportData(results []EndpointResult, language string, metadata map[string]string, now time.Time) reportData {
+	data := reportData{
+		GeneratedAt:    now.UTC(),
+		Language:       language,
+		Metadata:       metadata,
+		Results:        results,
+		Findings:       prioritizeFindings(results),
+		HasCriticality: hasCriticality(results),
+	}
+	if len(results) > 0 {
+		total := 0
+		for _, result := range results {
+			total += result.Score
+		}
+		data.AverageScore = total / len(results)
+		data.WeightedScore = weightedScore(results)
+	}
+	return data
+}
+
+// reportTemplateFuncs are the helpers available to custom report templates
+func reportTemplateFuncs(l localizer) map[string]interface{} {
+	return map[string]interface{}{
+		"t":           l.T,
+		"severity":    testSeverity,
+		"remediation": testRemediation,
+		"fingerprint": findingFingerprint,
+		"upper":       strings.ToUpper,
+		"join":        strings.Join,
+	}
+}
+
+// parseReportTemplate parses the template at path. Templates ending in .html or
+// .htm are parsed with html/template so that findings are escaped.
+func parseReportTemplate(path string, l localizer) (reportTemplate, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report template: %v", err)
+	}
+
+	name := filepath.Base(path)
+	var tmpl reportTemplate
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		tmpl, err = htmltemplate.New(name).Funcs(htmltemplate.FuncMap(reportTemplateFuncs(l))).Parse(string(source))
+	default:
+		tmpl, err = texttemplate.New(name).Funcs(texttemplate.FuncMap(reportTemplateFuncs(l))).Parse(string(source))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid report template %s: %v", path, err)
+	}
+	return tmpl, nil
+}
+
+// loadCustomReports parses every configured template so that mistakes are
+// reported before the scan starts
+func loadCustomReports(configs []ReportTemplateConfig, l localizer) ([]customReport, error) {
+	var reports []customReport
+	for _, config := range configs {
+		tmpl, err := parseReportTemplate(config.Template, l)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, customReport{template: tmpl, output: config.Output})
+	}
+	return reports, nil
+}
+
+// writeCustomReports renders each report and writes it to its output, or to stdout if none is set
+func writeCustomReports(reports []customReport, data reportData) error {
+	for _, report := range reports {
+		var buf bytes.Buffer
+		if err := report.template.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to render report: %v", err)
+		}
+		if err := writeOutput(report.output, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemplate(t *testing.T, name, source string) string {
+	dir, err := ioutil.TempDir("", "report-template")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	return path
+}
+
+func templateResults() []EndpointResult {
+	return []EndpointResult{{
+		URL:         "http://example.com/api",
+		Score:       50,
+		Criticality: "high",
+		Results: []TestResult{
+			{TestName: "Auth Test", Passed: true, Message: "Auth Test Passed"},
+			{TestName: "Injection Test", Passed: false, Message: "<script>alert(1)</script>"},
+		},
+	}}
+}
+
+func TestMarkdownReportTemplate(t *testing.T) {
+	path := writeTemplate(t, "audit.md", `# Audit ({{.Language}})
+Average: {{.AverageScore}}
+{{range .Findings}}- {{.TestName}} on {{.URL}} [{{upper .Severity}}] {{remediation .TestName}}
+{{end}}{{t "PASSED"}}`)
+
+	l, _ := newLocalizer("es")
+	tmpl, err := parseReportTemplate(path, l)
+	if err != nil {
+		t.Fatalf("Expected template to parse, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newReportData(templateResults(), "es", nil, time.Now())); err != nil {
+		t.Fatalf("Expected template to render, got %v", err)
+	}
+	report := buf.String()
+	for _, want := range []string{"# Audit (es)", "Average: 50", "- Injection Test on http://example.com/api [CRITICAL] Use parameterized queries", "APROBADO"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected report to contain %q, got %q", want, report)
+		}
+	}
+}
+
+func TestHTMLReportTemplateEscapesFindings(t *testing.T) {
+	path := writeTemplate(t, "audit.html", `{{range .Results}}{{range .Results}}<p>{{.Message}}</p>{{end}}{{end}}`)
+
+	reports, err := loadCustomReports([]ReportTemplateConfig{{Template: path, Output: filepath.Join(filepath.Dir(path), "out.html")}}, localizer{})
+	if err != nil {
+		t.Fatalf("Expected template to load, got %v", err)
+	}
+	if err := writeCustomReports(reports, newReportData(templateResults(), "", nil, time.Now())); err != nil {
+		t.Fatalf("Expected report to be written, got %v", err)
+	}
+
+	data, err := ioutil.ReadFile(reports[0].output)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if strings.Contains(string(data), "<script>") {
+		t.Errorf("Expected finding messages to be escaped, got %q", data)
+	}
+}
+
+func TestInvalidReportTemplate(t *testing.T) {
+	path := writeTemplate(t, "broken.md", "{{range .Results}")
+	if _, err := loadCustomReports([]ReportTemplateConfig{{Template: path}}, localizer{}); err == nil {
+		t.Errorf("Expected an error for an invalid template")
+	}
+	if _, err := loadCustomReports([]ReportTemplateConfig{{Template: path + ".missing"}}, localizer{}); err == nil {
+		t.Errorf("Expected an error for a missing template")
+	}
+}
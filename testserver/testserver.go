@@ -0,0 +1,156 @@
+// Package testserver provides an intentionally vulnerable API with known,
+// documented flaws. It is used by the scanner's own tests and benchmarks, and
+// lets users check that a deployment detects what it claims to detect.
+//
+// Never expose it on a network you do not control.
+package testserver
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Credentials accepted by the endpoints that require basic authentication
+const (
+	Username = "admin"
+	Password = "password"
+)
+
+// Flaws seeded into the endpoints
+const (
+	FlawSQLInjection   = "sqli"
+	FlawXSS            = "xss"
+	FlawIDOR           = "idor"
+	FlawMissingHeaders = "missing-headers"
+	FlawBrokenAuth     = "broken-auth"
+	FlawMethodRejected = "method-rejected"
+)
+
+// Endpoint is an endpoint of the test server and the flaws seeded into it
+type Endpoint struct {
+	Path    string // path including any query string
+	Method  string
+	Body    string // request body, with %s marking the injection point
+	Flaws   []string
+	handler http.HandlerFunc
+}
+
+// Endpoints returns the endpoints served by the test server
+func Endpoints() []Endpoint {
+	return []Endpoint{
+		{Path: "/secure", Method: "GET", handler: secure},
+		{Path: "/sqli", Method: "POST", Body: `{"id": "%s"}`, Flaws: []string{FlawSQLInjection}, handler: sqlInjection},
+		{Path: "/xss?q=scanner", Method: "GET", Flaws: []string{FlawXSS}, handler: reflectedXSS},
+		{Path: "/users/2", Method: "GET", Flaws: []string{FlawIDOR}, handler: insecureDirectObjectReference},
+		{Path: "/no-headers", Method: "GET", Flaws: []string{FlawMissingHeaders}, handler: missingHeaders},
+		{Path: "/broken-auth", Method: "GET", Flaws: []string{FlawBrokenAuth}, handler: brokenAuth},
+		{Path: "/method", Method: "PUT", Body: `{"name": "widget"}`, Flaws: []string{FlawMethodRejected}, handler: methodRejected},
+	}
+}
+
+// Handler returns the handler serving all the endpoints
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, endpoint := range Endpoints() {
+		path := endpoint.Path
+		if i := strings.Index(path, "?"); i >= 0 {
+			path = path[:i]
+		}
+		handler := endpoint.handler
+		if !hasFlaw(endpoint, FlawMissingHeaders) {
+			handler = withSecurityHeaders(handler)
+		}
+		mux.Handle(path, handler)
+	}
+	return mux
+}
+
+// New starts a test server on a local port; callers must Close it
+func New() *httptest.Server {
+	return httptest.NewServer(Handler())
+}
+
+func hasFlaw(endpoint Endpoint, flaw string) bool {
+	for _, f := range endpoint.Flaws {
+		if f == flaw {
+			return true
+		}
+	}
+	return false
+}
+
+func withSecurityHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Permissions-Policy", "geolocation=(), camera=(), microphone=()")
+		next(w, r)
+	}
+}
+
+func authenticated(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	return ok && username == Username && password == Password
+}
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
+
+func secure(w http.ResponseWriter, r *http.Request) {
+	if !authenticated(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, `{"status": "ok"}`)
+}
+
+// sqlInjection builds its query by string concatenation and leaks database errors
+func sqlInjection(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if strings.Contains(string(body), "'") {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "You have an error in your SQL syntax near '%s'", body)
+		return
+	}
+	writeJSON(w, `{"id": 1, "name": "widget"}`)
+}
+
+// reflectedXSS echoes the q parameter into HTML without escaping it
+func reflectedXSS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, "<html><body>Results for %s</body></html>", r.URL.Query().Get("q"))
+}
+
+// insecureDirectObjectReference returns any user's record without checking
+// that it belongs to the caller
+func insecureDirectObjectReference(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/users/")
+	writeJSON(w, fmt.Sprintf(`{"id": "%s", "email": "user%s@example.com"}`, html.EscapeString(id), html.EscapeString(id)))
+}
+
+func missingHeaders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, `{"status": "ok"}`)
+}
+
+// brokenAuth rejects even valid credentials
+func brokenAuth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// methodRejected only accepts its documented method from authenticated callers
+func methodRejected(w http.ResponseWriter, r *http.Request) {
+	if !authenticated(r) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, `{"status": "ok"}`)
+}
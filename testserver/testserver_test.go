@@ -0,0 +1,69 @@
+package testserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEndpointsServeTheirFlaws(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/sqli", "application/json", strings.NewReader(`{"id": "' OR '1'='1"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(body), "SQL syntax") {
+		t.Errorf("Expected a leaked SQL error, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(server.URL + "/xss?q=<script>alert(1)</script>")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "<script>alert(1)</script>") {
+		t.Errorf("Expected the payload to be reflected, got %q", body)
+	}
+
+	resp, err = http.Get(server.URL + "/no-headers")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Content-Type-Options") != "" {
+		t.Errorf("Expected no security headers")
+	}
+
+	resp, err = http.Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected a 401 with security headers, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestEndpointsAreServed(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	for _, endpoint := range Endpoints() {
+		req, _ := http.NewRequest(endpoint.Method, server.URL+endpoint.Path, nil)
+		req.SetBasicAuth(Username, Password)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", endpoint.Path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			t.Errorf("Expected %s to be served", endpoint.Path)
+		}
+	}
+}
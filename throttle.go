@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetryAfter is the longest pause the scanner will honour before giving up on an endpoint
+	maxRetryAfter      = 60 * time.Second
+	maxThrottleRetries = 3
+	// defaultThrottleDelay is used for 429 responses without a Retry-After header
+	defaultThrottleDelay = time.Second
+)
+
+// ThrottledError is returned when the target keeps rate limiting an endpoint
+type ThrottledError struct{ RetryAfter time.Duration }
+
+func (e ThrottledError) Error() string {
+	return fmt.Sprintf("throttled by target (retry after %s); results are partial", e.RetryAfter)
+}
+
+func (e ThrottledError) Is(target error) bool { return target == ErrRateLimited }
+
+// throttleTransport pauses all requests to an endpoint while the target asks
+// the scanner to back off, retrying requests that were rejected as rate limited
+type throttleTransport struct {
+	base http.RoundTripper
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	events      int
+}
+
+func newThrottleTransport(base http.RoundTripper) *throttleTransport {
+	return &throttleTransport{base: base}
+}
+
+// Events returns the number of times the target throttled the endpoint
+func (t *throttleTransport) Events() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.events
+}
+
+// noRetryKey marks requests whose rate limited responses are returned to the
+// caller instead of retried
+type noRetryKey struct{}
+
+// withoutRetries marks a request that must be sent at most once, such as a
+// login attempt, for which being rate limited is itself the outcome
+func withoutRetries(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), noRetryKey{}, true))
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		t.mu.Lock()
+		wait := time.Until(t.pausedUntil)
+		t.mu.Unlock()
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		delay, retry := throttleDelay(resp, time.Now())
+		// A quota that resets far in the future must not stall the endpoint's
+		// other requests for longer than the scanner would wait for a retry
+		pause := delay
+		if pause > maxRetryAfter {
+			pause = maxRetryAfter
+		}
+		t.mu.Lock()
+		if until := time.Now().Add(pause); until.After(t.pausedUntil) {
+			t.pausedUntil = until
+		}
+		if retry {
+			t.events++
+		}
+		t.mu.Unlock()
+		if !retry || req.Context().Value(noRetryKey{}) != nil {
+			return resp, nil
+		}
+
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		if delay > maxRetryAfter || attempt == maxThrottleRetries {
+			return nil, ThrottledError{RetryAfter: delay}
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, ThrottledError{RetryAfter: delay}
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// throttleDelay inspects a response for rate limiting signals. It returns how
+// long requests to the endpoint should pause and whether the request was
+// rejected and must be retried.
+func throttleDelay(resp *http.Response, now time.Time) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return delay, true
+		}
+		return defaultThrottleDelay, true
+	case http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return delay, true
+		}
+		return 0, false
+	}
+
+	// The request succeeded but the quota is exhausted: pause until it resets
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64)
+		if err != nil || reset <= 0 {
+			return defaultThrottleDelay, false
+		}
+		// Large values are Unix timestamps rather than delta seconds
+		if reset > 1000000000 {
+			return time.Unix(reset, 0).Sub(now), false
+		}
+		return time.Duration(reset) * time.Second, false
+	}
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleTransportRetriesAfterRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, stats := newScanClient(&Config{}, APIEndpoint{}, nil)
+	err := performAuthTest(client, APIEndpoint{URL: server.URL, Method: "POST", Body: "payload"}, Auth{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.throttle.Events() != 1 {
+		t.Errorf("Expected 1 throttle event, got %d", stats.throttle.Events())
+	}
+	if len(bodies) != 2 || bodies[1] != "payload" {
+		t.Errorf("Expected the request body to be replayed, got %q", bodies)
+	}
+}
+
+func TestThrottleTransportGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, _ := newScanClient(&Config{}, APIEndpoint{}, nil)
+	err := performHTTPMethodTest(client, APIEndpoint{URL: server.URL, Method: "GET"})
+	var throttledErr ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.RetryAfter != time.Hour {
+		t.Fatalf("Expected throttled error, got %v", err)
+	}
+	if result := newTestResult("HTTP Method Test", err, 0); !result.Skipped {
+		t.Errorf("Expected throttled test to be skipped, got %+v", result)
+	}
+}
+
+func TestThrottleDelay(t *testing.T) {
+	now := time.Date(2024, 9, 25, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status  int
+		headers map[string]string
+		delay   time.Duration
+		retry   bool
+	}{
+		{http.StatusTooManyRequests, map[string]string{"Retry-After": "5"}, 5 * time.Second, true},
+		{http.StatusTooManyRequests, map[string]string{"Retry-After": "Wed, 25 Sep 2024 00:00:30 GMT"}, 30 * time.Second, true},
+		{http.StatusTooManyRequests, nil, defaultThrottleDelay, true},
+		{http.StatusServiceUnavailable, nil, 0, false},
+		{http.StatusServiceUnavailable, map[string]string{"Retry-After": "2"}, 2 * time.Second, true},
+		{http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "10"}, 10 * time.Second, false},
+		{http.StatusOK, map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "1727222420"}, 20 * time.Second, false},
+		{http.StatusOK, map[string]string{"X-RateLimit-Remaining": "3"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		for name, value := range tt.headers {
+			resp.Header.Set(name, value)
+		}
+		delay, retry := throttleDelay(resp, now)
+		if delay != tt.delay || retry != tt.retry {
+			t.Errorf("Expected (%s, %v) for %d %v, got (%s, %v)", tt.delay, tt.retry, tt.status, tt.headers, delay, retry)
+		}
+	}
+}
+
+func TestThrottleTransportCapsQuotaResetPause(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "86400")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := newThrottleTransport(http.DefaultTransport)
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if pause := time.Until(transport.pausedUntil); pause > maxRetryAfter {
+		t.Errorf("Expected the pause to be capped at %s, got %s", maxRetryAfter, pause)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const ticketLabel = "api-security-scanner"
+
+// ticketResolvedMark marks tickets whose finding was reported as resolved, so
+// that later scans do not comment again
+const ticketResolvedMark = ticketLabel + "-resolved"
+
+// TicketingConfig represents the issue tracker integrations
+type TicketingConfig struct {
+	Jira       *JiraConfig       `yaml:"jira"`
+	ServiceNow *ServiceNowConfig `yaml:"servicenow"`
+}
+
+// JiraConfig represents the Jira project that receives findings
+type JiraConfig struct {
+	URL       string                 `yaml:"url"`
+	Username  string                 `yaml:"username"`
+	APIToken  string                 `yaml:"api_token"`
+	Project   string                 `yaml:"project"`
+	IssueType string                 `yaml:"issue_type"`
+	Fields    map[string]interface{} `yaml:"fields"`
+}
+
+// ServiceNowConfig represents the ServiceNow table that receives findings
+type ServiceNowConfig struct {
+	URL      string                 `yaml:"url"`
+	Username string                 `yaml:"username"`
+	Password string                 `yaml:"password"`
+	Table    string                 `yaml:"table"`
+	Fields   map[string]interface{} `yaml:"fields"`
+}
+
+// ticketTracker is implemented by each issue tracker integration
+type ticketTracker interface {
+	// findOpen returns the open ticket for the fingerprint; its ID is "" if none exists
+	findOpen(fingerprint string) (openTicket, error)
+	create(endpointURL string, testResult TestResult, fingerprint string) error
+	// resolve comments that the finding no longer reproduces and marks the ticket as commented
+	resolve(id, message string) error
+}
+
+// openTicket is an open ticket of a finding
+type openTicket struct {
+	ID string
+	// ResolutionNoted is set once the scanner commented that the finding appears resolved
+	ResolutionNoted bool
+}
+
+// syncTickets opens tickets for new critical and high findings and comments,
+// once, on the open tickets of findings that no longer reproduce. Closing the
+// ticket is left to its owner.
+func syncTickets(config TicketingConfig, results []EndpointResult) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var trackers []ticketTracker
+	if config.Jira != nil {
+		trackers = append(trackers, &jiraTracker{client: client, config: *config.Jira})
+	}
+	if config.ServiceNow != nil {
+		trackers = append(trackers, &serviceNowTracker{client: client, config: *config.ServiceNow})
+	}
+
+	for _, tracker := range trackers {
+		if err := syncTracker(tracker, results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncTracker(tracker ticketTracker, results []EndpointResult) error {
+	for _, result := range results {
+		for _, testResult := range result.Results {
+			if severity := resultSeverity(testResult); severity != "critical" && severity != "high" {
+				continue
+			}
+
+			fingerprint := findingFingerprint(result.key(), testResult.TestName)
+			ticket, err := tracker.findOpen(fingerprint)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case testResult.Failed() && ticket.ID == "":
+				if err := tracker.create(result.URL, testResult, fingerprint); err != nil {
+					return err
+				}
+			case testResult.Passed && ticket.ID != "" && !ticket.ResolutionNoted:
+				message := fmt.Sprintf("%s no longer fails on %s as of %s; this finding appears to be resolved.",
+					testResult.TestName, result.URL, time.Now().Format(time.RFC3339))
+				if err := tracker.resolve(ticket.ID, message); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func ticketSummary(endpointURL string, testResult TestResult) string {
+	return fmt.Sprintf("[API Security] %s failed on %s", testResult.TestName, endpointURL)
+}
+
+// ticketShortFingerprint keeps fingerprints short enough for tracker label fields
+func ticketShortFingerprint(fingerprint string) string {
+	return ticketLabel + "-" + fingerprint[:16]
+}
+
+type jiraTracker struct {
+	client *http.Client
+	config JiraConfig
+}
+
+func (j *jiraTracker) findOpen(fingerprint string) (openTicket, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`,
+		j.config.Project, ticketShortFingerprint(fingerprint))
+
+	var response struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Labels []string `json:"labels"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	endpoint := j.config.URL + "/rest/api/2/search?maxResults=1&fields=key,labels&jql=" + url.QueryEscape(jql)
+	if err := j.do("GET", endpoint, nil, &response); err != nil {
+		return openTicket{}, fmt.Errorf("jira search failed: %v", err)
+	}
+	if len(response.Issues) == 0 {
+		return openTicket{}, nil
+	}
+	ticket := openTicket{ID: response.Issues[0].Key}
+	for _, label := range response.Issues[0].Fields.Labels {
+		if label == ticketResolvedMark {
+			ticket.ResolutionNoted = true
+		}
+	}
+	return ticket, nil
+}
+
+func (j *jiraTracker) create(endpointURL string, testResult TestResult, fingerprint string) error {
+	issueType := j.config.IssueType
+	if issueType == "" {
+		issueType = "Bug"
+	}
+
+	fields := map[string]interface{}{}
+	for key, value := range j.config.Fields {
+		fields[key] = normalizeYAML(value)
+	}
+	fields["project"] = map[string]string{"key": j.config.Project}
+	fields["issuetype"] = map[string]string{"name": issueType}
+	fields["summary"] = ticketSummary(endpointURL, testResult)
+	fields["description"] = fmt.Sprintf("Endpoint: %s\nTest: %s\nSeverity: %s\nDetails: %s",
+		endpointURL, testResult.TestName, resultSeverity(testResult), testResult.Message)
+	fields["labels"] = []string{ticketLabel, ticketShortFingerprint(fingerprint)}
+
+	if err := j.do("POST", j.config.URL+"/rest/api/2/issue", map[string]interface{}{"fields": fields}, nil); err != nil {
+		return fmt.Errorf("jira issue creation failed: %v", err)
+	}
+	return nil
+}
+
+func (j *jiraTracker) resolve(id, message string) error {
+	endpoint := fmt.Sprintf("%s/rest/api/2/issue/%s/comment", j.config.URL, id)
+	if err := j.do("POST", endpoint, map[string]string{"body": message}, nil); err != nil {
+		return fmt.Errorf("jira comment failed: %v", err)
+	}
+	update := map[string]interface{}{"update": map[string]interface{}{
+		"labels": []map[string]string{{"add": ticketResolvedMark}},
+	}}
+	if err := j.do("PUT", fmt.Sprintf("%s/rest/api/2/issue/%s", j.config.URL, id), update, nil); err != nil {
+		return fmt.Errorf("jira label update failed: %v", err)
+	}
+	return nil
+}
+
+func (j *jiraTracker) do(method, endpoint string, body, out interface{}) error {
+	return doTrackerRequest(j.client, method, endpoint, j.config.Username, j.config.APIToken, body, out)
+}
+
+type serviceNowTracker struct {
+	client *http.Client
+	config ServiceNowConfig
+}
+
+func (s *serviceNowTracker) tableURL() string {
+	table := s.config.Table
+	if table == "" {
+		table = "incident"
+	}
+	return s.config.URL + "/api/now/table/" + table
+}
+
+func (s *serviceNowTracker) findOpen(fingerprint string) (openTicket, error) {
+	query := url.Values{}
+	query.Set("sysparm_query", "active=true^correlation_id="+ticketShortFingerprint(fingerprint))
+	query.Set("sysparm_fields", "sys_id,correlation_display")
+	query.Set("sysparm_limit", "1")
+
+	var response struct {
+		Result []struct {
+			SysID              string `json:"sys_id"`
+			CorrelationDisplay string `json:"correlation_display"`
+		} `json:"result"`
+	}
+	if err := s.do("GET", s.tableURL()+"?"+query.Encode(), nil, &response); err != nil {
+		return openTicket{}, fmt.Errorf("servicenow query failed: %v", err)
+	}
+	if len(response.Result) == 0 {
+		return openTicket{}, nil
+	}
+	return openTicket{ID: response.Result[0].SysID, ResolutionNoted: response.Result[0].CorrelationDisplay == ticketResolvedMark}, nil
+}
+
+func (s *serviceNowTracker) create(endpointURL string, testResult TestResult, fingerprint string) error {
+	record := map[string]interface{}{}
+	for key, value := range s.config.Fields {
+		record[key] = normalizeYAML(value)
+	}
+	record["short_description"] = ticketSummary(endpointURL, testResult)
+	record["description"] = fmt.Sprintf("Endpoint: %s\nTest: %s\nSeverity: %s\nDetails: %s",
+		endpointURL, testResult.TestName, resultSeverity(testResult), testResult.Message)
+	record["correlation_id"] = ticketShortFingerprint(fingerprint)
+
+	if err := s.do("POST", s.tableURL(), record, nil); err != nil {
+		return fmt.Errorf("servicenow record creation failed: %v", err)
+	}
+	return nil
+}
+
+// resolve adds a work note and records it in the correlation display field,
+// next to the fingerprint in the correlation ID
+func (s *serviceNowTracker) resolve(id, message string) error {
+	if err := s.do("PATCH", s.tableURL()+"/"+id, map[string]string{"work_notes": message, "correlation_display": ticketResolvedMark}, nil); err != nil {
+		return fmt.Errorf("servicenow update failed: %v", err)
+	}
+	return nil
+}
+
+func (s *serviceNowTracker) do(method, endpoint string, body, out interface{}) error {
+	return doTrackerRequest(s.client, method, endpoint, s.config.Username, s.config.Password, body, out)
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by the
+// YAML decoder into map[string]interface{} so they can be encoded as JSON
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// doTrackerRequest sends a JSON request with basic auth and decodes the JSON response into out
+func doTrackerRequest(client *http.Client, method, endpoint, username, password string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSyncTicketsJira(t *testing.T) {
+	resolvedLabel := ticketShortFingerprint(findingFingerprint("http://example.com/b", "Injection Test"))
+
+	var mu sync.Mutex
+	var created []map[string]interface{}
+	var commented []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/2/search":
+			if strings.Contains(r.URL.Query().Get("jql"), resolvedLabel) {
+				w.Write([]byte(`{"issues": [{"key": "SEC-1"}]}`))
+				return
+			}
+			w.Write([]byte(`{"issues": []}`))
+		case r.URL.Path == "/rest/api/2/issue":
+			var body map[string]map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			created = append(created, body["fields"])
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasSuffix(r.URL.Path, "/comment"):
+			commented = append(commented, r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && r.URL.Path == "/rest/api/2/issue/SEC-1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := TicketingConfig{Jira: &JiraConfig{
+		URL:     server.URL,
+		Project: "SEC",
+		Fields:  map[string]interface{}{"priority": map[interface{}]interface{}{"name": "High"}},
+	}}
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Results: []TestResult{
+			{TestName: "Auth Test", Passed: false, Message: "authentication failed"},
+			{TestName: "HTTP Method Test", Passed: false, Message: "unexpected status code: 405"},
+		}},
+		{URL: "http://example.com/b", Results: []TestResult{
+			{TestName: "Injection Test", Passed: true},
+		}},
+	}
+
+	if err := syncTickets(config, results); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("Expected 1 created issue, got %d", len(created))
+	}
+	if created[0]["summary"] != "[API Security] Auth Test failed on http://example.com/a" {
+		t.Errorf("Unexpected summary: %v", created[0]["summary"])
+	}
+	if priority, ok := created[0]["priority"].(map[string]interface{}); !ok || priority["name"] != "High" {
+		t.Errorf("Expected custom priority field, got %v", created[0]["priority"])
+	}
+	if len(commented) != 1 || commented[0] != "/rest/api/2/issue/SEC-1/comment" {
+		t.Errorf("Expected resolution comment on SEC-1, got %v", commented)
+	}
+}
+
+func TestSyncTicketsServiceNowDeduplicates(t *testing.T) {
+	creates := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"result": [{"sys_id": "abc123"}]}`))
+		case "POST":
+			creates++
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	config := TicketingConfig{ServiceNow: &ServiceNowConfig{URL: server.URL}}
+	results := []EndpointResult{
+		{URL: "http://example.com/a", Results: []TestResult{
+			{TestName: "Injection Test", Passed: false, Message: "potential SQL injection detected"},
+		}},
+	}
+
+	if err := syncTickets(config, results); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if creates != 0 {
+		t.Errorf("Expected existing incident to be reused, got %d creates", creates)
+	}
+}
+
+func TestSyncTrackerCommentsOnResolutionOnce(t *testing.T) {
+	var mu sync.Mutex
+	var labels []string
+	comments := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/2/search":
+			data, _ := json.Marshal(labels)
+			fmt.Fprintf(w, `{"issues": [{"key": "SEC-1", "fields": {"labels": %s}}]}`, data)
+		case r.URL.Path == "/rest/api/2/issue/SEC-1/comment":
+			comments++
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && r.URL.Path == "/rest/api/2/issue/SEC-1":
+			var body struct {
+				Update struct {
+					Labels []map[string]string `json:"labels"`
+				} `json:"update"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, label := range body.Update.Labels {
+				labels = append(labels, label["add"])
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracker := &jiraTracker{client: server.Client(), config: JiraConfig{URL: server.URL, Project: "SEC"}}
+	results := []EndpointResult{{URL: "http://example.com/b", Results: []TestResult{{TestName: "Injection Test", Passed: true}}}}
+	for scan := 0; scan < 2; scan++ {
+		if err := syncTracker(tracker, results); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if comments != 1 {
+		t.Errorf("Expected a single resolution comment over two scans, got %d", comments)
+	}
+	if len(labels) != 1 || labels[0] != ticketResolvedMark {
+		t.Errorf("Expected the ticket to be labelled as commented, got %v", labels)
+	}
+}
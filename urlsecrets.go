@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// urlSecrets describes the apparent secrets in a URL: query parameters named
+// like credentials, a password in the user info and values that are
+// base64-encoded user:password pairs. The secrets themselves are left out.
+func urlSecrets(u *url.URL) []string {
+	var secrets []string
+	if _, ok := u.User.Password(); ok {
+		secrets = append(secrets, "password in the user info")
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case sensitiveParam(name):
+			secrets = append(secrets, name+" parameter")
+		case base64Credentials(query[name]):
+			secrets = append(secrets, fmt.Sprintf("base64-encoded credentials in the %s parameter", name))
+		}
+	}
+	return secrets
+}
+
+// base64Credentials reports whether any of the values decodes to a printable user:password pair
+func base64Credentials(values []string) bool {
+	for _, value := range values {
+		if len(value) < 8 {
+			continue
+		}
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+			decoded, err := encoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			if credentialPairPattern.Match(decoded) {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
+
+// credentialPairPattern matches user:password, but not JSON or other structured values
+var credentialPairPattern = regexp.MustCompile(`^[A-Za-z0-9._@+-]+:[!-~]+$`)
+
+// redirectRecorder records the redirects served to a scan client whose
+// target URL carries secrets, which then end up in logs and browser history
+type redirectRecorder struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	found map[string]bool
+}
+
+func (r *redirectRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return resp, err
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return resp, nil
+	}
+	if secrets := urlSecrets(location); len(secrets) > 0 {
+		r.mu.Lock()
+		if r.found == nil {
+			r.found = map[string]bool{}
+		}
+		r.found[fmt.Sprintf("redirect to %s (%s)", withoutSecrets(location), strings.Join(secrets, ", "))] = true
+		r.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// Redirects returns the recorded redirects, sorted
+func (r *redirectRecorder) Redirects() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortedKeys(r.found)
+}
+
+// withoutSecrets returns the URL without its user info, query and fragment
+func withoutSecrets(u *url.URL) string {
+	stripped := *u
+	stripped.User = nil
+	stripped.RawQuery = ""
+	stripped.Fragment = ""
+	return stripped.String()
+}
+
+// checkURLSecrets fails when the endpoint's URL or a redirect it served
+// carries apparent secrets. It sends no requests of its own.
+func checkURLSecrets(endpoint APIEndpoint, redirects []string) error {
+	var found []string
+	if u, err := url.Parse(endpoint.URL); err == nil {
+		if secrets := urlSecrets(u); len(secrets) > 0 {
+			found = append(found, fmt.Sprintf("target URL %s (%s)", withoutSecrets(u), strings.Join(secrets, ", ")))
+		}
+	}
+	found = append(found, redirects...)
+	if len(found) == 0 {
+		return nil
+	}
+	return URLTokenError{"secrets in URLs: " + strings.Join(found, "; ")}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWAFPacing caps the configured delay so a typo cannot stall a scan indefinitely
+const maxWAFPacing = 10 * time.Second
+
+// WAFConfig represents the WAF handling options
+type WAFConfig struct {
+	Evasion WAFEvasionConfig `yaml:"evasion"`
+}
+
+// WAFEvasionConfig represents the optional, non-destructive evasion modes
+type WAFEvasionConfig struct {
+	RandomizeHeaders bool          `yaml:"randomize_headers"`
+	Pacing           time.Duration `yaml:"pacing"`
+}
+
+// WAFBlockedError is returned when a response was produced by a WAF rather than the API
+type WAFBlockedError struct{ Vendor string }
+
+func (e WAFBlockedError) Error() string {
+	return fmt.Sprintf("blocked by WAF (%s)", e.Vendor)
+}
+
+// wafSignature describes how to recognise responses from a given WAF vendor
+type wafSignature struct {
+	vendor  string
+	headers map[string]string // header name to value substring ("" matches any value)
+	body    []string
+}
+
+var wafSignatures = []wafSignature{
+	{vendor: "Cloudflare", headers: map[string]string{"Cf-Ray": "", "Cf-Mitigated": "", "Server": "cloudflare"}, body: []string{"Attention Required! | Cloudflare", "cf-error-details", "/cdn-cgi/challenge-platform"}},
+	{vendor: "Akamai", headers: map[string]string{"Server": "AkamaiGHost", "Akamai-Grn": ""}, body: []string{"You don't have permission to access", "errors.edgesuite.net"}},
+	{vendor: "AWS WAF", headers: map[string]string{"X-Amzn-Waf-Action": ""}, body: []string{"Request blocked.", "Generated by cloudfront (CloudFront)"}},
+	{vendor: "Imperva", headers: map[string]string{"X-Iinfo": "", "X-Cdn": "Incapsula"}, body: []string{"Incapsula incident ID", "_Incapsula_Resource"}},
+	{vendor: "Sucuri", headers: map[string]string{"X-Sucuri-Id": "", "Server": "Sucuri/Cloudproxy"}, body: []string{"Sucuri WebSite Firewall - Access Denied"}},
+	{vendor: "F5 BIG-IP ASM", body: []string{"The requested URL was rejected. Please consult with your administrator."}},
+	{vendor: "ModSecurity", headers: map[string]string{"Server": "Mod_Security"}, body: []string{"This error was generated by Mod_Security", "Not Acceptable!"}},
+}
+
+// wafBlockStatuses are the status codes WAFs use for blocks and challenges
+var wafBlockStatuses = map[int]bool{
+	http.StatusForbidden:          true,
+	http.StatusNotAcceptable:      true,
+	http.StatusTooManyRequests:    true,
+	http.StatusNotImplemented:     true,
+	http.StatusServiceUnavailable: true,
+}
+
+// detectWAF returns the vendor of the WAF that blocked the response, or "" if
+// the response does not look like a WAF block
+func detectWAF(resp *http.Response, body []byte) string {
+	if !wafBlockStatuses[resp.StatusCode] {
+		return ""
+	}
+	for _, signature := range wafSignatures {
+		for name, value := range signature.headers {
+			if got := resp.Header.Get(name); got != "" && strings.Contains(strings.ToLower(got), strings.ToLower(value)) {
+				return signature.vendor
+			}
+		}
+		for _, marker := range signature.body {
+			if strings.Contains(string(body), marker) {
+				return signature.vendor
+			}
+		}
+	}
+	return ""
+}
+
+// checkWAF reads a bounded prefix of the response body and returns a
+// WAFBlockedError if the response was a WAF block
+func checkWAF(resp *http.Response) error {
+	if !wafBlockStatuses[resp.StatusCode] {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return checkWAFBody(resp, body)
+}
+
+// checkWAFBody is checkWAF for a response whose body was already read
+func checkWAFBody(resp *http.Response, body []byte) error {
+	if !wafBlockStatuses[resp.StatusCode] {
+		return nil
+	}
+	if vendor := detectWAF(resp, body); vendor != "" {
+		return WAFBlockedError{Vendor: vendor}
+	}
+	return nil
+}
+
+var evasionUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+}
+
+var evasionLanguages = []string{"en-US,en;q=0.9", "en-GB,en;q=0.8", "es-ES,es;q=0.9,en;q=0.7", "de-DE,de;q=0.9,en;q=0.6"}
+
+// evasionTransport applies the configured evasion modes to every request
+type evasionTransport struct {
+	base   http.RoundTripper
+	config WAFEvasionConfig
+
+	mu   sync.Mutex
+	rand *rand.Rand
+	last time.Time
+}
+
+func newEvasionTransport(base http.RoundTripper, config WAFEvasionConfig) *evasionTransport {
+	if config.Pacing > maxWAFPacing {
+		config.Pacing = maxWAFPacing
+	}
+	return &evasionTransport{base: base, config: config, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (t *evasionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if wait := t.config.Pacing - time.Since(t.last); t.config.Pacing > 0 && wait > 0 {
+		time.Sleep(wait)
+	}
+	t.last = time.Now()
+	var userAgent, language string
+	if t.config.RandomizeHeaders {
+		userAgent = evasionUserAgents[t.rand.Intn(len(evasionUserAgents))]
+		language = evasionLanguages[t.rand.Intn(len(evasionLanguages))]
+	}
+	t.mu.Unlock()
+
+	if userAgent != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Accept-Language", language)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDetectWAF(t *testing.T) {
+	tests := []struct {
+		status  int
+		headers map[string]string
+		body    string
+		vendor  string
+	}{
+		{http.StatusForbidden, map[string]string{"Server": "cloudflare", "CF-RAY": "123-AMS"}, "Attention Required! | Cloudflare", "Cloudflare"},
+		{http.StatusForbidden, map[string]string{"Server": "AkamaiGHost"}, "Access Denied", "Akamai"},
+		{http.StatusForbidden, nil, "<html>Incapsula incident ID: 123</html>", "Imperva"},
+		{http.StatusOK, map[string]string{"Server": "cloudflare", "CF-RAY": "123-AMS"}, "{}", ""},
+		{http.StatusForbidden, nil, `{"error": "forbidden"}`, ""},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		for name, value := range tt.headers {
+			resp.Header.Set(name, value)
+		}
+		if vendor := detectWAF(resp, []byte(tt.body)); vendor != tt.vendor {
+			t.Errorf("Expected vendor %q for status %d and body %q, got %q", tt.vendor, tt.status, tt.body, vendor)
+		}
+	}
+}
+
+func TestWAFBlockIsSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "cloudflare")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Attention Required! | Cloudflare"))
+	}))
+	defer server.Close()
+
+	err := performAuthTest(server.Client(), APIEndpoint{URL: server.URL, Method: "GET"}, Auth{})
+	var wafErr WAFBlockedError
+	if !errors.As(err, &wafErr) || wafErr.Vendor != "Cloudflare" {
+		t.Fatalf("Expected Cloudflare block, got %v", err)
+	}
+
+	result := newTestResult("Auth Test", err, 0)
+	if !result.Skipped || result.Failed() || result.Message != "blocked by WAF (Cloudflare)" {
+		t.Errorf("Expected skipped result, got %+v", result)
+	}
+}
+
+func TestEvasionTransport(t *testing.T) {
+	var mu sync.Mutex
+	var userAgents []string
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		times = append(times, time.Now())
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newEvasionTransport(http.DefaultTransport, WAFEvasionConfig{RandomizeHeaders: true, Pacing: 50 * time.Millisecond})}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	for _, ua := range userAgents {
+		found := false
+		for _, candidate := range evasionUserAgents {
+			found = found || ua == candidate
+		}
+		if !found {
+			t.Errorf("Expected a browser user agent, got %q", ua)
+		}
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Expected requests to be paced, got gap %s", gap)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const webhookEvent = "scan.completed"
+
+// WebhookConfig represents a URL that receives the full scan results when a scan completes
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// webhookSignature signs the timestamp and body so that receivers can verify
+// the sender and reject replayed deliveries
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhooks posts the results document to every configured webhook and
+// returns the deliveries that failed, without stopping at the first one
+func sendWebhooks(client *http.Client, webhooks []WebhookConfig, results []EndpointResult, metadata map[string]string, now time.Time) []error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(newScanDocument(results, metadata, now))
+	if err != nil {
+		return []error{fmt.Errorf("failed to encode results: %v", err)}
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	var errs []error
+	for _, webhook := range webhooks {
+		if err := sendWebhook(client, webhook, webhookEvent, body, timestamp); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %v", webhook.URL, err))
+		}
+	}
+	return errs
+}
+
+func sendWebhook(client *http.Client, webhook WebhookConfig, event string, body []byte, timestamp string) error {
+	if webhook.URL == "" {
+		return fmt.Errorf("no url configured")
+	}
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Scanner-Event", event)
+	req.Header.Set("X-Scanner-Timestamp", timestamp)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Scanner-Signature", webhookSignature(webhook.Secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendWebhooksSignsResults(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	var received scanDocument
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	results := []EndpointResult{{URL: "http://api.example.com/users", Score: 50, Results: []TestResult{{TestName: "Injection Test", Message: "sql error"}}}}
+	webhooks := []WebhookConfig{{URL: server.URL, Secret: "s3cret", Headers: map[string]string{"Authorization": "Bearer lake"}}}
+	if errs := sendWebhooks(server.Client(), webhooks, results, nil, now); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	if received.SchemaVersion != resultSchemaVersion || len(received.Results) != 1 || received.Results[0].Score != 50 {
+		t.Errorf("Expected the full results document, got %+v", received)
+	}
+	if headers.Get("X-Scanner-Event") != "scan.completed" || headers.Get("X-Scanner-Timestamp") != "1700000000" || headers.Get("Authorization") != "Bearer lake" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+	if expected := webhookSignature("s3cret", "1700000000", body); headers.Get("X-Scanner-Signature") != expected {
+		t.Errorf("Expected signature %s, got %s", expected, headers.Get("X-Scanner-Signature"))
+	}
+}
+
+func TestSendWebhooksReportsEachFailure(t *testing.T) {
+	delivered := 0
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+		if r.Header.Get("X-Scanner-Signature") != "" {
+			t.Errorf("Expected unsigned deliveries without a secret")
+		}
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	webhooks := []WebhookConfig{{URL: failing.URL}, {URL: ok.URL}, {}}
+	errs := sendWebhooks(http.DefaultClient, webhooks, nil, nil, time.Now())
+	if len(errs) != 2 || delivered != 1 {
+		t.Errorf("Expected two failures and one delivery, got %v and %d", errs, delivered)
+	}
+}